@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -40,8 +41,32 @@ func main() {
 	}
 
 	// 4. 初始化服务
-	taskService := services.NewTaskService(db.DB)
-	statsService := services.NewStatsService(db.DB)
+	loc, err := time.LoadLocation(cfg.Server.Timezone)
+	if err != nil {
+		log.Fatalf("❌ 加载时区失败: %v", err)
+	}
+	calendarService := services.NewCalendarService(db.DB)
+	if err := calendarService.Reload(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	groupSettingsService := services.NewGroupSettingsService(db.DB)
+	if err := groupSettingsService.Reload(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+	dtClient := dingtalk.NewClient(
+		cfg.DingTalk.AppKey,
+		cfg.DingTalk.AppSecret,
+		cfg.DingTalk.AgentID,
+		cfg.DingTalk.RobotCode,
+	)
+	groupMemberService := services.NewGroupMemberService(db.DB, dtClient, cfg.DingTalk.ExcludeDeptLeaders)
+	exclusionService := services.NewReminderExclusionService(db.DB, cfg.DingTalk.ExcludeDeptLeaders)
+	taskService := services.NewTaskService(db.DB, loc, calendarService, groupSettingsService, groupMemberService, exclusionService)
+	statsService := services.NewStatsService(db.DB, taskService)
+	cardService := services.NewReminderCardService(db.DB, taskService, dtClient)
+	completionService := services.NewCompletionService(db.DB, taskService, cardService, cfg.CheckIn.OnTimeGrace, cfg.CheckIn.UndoWindow)
+	digestService := services.NewDigestService(db.DB, taskService, statsService, dtClient)
+	exportService := services.NewExportService(db.DB)
 	permService := services.NewPermissionService(db.DB)
 
 	// 5. 初始化超级管理员（从配置文件读取）
@@ -52,36 +77,39 @@ func main() {
 		log.Printf("⚠️  超级管理员初始化失败: %v", err)
 	}
 
-	// 6. 初始化钉钉客户端
-	dtClient := dingtalk.NewClient(
-		cfg.DingTalk.AppKey,
-		cfg.DingTalk.AppSecret,
-		cfg.DingTalk.AgentID,
-		cfg.DingTalk.RobotCode,
-	)
-
-	// 测试连接
+	// 6. 测试钉钉连接
 	if _, err := dtClient.GetAccessToken(); err != nil {
 		log.Fatalf("❌ 钉钉连接失败: %v", err)
 	}
 	log.Println("✓ 钉钉连接成功")
 
 	// 7. 初始化 Dify 处理器（基于会话的权限检查）
-	difyHandler := handlers.NewDifyHandler(permService, taskService, statsService, dtClient)
+	difyHandler := handlers.NewDifyHandler(permService, taskService, statsService, completionService, dtClient)
 
 	// 8. 初始化消息处理器
-	messageHandler := handlers.NewMessageHandler(cfg, taskService, statsService, permService, dtClient, difyHandler)
+	messageHandler := handlers.NewMessageHandler(cfg, taskService, statsService, permService, cardService, completionService, digestService, exportService, dtClient, difyHandler)
 
 	// 9. 启动调度器
-	sched, err := scheduler.NewScheduler(taskService, dtClient, cfg.Server.Timezone)
+	sched, err := scheduler.NewScheduler(taskService, cardService, digestService, dtClient, cfg.Server.Timezone, cfg.Scheduler.CatchUpGrace)
 	if err != nil {
 		log.Fatalf("❌ 创建调度器失败: %v", err)
 	}
 
-	if err := sched.Start(ctx); err != nil {
-		log.Fatalf("❌ 启动调度器失败: %v", err)
-	}
-	defer sched.Stop()
+	// 多副本部署时通过 Postgres advisory lock 选主，只有领导者运行调度器
+	elector := scheduler.NewLeaderElector(db.DB, cfg.Scheduler.LeaderLockID, cfg.Scheduler.ElectionInterval)
+	electorDone := make(chan struct{})
+	go func() {
+		defer close(electorDone)
+		elector.Run(ctx, func(leaderCtx context.Context) error {
+			// 领导者定期从钉钉同步群成员（提醒、未完成名单和完成率按群成员计算）
+			go groupMemberService.Run(leaderCtx, cfg.DingTalk.MemberSyncInterval)
+			return sched.Start(leaderCtx)
+		}, sched.Stop)
+	}()
+	defer func() {
+		cancel()
+		<-electorDone
+	}()
 
 	// 9.1. 设置任务创建回调：创建任务后自动注册提醒并检查是否需要立即发送
 	taskService.SetOnTaskCreatedCallback(func(task models.Task) {
@@ -92,10 +120,18 @@ func main() {
 			log.Printf("注册新任务到调度器失败: %v", err)
 		}
 
-		// 如果当前时间超过10点，立即发送10点提醒
+		// 如果今天已经错过了截止前的提醒，立即补发
 		sched.SendImmediateReminderIfNeeded(task)
 	})
 
+	// 9.2. 监听任务变更：任务在任意副本上被修改、暂停、恢复或删除后，领导者立即重新注册
+	listener := scheduler.NewTaskChangeListener(cfg.GetDSN(), services.TaskChangedChannel)
+	go listener.Run(ctx, sched.ReloadTask, sched.ReloadAll)
+
+	// 9.3. 监听定时汇总变更：新增或删除后领导者立即重新注册
+	digestListener := scheduler.NewTaskChangeListener(cfg.GetDSN(), services.DigestChangedChannel)
+	go digestListener.Run(ctx, func(int) { sched.ReloadDigests() }, sched.ReloadDigests)
+
 	// 10. 启动钉钉 Stream 客户端
 	streamClient := dingtalk.NewStreamClient(cfg.DingTalk.AppKey, cfg.DingTalk.AppSecret, messageHandler)
 	go func() {
@@ -106,7 +142,7 @@ func main() {
 	defer streamClient.Stop()
 
 	// 11. 启动 HTTP 服务器（健康检查 + API）
-	router := setupRouter(permService, taskService, statsService, difyHandler)
+	router := setupRouter(permService, taskService, statsService, calendarService, completionService, digestService, exportService, dtClient, difyHandler)
 	go func() {
 		addr := ":" + cfg.Server.Port
 		log.Printf("✓ HTTP 服务器启动在 %s", addr)
@@ -128,7 +164,7 @@ func main() {
 	log.Println("✅ 服务已停止")
 }
 
-func setupRouter(permService *services.PermissionService, taskService *services.TaskService, statsService *services.StatsService, difyHandler *handlers.DifyHandler) *gin.Engine {
+func setupRouter(permService *services.PermissionService, taskService *services.TaskService, statsService *services.StatsService, calendarService *services.CalendarService, completionService *services.CompletionService, digestService *services.DigestService, exportService *services.ExportService, dtClient *dingtalk.Client, difyHandler *handlers.DifyHandler) *gin.Engine {
 	gin.SetMode(gin.ReleaseMode)
 	router := gin.New()
 	router.Use(gin.Recovery())
@@ -149,14 +185,14 @@ func setupRouter(permService *services.PermissionService, taskService *services.
 	})
 
 	// API 路由
-	apiHandler := handlers.NewAPIHandler(permService, taskService, statsService)
+	apiHandler := handlers.NewAPIHandler(permService, taskService, statsService, calendarService, completionService, digestService, exportService, dtClient)
 
 	api := router.Group("/api/v1")
 	{
 		// Dify 集成 API（推荐使用）
 		dify := api.Group("/dify")
 		{
-			dify.POST("/execute", difyHandler.Execute)       // 统一执行端点（基于会话的权限检查）
+			dify.POST("/execute", difyHandler.Execute)          // 统一执行端点（基于会话的权限检查）
 			dify.POST("/send_message", difyHandler.SendMessage) // 发送消息端点（供 Dify 调用）
 		}
 
@@ -183,11 +219,83 @@ func setupRouter(permService *services.PermissionService, taskService *services.
 		// 任务相关 API（需要权限验证）
 		tasks := api.Group("/tasks")
 		{
-			tasks.POST("", apiHandler.CreateTaskAPI)                    // 创建任务
-			tasks.GET("", apiHandler.GetTasksAPI)                       // 获取任务列表
-			tasks.DELETE("/:taskID", apiHandler.DeleteTaskAPI)          // 删除任务
-			tasks.POST("/:taskID/complete", apiHandler.CompleteTaskAPI) // 打卡完成任务
-			tasks.GET("/:taskID/stats", apiHandler.GetStatsAPI)         // 获取统计数据
+			tasks.POST("", apiHandler.CreateTaskAPI)                                            // 创建任务
+			tasks.GET("", apiHandler.GetTasksAPI)                                               // 获取任务列表
+			tasks.DELETE("/:taskID", apiHandler.DeleteTaskAPI)                                  // 删除任务
+			tasks.PATCH("/:taskID", apiHandler.UpdateTaskAPI)                                   // 修改任务
+			tasks.POST("/:taskID/pause", apiHandler.PauseTaskAPI)                               // 暂停任务
+			tasks.POST("/:taskID/resume", apiHandler.ResumeTaskAPI)                             // 恢复任务
+			tasks.POST("/:taskID/complete", apiHandler.CompleteTaskAPI)                         // 打卡完成任务
+			tasks.POST("/:taskID/complete/undo", apiHandler.UndoCompletionAPI)                  // 撤销自己的打卡
+			tasks.PUT("/:taskID/completions/:userID", apiHandler.CorrectCompletionAPI)          // 更正成员的打卡
+			tasks.GET("/:taskID/completion-audit", apiHandler.GetCompletionAuditAPI)            // 查看打卡变更记录
+			tasks.GET("/:taskID/submissions", apiHandler.GetSubmissionsAPI)                     // 查看打卡提交内容
+			tasks.GET("/:taskID/stats", apiHandler.GetStatsAPI)                                 // 获取统计数据
+			tasks.GET("/:taskID/reminder-plan", apiHandler.GetReminderPlanAPI)                  // 获取提醒计划
+			tasks.PUT("/:taskID/reminder-plan", apiHandler.SetReminderPlanAPI)                  // 设置提醒计划
+			tasks.PUT("/:taskID/calendar-policy", apiHandler.SetCalendarPolicyAPI)              // 设置节假日策略
+			tasks.PUT("/:taskID/timezone", apiHandler.SetTaskTimezoneAPI)                       // 设置任务时区
+			tasks.POST("/:taskID/skip", apiHandler.SkipOccurrenceAPI)                           // 跳过某一期
+			tasks.DELETE("/:taskID/skip", apiHandler.CancelSkipAPI)                             // 取消跳过
+			tasks.POST("/:taskID/snooze", apiHandler.SnoozeReminderAPI)                         // 延后下一次提醒
+			tasks.GET("/:taskID/overrides", apiHandler.GetOccurrenceOverridesAPI)               // 查看跳过/延后记录
+			tasks.GET("/:taskID/assignees", apiHandler.GetTaskAssigneesAPI)                     // 查看负责人和豁免
+			tasks.PUT("/:taskID/assignees", apiHandler.SetTaskAssigneesAPI)                     // 设置负责人（用户/部门）
+			tasks.POST("/:taskID/exemptions", apiHandler.AddTaskExemptionAPI)                   // 登记豁免
+			tasks.DELETE("/:taskID/exemptions/:exemptionID", apiHandler.DeleteTaskExemptionAPI) // 删除豁免
+		}
+
+		// 群设置 API
+		groups := api.Group("/groups")
+		{
+			groups.GET("/:groupChatID/settings", apiHandler.GetGroupSettingsAPI)      // 获取群设置
+			groups.PUT("/:groupChatID/settings", apiHandler.SetGroupSettingsAPI)      // 更新群设置（默认时区）
+			groups.GET("/:groupChatID/members", apiHandler.GetGroupMembersAPI)        // 获取群成员（不含免提醒的成员）
+			groups.POST("/:groupChatID/members/sync", apiHandler.SyncGroupMembersAPI) // 立即从钉钉同步群成员
+		}
+
+		// 免提醒设置 API
+		exclusions := api.Group("/reminder-exclusions")
+		{
+			exclusions.GET("", apiHandler.ListReminderExclusionsAPI)             // 查询免提醒设置
+			exclusions.PUT("/:userID", apiHandler.SetReminderExclusionAPI)       // 设置免提醒 / 强制提醒
+			exclusions.DELETE("/:userID", apiHandler.DeleteReminderExclusionAPI) // 删除设置，恢复默认规则
+		}
+
+		// 历史统计 API
+		api.GET("/stats", apiHandler.GetHistoryStatsAPI)      // 按任务、群、成员和日期范围统计
+		api.GET("/leaderboard", apiHandler.GetLeaderboardAPI) // 月度排行榜（连续按时、首位提交徽章）
+
+		// 定时汇总 API
+		digests := api.Group("/digests")
+		{
+			digests.GET("", apiHandler.ListDigestsAPI)                // 查询定时汇总
+			digests.POST("", apiHandler.CreateDigestAPI)              // 新增定时汇总（日报/周报）
+			digests.DELETE("/:digestID", apiHandler.DeleteDigestAPI)  // 删除定时汇总
+			digests.POST("/:digestID/send", apiHandler.SendDigestAPI) // 立即发送一次
+		}
+
+		// 导出 API（format=csv|xlsx）
+		exports := api.Group("/exports")
+		{
+			exports.GET("/completions", apiHandler.ExportCompletionsAPI)          // 导出打卡记录
+			exports.GET("/reminder-logs", apiHandler.ExportReminderLogsAPI)       // 导出提醒发送记录
+			exports.GET("/permission-audit", apiHandler.ExportPermissionAuditAPI) // 导出权限审计日志
+		}
+
+		// 请假 API
+		leaves := api.Group("/leaves")
+		{
+			leaves.GET("", apiHandler.ListLeavesAPI)              // 查询请假
+			leaves.POST("", apiHandler.AddLeaveAPI)               // 为成员登记请假
+			leaves.DELETE("/:leaveID", apiHandler.DeleteLeaveAPI) // 删除请假
+		}
+
+		// 节假日日历 API
+		calendar := api.Group("/calendar")
+		{
+			calendar.GET("", apiHandler.ListCalendarAPI)           // 查询节假日日历
+			calendar.POST("/import", apiHandler.ImportCalendarAPI) // 导入节假日日历（JSON/ICS）
 		}
 	}
 
@@ -3,7 +3,9 @@ package config
 import (
 	"fmt"
 	"os"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
@@ -21,6 +23,12 @@ type Config struct {
 	// Dify 配置
 	Dify DifyConfig
 
+	// 调度器配置
+	Scheduler SchedulerConfig
+
+	// 打卡配置
+	CheckIn CheckInConfig
+
 	// 管理员配置
 	AdminUsers []string
 }
@@ -30,6 +38,9 @@ type DingTalkConfig struct {
 	AppSecret  string
 	AgentID    string
 	RobotCode  string
+
+	MemberSyncInterval time.Duration // 从钉钉同步群成员的间隔
+	ExcludeDeptLeaders bool          // 钉钉部门主管默认免提醒（可按人单独恢复）
 }
 
 type DatabaseConfig struct {
@@ -46,6 +57,17 @@ type ServerConfig struct {
 	Timezone string
 }
 
+type SchedulerConfig struct {
+	LeaderLockID     int64         // 选主使用的 Postgres advisory lock ID，同一数据库的所有副本需一致
+	ElectionInterval time.Duration // 竞选重试和领导权检查的间隔
+	CatchUpGrace     time.Duration // 启动时补发错过提醒的窗口
+}
+
+type CheckInConfig struct {
+	OnTimeGrace time.Duration // 截止后多长时间内打卡仍算按时
+	UndoWindow  time.Duration // 打卡后成员可以自己撤销的时长
+}
+
 type DifyConfig struct {
 	APIKey      string
 	WebhookURL  string
@@ -62,6 +84,9 @@ func Load() (*Config, error) {
 			AppSecret: getEnv("DINGTALK_APP_SECRET", ""),
 			AgentID:   getEnv("DINGTALK_AGENT_ID", ""),
 			RobotCode: getEnv("DINGTALK_ROBOT_CODE", ""),
+
+			MemberSyncInterval: getEnvDuration("DINGTALK_MEMBER_SYNC_INTERVAL", time.Hour),
+			ExcludeDeptLeaders: getEnv("DINGTALK_EXCLUDE_DEPT_LEADERS", "false") == "true",
 		},
 		Database: DatabaseConfig{
 			Host:     getEnv("DB_HOST", "localhost"),
@@ -80,6 +105,15 @@ func Load() (*Config, error) {
 			WebhookURL: getEnv("DIFY_WEBHOOK_URL", ""),
 			Enabled:    getEnv("DIFY_ENABLED", "false") == "true",
 		},
+		Scheduler: SchedulerConfig{
+			LeaderLockID:     getEnvInt64("SCHEDULER_LOCK_ID", 72620001),
+			ElectionInterval: getEnvDuration("SCHEDULER_ELECTION_INTERVAL", 5*time.Second),
+			CatchUpGrace:     getEnvDuration("SCHEDULER_CATCHUP_GRACE", 30*time.Minute),
+		},
+		CheckIn: CheckInConfig{
+			OnTimeGrace: getEnvDuration("CHECKIN_ON_TIME_GRACE", 0),
+			UndoWindow:  getEnvDuration("CHECKIN_UNDO_WINDOW", 10*time.Minute),
+		},
 		AdminUsers: parseAdminUsers(getEnv("ADMIN_USERS", "")),
 	}
 	
@@ -119,6 +153,20 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt64(key string, defaultValue int64) int64 {
+	if value, err := strconv.ParseInt(os.Getenv(key), 10, 64); err == nil {
+		return value
+	}
+	return defaultValue
+}
+
+func getEnvDuration(key string, defaultValue time.Duration) time.Duration {
+	if value, err := time.ParseDuration(os.Getenv(key)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
 func parseAdminUsers(adminStr string) []string {
 	if adminStr == "" {
 		return []string{}
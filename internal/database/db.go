@@ -88,6 +88,186 @@ func (db *DB) RunMigrations() error {
 			member_count INT DEFAULT 0,
 			completed_count INT DEFAULT 0
 		)`,
+		`CREATE TABLE IF NOT EXISTS task_reminder_plans (
+			id SERIAL PRIMARY KEY,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			position INT NOT NULL,
+			offset_spec VARCHAR(50) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE(task_id, position)
+		)`,
+		`CREATE TABLE IF NOT EXISTS holidays (
+			id SERIAL PRIMARY KEY,
+			date DATE UNIQUE NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			name VARCHAR(100),
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT check_holiday_kind CHECK (kind IN ('HOLIDAY', 'WORKDAY'))
+		)`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS calendar_policy VARCHAR(20) NOT NULL DEFAULT 'NONE'`,
+		`ALTER TYPE task_status ADD VALUE IF NOT EXISTS 'FINISHED'`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS schedule_kind VARCHAR(20) NOT NULL DEFAULT 'CRON'`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS start_date DATE`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS end_date DATE`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS max_occurrences INT NOT NULL DEFAULT 0`,
+		`ALTER TABLE tasks ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS group_settings (
+			group_chat_id VARCHAR(100) PRIMARY KEY,
+			timezone VARCHAR(64) NOT NULL DEFAULT '',
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS task_occurrence_overrides (
+			id SERIAL PRIMARY KEY,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			kind VARCHAR(20) NOT NULL,
+			task_date DATE,
+			snooze_minutes INT NOT NULL DEFAULT 0,
+			reminder_at TIMESTAMPTZ,
+			reason TEXT,
+			created_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT check_override_kind CHECK (kind IN ('SKIP', 'SNOOZE'))
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_overrides_skip_date
+			ON task_occurrence_overrides(task_id, task_date) WHERE kind = 'SKIP'`,
+		`CREATE INDEX IF NOT EXISTS idx_overrides_task ON task_occurrence_overrides(task_id, kind)`,
+		`CREATE TABLE IF NOT EXISTS group_members (
+			group_chat_id VARCHAR(100) NOT NULL,
+			user_id VARCHAR(100) NOT NULL,
+			user_name VARCHAR(100),
+			synced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (group_chat_id, user_id)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_group_members_user ON group_members(user_id)`,
+		`ALTER TABLE group_members ADD COLUMN IF NOT EXISTS dept_leader BOOLEAN NOT NULL DEFAULT FALSE`,
+		`ALTER TABLE group_members ADD COLUMN IF NOT EXISTS profile_synced_at TIMESTAMP`,
+		`CREATE TABLE IF NOT EXISTS task_assignees (
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			assignee_type VARCHAR(20) NOT NULL,
+			assignee_id VARCHAR(100) NOT NULL,
+			created_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (task_id, assignee_type, assignee_id),
+			CONSTRAINT check_assignee_type CHECK (assignee_type IN ('USER', 'DEPARTMENT'))
+		)`,
+		`CREATE TABLE IF NOT EXISTS task_exemptions (
+			id SERIAL PRIMARY KEY,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id VARCHAR(100) NOT NULL,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL,
+			reason TEXT,
+			created_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT check_exemption_range CHECK (end_date >= start_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_task_exemptions_task ON task_exemptions(task_id, end_date)`,
+		`CREATE TABLE IF NOT EXISTS reminder_cards (
+			out_track_id VARCHAR(100) PRIMARY KEY,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			group_chat_id VARCHAR(100) NOT NULL,
+			task_date DATE NOT NULL,
+			title VARCHAR(255) NOT NULL,
+			body TEXT NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_reminder_cards_task ON reminder_cards(task_id, task_date)`,
+		`CREATE TABLE IF NOT EXISTS member_snoozes (
+			id SERIAL PRIMARY KEY,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id VARCHAR(100) NOT NULL,
+			task_date DATE NOT NULL,
+			remind_at TIMESTAMPTZ NOT NULL,
+			sent_at TIMESTAMPTZ,
+			created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (task_id, user_id, task_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_member_snoozes_due ON member_snoozes(remind_at) WHERE sent_at IS NULL`,
+		`CREATE TABLE IF NOT EXISTS completion_submissions (
+			id SERIAL PRIMARY KEY,
+			completion_id INT NOT NULL REFERENCES completion_records(id) ON DELETE CASCADE,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id VARCHAR(100) NOT NULL,
+			task_date DATE NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			content TEXT NOT NULL DEFAULT '',
+			file_name VARCHAR(255),
+			download_code TEXT,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT check_submission_kind CHECK (kind IN ('TEXT', 'LINK', 'PICTURE', 'FILE', 'VIDEO', 'AUDIO'))
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_submissions_task_date ON completion_submissions(task_id, task_date)`,
+		`CREATE TABLE IF NOT EXISTS completion_audit_logs (
+			id SERIAL PRIMARY KEY,
+			task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+			user_id VARCHAR(100) NOT NULL,
+			task_date DATE NOT NULL,
+			action VARCHAR(20) NOT NULL,
+			operator_id VARCHAR(100) NOT NULL,
+			reason TEXT,
+			previous_completed_at TIMESTAMP,
+			previous_on_time BOOLEAN,
+			is_on_time BOOLEAN,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT check_completion_audit_action CHECK (action IN ('UNDO', 'MARK_DONE', 'MARK_UNDONE'))
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_completion_audit_task ON completion_audit_logs(task_id, created_at)`,
+		`CREATE TABLE IF NOT EXISTS member_leaves (
+			id SERIAL PRIMARY KEY,
+			user_id VARCHAR(100) NOT NULL,
+			task_id INT REFERENCES tasks(id) ON DELETE CASCADE,
+			start_date DATE NOT NULL,
+			end_date DATE NOT NULL,
+			reason TEXT,
+			created_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT check_leave_range CHECK (end_date >= start_date)
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_member_leaves_user ON member_leaves(user_id, end_date)`,
+		`CREATE INDEX IF NOT EXISTS idx_member_leaves_dates ON member_leaves(start_date, end_date)`,
+		`CREATE TABLE IF NOT EXISTS digests (
+			id SERIAL PRIMARY KEY,
+			group_chat_id VARCHAR(100) NOT NULL,
+			kind VARCHAR(20) NOT NULL,
+			cron_expr VARCHAR(100) NOT NULL,
+			calendar_policy VARCHAR(20) NOT NULL DEFAULT 'NONE',
+			target VARCHAR(20) NOT NULL DEFAULT 'GROUP',
+			recipients TEXT[] NOT NULL DEFAULT '{}',
+			created_by VARCHAR(100) NOT NULL,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			last_sent_at TIMESTAMPTZ,
+			CONSTRAINT check_digest_kind CHECK (kind IN ('DAILY', 'WEEKLY')),
+			CONSTRAINT check_digest_target CHECK (target IN ('GROUP', 'PRIVATE'))
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_digests_group ON digests(group_chat_id)`,
+		`ALTER TABLE digests ADD COLUMN IF NOT EXISTS include_leaderboard BOOLEAN NOT NULL DEFAULT FALSE`,
+		`CREATE TABLE IF NOT EXISTS group_member_periods (
+			id SERIAL PRIMARY KEY,
+			group_chat_id VARCHAR(100) NOT NULL,
+			user_id VARCHAR(100) NOT NULL,
+			user_name VARCHAR(100),
+			joined_at TIMESTAMP,
+			left_at TIMESTAMP
+		)`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_group_member_periods_open
+			ON group_member_periods(group_chat_id, user_id) WHERE left_at IS NULL`,
+		// 开始记录前已同步的成员视为一直在群内
+		`INSERT INTO group_member_periods (group_chat_id, user_id, user_name)
+			SELECT m.group_chat_id, m.user_id, m.user_name
+			FROM group_members m
+			WHERE NOT EXISTS (
+				SELECT 1 FROM group_member_periods p
+				WHERE p.group_chat_id = m.group_chat_id AND p.user_id = m.user_id
+			)`,
+		`ALTER TABLE reminder_logs ADD COLUMN IF NOT EXISTS reminder_offset VARCHAR(50)`,
+		`ALTER TABLE reminder_logs ADD COLUMN IF NOT EXISTS occurrence_at TIMESTAMPTZ`,
+		`ALTER TABLE reminder_logs ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'SENT'`,
+		`CREATE UNIQUE INDEX IF NOT EXISTS idx_reminder_logs_occurrence
+			ON reminder_logs(task_id, reminder_type, reminder_offset, occurrence_at)`,
+		// 升级步骤写法较长（如 "+2h summary to=cidXXX"），重复催办的幂等键带 "#n" 后缀
+		`ALTER TABLE task_reminder_plans ALTER COLUMN offset_spec TYPE VARCHAR(200)`,
+		`ALTER TABLE reminder_logs ALTER COLUMN reminder_offset TYPE VARCHAR(210)`,
 		`CREATE INDEX IF NOT EXISTS idx_tasks_status ON tasks(status)`,
 		`CREATE INDEX IF NOT EXISTS idx_tasks_group_chat ON tasks(group_chat_id, status)`,
 		`CREATE INDEX IF NOT EXISTS idx_completion_task_date ON completion_records(task_id, task_date)`,
@@ -104,6 +284,24 @@ func (db *DB) RunMigrations() error {
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_dingtalk_id ON users(dingtalk_user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_users_role ON users(role)`,
+		// 免提醒设置：首次建表时保留升级前"超级管理员不被提醒"的行为，之后可按人调整
+		`DO $$
+	BEGIN
+		IF to_regclass('reminder_exclusions') IS NULL THEN
+			CREATE TABLE reminder_exclusions (
+				user_id VARCHAR(100) NOT NULL,
+				group_chat_id VARCHAR(100) NOT NULL DEFAULT '',
+				excluded BOOLEAN NOT NULL DEFAULT TRUE,
+				reason TEXT,
+				created_by VARCHAR(100) NOT NULL,
+				created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+				PRIMARY KEY (user_id, group_chat_id)
+			);
+			INSERT INTO reminder_exclusions (user_id, excluded, reason, created_by)
+			SELECT dingtalk_user_id, TRUE, '升级前的超级管理员', 'system' FROM users WHERE role = 'super_admin';
+		END IF;
+	END $$`,
 		`CREATE TABLE IF NOT EXISTS permissions (
 			id SERIAL PRIMARY KEY,
 			name VARCHAR(100) UNIQUE NOT NULL,
@@ -145,7 +343,8 @@ func (db *DB) RunMigrations() error {
 			('delete_task', '删除任务', '删除任务'),
 			('list_tasks', '查看任务列表', '任务列表'),
 			('complete_task', '打卡完成任务', '已完成'),
-			('view_stats', '查看统计', '统计')
+			('view_stats', '查看统计', '统计'),
+			('manage_calendar', '管理节假日日历', '导入日历')
 		ON CONFLICT (name) DO NOTHING`,
 
 		// 初始化角色权限映射
@@ -158,12 +357,14 @@ func (db *DB) RunMigrations() error {
 			('super_admin', 'list_tasks'),
 			('super_admin', 'complete_task'),
 			('super_admin', 'view_stats'),
+			('super_admin', 'manage_calendar'),
 			('admin', 'create_task'),
 			('admin', 'update_task'),
 			('admin', 'delete_task'),
 			('admin', 'list_tasks'),
 			('admin', 'complete_task'),
 			('admin', 'view_stats'),
+			('admin', 'manage_calendar'),
 			('member', 'complete_task'),
 			('member', 'view_stats'),
 			('member', 'list_tasks')
@@ -0,0 +1,110 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Attachment 消息中的图片、文件、视频或语音（通过下载码换取下载链接）
+type Attachment struct {
+	Type         string `json:"type"` // picture / file / video / audio
+	DownloadCode string `json:"downloadCode"`
+	FileName     string `json:"fileName,omitempty"`
+}
+
+// messageContent 非文本消息的 content 字段
+type messageContent struct {
+	DownloadCode string `json:"downloadCode"`
+	FileName     string `json:"fileName"`
+	RichText     []struct {
+		Text         string `json:"text"`
+		Type         string `json:"type"`
+		DownloadCode string `json:"downloadCode"`
+	} `json:"richText"`
+}
+
+// parseMessageContent 解析非文本消息：返回其中的附件，以及图文混排消息中的文字
+func parseMessageContent(msgType string, content interface{}) ([]Attachment, string) {
+	if content == nil {
+		return nil, ""
+	}
+	data, err := json.Marshal(content)
+	if err != nil {
+		return nil, ""
+	}
+	var c messageContent
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, ""
+	}
+
+	switch msgType {
+	case "picture", "file", "video", "audio":
+		if c.DownloadCode == "" {
+			return nil, ""
+		}
+		return []Attachment{{Type: msgType, DownloadCode: c.DownloadCode, FileName: c.FileName}}, ""
+
+	case "richText":
+		var attachments []Attachment
+		var text strings.Builder
+		for _, item := range c.RichText {
+			if item.DownloadCode != "" {
+				kind := item.Type
+				if kind == "" {
+					kind = "picture"
+				}
+				attachments = append(attachments, Attachment{Type: kind, DownloadCode: item.DownloadCode})
+				continue
+			}
+			text.WriteString(item.Text)
+		}
+		return attachments, text.String()
+	}
+
+	return nil, ""
+}
+
+// GetMessageFileDownloadURL 用机器人收到的文件/图片下载码换取临时下载链接
+func (c *Client) GetMessageFileDownloadURL(downloadCode string) (string, error) {
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	payload := map[string]string{
+		"downloadCode": downloadCode,
+		"robotCode":    c.RobotCode,
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, "https://api.dingtalk.com/v1.0/robot/messageFiles/download", strings.NewReader(string(data)))
+	if err != nil {
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("x-acs-dingtalk-access-token", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		DownloadURL string `json:"downloadUrl"`
+		Code        string `json:"code"`
+		Message     string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK || result.DownloadURL == "" {
+		return "", fmt.Errorf("获取文件下载链接失败 (%d): %s %s", resp.StatusCode, result.Code, result.Message)
+	}
+	return result.DownloadURL, nil
+}
@@ -0,0 +1,108 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// 机器人互动卡片使用的钉钉内置模板（无需在卡片平台创建）
+const standardCardTemplate = "StandardCard"
+
+// CardAction 互动卡片上的按钮，点击后通过 Stream 回调，CardCallback.Value 为按钮 ID
+type CardAction struct {
+	ID      string
+	Text    string
+	Primary bool // 主按钮（高亮显示）
+}
+
+// NewOutTrackID 生成互动卡片的唯一标识，用于回调和更新卡片
+func NewOutTrackID(prefix string) string {
+	return fmt.Sprintf("%s-%d", prefix, time.Now().UnixNano())
+}
+
+// SendInteractiveCard 在群内发送带按钮的互动卡片
+func (c *Client) SendInteractiveCard(chatID, outTrackID, title, markdown string, actions []CardAction) error {
+	return c.SendInteractiveCardWithMentions(chatID, outTrackID, title, markdown, actions, nil)
+}
+
+// SendInteractiveCardWithMentions 在群内发送互动卡片并@指定用户（atUsers 为用户ID → 显示名称）
+func (c *Client) SendInteractiveCardWithMentions(chatID, outTrackID, title, markdown string, actions []CardAction, atUsers map[string]string) error {
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return err
+	}
+
+	cardData, err := json.Marshal(standardCardData(title, markdown, actions))
+	if err != nil {
+		return fmt.Errorf("序列化卡片失败: %w", err)
+	}
+
+	url := "https://api.dingtalk.com/v1.0/im/v1.0/robot/interactiveCards/send"
+	payload := map[string]interface{}{
+		"cardTemplateId":     standardCardTemplate,
+		"openConversationId": chatID,
+		"cardBizId":          outTrackID,
+		"robotCode":          c.RobotCode,
+		"cardData":           string(cardData),
+	}
+	if len(atUsers) > 0 {
+		payload["userIdType"] = 1 // atOpenIds 的 key 为 userid
+		payload["atOpenIds"] = atUsers
+	}
+
+	return c.sendRequestWithHeader(url, payload, token)
+}
+
+// UpdateInteractiveCard 按 outTrackID 更新已发送的互动卡片（整体替换卡片内容）
+func (c *Client) UpdateInteractiveCard(outTrackID, title, markdown string, actions []CardAction) error {
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return err
+	}
+
+	cardData, err := json.Marshal(standardCardData(title, markdown, actions))
+	if err != nil {
+		return fmt.Errorf("序列化卡片失败: %w", err)
+	}
+
+	url := "https://api.dingtalk.com/v1.0/im/robots/interactiveCards"
+	payload := map[string]interface{}{
+		"cardBizId": outTrackID,
+		"cardData":  string(cardData),
+	}
+
+	return c.sendRequestWithMethod(http.MethodPut, url, payload, token)
+}
+
+// standardCardData 构造 StandardCard 的卡片内容：标题、Markdown 正文和一行按钮
+func standardCardData(title, markdown string, actions []CardAction) map[string]interface{} {
+	buttons := make([]map[string]interface{}, len(actions))
+	for i, action := range actions {
+		status := "normal"
+		if action.Primary {
+			status = "primary"
+		}
+		buttons[i] = map[string]interface{}{
+			"type":       "button",
+			"id":         action.ID,
+			"label":      map[string]string{"type": "text", "text": action.Text},
+			"actionType": "request",
+			"status":     status,
+		}
+	}
+
+	contents := []map[string]interface{}{
+		{"type": "markdown", "id": "content", "text": markdown},
+	}
+	if len(buttons) > 0 {
+		contents = append(contents, map[string]interface{}{"type": "action", "id": "actions", "actions": buttons})
+	}
+
+	return map[string]interface{}{
+		"config":   map[string]bool{"autoLayout": true, "enableForward": true},
+		"header":   map[string]interface{}{"title": map[string]string{"type": "text", "text": title}},
+		"contents": contents,
+	}
+}
@@ -160,6 +160,46 @@ func (c *Client) SendMarkdownWithMentions(chatID, title, text string, atUserIDs
 	return c.sendRequest(url, payload)
 }
 
+// 单次批量私聊最多的用户数
+const maxBatchUsers = 20
+
+// 发送私聊 Markdown 消息（机器人单聊，新版 API），用户较多时分批发送
+func (c *Client) SendPrivateMarkdown(userIDs []string, title, text string) error {
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return err
+	}
+
+	url := "https://api.dingtalk.com/v1.0/robot/oToMessages/batchSend"
+
+	msgParamJSON, err := json.Marshal(map[string]string{
+		"title": title,
+		"text":  text,
+	})
+	if err != nil {
+		return fmt.Errorf("序列化消息参数失败: %w", err)
+	}
+
+	for start := 0; start < len(userIDs); start += maxBatchUsers {
+		end := min(start+maxBatchUsers, len(userIDs))
+		payload := map[string]interface{}{
+			"robotCode": c.RobotCode,
+			"userIds":   userIDs[start:end],
+			"msgKey":    "sampleMarkdown",
+			"msgParam":  string(msgParamJSON),
+		}
+		if err := c.sendRequestWithHeader(url, payload, token); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // 通用发送请求（旧版 API，带 access_token 在 URL 中）
 func (c *Client) sendRequest(url string, payload interface{}) error {
 	data, err := json.Marshal(payload)
@@ -192,12 +232,17 @@ func (c *Client) sendRequest(url string, payload interface{}) error {
 
 // 新版 API 请求（access_token 在 header 中）
 func (c *Client) sendRequestWithHeader(url string, payload interface{}, token string) error {
+	return c.sendRequestWithMethod(http.MethodPost, url, payload, token)
+}
+
+// 新版 API 请求，指定 HTTP 方法（如更新卡片使用 PUT）
+func (c *Client) sendRequestWithMethod(method, url string, payload interface{}, token string) error {
 	data, err := json.Marshal(payload)
 	if err != nil {
 		return fmt.Errorf("序列化请求失败: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(data))
+	req, err := http.NewRequest(method, url, bytes.NewBuffer(data))
 	if err != nil {
 		return fmt.Errorf("创建请求失败: %w", err)
 	}
@@ -0,0 +1,141 @@
+package dingtalk
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// GetGroupMemberIDs 获取群成员的 userid 列表（旧版 chat/get 接口，与 chat/send 使用同一个 chatid）
+func (c *Client) GetGroupMemberIDs(chatID string) ([]string, error) {
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	api := fmt.Sprintf("https://oapi.dingtalk.com/chat/get?access_token=%s&chatid=%s", token, url.QueryEscape(chatID))
+
+	var result struct {
+		ChatInfo struct {
+			Name       string   `json:"name"`
+			Owner      string   `json:"owner"`
+			UserIDList []string `json:"useridlist"`
+		} `json:"chat_info"`
+	}
+	if err := c.callOAPI(http.MethodGet, api, nil, &result); err != nil {
+		return nil, fmt.Errorf("获取群成员失败: %w", err)
+	}
+
+	return result.ChatInfo.UserIDList, nil
+}
+
+// UserProfile 用户的姓名和是否为部门主管
+type UserProfile struct {
+	Name       string
+	DeptLeader bool // 是否为任一所在部门的主管
+}
+
+// GetUserProfile 获取用户的姓名和部门主管标记
+func (c *Client) GetUserProfile(userID string) (*UserProfile, error) {
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	api := fmt.Sprintf("https://oapi.dingtalk.com/topapi/v2/user/get?access_token=%s", token)
+
+	var result struct {
+		Result struct {
+			Name         string `json:"name"`
+			LeaderInDept []struct {
+				DeptID int64 `json:"dept_id"`
+				Leader bool  `json:"leader"`
+			} `json:"leader_in_dept"`
+		} `json:"result"`
+	}
+	if err := c.callOAPI(http.MethodPost, api, map[string]string{"userid": userID}, &result); err != nil {
+		return nil, fmt.Errorf("获取用户 %s 信息失败: %w", userID, err)
+	}
+
+	profile := &UserProfile{Name: result.Result.Name}
+	for _, dept := range result.Result.LeaderInDept {
+		if dept.Leader {
+			profile.DeptLeader = true
+			break
+		}
+	}
+	return profile, nil
+}
+
+// GetDepartmentUserIDs 获取部门（不含子部门）成员的 userid 列表
+func (c *Client) GetDepartmentUserIDs(deptID string) ([]string, error) {
+	id, err := strconv.ParseInt(deptID, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("无效的部门ID: %s", deptID)
+	}
+
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return nil, err
+	}
+
+	api := fmt.Sprintf("https://oapi.dingtalk.com/topapi/user/listid?access_token=%s", token)
+
+	var result struct {
+		Result struct {
+			UserIDList []string `json:"userid_list"`
+		} `json:"result"`
+	}
+	if err := c.callOAPI(http.MethodPost, api, map[string]int64{"dept_id": id}, &result); err != nil {
+		return nil, fmt.Errorf("获取部门 %s 成员失败: %w", deptID, err)
+	}
+
+	return result.Result.UserIDList, nil
+}
+
+// callOAPI 调用旧版 API（access_token 在 URL 中），检查 errcode 后将响应解析到 out
+func (c *Client) callOAPI(method, api string, payload interface{}, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return fmt.Errorf("序列化请求失败: %w", err)
+		}
+		body = bytes.NewBuffer(data)
+	}
+
+	req, err := http.NewRequest(method, api, body)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(resp.Body)
+	var status struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+	}
+	if err := json.Unmarshal(data, &status); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	if status.ErrCode != 0 {
+		return fmt.Errorf("钉钉 API 错误 (%d): %s", status.ErrCode, status.ErrMsg)
+	}
+
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,92 @@
+package dingtalk
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// UploadFile 上传文件到钉钉媒体库，返回 media_id（文件边读边上传，不整体读入内存）
+func (c *Client) UploadFile(fileName string, data io.Reader) (string, error) {
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return "", err
+	}
+
+	body, writer := io.Pipe()
+	form := multipart.NewWriter(writer)
+	go func() {
+		part, err := form.CreateFormFile("media", fileName)
+		if err == nil {
+			_, err = io.Copy(part, data)
+		}
+		if err == nil {
+			err = form.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+
+	api := fmt.Sprintf("https://oapi.dingtalk.com/media/upload?access_token=%s&type=file", url.QueryEscape(token))
+	req, err := http.NewRequest(http.MethodPost, api, body)
+	if err != nil {
+		body.Close()
+		return "", fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", form.FormDataContentType())
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("上传文件失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		MediaID string `json:"media_id"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.ErrCode != 0 || result.MediaID == "" {
+		return "", fmt.Errorf("上传文件失败 (%d): %s", result.ErrCode, result.ErrMsg)
+	}
+	return result.MediaID, nil
+}
+
+// SendGroupFile 上传文件并以机器人身份发送到群
+func (c *Client) SendGroupFile(chatID, fileName string, data io.Reader) error {
+	mediaID, err := c.UploadFile(fileName, data)
+	if err != nil {
+		return err
+	}
+
+	token, err := c.GetAccessToken()
+	if err != nil {
+		return err
+	}
+
+	msgParam := map[string]string{
+		"mediaId":  mediaID,
+		"fileName": fileName,
+		"fileType": strings.TrimPrefix(strings.ToLower(filepath.Ext(fileName)), "."),
+	}
+	msgParamJSON, err := json.Marshal(msgParam)
+	if err != nil {
+		return fmt.Errorf("序列化消息参数失败: %w", err)
+	}
+
+	payload := map[string]interface{}{
+		"msgKey":             "sampleFile",
+		"msgParam":           string(msgParamJSON),
+		"openConversationId": chatID,
+		"robotCode":          c.RobotCode,
+	}
+
+	return c.sendRequestWithHeader("https://api.dingtalk.com/v1.0/robot/groupMessages/send", payload, token)
+}
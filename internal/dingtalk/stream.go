@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 
+	"github.com/open-dingtalk/dingtalk-stream-sdk-go/card"
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/chatbot"
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/client"
 	"github.com/open-dingtalk/dingtalk-stream-sdk-go/logger"
@@ -45,13 +46,14 @@ type IncomingMessage struct {
 		DingtalkID string `json:"dingtalkId"`
 		StaffID    string `json:"staffId"`
 	} `json:"atUsers"`
+	Attachments []Attachment `json:"attachments,omitempty"` // 图片、文件等非文本消息中的附件
 }
 
 type CardCallback struct {
 	OutTrackID string `json:"outTrackId"`
 	CorpID     string `json:"corpId"`
 	UserID     string `json:"userId"`
-	Value      string `json:"value"`
+	Value      string `json:"value"` // 被点击的按钮 ID（CardAction.ID）
 }
 
 func NewStreamClient(appKey, appSecret string, handler MessageHandler) *StreamClient {
@@ -71,6 +73,8 @@ func NewStreamClient(appKey, appSecret string, handler MessageHandler) *StreamCl
 func (s *StreamClient) Start(ctx context.Context) error {
 	// 注册群消息回调（v0.9.1 期望的签名为 IChatBotMessageHandler）
 	s.client.RegisterChatBotCallbackRouter(s.onBotMessage)
+	// 注册互动卡片按钮回调
+	s.client.RegisterCardCallbackRouter(s.onCardCallback)
 
 	// 启动 Stream 客户端
 	if err := s.client.Start(ctx); err != nil {
@@ -105,6 +109,12 @@ func (s *StreamClient) onBotMessage(ctx context.Context, df *chatbot.BotCallback
 	msg.SessionWebhook = df.SessionWebhook
 	msg.Text.Content = df.Text.Content
 	msg.MsgType = df.Msgtype
+	// 图片/文件等消息的附件；图文混排消息的文字不在 text 字段中
+	attachments, richText := parseMessageContent(df.Msgtype, df.Content)
+	msg.Attachments = attachments
+	if msg.Text.Content == "" {
+		msg.Text.Content = richText
+	}
 	if len(df.AtUsers) > 0 {
 		for _, u := range df.AtUsers {
 			msg.AtUsers = append(msg.AtUsers, struct {
@@ -125,6 +135,25 @@ func (s *StreamClient) onBotMessage(ctx context.Context, df *chatbot.BotCallback
 	return []byte("✅ 收到"), nil
 }
 
+// 互动卡片按钮回调：映射为 CardCallback 后交给业务层处理
+func (s *StreamClient) onCardCallback(ctx context.Context, req *card.CardRequest) (*card.CardResponse, error) {
+	callback := &CardCallback{
+		OutTrackID: req.OutTrackId,
+		CorpID:     req.CorpId,
+		UserID:     req.UserId,
+	}
+	if ids := req.CardActionData.CardPrivateData.ActionIdList; len(ids) > 0 {
+		callback.Value = ids[0]
+	}
+	log.Printf("收到卡片回调: out_track_id=%s, user=%s, action=%s", callback.OutTrackID, callback.UserID, callback.Value)
+
+	// 处理失败时只记录日志，避免钉钉重复投递同一次点击
+	if err := s.messageHandler.HandleCardCallback(ctx, callback); err != nil {
+		log.Printf("处理卡片回调失败: %v", err)
+	}
+	return &card.CardResponse{}, nil
+}
+
 func (s *StreamClient) Stop() {
 	if s.client != nil {
 		s.client.Close()
@@ -2,9 +2,16 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 
+	"dingteam-bot/internal/dingtalk"
 	"dingteam-bot/internal/models"
 	"dingteam-bot/internal/services"
 
@@ -13,17 +20,27 @@ import (
 
 // APIHandler HTTP API 处理器（供 Dify 调用）
 type APIHandler struct {
-	permService *services.PermissionService
-	taskService *services.TaskService
-	statsService *services.StatsService
+	permService       *services.PermissionService
+	taskService       *services.TaskService
+	statsService      *services.StatsService
+	calendarService   *services.CalendarService
+	completionService *services.CompletionService
+	digestService     *services.DigestService
+	exportService     *services.ExportService
+	dtClient          *dingtalk.Client
 }
 
 // NewAPIHandler 创建 API 处理器
-func NewAPIHandler(permService *services.PermissionService, taskService *services.TaskService, statsService *services.StatsService) *APIHandler {
+func NewAPIHandler(permService *services.PermissionService, taskService *services.TaskService, statsService *services.StatsService, calendarService *services.CalendarService, completionService *services.CompletionService, digestService *services.DigestService, exportService *services.ExportService, dtClient *dingtalk.Client) *APIHandler {
 	return &APIHandler{
-		permService:  permService,
-		taskService:  taskService,
-		statsService: statsService,
+		permService:       permService,
+		taskService:       taskService,
+		statsService:      statsService,
+		calendarService:   calendarService,
+		completionService: completionService,
+		digestService:     digestService,
+		exportService:     exportService,
+		dtClient:          dtClient,
 	}
 }
 
@@ -223,6 +240,81 @@ func (h *APIHandler) ListAdmins(c *gin.Context) {
 // 任务相关 API（带权限验证）
 // ========================================
 
+// createTaskRequest 创建任务请求（时间、日期字段为字符串）
+type createTaskRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Description    string   `json:"description"`
+	Type           string   `json:"type"`
+	CronExpr       string   `json:"cron_expr"`
+	DeadlineTime   string   `json:"deadline_time"` // HH:MM 或 HH:MM:SS
+	AdvanceMinutes int      `json:"advance_minutes"`
+	GroupChatID    string   `json:"group_chat_id" binding:"required"`
+	GroupChatName  string   `json:"group_chat_name"`
+	Status         string   `json:"status"`
+	CalendarPolicy string   `json:"calendar_policy"`
+	ReminderPlan   []string `json:"reminder_plan"`
+	ScheduleKind   string   `json:"schedule_kind"`   // CRON（默认）或 ONCE
+	DueAt          string   `json:"due_at"`          // 单次任务截止时间，如 2026-10-23 18:00
+	StartDate      string   `json:"start_date"`      // 周期任务开始日期，如 2026-10-19
+	EndDate        string   `json:"end_date"`        // 周期任务结束日期（含当天）
+	MaxOccurrences int      `json:"max_occurrences"` // 周期任务最多执行次数
+	Timezone       string   `json:"timezone"`        // 任务时区，如 Asia/Singapore（为空时使用群默认时区）
+}
+
+// toTask 转换为任务模型
+func (r createTaskRequest) toTask(taskService *services.TaskService) (models.Task, error) {
+	task := models.Task{
+		Name:           r.Name,
+		Description:    sql.NullString{String: r.Description, Valid: r.Description != ""},
+		Type:           models.TaskType(r.Type),
+		CronExpr:       r.CronExpr,
+		AdvanceMinutes: r.AdvanceMinutes,
+		GroupChatID:    r.GroupChatID,
+		GroupChatName:  sql.NullString{String: r.GroupChatName, Valid: r.GroupChatName != ""},
+		Status:         models.TaskStatus(r.Status),
+		CalendarPolicy: models.CalendarPolicy(r.CalendarPolicy),
+		ReminderPlan:   r.ReminderPlan,
+		ScheduleKind:   models.ScheduleKind(strings.ToUpper(r.ScheduleKind)),
+		MaxOccurrences: r.MaxOccurrences,
+		Timezone:       r.Timezone,
+	}
+	if task.Status == "" {
+		task.Status = models.TaskStatusActive
+	}
+
+	if r.DeadlineTime != "" {
+		deadline, err := services.ParseDeadlineTime(r.DeadlineTime)
+		if err != nil {
+			return task, err
+		}
+		task.DeadlineTime = sql.NullTime{Time: deadline, Valid: true}
+	}
+
+	if r.DueAt != "" {
+		dueAt, err := taskService.ParseDueAt(task, r.DueAt)
+		if err != nil {
+			return task, err
+		}
+		task.DueAt = sql.NullTime{Time: dueAt, Valid: true}
+	}
+
+	for _, field := range []struct {
+		value string
+		dest  *sql.NullTime
+	}{{r.StartDate, &task.StartDate}, {r.EndDate, &task.EndDate}} {
+		if field.value == "" {
+			continue
+		}
+		date, err := taskService.ParseTaskDate(task, field.value)
+		if err != nil {
+			return task, err
+		}
+		*field.dest = sql.NullTime{Time: date, Valid: true}
+	}
+
+	return task, nil
+}
+
 // CreateTaskAPI 创建任务 API
 // POST /api/v1/tasks
 // Header: X-Operator-ID (操作者ID，用于权限验证)
@@ -259,14 +351,22 @@ func (h *APIHandler) CreateTaskAPI(c *gin.Context) {
 	}
 
 	// 解析请求
-	var task models.Task
-	if err := c.ShouldBindJSON(&task); err != nil {
+	var req createTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "请求参数错误",
 		})
 		return
 	}
 
+	task, err := req.toTask(h.taskService)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
 	// 创建任务
 	task.CreatorUserID = operatorID
 	if err := h.taskService.CreateTask(&task); err != nil {
@@ -389,6 +489,148 @@ func (h *APIHandler) DeleteTaskAPI(c *gin.Context) {
 	})
 }
 
+// UpdateTaskAPI 修改任务 API（只修改请求中出现的字段）
+// PATCH /api/v1/tasks/:taskID
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+// Body: {"name": "写周总结", "cron_expr": "0 16 * * 5", "deadline_time": "17:00"}
+func (h *APIHandler) UpdateTaskAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法修改任务",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return
+	}
+
+	var req models.TaskUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	task, err := h.taskService.UpdateTask(taskID, req)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrTaskNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功修改任务")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "任务已更新",
+		"task":    task,
+	})
+}
+
+// PauseTaskAPI 暂停任务 API
+// POST /api/v1/tasks/:taskID/pause
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) PauseTaskAPI(c *gin.Context) {
+	h.changeTaskStatus(c, h.taskService.PauseTask, "任务已暂停", "成功暂停任务")
+}
+
+// ResumeTaskAPI 恢复任务 API
+// POST /api/v1/tasks/:taskID/resume
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ResumeTaskAPI(c *gin.Context) {
+	h.changeTaskStatus(c, h.taskService.ResumeTask, "任务已恢复", "成功恢复任务")
+}
+
+// changeTaskStatus 暂停/恢复任务的公共流程（需要 update_task 权限）
+func (h *APIHandler) changeTaskStatus(c *gin.Context, change func(taskID int) error, message, auditReason string) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	if err := change(taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, auditReason)
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": message,
+		"task_id": taskID,
+	})
+}
+
+// authorizeTaskUpdate 校验操作者的 update_task 权限并解析路径中的任务ID，失败时已写入响应
+func (h *APIHandler) authorizeTaskUpdate(c *gin.Context) (string, int, bool) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return "", 0, false
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法修改任务",
+			"reason": reason,
+		})
+		return "", 0, false
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return "", 0, false
+	}
+
+	return operatorID, taskID, true
+}
+
 // CompleteTaskAPI 打卡完成任务 API
 // POST /api/v1/tasks/:taskID/complete
 // Header: X-Operator-ID (操作者ID，用于权限验证)
@@ -418,70 +660,75 @@ func (h *APIHandler) CompleteTaskAPI(c *gin.Context) {
 
 	// 解析任务ID
 	var taskID int
-	if err := c.ShouldBindUri(&struct {
-		TaskID int `uri:"taskID" binding:"required"`
-	}{TaskID: taskID}); err != nil {
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "任务ID格式错误",
 		})
 		return
 	}
 
-	// 解析请求
+	// 解析请求（content 为可选的提交内容：说明文字，其中的链接单独记录）
 	var req struct {
-		GroupChatID string `json:"group_chat_id" binding:"required"`
-		Username    string `json:"username"`
+		Username string `json:"username"`
+		Content  string `json:"content"`
 	}
 
-	if err := c.ShouldBindJSON(&req); err != nil {
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "请求参数错误",
 		})
 		return
 	}
 
-	// 检查是否已打卡
-	completed, err := h.taskService.HasCompletedToday(taskID, operatorID)
+	task, err := h.taskService.GetTaskByID(taskID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "检查打卡状态失败",
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "任务不存在",
 		})
 		return
 	}
 
-	if completed {
+	// 记到当前这一期，按任务时区判断是否按时
+	record, err := h.completionService.Complete(*task, time.Time{}, operatorID, req.Username)
+	if err == services.ErrAlreadyCompleted || err == services.ErrTaskNotActive {
 		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "今天已经打过卡了",
+			"error": err.Error(),
 		})
 		return
 	}
-
-	// 创建完成记录
-	record := &models.CompletionRecord{
-		TaskID:      taskID,
-		UserID:      operatorID,
-		UserName:    sql.NullString{String: req.Username, Valid: req.Username != ""},
-		GroupChatID: req.GroupChatID,
-		IsOnTime:    true, // 这里需要根据实际情况判断
+	if err == services.ErrNotAssigned {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error": err.Error(),
+		})
+		return
 	}
-
-	if err := h.taskService.RecordCompletion(record); err != nil {
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": "打卡失败",
 		})
 		return
 	}
 
+	submissions := services.ParseSubmissions(req.Content, nil)
+	if err := h.taskService.AddSubmissions(record, submissions); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error":  "打卡成功，但提交内容保存失败",
+			"record": record,
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{
-		"message": "打卡成功",
-		"record":  record,
+		"message":     "打卡成功",
+		"record":      record,
+		"submissions": len(submissions),
 	})
 }
 
-// GetStatsAPI 获取统计数据 API
-// GET /api/v1/tasks/:taskID/stats
+// UndoCompletionAPI 撤销操作者在任务上最近一次打卡（须在打卡后的宽限时间内）
+// POST /api/v1/tasks/:taskID/complete/undo
 // Header: X-Operator-ID (操作者ID，用于权限验证)
-func (h *APIHandler) GetStatsAPI(c *gin.Context) {
+func (h *APIHandler) UndoCompletionAPI(c *gin.Context) {
 	operatorID := c.GetHeader("X-Operator-ID")
 	if operatorID == "" {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -494,12 +741,12 @@ func (h *APIHandler) GetStatsAPI(c *gin.Context) {
 	allowed, _, reason, err := h.permService.CanExecuteCommand(
 		c.Request.Context(),
 		operatorID,
-		models.PermViewStats,
+		models.PermCompleteTask,
 	)
 
 	if err != nil || !allowed {
 		c.JSON(http.StatusForbidden, gin.H{
-			"error":  "权限不足，无法查看统计",
+			"error":  "权限不足，无法撤销打卡",
 			"reason": reason,
 		})
 		return
@@ -507,25 +754,1941 @@ func (h *APIHandler) GetStatsAPI(c *gin.Context) {
 
 	// 解析任务ID
 	var taskID int
-	if err := c.ShouldBindUri(&struct {
-		TaskID int `uri:"taskID" binding:"required"`
-	}{TaskID: taskID}); err != nil {
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "任务ID格式错误",
 		})
 		return
 	}
 
-	// 获取统计数据
-	stats, err := h.statsService.GetTodayStats(taskID)
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "任务不存在",
+		})
+		return
+	}
+
+	_, record, err := h.completionService.Undo([]models.Task{*task}, operatorID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "已撤销打卡",
+		"record":  record,
+	})
+}
+
+// CorrectCompletionAPI 管理员更正成员在某一期的打卡（补卡或删除打卡），变更写入审计日志
+// PUT /api/v1/tasks/:taskID/completions/:userID
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) CorrectCompletionAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Date      string `json:"date"` // 为空时为当前这一期
+		Completed *bool  `json:"completed" binding:"required"`
+		OnTime    *bool  `json:"on_time"` // 补卡时是否记为按时，默认按时
+		Reason    string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	taskDate, err := h.taskService.ResolveTaskDate(*task, req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	onTime := req.OnTime == nil || *req.OnTime
+	audit, err := h.completionService.Correct(*task, c.Param("userID"), taskDate, *req.Completed, onTime, operatorID, req.Reason)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功更正打卡")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "打卡已更正",
+		"audit":   audit,
+	})
+}
+
+// GetCompletionAuditAPI 查看任务最近 30 天的打卡变更记录（撤销、补卡、删除打卡）
+// GET /api/v1/tasks/:taskID/completion-audit
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetCompletionAuditAPI(c *gin.Context) {
+	taskID, ok := h.authorizeTaskView(c)
+	if !ok {
+		return
+	}
+
+	audits, err := h.completionService.AuditLog(taskID, time.Now().AddDate(0, 0, -30))
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "获取统计数据失败",
+			"error": err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"stats": stats,
+		"task_id": taskID,
+		"audits":  audits,
+	})
+}
+
+// GetSubmissionsAPI 查看任务某一期的打卡提交内容（文件/图片附带临时下载链接）
+// GET /api/v1/tasks/:taskID/submissions?date=2026-10-16
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetSubmissionsAPI(c *gin.Context) {
+	taskID, ok := h.authorizeTaskView(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 未指定日期时为当前这一期
+	taskDate, err := h.taskService.ResolveTaskDate(*task, c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	submissions, err := h.taskService.GetSubmissions(taskID, taskDate)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 下载码换取的链接有时效，每次查询时重新获取
+	for i := range submissions {
+		if !submissions[i].IsAttachment() {
+			continue
+		}
+		url, err := h.dtClient.GetMessageFileDownloadURL(submissions[i].DownloadCode.String)
+		if err != nil {
+			log.Printf("获取提交文件下载链接失败: %v", err)
+			continue
+		}
+		submissions[i].DownloadURL = url
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":     taskID,
+		"task_date":   taskDate.Format("2006-01-02"),
+		"submissions": submissions,
+	})
+}
+
+// GetStatsAPI 获取统计数据 API
+// GET /api/v1/tasks/:taskID/stats
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetStatsAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermViewStats,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看统计",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return
+	}
+
+	// 获取统计数据
+	stats, err := h.statsService.GetTodayStats(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "获取统计数据失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats": stats,
+	})
+}
+
+// GetHistoryStatsAPI 历史统计：按天/周/月的完成率、按时率，以及每个成员的连续完成期数和缺卡记录
+// GET /api/v1/stats?task_id=1&group=cidXXX&user=user123&from=2026-09-01&to=2026-09-30&granularity=week
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetHistoryStatsAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermViewStats,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看统计",
+			"reason": reason,
+		})
+		return
+	}
+
+	query := models.StatsQuery{
+		GroupChatID: c.Query("group"),
+		UserID:      c.Query("user"),
+		Granularity: models.StatsGranularity(c.DefaultQuery("granularity", string(models.GranularityDay))),
+	}
+	if value := c.Query("task_id"); value != "" {
+		if _, err := fmt.Sscanf(value, "%d", &query.TaskID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "任务ID格式错误",
+			})
+			return
+		}
+	}
+
+	// 日期按任务（或群）的时区解析，默认统计最近 30 天
+	scope := models.Task{GroupChatID: query.GroupChatID}
+	if query.TaskID != 0 {
+		task, err := h.taskService.GetTaskByID(query.TaskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		scope = *task
+	}
+	loc := h.taskService.Location(scope)
+	query.To = services.StartOfToday(loc)
+	if value := c.Query("to"); value != "" {
+		if query.To, err = services.ParseTaskDate(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	query.From = query.To.AddDate(0, 0, -29)
+	if value := c.Query("from"); value != "" {
+		if query.From, err = services.ParseTaskDate(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	stats, err := h.statsService.GetHistoryStats(query)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"stats": stats,
+	})
+}
+
+// GetLeaderboardAPI 月度排行榜：按时完成次数、完成率、首位提交徽章和连续按时完成的期数
+// GET /api/v1/leaderboard?group_chat_id=xxx&task_id=1&month=2026-10
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetLeaderboardAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermViewStats,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看排行榜",
+			"reason": reason,
+		})
+		return
+	}
+
+	query := models.LeaderboardQuery{GroupChatID: c.Query("group_chat_id")}
+	if value := c.Query("task_id"); value != "" {
+		if _, err := fmt.Sscanf(value, "%d", &query.TaskID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "任务ID格式错误",
+			})
+			return
+		}
+	}
+	if query.TaskID == 0 && query.GroupChatID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "需要指定 group_chat_id 或 task_id",
+		})
+		return
+	}
+
+	// 月份按任务（或群）的时区解析，默认本月
+	scope := models.Task{GroupChatID: query.GroupChatID}
+	if query.TaskID != 0 {
+		task, err := h.taskService.GetTaskByID(query.TaskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		scope = *task
+	}
+	if query.Month, err = services.LeaderboardMonth(c.Query("month"), h.taskService.Location(scope)); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	board, err := h.statsService.GetLeaderboard(query)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"leaderboard": board,
+	})
+}
+
+// GetReminderPlanAPI 获取任务提醒计划 API
+// GET /api/v1/tasks/:taskID/reminder-plan
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetReminderPlanAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看任务",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return
+	}
+
+	items, err := h.taskService.GetReminderPlan(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":    taskID,
+		"plan":       items,
+		"is_default": len(items) == 0,
+	})
+}
+
+// SetReminderPlanAPI 设置任务提醒计划 API（整体替换，空列表恢复默认计划）
+// PUT /api/v1/tasks/:taskID/reminder-plan
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+// Body: {"offsets": ["-1d 18:00", "-2h", "deadline", "+30m"]}
+func (h *APIHandler) SetReminderPlanAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法修改任务",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return
+	}
+
+	var req struct {
+		Offsets []string `json:"offsets"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	if err := h.taskService.SetReminderPlan(taskID, req.Offsets); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置提醒计划")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "提醒计划已更新",
+		"task_id": taskID,
+		"offsets": req.Offsets,
+	})
+}
+
+// SetCalendarPolicyAPI 设置任务的节假日策略
+func (h *APIHandler) SetCalendarPolicyAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法修改任务",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return
+	}
+
+	var req struct {
+		Policy string `json:"calendar_policy"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	policy, err := models.ParseCalendarPolicy(req.Policy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.taskService.SetCalendarPolicy(taskID, policy); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrTaskNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置节假日策略")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "节假日策略已更新",
+		"task_id":         taskID,
+		"calendar_policy": policy,
+	})
+}
+
+// SetTaskTimezoneAPI 设置任务的时区
+// PUT /api/v1/tasks/:taskID/timezone
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+// Body: {"timezone": "Europe/Berlin"}（空字符串表示使用群默认时区）
+func (h *APIHandler) SetTaskTimezoneAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法修改任务",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return
+	}
+
+	var req struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	if err := h.taskService.SetTaskTimezone(taskID, req.Timezone); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrTaskNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置任务时区")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "任务时区已更新",
+		"task_id":  taskID,
+		"timezone": req.Timezone,
+	})
+}
+
+// SkipOccurrenceAPI 跳过任务的某一期（当期不提醒、不计入完成率）
+// POST /api/v1/tasks/:taskID/skip
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+// Body: {"date": "2026-10-20", "reason": "团建"}（date 为空时为当前周期）
+func (h *APIHandler) SkipOccurrenceAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Date   string `json:"date"`
+		Reason string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil && err != io.EOF {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	taskDate, err := h.taskService.ResolveTaskDate(*task, req.Date)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.taskService.SkipOccurrence(taskID, taskDate, operatorID, req.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功跳过任务")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "已跳过",
+		"task_id":   taskID,
+		"task_date": taskDate.Format("2006-01-02"),
+	})
+}
+
+// CancelSkipAPI 取消跳过任务的某一期
+// DELETE /api/v1/tasks/:taskID/skip?date=2026-10-20（date 为空时为当前周期）
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) CancelSkipAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	taskDate, err := h.taskService.ResolveTaskDate(*task, c.Query("date"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.taskService.CancelSkip(taskID, taskDate); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功取消跳过任务")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "已取消跳过",
+		"task_id":   taskID,
+		"task_date": taskDate.Format("2006-01-02"),
+	})
+}
+
+// SnoozeReminderAPI 将任务的下一次提醒延后若干分钟
+// POST /api/v1/tasks/:taskID/snooze
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+// Body: {"minutes": 30, "reason": "会议延长"}
+func (h *APIHandler) SnoozeReminderAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Minutes int    `json:"minutes" binding:"required"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	if err := h.taskService.SnoozeNextReminder(taskID, req.Minutes, operatorID, req.Reason); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功延后提醒")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "下一次提醒已延后",
+		"task_id": taskID,
+		"minutes": req.Minutes,
+	})
+}
+
+// GetOccurrenceOverridesAPI 查看任务近期的跳过记录和尚未结束的延后设置
+// GET /api/v1/tasks/:taskID/overrides
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetOccurrenceOverridesAPI(c *gin.Context) {
+	taskID, ok := h.authorizeTaskView(c)
+	if !ok {
+		return
+	}
+
+	// 最近 30 天的跳过记录
+	overrides, err := h.taskService.GetOccurrenceOverrides(taskID, time.Now().AddDate(0, 0, -30))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":   taskID,
+		"overrides": overrides,
+	})
+}
+
+// authorizeTaskView 校验操作者的查看任务权限并解析任务ID，失败时已写入响应
+func (h *APIHandler) authorizeTaskView(c *gin.Context) (int, bool) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return 0, false
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看任务",
+			"reason": reason,
+		})
+		return 0, false
+	}
+
+	// 解析任务ID
+	var taskID int
+	if _, err := fmt.Sscanf(c.Param("taskID"), "%d", &taskID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "任务ID格式错误",
+		})
+		return 0, false
+	}
+
+	return taskID, true
+}
+
+// ========================================
+// 负责人与豁免 API
+// ========================================
+
+// GetTaskAssigneesAPI 获取任务负责人、今天需要执行的成员和仍生效的豁免
+// GET /api/v1/tasks/:taskID/assignees
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetTaskAssigneesAPI(c *gin.Context) {
+	taskID, ok := h.authorizeTaskView(c)
+	if !ok {
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	assignees, err := h.taskService.GetTaskAssignees(taskID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	today := h.taskService.Today(*task)
+	members, err := h.taskService.GetResponsibleMembers(*task, h.taskService.TaskDate(*task, time.Now()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	exemptions, err := h.taskService.GetTaskExemptions(taskID, today)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"task_id":    taskID,
+		"assignees":  assignees,
+		"members":    members,
+		"exemptions": exemptions,
+	})
+}
+
+// SetTaskAssigneesAPI 设置任务负责人（users 和 departments 都为空时恢复为群内所有成员）
+// PUT /api/v1/tasks/:taskID/assignees
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) SetTaskAssigneesAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Users       []string `json:"users"`
+		Departments []string `json:"departments"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	if _, err := h.taskService.GetTaskByID(taskID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.taskService.SetTaskAssignees(taskID, req.Users, req.Departments, operatorID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置任务负责人")
+
+	assignees, _ := h.taskService.GetTaskAssignees(taskID)
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "负责人已更新",
+		"task_id":   taskID,
+		"assignees": assignees,
+	})
+}
+
+// AddTaskExemptionAPI 登记成员在一段日期内免于执行任务
+// POST /api/v1/tasks/:taskID/exemptions
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) AddTaskExemptionAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID    string `json:"user_id" binding:"required"`
+		StartDate string `json:"start_date" binding:"required"`
+		EndDate   string `json:"end_date"` // 为空时只豁免开始日期当天
+		Reason    string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	exemption := models.TaskExemption{
+		TaskID:    taskID,
+		UserID:    req.UserID,
+		Reason:    sql.NullString{String: req.Reason, Valid: req.Reason != ""},
+		CreatedBy: operatorID,
+	}
+	exemption.StartDate, err = h.taskService.ParseTaskDate(*task, req.StartDate)
+	if err == nil && req.EndDate != "" {
+		exemption.EndDate, err = h.taskService.ParseTaskDate(*task, req.EndDate)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	created, err := h.taskService.AddTaskExemption(exemption)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功登记豁免")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message":   "豁免已登记",
+		"exemption": created,
+	})
+}
+
+// DeleteTaskExemptionAPI 删除一条豁免
+// DELETE /api/v1/tasks/:taskID/exemptions/:exemptionID
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) DeleteTaskExemptionAPI(c *gin.Context) {
+	operatorID, taskID, ok := h.authorizeTaskUpdate(c)
+	if !ok {
+		return
+	}
+
+	var exemptionID int
+	if _, err := fmt.Sscanf(c.Param("exemptionID"), "%d", &exemptionID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "豁免ID格式错误",
+		})
+		return
+	}
+
+	if err := h.taskService.DeleteTaskExemption(taskID, exemptionID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功删除豁免")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "豁免已删除",
+	})
+}
+
+// ========================================
+// 免提醒设置 API
+// ========================================
+
+// ListReminderExclusionsAPI 列出免提醒设置
+// GET /api/v1/reminder-exclusions?group_chat_id=xxx（为空时列出所有设置）
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ListReminderExclusionsAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看免提醒设置",
+			"reason": reason,
+		})
+		return
+	}
+
+	groupChatID := c.Query("group_chat_id")
+	exclusions, err := h.taskService.GetReminderExclusions(groupChatID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	resp := gin.H{
+		"exclusions": exclusions,
+	}
+	if groupChatID != "" {
+		leaders, err := h.taskService.GetDeptLeaders(groupChatID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		resp["dept_leaders"] = leaders
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// SetReminderExclusionAPI 设置成员是否免提醒
+// PUT /api/v1/reminder-exclusions/:userID
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) SetReminderExclusionAPI(c *gin.Context) {
+	operatorID, ok := h.authorizeUpdate(c, "权限不足，无法修改免提醒设置")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		GroupChatID string `json:"group_chat_id"` // 为空时对所有群生效
+		Excluded    *bool  `json:"excluded" binding:"required"`
+		Reason      string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	userID := c.Param("userID")
+	if err := h.taskService.SetReminderExclusion(userID, req.GroupChatID, *req.Excluded, req.Reason, operatorID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置免提醒")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "免提醒设置已更新",
+		"user_id":       userID,
+		"group_chat_id": req.GroupChatID,
+		"excluded":      *req.Excluded,
+	})
+}
+
+// DeleteReminderExclusionAPI 删除成员的免提醒设置，恢复为默认规则
+// DELETE /api/v1/reminder-exclusions/:userID?group_chat_id=xxx（为空时删除全局设置）
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) DeleteReminderExclusionAPI(c *gin.Context) {
+	operatorID, ok := h.authorizeUpdate(c, "权限不足，无法修改免提醒设置")
+	if !ok {
+		return
+	}
+
+	if err := h.taskService.RemoveReminderExclusion(c.Param("userID"), c.Query("group_chat_id")); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功删除免提醒设置")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "免提醒设置已删除",
+	})
+}
+
+// authorizeUpdate 校验操作者的修改任务权限（免提醒、请假等不针对单个任务的设置），失败时已写入响应
+func (h *APIHandler) authorizeUpdate(c *gin.Context, denied string) (string, bool) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return "", false
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  denied,
+			"reason": reason,
+		})
+		return "", false
+	}
+
+	return operatorID, true
+}
+
+// ========================================
+// 请假 API
+// ========================================
+
+// ListLeavesAPI 查询今天（或 from）及之后仍生效的请假
+// GET /api/v1/leaves?user_id=xxx&group_chat_id=xxx&from=2026-10-01
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ListLeavesAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看请假",
+			"reason": reason,
+		})
+		return
+	}
+
+	groupChatID := c.Query("group_chat_id")
+	loc := h.taskService.Location(models.Task{GroupChatID: groupChatID})
+	from := services.StartOfToday(loc)
+	if value := c.Query("from"); value != "" {
+		if from, err = services.ParseLeaveDate(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	// 按用户或群成员过滤
+	var userIDs []string
+	if userID := c.Query("user_id"); userID != "" {
+		userIDs = []string{userID}
+	} else if groupChatID != "" {
+		members, err := h.taskService.GetGroupMembers(groupChatID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		if len(members) == 0 {
+			c.JSON(http.StatusOK, gin.H{"leaves": []models.Leave{}})
+			return
+		}
+		for _, member := range members {
+			userIDs = append(userIDs, member.UserID)
+		}
+	}
+
+	leaves, err := h.taskService.GetLeaves(userIDs, from)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"leaves": leaves,
+	})
+}
+
+// AddLeaveAPI 为成员登记请假（可只对某个任务），期间不提醒、不计入完成率
+// POST /api/v1/leaves
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) AddLeaveAPI(c *gin.Context) {
+	operatorID, ok := h.authorizeUpdate(c, "权限不足，无法登记请假")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		UserID    string `json:"user_id" binding:"required"`
+		StartDate string `json:"start_date" binding:"required"`
+		EndDate   string `json:"end_date"` // 为空时只请开始日期当天
+		TaskID    int    `json:"task_id"`  // 为 0 时对所有任务生效
+		Reason    string `json:"reason"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	leave := models.Leave{
+		UserID:    req.UserID,
+		Reason:    sql.NullString{String: req.Reason, Valid: req.Reason != ""},
+		CreatedBy: operatorID,
+	}
+	loc := h.taskService.Location(models.Task{})
+	if req.TaskID != 0 {
+		task, err := h.taskService.GetTaskByID(req.TaskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		leave.TaskID = sql.NullInt64{Int64: int64(task.ID), Valid: true}
+		loc = h.taskService.Location(*task)
+	}
+
+	var err error
+	leave.StartDate, err = services.ParseLeaveDate(req.StartDate, loc)
+	if err == nil && req.EndDate != "" {
+		leave.EndDate, err = services.ParseLeaveDate(req.EndDate, loc)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	created, err := h.taskService.AddLeave(leave)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功登记请假")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "请假已登记",
+		"leave":   created,
+	})
+}
+
+// DeleteLeaveAPI 删除一条请假
+// DELETE /api/v1/leaves/:leaveID
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) DeleteLeaveAPI(c *gin.Context) {
+	operatorID, ok := h.authorizeUpdate(c, "权限不足，无法删除请假")
+	if !ok {
+		return
+	}
+
+	var leaveID int
+	if _, err := fmt.Sscanf(c.Param("leaveID"), "%d", &leaveID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请假ID格式错误",
+		})
+		return
+	}
+
+	if err := h.taskService.DeleteLeave(leaveID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功删除请假")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "请假已删除",
+	})
+}
+
+// ========================================
+// 定时汇总 API
+// ========================================
+
+// ListDigestsAPI 查询定时汇总
+// GET /api/v1/digests?group_chat_id=xxx
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ListDigestsAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看定时汇总",
+			"reason": reason,
+		})
+		return
+	}
+
+	digests, err := h.digestService.ListDigests(c.Query("group_chat_id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"digests": digests,
+	})
+}
+
+// CreateDigestAPI 新增定时汇总
+// POST /api/v1/digests
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) CreateDigestAPI(c *gin.Context) {
+	operatorID, ok := h.authorizeUpdate(c, "权限不足，无法设置定时汇总")
+	if !ok {
+		return
+	}
+
+	var req struct {
+		GroupChatID    string   `json:"group_chat_id" binding:"required"`
+		Kind           string   `json:"kind" binding:"required"`      // DAILY / WEEKLY
+		CronExpr       string   `json:"cron_expr" binding:"required"` // 按群时区计算
+		CalendarPolicy string   `json:"calendar_policy"`
+		Target         string   `json:"target"`     // GROUP（默认）/ PRIVATE
+		Recipients     []string `json:"recipients"` // 私聊接收人，为空时为操作者
+		// 末尾附带本月排行榜前三名
+		IncludeLeaderboard bool `json:"include_leaderboard"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	policy, err := models.ParseCalendarPolicy(req.CalendarPolicy)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	digest, err := h.digestService.CreateDigest(models.Digest{
+		GroupChatID:        req.GroupChatID,
+		Kind:               models.DigestKind(strings.ToUpper(req.Kind)),
+		CronExpr:           req.CronExpr,
+		CalendarPolicy:     policy,
+		Target:             models.DigestTarget(strings.ToUpper(req.Target)),
+		Recipients:         req.Recipients,
+		IncludeLeaderboard: req.IncludeLeaderboard,
+		CreatedBy:          operatorID,
+	})
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置定时汇总")
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "定时汇总已设置",
+		"digest":  digest,
+	})
+}
+
+// DeleteDigestAPI 删除定时汇总
+// DELETE /api/v1/digests/:digestID
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) DeleteDigestAPI(c *gin.Context) {
+	operatorID, ok := h.authorizeUpdate(c, "权限不足，无法删除定时汇总")
+	if !ok {
+		return
+	}
+
+	var digestID int
+	if _, err := fmt.Sscanf(c.Param("digestID"), "%d", &digestID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "汇总ID格式错误",
+		})
+		return
+	}
+
+	if err := h.digestService.DeleteDigest(digestID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功删除定时汇总")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时汇总已删除",
+	})
+}
+
+// SendDigestAPI 立即生成并发送一次定时汇总（不影响定时发送）
+// POST /api/v1/digests/:digestID/send
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) SendDigestAPI(c *gin.Context) {
+	if _, ok := h.authorizeUpdate(c, "权限不足，无法发送定时汇总"); !ok {
+		return
+	}
+
+	var digestID int
+	if _, err := fmt.Sscanf(c.Param("digestID"), "%d", &digestID); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "汇总ID格式错误",
+		})
+		return
+	}
+
+	digest, err := h.digestService.GetDigest(digestID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	if err := h.digestService.Send(*digest, time.Now()); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "定时汇总已发送",
+	})
+}
+
+// ========================================
+// 导出 API
+// ========================================
+
+// ExportCompletionsAPI 导出打卡记录（含提交内容）
+// GET /api/v1/exports/completions?from=2026-10-01&to=2026-10-31&task_id=1&group_chat_id=xxx&user_id=xxx&format=csv|xlsx
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ExportCompletionsAPI(c *gin.Context) {
+	h.export(c, models.ExportCompletions, models.PermUpdateTask)
+}
+
+// ExportReminderLogsAPI 导出提醒发送记录
+// GET /api/v1/exports/reminder-logs?from=2026-10-01&to=2026-10-31&task_id=1&group_chat_id=xxx&format=csv|xlsx
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ExportReminderLogsAPI(c *gin.Context) {
+	h.export(c, models.ExportReminderLogs, models.PermUpdateTask)
+}
+
+// ExportPermissionAuditAPI 导出权限审计日志（仅主管理员）
+// GET /api/v1/exports/permission-audit?from=2026-10-01&to=2026-10-31&user_id=xxx&format=csv|xlsx
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) ExportPermissionAuditAPI(c *gin.Context) {
+	h.export(c, models.ExportPermissionAudit, models.PermAddAdmin)
+}
+
+// export 校验权限、解析筛选条件后将导出文件流式写入响应
+// 日期按任务（或群）的时区解析，默认导出本月 1 日到今天
+func (h *APIHandler) export(c *gin.Context, kind models.ExportKind, permission models.PermissionName) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		permission,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, permission, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  fmt.Sprintf("权限不足，无法导出%s", kind.DisplayName()),
+			"reason": reason,
+		})
+		return
+	}
+
+	format := models.ExportFormat(strings.ToLower(c.DefaultQuery("format", string(models.ExportCSV))))
+	if !format.IsValid() {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "不支持的导出格式，可选 csv、xlsx",
+		})
+		return
+	}
+
+	query := models.ExportQuery{
+		GroupChatID: c.Query("group_chat_id"),
+		UserID:      c.Query("user_id"),
+	}
+	if value := c.Query("task_id"); value != "" {
+		if _, err := fmt.Sscanf(value, "%d", &query.TaskID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "任务ID格式错误",
+			})
+			return
+		}
+	}
+
+	scope := models.Task{GroupChatID: query.GroupChatID}
+	if query.TaskID != 0 {
+		task, err := h.taskService.GetTaskByID(query.TaskID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		scope = *task
+	}
+	loc := h.taskService.Location(scope)
+	query.To = services.StartOfToday(loc)
+	if value := c.Query("to"); value != "" {
+		if query.To, err = services.ParseTaskDate(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+	query.From = query.To.AddDate(0, 0, 1-query.To.Day())
+	if value := c.Query("from"); value != "" {
+		if query.From, err = services.ParseTaskDate(value, loc); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+	}
+
+	fileName := services.ExportFileName(kind, format, query)
+	asciiName := fmt.Sprintf("%s_%s_%s.%s", kind, query.From.Format("2006-01-02"), query.To.Format("2006-01-02"), format)
+	out := &exportResponse{c: c, contentType: format.ContentType(), disposition: fmt.Sprintf(
+		`attachment; filename="%s"; filename*=UTF-8''%s`, asciiName, url.PathEscape(fileName),
+	)}
+
+	count, err := h.exportService.Export(out, kind, format, query)
+	if err != nil {
+		if !out.started {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": err.Error(),
+			})
+			return
+		}
+		// 文件已开始写出，只能中断响应
+		log.Printf("❌ 导出%s中断: %v", kind.DisplayName(), err)
+		return
+	}
+
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, permission, true,
+		fmt.Sprintf("导出%s %d 条（%s 至 %s）", kind.DisplayName(), count, query.From.Format("2006-01-02"), query.To.Format("2006-01-02")))
+}
+
+// exportResponse 写入第一个字节时才设置下载响应头，导出开始前出错仍可返回 JSON
+type exportResponse struct {
+	c           *gin.Context
+	contentType string
+	disposition string
+	started     bool
+}
+
+func (w *exportResponse) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", w.disposition)
+		w.c.Status(http.StatusOK)
+	}
+	return w.c.Writer.Write(p)
+}
+
+// ========================================
+// 群设置 API
+// ========================================
+
+// GetGroupSettingsAPI 获取群设置
+// GET /api/v1/groups/:groupChatID/settings
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetGroupSettingsAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看群设置",
+			"reason": reason,
+		})
+		return
+	}
+
+	groupChatID := c.Param("groupChatID")
+	settings, err := h.taskService.GetGroupSettings(groupChatID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"settings":           settings,
+		"effective_timezone": h.taskService.Location(models.Task{GroupChatID: groupChatID}).String(),
+	})
+}
+
+// GetGroupMembersAPI 获取群成员（从钉钉同步）
+// GET /api/v1/groups/:groupChatID/members
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) GetGroupMembersAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermListTasks,
+	)
+
+	if err != nil || !allowed {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法查看群成员",
+			"reason": reason,
+		})
+		return
+	}
+
+	members, err := h.taskService.GetGroupMembers(c.Param("groupChatID"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"members": members,
+		"count":   len(members),
+	})
+}
+
+// SyncGroupMembersAPI 立即从钉钉同步群成员
+// POST /api/v1/groups/:groupChatID/members/sync
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+func (h *APIHandler) SyncGroupMembersAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法同步群成员",
+			"reason": reason,
+		})
+		return
+	}
+
+	groupChatID := c.Param("groupChatID")
+	count, err := h.taskService.SyncGroupMembers(groupChatID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "同步群成员")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "群成员同步成功",
+		"total":   count,
+	})
+}
+
+// SetGroupSettingsAPI 更新群设置
+// PUT /api/v1/groups/:groupChatID/settings
+// Header: X-Operator-ID (操作者ID，用于权限验证)
+// Body: {"timezone": "Asia/Singapore"}（空字符串表示使用全局默认时区）
+func (h *APIHandler) SetGroupSettingsAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermUpdateTask,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法修改群设置",
+			"reason": reason,
+		})
+		return
+	}
+
+	var req struct {
+		Timezone string `json:"timezone"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "请求参数错误",
+		})
+		return
+	}
+
+	groupChatID := c.Param("groupChatID")
+	if err := h.taskService.SetGroupTimezone(groupChatID, req.Timezone); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermUpdateTask, true, "成功设置群时区")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "群设置已更新",
+		"group_chat_id": groupChatID,
+		"timezone":      req.Timezone,
+	})
+}
+
+// ========================================
+// 节假日日历 API
+// ========================================
+
+// ImportCalendarAPI 导入节假日日历（请求体为 JSON 或 ICS 文件内容）
+func (h *APIHandler) ImportCalendarAPI(c *gin.Context) {
+	operatorID := c.GetHeader("X-Operator-ID")
+	if operatorID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "缺少操作者ID (X-Operator-ID header)",
+		})
+		return
+	}
+
+	// 权限验证
+	allowed, _, reason, err := h.permService.CanExecuteCommand(
+		c.Request.Context(),
+		operatorID,
+		models.PermManageCalendar,
+	)
+
+	if err != nil || !allowed {
+		h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermManageCalendar, false, reason)
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":  "权限不足，无法导入日历",
+			"reason": reason,
+		})
+		return
+	}
+
+	// 格式：优先使用 format 参数，其次根据 Content-Type 判断
+	format := c.Query("format")
+	if format == "" {
+		format = "json"
+		if strings.Contains(c.ContentType(), "calendar") {
+			format = "ics"
+		}
+	}
+
+	data, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": "读取请求内容失败",
+		})
+		return
+	}
+
+	count, err := h.calendarService.Import(format, data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	// 记录审计日志
+	h.permService.LogPermissionCheck(c.Request.Context(), operatorID, models.PermManageCalendar, true, fmt.Sprintf("导入节假日日历 %d 天", count))
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "日历导入成功",
+		"count":   count,
+	})
+}
+
+// ListCalendarAPI 查询节假日日历（默认当年）
+func (h *APIHandler) ListCalendarAPI(c *gin.Context) {
+	year := time.Now().Year()
+	if y := c.Query("year"); y != "" {
+		if _, err := fmt.Sscanf(y, "%d", &year); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error": "年份格式错误",
+			})
+			return
+		}
+	}
+
+	from := time.Date(year, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(year, 12, 31, 0, 0, 0, 0, time.UTC)
+	holidays, err := h.calendarService.ListHolidays(from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"year":     year,
+		"holidays": holidays,
+		"count":    len(holidays),
 	})
 }
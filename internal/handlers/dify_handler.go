@@ -1,9 +1,11 @@
 package handlers
 
 import (
+	"database/sql"
 	"fmt"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 
@@ -15,11 +17,12 @@ import (
 
 // DifyHandler 处理 Dify 回调请求
 type DifyHandler struct {
-	permService  *services.PermissionService
-	taskService  *services.TaskService
-	statsService *services.StatsService
-	sessionStore *SessionStore
-	dtClient     interface {
+	permService       *services.PermissionService
+	taskService       *services.TaskService
+	statsService      *services.StatsService
+	completionService *services.CompletionService
+	sessionStore      *SessionStore
+	dtClient          interface {
 		SendGroupMessage(chatID, content string) error
 	}
 }
@@ -29,16 +32,18 @@ func NewDifyHandler(
 	permService *services.PermissionService,
 	taskService *services.TaskService,
 	statsService *services.StatsService,
+	completionService *services.CompletionService,
 	dtClient interface {
 		SendGroupMessage(chatID, content string) error
 	},
 ) *DifyHandler {
 	return &DifyHandler{
-		permService:  permService,
-		taskService:  taskService,
-		statsService: statsService,
-		sessionStore: NewSessionStore(),
-		dtClient:     dtClient,
+		permService:       permService,
+		taskService:       taskService,
+		statsService:      statsService,
+		completionService: completionService,
+		sessionStore:      NewSessionStore(),
+		dtClient:          dtClient,
 	}
 }
 
@@ -152,10 +157,11 @@ func (h *DifyHandler) Execute(c *gin.Context) {
 		req.ConversationID, session.UserID, req.Action)
 
 	// 2. 验证权限
+	perm := actionPermission(req.Action)
 	allowed, _, reason, err := h.permService.CanExecuteCommand(
 		c.Request.Context(),
 		session.UserID,
-		models.PermissionName(req.Action),
+		perm,
 	)
 
 	if err != nil {
@@ -169,7 +175,7 @@ func (h *DifyHandler) Execute(c *gin.Context) {
 
 	if !allowed {
 		// 记录审计日志
-		h.permService.LogPermissionCheck(c.Request.Context(), session.UserID, models.PermissionName(req.Action), false, reason)
+		h.permService.LogPermissionCheck(c.Request.Context(), session.UserID, perm, false, reason)
 
 		c.JSON(http.StatusOK, DifyExecuteResponse{
 			Success: false,
@@ -180,18 +186,38 @@ func (h *DifyHandler) Execute(c *gin.Context) {
 	}
 
 	// 3. 权限通过，执行操作
-	h.permService.LogPermissionCheck(c.Request.Context(), session.UserID, models.PermissionName(req.Action), true, reason)
+	h.permService.LogPermissionCheck(c.Request.Context(), session.UserID, perm, true, reason)
 
 	// 根据 action 类型分发到具体处理函数
 	switch req.Action {
 	case "create_task":
 		h.handleCreateTask(c, session, req)
+	case "update_task":
+		h.handleUpdateTask(c, session, req)
+	case "pause_task":
+		h.handlePauseTask(c, session, req)
+	case "resume_task":
+		h.handleResumeTask(c, session, req)
+	case "skip_occurrence":
+		h.handleSkipOccurrence(c, session, req)
+	case "snooze_reminder":
+		h.handleSnoozeReminder(c, session, req)
+	case "set_assignees":
+		h.handleSetAssignees(c, session, req)
+	case "add_exemption":
+		h.handleAddExemption(c, session, req)
+	case "set_reminder_exclusion":
+		h.handleSetReminderExclusion(c, session, req)
 	case "delete_task":
 		h.handleDeleteTask(c, session, req)
 	case "list_tasks":
 		h.handleListTasks(c, session, req)
 	case "complete_task":
 		h.handleCompleteTask(c, session, req)
+	case "undo_completion":
+		h.handleUndoCompletion(c, session, req)
+	case "correct_completion":
+		h.handleCorrectCompletion(c, session, req)
 	case "view_stats":
 		h.handleViewStats(c, session, req)
 	case "add_admin":
@@ -207,6 +233,19 @@ func (h *DifyHandler) Execute(c *gin.Context) {
 	}
 }
 
+// actionPermission 操作需要的权限（暂停、恢复、跳过、延后、指派、豁免、免提醒和更正打卡属于修改任务，
+// 撤销打卡属于打卡，其余操作与权限同名）
+func actionPermission(action string) models.PermissionName {
+	switch action {
+	case "pause_task", "resume_task", "skip_occurrence", "snooze_reminder", "set_assignees", "add_exemption",
+		"set_reminder_exclusion", "correct_completion":
+		return models.PermUpdateTask
+	case "undo_completion":
+		return models.PermCompleteTask
+	}
+	return models.PermissionName(action)
+}
+
 // ========================================
 // 具体操作处理函数
 // ========================================
@@ -217,10 +256,12 @@ func (h *DifyHandler) handleCreateTask(c *gin.Context, session *SessionInfo, req
 	cronExpr, _ := req.Params["cron_expr"].(string)
 	taskType, _ := req.Params["type"].(string)
 
-	if name == "" || cronExpr == "" {
+	dueAt, _ := req.Params["due_at"].(string)
+
+	if name == "" || (cronExpr == "" && dueAt == "") {
 		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
 			Success: false,
-			Message: "缺少必要参数: name 或 cron_expr",
+			Message: "缺少必要参数: name 或 cron_expr（单次任务为 due_at）",
 		})
 		return
 	}
@@ -235,6 +276,30 @@ func (h *DifyHandler) handleCreateTask(c *gin.Context, session *SessionInfo, req
 		Status:        models.TaskStatusActive,
 	}
 
+	// 可选的节假日策略：NONE / SKIP_HOLIDAYS / WORKDAYS_ONLY / INCLUDE_MAKEUP
+	if policy, ok := req.Params["calendar_policy"].(string); ok {
+		task.CalendarPolicy = models.CalendarPolicy(policy)
+	}
+
+	// 单次任务的截止时间，或周期任务的起止日期和执行次数
+	if err := h.parseTaskSchedule(task, req.Params); err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "任务时间参数错误",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	// 可选的提醒计划，如 ["-1d 18:00", "-2h", "deadline"]
+	if plan, ok := req.Params["reminder_plan"].([]interface{}); ok {
+		for _, item := range plan {
+			if spec, ok := item.(string); ok {
+				task.ReminderPlan = append(task.ReminderPlan, spec)
+			}
+		}
+	}
+
 	if err := h.taskService.CreateTask(task); err != nil {
 		c.JSON(http.StatusInternalServerError, DifyExecuteResponse{
 			Success: false,
@@ -246,11 +311,340 @@ func (h *DifyHandler) handleCreateTask(c *gin.Context, session *SessionInfo, req
 
 	c.JSON(http.StatusOK, DifyExecuteResponse{
 		Success: true,
-		Message: fmt.Sprintf("✅ 任务创建成功！\n\n📋 名称: %s\n⏰ Cron: %s", task.Name, task.CronExpr),
+		Message: fmt.Sprintf("✅ 任务创建成功！\n\n📋 名称: %s\n⏰ 时间: %s", task.Name, h.taskService.DescribeSchedule(*task)),
+		Data:    task,
+	})
+}
+
+// parseTaskSchedule 解析创建任务的时间参数：timezone、due_at、start_date、end_date、max_occurrences
+func (h *DifyHandler) parseTaskSchedule(task *models.Task, params map[string]interface{}) error {
+	if kind, ok := params["schedule_kind"].(string); ok {
+		task.ScheduleKind = models.ScheduleKind(strings.ToUpper(kind))
+	}
+	if timezone, ok := params["timezone"].(string); ok {
+		task.Timezone = timezone
+	}
+
+	if value, ok := params["due_at"].(string); ok && value != "" {
+		dueAt, err := h.taskService.ParseDueAt(*task, value)
+		if err != nil {
+			return err
+		}
+		task.DueAt = sql.NullTime{Time: dueAt, Valid: true}
+	}
+
+	if value, ok := params["start_date"].(string); ok && value != "" {
+		date, err := h.taskService.ParseTaskDate(*task, value)
+		if err != nil {
+			return err
+		}
+		task.StartDate = sql.NullTime{Time: date, Valid: true}
+	}
+
+	if value, ok := params["end_date"].(string); ok && value != "" {
+		date, err := h.taskService.ParseTaskDate(*task, value)
+		if err != nil {
+			return err
+		}
+		task.EndDate = sql.NullTime{Time: date, Valid: true}
+	}
+
+	if value, ok := params["max_occurrences"].(float64); ok {
+		task.MaxOccurrences = int(value)
+	}
+
+	return nil
+}
+
+func (h *DifyHandler) handleUpdateTask(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	taskID, ok := req.Params["task_id"].(float64)
+	if !ok {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id",
+		})
+		return
+	}
+
+	// 只修改参数中出现的字段
+	var update models.TaskUpdate
+	for key, dest := range map[string]**string{
+		"name":          &update.Name,
+		"description":   &update.Description,
+		"type":          &update.Type,
+		"cron_expr":     &update.CronExpr,
+		"deadline_time": &update.DeadlineTime,
+		"due_at":        &update.DueAt,
+	} {
+		if value, ok := req.Params[key].(string); ok {
+			*dest = &value
+		}
+	}
+	if value, ok := req.Params["advance_minutes"].(float64); ok {
+		minutes := int(value)
+		update.AdvanceMinutes = &minutes
+	}
+
+	task, err := h.taskService.UpdateTask(int(taskID), update)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "修改任务失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("✅ 任务已更新\n\n📋 名称: %s\n⏰ 时间: %s", task.Name, h.taskService.DescribeSchedule(*task)),
 		Data:    task,
 	})
 }
 
+func (h *DifyHandler) handlePauseTask(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	h.changeTaskStatus(c, req, h.taskService.PauseTask, "暂停任务失败", "⏸️ 任务已暂停")
+}
+
+func (h *DifyHandler) handleResumeTask(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	h.changeTaskStatus(c, req, h.taskService.ResumeTask, "恢复任务失败", "▶️ 任务已恢复")
+}
+
+// changeTaskStatus 暂停/恢复任务的公共流程
+func (h *DifyHandler) changeTaskStatus(c *gin.Context, req DifyExecuteRequest, change func(taskID int) error, failure, success string) {
+	taskID, ok := req.Params["task_id"].(float64)
+	if !ok {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id",
+		})
+		return
+	}
+
+	if err := change(int(taskID)); err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: failure,
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: success,
+	})
+}
+
+func (h *DifyHandler) handleSkipOccurrence(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	taskID, ok := req.Params["task_id"].(float64)
+	if !ok {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id",
+		})
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(int(taskID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, DifyExecuteResponse{
+			Success: false,
+			Message: "任务不存在",
+		})
+		return
+	}
+
+	// date 为空时跳过当前周期
+	date, _ := req.Params["date"].(string)
+	reason, _ := req.Params["reason"].(string)
+	taskDate, err := h.taskService.ResolveTaskDate(*task, date)
+	if err == nil {
+		err = h.taskService.SkipOccurrence(task.ID, taskDate, session.UserID, reason)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "跳过失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("⏭️ 已跳过任务 %s 的 %s 这一期", task.Name, taskDate.Format("2006-01-02")),
+	})
+}
+
+func (h *DifyHandler) handleSnoozeReminder(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	taskID, ok := req.Params["task_id"].(float64)
+	minutes, ok2 := req.Params["minutes"].(float64)
+	if !ok || !ok2 {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id 或 minutes",
+		})
+		return
+	}
+
+	reason, _ := req.Params["reason"].(string)
+	if err := h.taskService.SnoozeNextReminder(int(taskID), int(minutes), session.UserID, reason); err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "延后提醒失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("⏰ 下一次提醒已延后 %d 分钟", int(minutes)),
+	})
+}
+
+func (h *DifyHandler) handleSetAssignees(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	taskID, ok := req.Params["task_id"].(float64)
+	if !ok {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id",
+		})
+		return
+	}
+
+	// user_ids 和 department_ids 都为空时恢复为群内所有成员
+	users := stringParams(req.Params["user_ids"])
+	departments := stringParams(req.Params["department_ids"])
+	if err := h.taskService.SetTaskAssignees(int(taskID), users, departments, session.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, DifyExecuteResponse{
+			Success: false,
+			Message: "指派任务失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	message := "✅ 任务已恢复为群内所有成员执行"
+	if len(users)+len(departments) > 0 {
+		message = fmt.Sprintf("✅ 已指派 %d 位成员、%d 个部门负责该任务", len(users), len(departments))
+	}
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+func (h *DifyHandler) handleAddExemption(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	taskID, ok := req.Params["task_id"].(float64)
+	userID, _ := req.Params["user_id"].(string)
+	startDate, _ := req.Params["start_date"].(string)
+	if !ok || userID == "" || startDate == "" {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id、user_id 或 start_date",
+		})
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(int(taskID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, DifyExecuteResponse{
+			Success: false,
+			Message: "任务不存在",
+		})
+		return
+	}
+
+	endDate, _ := req.Params["end_date"].(string)
+	reason, _ := req.Params["reason"].(string)
+	exemption := models.TaskExemption{
+		TaskID:    task.ID,
+		UserID:    userID,
+		Reason:    sql.NullString{String: reason, Valid: reason != ""},
+		CreatedBy: session.UserID,
+	}
+	exemption.StartDate, err = h.taskService.ParseTaskDate(*task, startDate)
+	if err == nil && endDate != "" {
+		exemption.EndDate, err = h.taskService.ParseTaskDate(*task, endDate)
+	}
+	var created *models.TaskExemption
+	if err == nil {
+		created, err = h.taskService.AddTaskExemption(exemption)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "登记豁免失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("✅ 已豁免该成员 %s ~ %s 执行任务 %s", created.StartDate.Format("2006-01-02"), created.EndDate.Format("2006-01-02"), task.Name),
+	})
+}
+
+func (h *DifyHandler) handleSetReminderExclusion(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	userID, _ := req.Params["user_id"].(string)
+	excluded, ok := req.Params["excluded"].(bool)
+	if userID == "" || !ok {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: user_id 或 excluded",
+		})
+		return
+	}
+
+	// 默认只对当前群生效，global=true 时对所有群生效
+	groupChatID := session.GroupChatID
+	if global, _ := req.Params["global"].(bool); global {
+		groupChatID = ""
+	}
+	reason, _ := req.Params["reason"].(string)
+
+	if err := h.taskService.SetReminderExclusion(userID, groupChatID, excluded, reason, session.UserID); err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "修改免提醒设置失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	message := "🔔 已恢复提醒该成员"
+	if excluded {
+		message = "🔕 已设置该成员免提醒，不再被 @，也不计入完成率"
+	}
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: message,
+	})
+}
+
+// stringParams 将 Dify 传入的字符串数组参数转换为 []string（也接受逗号分隔的字符串）
+func stringParams(value interface{}) []string {
+	var result []string
+	switch v := value.(type) {
+	case []interface{}:
+		for _, item := range v {
+			if str, ok := item.(string); ok && strings.TrimSpace(str) != "" {
+				result = append(result, strings.TrimSpace(str))
+			}
+		}
+	case string:
+		for _, str := range strings.Split(v, ",") {
+			if str = strings.TrimSpace(str); str != "" {
+				result = append(result, str)
+			}
+		}
+	}
+	return result
+}
+
 func (h *DifyHandler) handleDeleteTask(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
 	taskID, ok := req.Params["task_id"].(float64)
 	if !ok {
@@ -304,47 +698,165 @@ func (h *DifyHandler) handleCompleteTask(c *gin.Context, session *SessionInfo, r
 		return
 	}
 
-	// 检查是否已打卡
-	completed, err := h.taskService.HasCompletedToday(int(taskID), session.UserID)
+	// 只能为当前群的任务打卡
+	task, err := h.taskService.GetTaskByID(int(taskID))
+	if err != nil || task.GroupChatID != session.GroupChatID {
+		c.JSON(http.StatusNotFound, DifyExecuteResponse{
+			Success: false,
+			Message: "任务不存在",
+		})
+		return
+	}
+
+	// 记到当前这一期，按任务时区判断是否按时
+	record, err := h.completionService.Complete(*task, time.Time{}, session.UserID, session.Username)
+	if err == services.ErrAlreadyCompleted {
+		c.JSON(http.StatusOK, DifyExecuteResponse{
+			Success: false,
+			Message: "✅ 您本期已经打过卡了！",
+		})
+		return
+	}
+	if err == services.ErrTaskNotActive {
+		c.JSON(http.StatusOK, DifyExecuteResponse{
+			Success: false,
+			Message: fmt.Sprintf("❌ 任务 %s 已不在进行中", task.Name),
+		})
+		return
+	}
+	if err == services.ErrNotAssigned {
+		c.JSON(http.StatusOK, DifyExecuteResponse{
+			Success: false,
+			Message: fmt.Sprintf("❌ 您不是任务 %s 的负责人", task.Name),
+		})
+		return
+	}
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, DifyExecuteResponse{
 			Success: false,
-			Message: "检查打卡状态失败",
+			Message: "打卡失败",
 		})
 		return
 	}
 
-	if completed {
-		c.JSON(http.StatusOK, DifyExecuteResponse{
+	// 可选的提交内容（说明文字，其中的链接单独记录）
+	content, _ := req.Params["content"].(string)
+	if err := h.taskService.AddSubmissions(record, services.ParseSubmissions(content, nil)); err != nil {
+		c.JSON(http.StatusInternalServerError, DifyExecuteResponse{
 			Success: false,
-			Message: "✅ 您今天已经打过卡了！",
+			Message: "打卡成功，但提交内容保存失败",
+			Data:    record,
 		})
 		return
 	}
 
-	// 记录打卡
-	record := &models.CompletionRecord{
-		TaskID:      int(taskID),
-		UserID:      session.UserID,
-		GroupChatID: session.GroupChatID,
-		IsOnTime:    true,
+	message := "✅ 打卡成功！"
+	if !record.IsOnTime {
+		message = "⏰ 打卡成功（已过截止时间）！"
 	}
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: message,
+		Data:    record,
+	})
+}
 
-	if err := h.taskService.RecordCompletion(record); err != nil {
-		c.JSON(http.StatusInternalServerError, DifyExecuteResponse{
+// handleUndoCompletion 撤销自己最近一次打卡（task_id 可选，不指定时为本群所有进行中的任务）
+func (h *DifyHandler) handleUndoCompletion(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	var tasks []models.Task
+	if taskID, ok := req.Params["task_id"].(float64); ok {
+		task, err := h.taskService.GetTaskByID(int(taskID))
+		if err != nil {
+			c.JSON(http.StatusNotFound, DifyExecuteResponse{
+				Success: false,
+				Message: "任务不存在",
+			})
+			return
+		}
+		tasks = []models.Task{*task}
+	} else {
+		var err error
+		tasks, err = h.taskService.GetActiveTasksByGroup(session.GroupChatID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, DifyExecuteResponse{
+				Success: false,
+				Message: "查询任务失败",
+			})
+			return
+		}
+	}
+
+	task, record, err := h.completionService.Undo(tasks, session.UserID)
+	if err != nil {
+		c.JSON(http.StatusOK, DifyExecuteResponse{
 			Success: false,
-			Message: "打卡失败",
+			Message: "撤销打卡失败",
+			Reason:  err.Error(),
 		})
 		return
 	}
 
 	c.JSON(http.StatusOK, DifyExecuteResponse{
 		Success: true,
-		Message: "✅ 打卡成功！",
+		Message: fmt.Sprintf("↩️ 已撤销 %s（%s）的打卡", task.Name, record.TaskDate.Format("2006-01-02")),
 		Data:    record,
 	})
 }
 
+// handleCorrectCompletion 管理员更正成员某一期的打卡：completed 为 true 时补卡，为 false 时删除打卡
+func (h *DifyHandler) handleCorrectCompletion(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
+	taskID, ok := req.Params["task_id"].(float64)
+	userID, _ := req.Params["user_id"].(string)
+	completed, hasCompleted := req.Params["completed"].(bool)
+	if !ok || userID == "" || !hasCompleted {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "缺少参数: task_id、user_id 或 completed",
+		})
+		return
+	}
+
+	task, err := h.taskService.GetTaskByID(int(taskID))
+	if err != nil {
+		c.JSON(http.StatusNotFound, DifyExecuteResponse{
+			Success: false,
+			Message: "任务不存在",
+		})
+		return
+	}
+
+	date, _ := req.Params["date"].(string)
+	reason, _ := req.Params["reason"].(string)
+	onTime, hasOnTime := req.Params["on_time"].(bool)
+	if !hasOnTime {
+		onTime = true
+	}
+
+	taskDate, err := h.taskService.ResolveTaskDate(*task, date)
+	var audit *models.CompletionAudit
+	if err == nil {
+		audit, err = h.completionService.Correct(*task, userID, taskDate, completed, onTime, session.UserID, reason)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, DifyExecuteResponse{
+			Success: false,
+			Message: "更正打卡失败",
+			Reason:  err.Error(),
+		})
+		return
+	}
+
+	state := "已完成"
+	if !completed {
+		state = "未完成"
+	}
+	c.JSON(http.StatusOK, DifyExecuteResponse{
+		Success: true,
+		Message: fmt.Sprintf("✏️ 已将该成员 %s（%s）更正为%s", task.Name, taskDate.Format("2006-01-02"), state),
+		Data:    audit,
+	})
+}
+
 func (h *DifyHandler) handleViewStats(c *gin.Context, session *SessionInfo, req DifyExecuteRequest) {
 	taskID, ok := req.Params["task_id"].(float64)
 	if !ok {
@@ -9,9 +9,13 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"os"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"dingteam-bot/internal/config"
 	"dingteam-bot/internal/dingtalk"
@@ -20,12 +24,16 @@ import (
 )
 
 type MessageHandler struct {
-	cfg          *config.Config
-	taskService  *services.TaskService
-	statsService *services.StatsService
-	permService  *services.PermissionService
-	dtClient     *dingtalk.Client
-	difyHandler  *DifyHandler
+	cfg               *config.Config
+	taskService       *services.TaskService
+	statsService      *services.StatsService
+	permService       *services.PermissionService
+	cardService       *services.ReminderCardService
+	completionService *services.CompletionService
+	digestService     *services.DigestService
+	exportService     *services.ExportService
+	dtClient          *dingtalk.Client
+	difyHandler       *DifyHandler
 }
 
 func NewMessageHandler(
@@ -33,26 +41,42 @@ func NewMessageHandler(
 	taskService *services.TaskService,
 	statsService *services.StatsService,
 	permService *services.PermissionService,
+	cardService *services.ReminderCardService,
+	completionService *services.CompletionService,
+	digestService *services.DigestService,
+	exportService *services.ExportService,
 	dtClient *dingtalk.Client,
 	difyHandler *DifyHandler,
 ) *MessageHandler {
 	return &MessageHandler{
-		cfg:          cfg,
-		taskService:  taskService,
-		statsService: statsService,
-		permService:  permService,
-		dtClient:     dtClient,
-		difyHandler:  difyHandler,
+		cfg:               cfg,
+		taskService:       taskService,
+		statsService:      statsService,
+		permService:       permService,
+		cardService:       cardService,
+		completionService: completionService,
+		digestService:     digestService,
+		exportService:     exportService,
+		dtClient:          dtClient,
+		difyHandler:       difyHandler,
 	}
 }
 
 // 处理群消息
 func (h *MessageHandler) HandleMessage(ctx context.Context, msg *dingtalk.IncomingMessage) error {
-	// 只处理 @ 机器人的消息
-	if !msg.IsInAtList {
+	// 只处理 @ 机器人的消息；图片、文件消息无法 @ 机器人，可能是打卡的提交内容
+	if !msg.IsInAtList && len(msg.Attachments) == 0 {
 		return nil
 	}
 
+	// 提取纯文本内容（去除 @机器人 部分）
+	content := h.extractContent(msg.Text.Content)
+	content = strings.TrimSpace(content)
+
+	if !msg.IsInAtList {
+		return h.handleUnmentionedAttachments(msg, content)
+	}
+
 	// 注册会话信息（供 Dify 后续调用时使用）
 	if h.difyHandler != nil {
 		h.difyHandler.RegisterSession(
@@ -63,12 +87,13 @@ func (h *MessageHandler) HandleMessage(ctx context.Context, msg *dingtalk.Incomi
 		)
 	}
 
-	// 提取纯文本内容（去除 @机器人 部分）
-	content := h.extractContent(msg.Text.Content)
-	content = strings.TrimSpace(content)
-
 	log.Printf("处理指令: %s (来自 %s)", content, msg.SenderNick)
 
+	// 附带图片、文件的打卡在本地处理（Dify 无法接收附件）
+	if len(msg.Attachments) > 0 && (content == "" || isCompletionCommand(content)) {
+		return h.handleCompletion(msg, content)
+	}
+
 	// 如果启用了 Dify，则转发给 Dify 处理
 	if h.cfg.Dify.Enabled {
 		return h.forwardToDify(ctx, msg, content)
@@ -94,237 +119,2108 @@ func (h *MessageHandler) forwardToDify(ctx context.Context, msg *dingtalk.Incomi
 		"user":          msg.SenderNick,
 	}
 
-	data, err := json.Marshal(payload)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("序列化 Dify 请求失败: %v", err)
+		return h.sendReply(msg, "❌ 消息处理失败")
+	}
+
+	// 发送请求到 Dify 工作流
+	req, err := http.NewRequestWithContext(ctx, "POST", h.cfg.Dify.WebhookURL, bytes.NewBuffer(data))
+	if err != nil {
+		log.Printf("创建 Dify 请求失败: %v", err)
+		return h.sendReply(msg, "❌ 消息处理失败")
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+h.cfg.Dify.APIKey)
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		log.Printf("调用 Dify API 失败: %v", err)
+		return h.sendReply(msg, "❌ 消息处理失败")
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("Dify API 返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return h.sendReply(msg, "❌ 消息处理失败")
+	}
+
+	// 解析 Dify 工作流响应
+	// 工作流响应格式：{"data": {"outputs": {"reply_polisher": "..."}}, ...}
+	var difyResp struct {
+		TaskID        string `json:"task_id"`
+		WorkflowRunID string `json:"workflow_run_id"`
+		Data          struct {
+			ID      string                 `json:"id"`
+			Status  string                 `json:"status"`
+			Outputs map[string]interface{} `json:"outputs"`
+			Error   string                 `json:"error"`
+		} `json:"data"`
+	}
+
+	if err := json.Unmarshal(body, &difyResp); err != nil {
+		log.Printf("解析 Dify 响应失败: %v, 响应: %s", err, string(body))
+		return h.sendReply(msg, "❌ 消息处理失败")
+	}
+
+	// 检查工作流执行状态
+	if difyResp.Data.Status != "succeeded" {
+		log.Printf("Dify 工作流执行失败: status=%s, error=%s", difyResp.Data.Status, difyResp.Data.Error)
+		return h.sendReply(msg, "❌ 消息处理失败")
+	}
+
+	// 提取工作流输出中的回复内容
+	// 尝试从常见的输出字段中提取回复
+	var reply string
+	for key, value := range difyResp.Data.Outputs {
+		// 常见的回复字段名：reply, reply_polisher, text, answer 等
+		if str, ok := value.(string); ok && str != "" {
+			reply = str
+			log.Printf("从工作流输出字段 '%s' 中提取到回复", key)
+			break
+		}
+	}
+
+	// 如果 Dify 返回了回复，则发送给用户
+	if reply != "" {
+		return h.sendReply(msg, reply)
+	}
+
+	// 如果没有回复，表示 Dify 可能已经通过工具调用处理了请求
+	log.Printf("Dify 工作流处理完成，无直接回复")
+	return nil
+}
+
+// legacyPrefixCommands 按前缀匹配的传统命令（较长的前缀在前），值为命令名称（别名映射到同一个命令）
+var legacyPrefixCommands = []struct {
+	prefix  string
+	command string
+}{
+	{"撤销打卡", "撤销打卡"},
+	{"补卡", "补卡"},
+	{"删除打卡", "删除打卡"},
+	{"请假名单", "请假名单"},
+	{"取消请假", "取消请假"},
+	{"请假", "请假"},
+	{"导出", "导出"},
+	{"排行榜", "排行榜"},
+	{"创建单次任务", "创建单次任务"},
+	{"创建任务", "创建任务"},
+	{"新建任务", "创建任务"},
+	{"修改任务", "修改任务"},
+	{"编辑任务", "修改任务"},
+	{"暂停任务", "暂停任务"},
+	{"恢复任务", "恢复任务"},
+	{"取消跳过", "取消跳过"},
+	{"跳过", "跳过"},
+	{"延后提醒", "延后提醒"},
+	{"推迟提醒", "延后提醒"},
+	{"指派任务", "指派任务"},
+	{"任务成员", "任务成员"},
+	{"取消豁免", "取消豁免"},
+	{"豁免", "豁免"},
+	{"提醒计划", "提醒计划"},
+	{"节假日策略", "节假日策略"},
+	{"任务时区", "任务时区"},
+	{"群时区", "群时区"},
+	{"同步群成员", "同步群成员"},
+	{"免提醒名单", "免提醒名单"},
+	{"免提醒", "免提醒"},
+	{"恢复提醒", "恢复提醒"},
+	{"定时汇总", "定时汇总"},
+	{"汇总列表", "汇总列表"},
+	{"删除汇总", "删除汇总"},
+	{"发送汇总", "发送汇总"},
+	{"添加管理员", "添加管理员"},
+	{"提升管理员", "添加管理员"},
+	{"移除管理员", "移除管理员"},
+	{"降级管理员", "移除管理员"},
+}
+
+// legacyCommand 返回消息内容对应的传统命令名称，无法识别时返回空字符串
+// 按前缀匹配的命令先于关键词匹配：任务名称、字段值或原因中可能包含"已完成""报告"等词
+func legacyCommand(content string) string {
+	for _, c := range legacyPrefixCommands {
+		if strings.HasPrefix(content, c.prefix) {
+			return c.command
+		}
+	}
+
+	switch {
+	case isCompletionCommand(content):
+		return "已完成"
+	case strings.Contains(content, "统计") || strings.Contains(content, "报告"):
+		return "统计"
+	case strings.Contains(content, "任务列表") || strings.Contains(content, "查看任务"):
+		return "任务列表"
+	case strings.Contains(content, "管理员列表"):
+		return "管理员列表"
+	case strings.Contains(content, "我的权限"):
+		return "我的权限"
+	case strings.Contains(content, "帮助") || content == "?":
+		return "帮助"
+	}
+	return ""
+}
+
+// handleLegacyCommand 处理传统命令（兜底方案）
+func (h *MessageHandler) handleLegacyCommand(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	switch legacyCommand(content) {
+	case "撤销打卡":
+		return h.handleUndoCompletion(msg, content)
+	case "补卡":
+		return h.handleCorrectCompletion(ctx, msg, content, "补卡", true)
+	case "删除打卡":
+		return h.handleCorrectCompletion(ctx, msg, content, "删除打卡", false)
+	case "请假名单":
+		return h.handleListLeaves(msg)
+	case "取消请假":
+		return h.handleCancelLeave(ctx, msg, content)
+	case "请假":
+		return h.handleLeave(ctx, msg, content)
+	case "导出":
+		return h.handleExport(ctx, msg, content)
+	case "排行榜":
+		return h.handleLeaderboard(msg, content)
+	case "创建单次任务":
+		return h.handleCreateOnceTask(msg, content)
+	case "创建任务":
+		return h.handleCreateTask(msg, content)
+	case "修改任务":
+		return h.handleUpdateTask(ctx, msg, content)
+	case "暂停任务":
+		return h.handlePauseTask(ctx, msg, content)
+	case "恢复任务":
+		return h.handleResumeTask(ctx, msg, content)
+	case "取消跳过":
+		return h.handleCancelSkip(ctx, msg, content)
+	case "跳过":
+		return h.handleSkipOccurrence(ctx, msg, content)
+	case "延后提醒":
+		return h.handleSnoozeReminder(ctx, msg, content)
+	case "指派任务":
+		return h.handleAssignTask(ctx, msg, content)
+	case "任务成员":
+		return h.handleTaskMembers(msg, content)
+	case "取消豁免":
+		return h.handleCancelExemption(ctx, msg, content)
+	case "豁免":
+		return h.handleAddExemption(ctx, msg, content)
+	case "提醒计划":
+		return h.handleReminderPlan(ctx, msg, content)
+	case "节假日策略":
+		return h.handleCalendarPolicy(ctx, msg, content)
+	case "任务时区":
+		return h.handleTaskTimezone(ctx, msg, content)
+	case "群时区":
+		return h.handleGroupTimezone(ctx, msg, content)
+	case "同步群成员":
+		return h.handleSyncGroupMembers(ctx, msg)
+	case "免提醒名单":
+		return h.handleListReminderExclusions(msg)
+	case "免提醒":
+		return h.handleSetReminderExclusion(ctx, msg, content, "免提醒", true)
+	case "恢复提醒":
+		return h.handleSetReminderExclusion(ctx, msg, content, "恢复提醒", false)
+	case "定时汇总":
+		return h.handleCreateDigest(ctx, msg, content)
+	case "汇总列表":
+		return h.handleListDigests(msg)
+	case "删除汇总":
+		return h.handleDigestByID(ctx, msg, content, "删除汇总")
+	case "发送汇总":
+		return h.handleDigestByID(ctx, msg, content, "发送汇总")
+	case "添加管理员":
+		return h.handlePromoteAdmin(ctx, msg, content)
+	case "移除管理员":
+		return h.handleDemoteAdmin(ctx, msg, content)
+	case "已完成":
+		return h.handleCompletion(msg, content)
+	case "统计":
+		return h.handleStats(msg, content)
+	case "任务列表":
+		return h.handleListTasks(msg)
+	case "管理员列表":
+		return h.handleListAdmins(ctx, msg)
+	case "我的权限":
+		return h.handleMyPermissions(ctx, msg)
+	case "帮助":
+		return h.handleHelp(msg)
+	default:
+		return h.sendReply(msg, "❓ 未识别的命令，发送「帮助」查看可用指令")
+	}
+}
+
+// 任务选择卡片上查看统计的按钮 ID 前缀（打卡按钮与提醒卡片共用 services.CardActionCheckIn）
+const cardActionStats = "stats"
+
+// isCompletionCommand 是否为打卡命令
+func isCompletionCommand(content string) bool {
+	return strings.Contains(content, "已完成") || strings.Contains(content, "我已提交")
+}
+
+// 处理打卡
+// 格式: 已完成 [任务名称|#任务ID] [说明或链接]，可以附带图片、文件作为提交内容
+// 群内有多个任务且无法确定时回复卡片供选择（附带提交内容时改为文字提示，避免内容丢失）
+func (h *MessageHandler) handleCompletion(msg *dingtalk.IncomingMessage, content string) error {
+	tasks, err := h.groupTasks(msg)
+	if tasks == nil {
+		return err
+	}
+	return h.checkIn(msg, tasks, content)
+}
+
+// handleUnmentionedAttachments 处理未 @ 机器人的图片、文件消息：带"已完成"时按打卡处理；
+// 否则只在发送者有今天到期或已逾期、尚未完成的任务时作为这些任务的打卡，普通的文件分享不处理
+func (h *MessageHandler) handleUnmentionedAttachments(msg *dingtalk.IncomingMessage, content string) error {
+	if isCompletionCommand(content) {
+		log.Printf("处理附件打卡: %s (来自 %s)", content, msg.SenderNick)
+		return h.handleCompletion(msg, content)
+	}
+
+	tasks, err := h.pendingTasks(msg.ConversationID, msg.SenderStaffID)
+	if err != nil {
+		log.Printf("查询待完成任务失败: %v", err)
+		return nil
+	}
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	log.Printf("作为待完成任务的打卡处理附件 (来自 %s)", msg.SenderNick)
+	return h.checkIn(msg, tasks, content)
+}
+
+// pendingTasks 成员在群内今天到期或已逾期、需要执行且尚未完成的任务
+func (h *MessageHandler) pendingTasks(groupChatID, userID string) ([]models.Task, error) {
+	tasks, err := h.taskService.GetActiveTasksByGroup(groupChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	var pending []models.Task
+	for _, task := range tasks {
+		if task.Type != models.TaskTypeTask {
+			continue
+		}
+		taskDate := h.taskService.TaskDate(task, now)
+		if taskDate.After(services.StartOfToday(h.taskService.Location(task))) {
+			continue
+		}
+
+		userIDs, err := h.taskService.GetResponsibleUserIDs(task, taskDate)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(userIDs, userID) {
+			continue
+		}
+		completed, err := h.taskService.HasCompleted(task.ID, userID, taskDate)
+		if err != nil {
+			return nil, err
+		}
+		if !completed {
+			pending = append(pending, task)
+		}
+	}
+	return pending, nil
+}
+
+// checkIn 从 tasks 中选出要打卡的任务，为消息发送者记录打卡和提交内容
+func (h *MessageHandler) checkIn(msg *dingtalk.IncomingMessage, tasks []models.Task, content string) error {
+	selector, note := splitCheckIn(tasks, removeWords(content, "已完成", "我已提交"), len(msg.Attachments) > 0)
+	submissions := services.ParseSubmissions(note, msg.Attachments)
+
+	task, err := h.chooseTask(msg, tasks, selector, services.CardActionCheckIn, "选择要打卡的任务", len(submissions) == 0)
+	if task == nil {
+		return err
+	}
+
+	// 本次打卡所属的任务日期（周任务记到本周的触发日，逾期未交的上一期在下一期提醒开始前仍可补交）
+	taskDate, err := h.taskService.CheckInDate(*task, msg.SenderStaffID, time.Now())
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 检查打卡状态失败: %v", err))
+	}
+	return h.completeTask(msg, *task, taskDate, submissions)
+}
+
+// splitCheckIn 将打卡命令的参数拆分为任务选择和提交内容：第一个词能确定任务时作为任务选择，其余为说明；
+// 否则只有一个任务、附带附件、链接或有多个词时全部作为说明，只有一个词时仍按任务名称匹配
+func splitCheckIn(tasks []models.Task, args string, hasAttachments bool) (selector, note string) {
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return "", ""
+	}
+
+	first := fields[0]
+	isLink := strings.HasPrefix(first, "http://") || strings.HasPrefix(first, "https://")
+	if !isLink && len(services.MatchTasks(tasks, first)) == 1 {
+		return first, strings.TrimSpace(strings.TrimPrefix(args, first))
+	}
+	if len(tasks) == 1 || hasAttachments || isLink || len(fields) > 1 {
+		return "", args
+	}
+	return args, ""
+}
+
+// completeTask 为消息发送者记录任务在 taskDate 这一期的打卡（附带提交内容）
+// 本期已打卡时，提交内容追加到已有的打卡记录
+func (h *MessageHandler) completeTask(msg *dingtalk.IncomingMessage, task models.Task, taskDate time.Time, submissions []models.Submission) error {
+	// 检查是否已打卡
+	completionID, err := h.taskService.GetCompletionID(task.ID, msg.SenderStaffID, taskDate)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 检查打卡状态失败: %v", err))
+	}
+
+	if completionID != 0 {
+		if len(submissions) == 0 {
+			return h.sendReply(msg, fmt.Sprintf("✅ 您本期已经完成过 %s 了！", task.Name))
+		}
+		record := &models.CompletionRecord{ID: completionID, TaskID: task.ID, UserID: msg.SenderStaffID, TaskDate: taskDate}
+		if err := h.taskService.AddSubmissions(record, submissions); err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+		}
+		return h.sendReply(msg, fmt.Sprintf("📎 已为 %s 补充 %d 条提交内容", task.Name, len(submissions)))
+	}
+
+	// 按任务时区判断这一期是否按时（截止后的宽限时间内仍算按时）
+	// 是否为任务的负责人由 Complete 检查
+	record, err := h.completionService.Complete(task, taskDate, msg.SenderStaffID, msg.SenderNick)
+	if err == services.ErrNotAssigned {
+		return h.sendReply(msg, fmt.Sprintf("❌ %s 不是任务 %s 的负责人", h.senderName(msg), task.Name))
+	}
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 打卡失败: %v", err))
+	}
+
+	reply := fmt.Sprintf("✅ 打卡成功！任务: %s", task.Name)
+	if !record.IsOnTime {
+		reply = fmt.Sprintf("⏰ 打卡成功（已过截止时间）！任务: %s", task.Name)
+	}
+
+	if len(submissions) > 0 {
+		if err := h.taskService.AddSubmissions(record, submissions); err != nil {
+			log.Printf("保存提交内容失败: %v", err)
+			reply += "\n⚠️ 提交内容保存失败，请重新发送: 已完成 #" + strconv.Itoa(task.ID) + " <内容>"
+		} else {
+			reply += fmt.Sprintf("（附 %d 条提交内容）", len(submissions))
+		}
+	}
+
+	return h.sendReply(msg, reply)
+}
+
+// 处理撤销打卡：撤销自己最近的一次打卡（打卡后 CHECKIN_UNDO_WINDOW 内有效）
+// 格式: 撤销打卡 [任务名称|#任务ID]
+func (h *MessageHandler) handleUndoCompletion(msg *dingtalk.IncomingMessage, content string) error {
+	tasks, err := h.groupTasks(msg)
+	if tasks == nil {
+		return err
+	}
+
+	if selector := removeWords(content, "撤销打卡"); selector != "" {
+		tasks = services.MatchTasks(tasks, selector)
+		if len(tasks) == 0 {
+			return h.sendReply(msg, fmt.Sprintf("❌ 未找到任务「%s」", selector))
+		}
+	}
+
+	task, record, err := h.completionService.Undo(tasks, msg.SenderStaffID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	return h.sendReply(msg, fmt.Sprintf("↩️ 已撤销 %s 在 %s（%s）的打卡",
+		h.senderName(msg), task.Name, record.TaskDate.Format("2006-01-02")))
+}
+
+// 处理管理员更正打卡：补卡记为已完成（带"迟交"记为未按时），删除打卡改为未完成
+// 格式: 补卡 <名称> @用户... [日期] [迟交] [原因] / 删除打卡 <名称> @用户... [日期] [原因]
+// 例如: 补卡 写日报 @张三 2026-10-15 线下已提交
+func (h *MessageHandler) handleCorrectCompletion(ctx context.Context, msg *dingtalk.IncomingMessage, content, command string, completed bool) error {
+	usage := "格式: 补卡 <名称> @用户 [日期] [迟交] [原因] / 删除打卡 <名称> @用户 [日期] [原因]\n例: 补卡 写日报 @张三 2026-10-15 线下已提交"
+	fields := strings.Fields(strings.TrimPrefix(content, command))
+	users := mentionedUserIDs(msg)
+	if len(fields) == 0 || len(users) == 0 {
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以更正打卡")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	// 未指定日期时为当前这一期，日期按所属周期换算（周任务记到该周的触发日）
+	taskDate := h.taskService.TaskDate(*task, time.Now())
+	rest := fields[1:]
+	if len(rest) > 0 {
+		if date, err := h.taskService.ResolveTaskDate(*task, rest[0]); err == nil {
+			taskDate = date
+			rest = rest[1:]
+		}
+	}
+	onTime := true
+	if completed && len(rest) > 0 && rest[0] == "迟交" {
+		onTime = false
+		rest = rest[1:]
+	}
+	correctReason := strings.Join(rest, " ")
+
+	var failures []string
+	for _, userID := range users {
+		if _, err := h.completionService.Correct(*task, userID, taskDate, completed, onTime, msg.SenderStaffID, correctReason); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", h.displayNameOr(userID), err))
+		}
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, command)
+
+	done := "已完成"
+	if !completed {
+		done = "未完成"
+	} else if !onTime {
+		done = "已完成（未按时）"
+	}
+	if len(failures) == len(users) {
+		return h.sendReply(msg, "❌ 更正打卡失败:\n- "+strings.Join(failures, "\n- "))
+	}
+	reply := fmt.Sprintf("✏️ 已将 %d 人的 %s（%s）更正为%s", len(users)-len(failures), task.Name, taskDate.Format("2006-01-02"), done)
+	if len(failures) > 0 {
+		reply += "\n\n❌ 以下成员未更正:\n- " + strings.Join(failures, "\n- ")
+	}
+	return h.sendReply(msg, reply)
+}
+
+// 处理统计查询
+// 格式: 统计 [任务名称|#任务ID]
+func (h *MessageHandler) handleStats(msg *dingtalk.IncomingMessage, content string) error {
+	selector := removeWords(content, "统计", "报告", "查看", "本周", "今日", "今天")
+	task, err := h.selectGroupTask(msg, selector, cardActionStats, "选择要查看统计的任务")
+	if task == nil {
+		return err
+	}
+	return h.sendTaskStats(msg, *task)
+}
+
+// sendTaskStats 发送任务本期的统计报告
+func (h *MessageHandler) sendTaskStats(msg *dingtalk.IncomingMessage, task models.Task) error {
+	stats, err := h.statsService.GetTodayStats(task.ID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 获取统计失败: %v", err))
+	}
+
+	report := h.statsService.FormatStatsReport(stats)
+	return h.sendReply(msg, report)
+}
+
+// selectGroupTask 按名称或ID从本群的活跃任务中选择一个
+// 只有一个匹配时返回该任务；否则已回复（无任务、或发送候选任务卡片），返回 nil 和发送结果
+func (h *MessageHandler) selectGroupTask(msg *dingtalk.IncomingMessage, selector, action, title string) (*models.Task, error) {
+	tasks, err := h.groupTasks(msg)
+	if tasks == nil {
+		return nil, err
+	}
+	return h.chooseTask(msg, tasks, selector, action, title, true)
+}
+
+// groupTasks 本群的活跃任务；没有任务时已回复，返回 nil 和发送结果
+func (h *MessageHandler) groupTasks(msg *dingtalk.IncomingMessage) ([]models.Task, error) {
+	tasks, err := h.taskService.GetActiveTasksByGroup(msg.ConversationID)
+	if err != nil {
+		return nil, h.sendReply(msg, fmt.Sprintf("❌ 查询任务失败: %v", err))
+	}
+
+	if len(tasks) == 0 {
+		return nil, h.sendReply(msg, "❌ 当前群没有活跃的任务")
+	}
+	return tasks, nil
+}
+
+// chooseTask 从任务中选出与 selector 唯一匹配的任务；无法确定时回复候选任务（useCard 为 false 时只发文字提示）
+func (h *MessageHandler) chooseTask(msg *dingtalk.IncomingMessage, tasks []models.Task, selector, action, title string, useCard bool) (*models.Task, error) {
+	candidates := services.MatchTasks(tasks, selector)
+	if len(candidates) == 1 {
+		return &candidates[0], nil
+	}
+
+	text := "本群有多个任务，请选择："
+	if len(candidates) == 0 {
+		text = fmt.Sprintf("未找到任务「%s」，请选择：", selector)
+		candidates = tasks
+	}
+	if !useCard {
+		return nil, h.sendReply(msg, taskChoiceText(text, h.taskChoiceLines(candidates), "已完成 #任务ID <说明或链接>，并重新附上图片或文件"))
+	}
+	return nil, h.sendTaskChoiceCard(msg, action, title, text, candidates)
+}
+
+// sendTaskChoiceCard 发送候选任务卡片，点击后由 HandleCardCallback 处理；卡片发送失败时改为文字提示
+func (h *MessageHandler) sendTaskChoiceCard(msg *dingtalk.IncomingMessage, action, title, text string, tasks []models.Task) error {
+	actions := make([]dingtalk.CardAction, len(tasks))
+	for i, task := range tasks {
+		actions[i] = dingtalk.CardAction{
+			ID:   fmt.Sprintf("%s:%d", action, task.ID),
+			Text: task.Name,
+		}
+	}
+	lines := h.taskChoiceLines(tasks)
+
+	err := h.dtClient.SendInteractiveCard(msg.ConversationID, dingtalk.NewOutTrackID(action), title, text+"\n\n"+lines, actions)
+	if err == nil {
+		return nil
+	}
+	log.Printf("发送任务选择卡片失败，改为文字提示: %v", err)
+
+	command := "已完成"
+	if action == cardActionStats {
+		command = "统计"
+	}
+	return h.sendReply(msg, taskChoiceText(text, lines, fmt.Sprintf("%s #任务ID 或 %s <任务名称>", command, command)))
+}
+
+// taskChoiceLines 候选任务列表（每行一个任务）
+func (h *MessageHandler) taskChoiceLines(tasks []models.Task) string {
+	var lines strings.Builder
+	for _, task := range tasks {
+		lines.WriteString(fmt.Sprintf("- #%d %s（%s）\n", task.ID, task.Name, h.taskService.DescribeSchedule(task)))
+	}
+	return lines.String()
+}
+
+// taskChoiceText 以文字列出候选任务，并提示如何指定任务
+func taskChoiceText(text, lines, usage string) string {
+	return fmt.Sprintf("❓ %s\n\n%s\n请发送: %s", text, lines, usage)
+}
+
+// removeWords 去掉内容中的命令关键词，剩余部分作为参数
+func removeWords(content string, words ...string) string {
+	for _, word := range words {
+		content = strings.ReplaceAll(content, word, " ")
+	}
+	return strings.TrimSpace(content)
+}
+
+// 处理创建任务
+func (h *MessageHandler) handleCreateTask(msg *dingtalk.IncomingMessage, content string) error {
+	// 检查权限
+	if !h.cfg.IsAdmin(msg.SenderStaffID) {
+		return h.sendReply(msg, "❌ 只有管理员可以创建任务")
+	}
+
+	// 解析命令
+	// 格式: 创建任务 <名称> <cron表达式> [截止时间] [类型]
+	// 例如: 创建任务 写周报 0 17 * * 5 15:00 TASK
+	task, err := h.parseCreateTaskCommand(content, msg)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 解析命令失败: %v\n\n格式: 创建任务 <名称> <cron表达式> [截止时间] [类型]", err))
+	}
+
+	if err := h.taskService.CreateTask(task); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 创建任务失败: %v", err))
+	}
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 任务创建成功！\n\n📋 名称: %s\n⏰ Cron: %s\n📊 类型: %s", task.Name, task.CronExpr, task.Type))
+}
+
+// 处理创建单次任务
+// 格式: 创建单次任务 <名称> <日期> <时间> [类型]
+// 例如: 创建单次任务 交报销单 2026-10-23 18:00
+func (h *MessageHandler) handleCreateOnceTask(msg *dingtalk.IncomingMessage, content string) error {
+	if !h.cfg.IsAdmin(msg.SenderStaffID) {
+		return h.sendReply(msg, "❌ 只有管理员可以创建任务")
+	}
+
+	usage := "格式: 创建单次任务 <名称> <日期> <时间> [类型]\n例: 创建单次任务 交报销单 2026-10-23 18:00"
+	parts := strings.Fields(content)
+	if len(parts) < 4 {
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	// 单次任务默认为任务型（需要打卡）
+	task := &models.Task{
+		Name:           parts[1],
+		Type:           models.TaskTypeTask,
+		ScheduleKind:   models.ScheduleKindOnce,
+		GroupChatID:    msg.ConversationID,
+		GroupChatName:  sql.NullString{String: msg.ConversationTitle, Valid: true},
+		CreatorUserID:  msg.SenderStaffID,
+		CreatorName:    sql.NullString{String: msg.SenderNick, Valid: true},
+		Status:         models.TaskStatusActive,
+		AdvanceMinutes: 60,
+	}
+	if len(parts) >= 5 && parts[4] == "NOTIFICATION" {
+		task.Type = models.TaskTypeNotification
+	}
+
+	// 截止时间按群的默认时区解析
+	dueAt, err := h.taskService.ParseDueAt(*task, parts[2]+" "+parts[3])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v\n\n%s", err, usage))
+	}
+	task.DueAt = sql.NullTime{Time: dueAt, Valid: true}
+
+	if err := h.taskService.CreateTask(task); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 创建任务失败: %v", err))
+	}
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 单次任务创建成功！\n\n📋 名称: %s\n⏰ 截止: %s\n📊 类型: %s", task.Name, dueAt.Format("2006-01-02 15:04"), task.Type))
+}
+
+// 处理任务列表
+func (h *MessageHandler) handleListTasks(msg *dingtalk.IncomingMessage) error {
+	tasks, err := h.taskService.GetActiveTasksByGroup(msg.ConversationID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 查询失败: %v", err))
+	}
+
+	if len(tasks) == 0 {
+		return h.sendReply(msg, "当前群没有活跃的任务")
+	}
+
+	var list strings.Builder
+	list.WriteString("📋 **当前任务列表**\n\n")
+	for i, task := range tasks {
+		list.WriteString(fmt.Sprintf("%d. %s\n", i+1, task.Name))
+		list.WriteString(fmt.Sprintf("   - 类型: %s\n", task.Type))
+		list.WriteString(fmt.Sprintf("   - 时间: %s\n", h.taskService.DescribeSchedule(task)))
+		if !task.IsOnce() && task.DeadlineTime.Valid {
+			list.WriteString(fmt.Sprintf("   - 截止: %s\n", task.DeadlineTime.Time.Format("15:04")))
+		}
+		list.WriteString(fmt.Sprintf("   - 提醒: %s\n", strings.Join(h.effectiveReminderPlan(task), ", ")))
+		if task.Timezone != "" {
+			list.WriteString(fmt.Sprintf("   - 时区: %s\n", task.Timezone))
+		}
+		if task.CalendarPolicy != "" && task.CalendarPolicy != models.CalendarPolicyNone {
+			list.WriteString(fmt.Sprintf("   - 节假日: %s\n", task.CalendarPolicy.DisplayName()))
+		}
+		list.WriteString("\n")
+	}
+
+	return h.sendReply(msg, list.String())
+}
+
+// 处理跳过某一期
+// 格式: 跳过今天 <名称> [原因] 或 跳过 <名称> <日期> [原因]
+// 例如: 跳过今天 写日报 团建
+func (h *MessageHandler) handleSkipOccurrence(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 跳过今天 <名称> [原因] 或 跳过 <名称> <日期> [原因]\n例: 跳过今天 写日报 团建"
+	fields := strings.Fields(content)
+
+	var name, dateArg, reason string
+	switch {
+	case fields[0] == "跳过今天" && len(fields) >= 2:
+		name, reason = fields[1], trimFields(content, 2)
+	case fields[0] == "跳过" && len(fields) >= 3:
+		name, dateArg, reason = fields[1], fields[2], trimFields(content, 3)
+	default:
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	allowed, _, permReason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, permReason)
+		return h.sendReply(msg, "❌ 只有管理员可以跳过任务")
+	}
+
+	task, err := h.findGroupTask(msg, name)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	taskDate, err := h.taskService.ResolveTaskDate(*task, dateArg)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v\n\n%s", err, usage))
+	}
+
+	if err := h.taskService.SkipOccurrence(task.ID, taskDate, msg.SenderStaffID, reason); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 跳过失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "跳过任务")
+
+	return h.sendReply(msg, fmt.Sprintf("⏭️ 已跳过任务 **%s** 的 %s 这一期，当期不再提醒，也不计入完成率\n\n撤销请发送: 取消跳过 %s %s", task.Name, taskDate.Format("2006-01-02"), task.Name, taskDate.Format("2006-01-02")))
+}
+
+// 处理取消跳过
+// 格式: 取消跳过 <名称> [日期]
+func (h *MessageHandler) handleCancelSkip(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	fields := strings.Fields(strings.TrimPrefix(content, "取消跳过"))
+	if len(fields) == 0 {
+		return h.sendReply(msg, "❌ 格式: 取消跳过 <名称> [日期]")
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以取消跳过")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	var dateArg string
+	if len(fields) >= 2 {
+		dateArg = fields[1]
+	}
+	taskDate, err := h.taskService.ResolveTaskDate(*task, dateArg)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	if err := h.taskService.CancelSkip(task.ID, taskDate); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 取消跳过失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "取消跳过任务")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 任务 **%s** 的 %s 这一期恢复提醒", task.Name, taskDate.Format("2006-01-02")))
+}
+
+// 处理延后提醒
+// 格式: 延后提醒 <名称> <分钟>
+// 例如: 延后提醒 写日报 30
+func (h *MessageHandler) handleSnoozeReminder(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 延后提醒 <名称> <分钟>\n例: 延后提醒 写日报 30"
+	fields := strings.Fields(content)
+	if len(fields) < 3 {
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	minutes, err := strconv.Atoi(strings.TrimSuffix(fields[2], "分钟"))
+	if err != nil {
+		return h.sendReply(msg, "❌ 延后时间应为分钟数\n\n"+usage)
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以延后提醒")
+	}
+
+	task, err := h.findGroupTask(msg, fields[1])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	if err := h.taskService.SnoozeNextReminder(task.ID, minutes, msg.SenderStaffID, trimFields(content, 3)); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 延后提醒失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "延后提醒")
+
+	return h.sendReply(msg, fmt.Sprintf("⏰ 任务 **%s** 的下一次提醒将延后 %d 分钟", task.Name, minutes))
+}
+
+// 处理指派任务负责人（替换原有负责人）
+// 格式: 指派任务 <名称> @用户... [部门 <部门ID>...] 或 指派任务 <名称> 全员
+// 例如: 指派任务 写周报 @张三 @李四 部门 123456
+func (h *MessageHandler) handleAssignTask(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 指派任务 <名称> @用户... [部门 <部门ID>...]\n恢复为群内所有人: 指派任务 <名称> 全员"
+	fields := strings.Fields(strings.TrimPrefix(content, "指派任务"))
+	if len(fields) == 0 {
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	var departments []string
+	everyone := false
+	for i := 1; i < len(fields); i++ {
+		switch fields[i] {
+		case "全员", "所有人":
+			everyone = true
+		case "部门":
+			for i+1 < len(fields) {
+				i++
+				departments = append(departments, fields[i])
+			}
+		}
+	}
+	users := mentionedUserIDs(msg)
+	if !everyone && len(users) == 0 && len(departments) == 0 {
+		return h.sendReply(msg, "❌ 请 @ 负责人或指定部门\n\n"+usage)
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以指派任务")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	if everyone {
+		users, departments = nil, nil
+	}
+	if err := h.taskService.SetTaskAssignees(task.ID, users, departments, msg.SenderStaffID); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 指派任务失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "指派任务")
+
+	if everyone {
+		return h.sendReply(msg, fmt.Sprintf("✅ 任务 **%s** 已恢复为群内所有成员执行", task.Name))
+	}
+	return h.handleTaskMembers(msg, "任务成员 "+task.Name)
+}
+
+// 处理查看任务成员：负责人、今天需要执行的成员和仍生效的豁免
+// 格式: 任务成员 <名称>
+func (h *MessageHandler) handleTaskMembers(msg *dingtalk.IncomingMessage, content string) error {
+	fields := strings.Fields(strings.TrimPrefix(content, "任务成员"))
+	if len(fields) == 0 {
+		return h.sendReply(msg, "❌ 格式: 任务成员 <名称>")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	assignees, err := h.taskService.GetTaskAssignees(task.ID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+	members, err := h.taskService.GetResponsibleMembers(*task, h.taskService.TaskDate(*task, time.Now()))
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+	exemptions, err := h.taskService.GetTaskExemptions(task.ID, h.taskService.Today(*task))
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	// 负责人和豁免中的用户显示姓名
+	var userIDs []string
+	for _, a := range assignees {
+		if a.Type == models.AssigneeUser {
+			userIDs = append(userIDs, a.AssigneeID)
+		}
+	}
+	for _, e := range exemptions {
+		userIDs = append(userIDs, e.UserID)
+	}
+	names, err := h.taskService.GetUserNames(userIDs)
+	if err != nil {
+		names = map[string]string{}
+	}
+	for _, member := range members {
+		names[member.UserID] = member.DisplayName()
+	}
+	displayName := func(userID string) string {
+		if name, ok := names[userID]; ok {
+			return name
+		}
+		return userID
+	}
+
+	var reply strings.Builder
+	reply.WriteString(fmt.Sprintf("👥 **%s** 的执行成员\n\n", task.Name))
+	if len(assignees) == 0 {
+		reply.WriteString("**负责人：** 群内所有成员\n")
+	} else {
+		var labels []string
+		for _, a := range assignees {
+			if a.Type == models.AssigneeDepartment {
+				labels = append(labels, "部门 "+a.AssigneeID)
+			} else {
+				labels = append(labels, displayName(a.AssigneeID))
+			}
+		}
+		reply.WriteString(fmt.Sprintf("**负责人：** %s\n", strings.Join(labels, "、")))
+	}
+
+	labels := make([]string, len(members))
+	for i, member := range members {
+		labels[i] = member.DisplayName()
+	}
+	reply.WriteString(fmt.Sprintf("**本期需执行（%d 人）：** %s\n", len(members), strings.Join(labels, "、")))
+
+	if len(exemptions) > 0 {
+		reply.WriteString("\n**豁免：**\n")
+		for _, e := range exemptions {
+			line := fmt.Sprintf("- %s: %s ~ %s", displayName(e.UserID), e.StartDate.Format("2006-01-02"), e.EndDate.Format("2006-01-02"))
+			if e.Reason.Valid {
+				line += fmt.Sprintf("（%s）", e.Reason.String)
+			}
+			reply.WriteString(line + "\n")
+		}
+	}
+
+	return h.sendReply(msg, reply.String())
+}
+
+// 处理登记豁免（如请假期间不提醒、不计入完成率）
+// 格式: 豁免 <名称> @用户... <开始日期> [结束日期] [原因]
+// 例如: 豁免 写日报 @张三 2026-10-20 2026-10-24 年假
+func (h *MessageHandler) handleAddExemption(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 豁免 <名称> @用户 <开始日期> [结束日期] [原因]\n例: 豁免 写日报 @张三 2026-10-20 2026-10-24 年假"
+	fields := strings.Fields(strings.TrimPrefix(content, "豁免"))
+	users := mentionedUserIDs(msg)
+	if len(fields) < 2 || len(users) == 0 {
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以登记豁免")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	start, err := h.taskService.ParseTaskDate(*task, fields[1])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v\n\n%s", err, usage))
+	}
+	end := start
+	rest := 2
+	if len(fields) > 2 {
+		if date, err := h.taskService.ParseTaskDate(*task, fields[2]); err == nil {
+			end = date
+			rest = 3
+		}
+	}
+	exemptReason := strings.Join(fields[rest:], " ")
+
+	for _, userID := range users {
+		_, err := h.taskService.AddTaskExemption(models.TaskExemption{
+			TaskID:    task.ID,
+			UserID:    userID,
+			StartDate: start,
+			EndDate:   end,
+			Reason:    sql.NullString{String: exemptReason, Valid: exemptReason != ""},
+			CreatedBy: msg.SenderStaffID,
+		})
+		if err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ 登记豁免失败: %v", err))
+		}
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "登记豁免")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 已豁免 %d 人执行任务 **%s**: %s ~ %s，期间不提醒、不计入完成率",
+		len(users), task.Name, start.Format("2006-01-02"), end.Format("2006-01-02")))
+}
+
+// 处理取消豁免：取消被 @ 成员今天及之后仍生效的豁免
+// 格式: 取消豁免 <名称> @用户...
+func (h *MessageHandler) handleCancelExemption(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	fields := strings.Fields(strings.TrimPrefix(content, "取消豁免"))
+	users := mentionedUserIDs(msg)
+	if len(fields) == 0 || len(users) == 0 {
+		return h.sendReply(msg, "❌ 格式: 取消豁免 <名称> @用户")
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以取消豁免")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	var cancelled int
+	for _, userID := range users {
+		n, err := h.taskService.CancelUserExemptions(task.ID, userID, h.taskService.Today(*task))
+		if err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ 取消豁免失败: %v", err))
+		}
+		cancelled += n
+	}
+	if cancelled == 0 {
+		return h.sendReply(msg, "❌ 没有仍生效的豁免")
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "取消豁免")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 已取消 %d 条豁免", cancelled))
+}
+
+// 处理请假：请假期间不被提醒，也不计入完成率；管理员可以 @ 成员代为登记
+// 格式: 请假 [@用户...] [日期] [到 结束日期] [#任务ID] [原因]
+// 例如: 请假 明天 / 请假 10-20 到 10-25 年假 / 请假 @张三 明天 #3 出差
+func (h *MessageHandler) handleLeave(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 请假 [@用户] [日期] [到 结束日期] [#任务ID] [原因]\n例: 请假 明天 / 请假 10-20 到 10-25 年假"
+	users, forOthers := leaveUsers(msg)
+	if forOthers {
+		if allowed, err := h.checkUpdatePermission(ctx, msg, "只有管理员可以为他人登记请假"); !allowed {
+			return err
+		}
+	}
+
+	loc := h.taskService.Location(models.Task{GroupChatID: msg.ConversationID})
+	start, end, rest, err := parseLeaveRange(strings.Fields(strings.TrimPrefix(content, "请假")), loc)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v\n\n%s", err, usage))
+	}
+	// 本人只能为今天及以后请假，补登过去的日期需要管理员
+	if !forOthers && start.Before(services.StartOfToday(loc)) {
+		return h.sendReply(msg, "❌ 不能为已经过去的日期请假，请联系管理员登记")
+	}
+
+	leave := models.Leave{StartDate: start, EndDate: end, CreatedBy: msg.SenderStaffID}
+	scope := "所有任务"
+	if len(rest) > 0 && strings.HasPrefix(rest[0], "#") {
+		task, err := h.leaveTask(msg, rest[0])
+		if err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+		}
+		leave.TaskID = sql.NullInt64{Int64: int64(task.ID), Valid: true}
+		scope = task.Name
+		rest = rest[1:]
+	}
+	reason := strings.Join(rest, " ")
+	leave.Reason = sql.NullString{String: reason, Valid: reason != ""}
+
+	names := make([]string, 0, len(users))
+	for _, userID := range users {
+		leave.UserID = userID
+		if _, err := h.taskService.AddLeave(leave); err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+		}
+		names = append(names, h.displayNameOr(userID))
+	}
+	if forOthers {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "登记请假")
+	}
+	h.refreshGroupCards(msg.ConversationID)
+
+	period := start.Format("2006-01-02")
+	if !end.Equal(start) {
+		period += " ~ " + end.Format("2006-01-02")
+	}
+	return h.sendReply(msg, fmt.Sprintf("🏖️ 已登记 %s 请假 %s（%s），期间不提醒、不计入完成率",
+		strings.Join(names, "、"), period, scope))
+}
+
+// 处理取消请假：取消今天及之后的请假（已经开始的请假保留到昨天）
+// 只取消所有任务通用的和本群任务的请假，带 #任务ID 时只取消该任务的请假
+// 格式: 取消请假 [@用户...] [#任务ID]
+func (h *MessageHandler) handleCancelLeave(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	users, forOthers := leaveUsers(msg)
+	if forOthers {
+		if allowed, err := h.checkUpdatePermission(ctx, msg, "只有管理员可以取消他人的请假"); !allowed {
+			return err
+		}
+	}
+
+	taskID := 0
+	for _, field := range strings.Fields(strings.TrimPrefix(content, "取消请假")) {
+		if strings.HasPrefix(field, "#") {
+			task, err := h.leaveTask(msg, field)
+			if err != nil {
+				return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+			}
+			taskID = task.ID
+		}
+	}
+
+	today := services.StartOfToday(h.taskService.Location(models.Task{GroupChatID: msg.ConversationID}))
+	var cancelled int
+	for _, userID := range users {
+		n, err := h.taskService.CancelUserLeaves(userID, msg.ConversationID, taskID, today)
+		if err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ 取消请假失败: %v", err))
+		}
+		cancelled += n
+	}
+	if cancelled == 0 {
+		return h.sendReply(msg, "❌ 没有仍生效的请假")
+	}
+	if forOthers {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "取消请假")
+	}
+	h.refreshGroupCards(msg.ConversationID)
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 已取消 %d 条请假", cancelled))
+}
+
+// 处理查看请假名单：本群成员今天及之后的请假
+func (h *MessageHandler) handleListLeaves(msg *dingtalk.IncomingMessage) error {
+	// 免提醒的成员（如直接指定为负责人的主管）也可能请假
+	members, err := h.taskService.GetAllGroupMembers(msg.ConversationID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+	if len(members) == 0 {
+		return h.sendReply(msg, "🏖️ 本群暂无请假")
+	}
+	userIDs := make([]string, len(members))
+	names := make(map[string]string, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+		names[member.UserID] = member.DisplayName()
+	}
+
+	today := services.StartOfToday(h.taskService.Location(models.Task{GroupChatID: msg.ConversationID}))
+	leaves, err := h.taskService.GetLeaves(userIDs, today)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+	if len(leaves) == 0 {
+		return h.sendReply(msg, "🏖️ 本群暂无请假")
+	}
+
+	var reply strings.Builder
+	reply.WriteString("🏖️ **本群请假名单**\n\n")
+	for _, l := range leaves {
+		line := fmt.Sprintf("- %s: %s ~ %s", names[l.UserID], l.StartDate.Format("2006-01-02"), l.EndDate.Format("2006-01-02"))
+		if l.TaskID.Valid {
+			line += fmt.Sprintf("（任务 #%d）", l.TaskID.Int64)
+		}
+		if l.Reason.Valid {
+			line += " " + l.Reason.String
+		}
+		reply.WriteString(line + "\n")
+	}
+	return h.sendReply(msg, reply.String())
+}
+
+// leaveTask 请假命令中用 "#任务ID" 指定的本群任务
+func (h *MessageHandler) leaveTask(msg *dingtalk.IncomingMessage, selector string) (*models.Task, error) {
+	tasks, err := h.taskService.GetActiveTasksByGroup(msg.ConversationID)
+	if err != nil {
+		return nil, fmt.Errorf("查询任务失败: %v", err)
+	}
+	candidates := services.MatchTasks(tasks, selector)
+	if len(candidates) != 1 {
+		return nil, fmt.Errorf("未找到任务: %s", selector)
+	}
+	return &candidates[0], nil
+}
+
+// leaveUsers 请假的成员：被 @ 的成员，没有 @ 时为发送者本人；forOthers 表示包含他人，需要管理员权限
+func leaveUsers(msg *dingtalk.IncomingMessage) (users []string, forOthers bool) {
+	users = mentionedUserIDs(msg)
+	if len(users) == 0 {
+		return []string{msg.SenderStaffID}, false
+	}
+	for _, userID := range users {
+		if userID != msg.SenderStaffID {
+			forOthers = true
+		}
+	}
+	return users, forOthers
+}
+
+// checkUpdatePermission 校验管理类命令（为他人请假、定时汇总等）的修改任务权限，无权限时已回复
+func (h *MessageHandler) checkUpdatePermission(ctx context.Context, msg *dingtalk.IncomingMessage, denied string) (bool, error) {
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return false, h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return false, h.sendReply(msg, "❌ "+denied)
+	}
+	return true, nil
+}
+
+// parseLeaveRange 解析请假的日期范围，返回开始、结束日期和剩余参数
+// 支持 "明天"、"10-20 到 10-25"、"10-20~10-25"、"12-30 到 01-02"（跨年），省略日期时为今天
+func parseLeaveRange(fields []string, loc *time.Location) (start, end time.Time, rest []string, err error) {
+	start = services.StartOfToday(loc)
+	if len(fields) == 0 {
+		return start, start, nil, nil
+	}
+
+	// "10-20~10-25" 写在一起时拆开
+	for _, sep := range []string{"~", "～", "到", "至"} {
+		from, to, ok := strings.Cut(fields[0], sep)
+		if !ok {
+			continue
+		}
+		if _, err := services.ParseLeaveDate(from, loc); err == nil {
+			fields = append([]string{from, sep, to}, fields[1:]...)
+		}
+		break
+	}
+
+	date, err := services.ParseLeaveDate(fields[0], loc)
+	if err != nil {
+		// 第一个参数不是日期时视为原因（或任务），请今天
+		return start, start, fields, nil
+	}
+	start, end, fields = date, date, fields[1:]
+
+	if len(fields) >= 2 && isRangeSeparator(fields[0]) {
+		if end, err = services.ParseLeaveDate(fields[1], loc); err != nil {
+			return time.Time{}, time.Time{}, nil, err
+		}
+		// 省略年份的结束日期早于开始日期时视为跨年（如 12-30 到 01-02），顺延后超过半年的仍视为写反
+		if end.Before(start) && isMonthDay(fields[1]) && end.AddDate(1, 0, 0).Before(start.AddDate(0, 6, 0)) {
+			end = end.AddDate(1, 0, 0)
+		}
+		fields = fields[2:]
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+	return start, end, fields, nil
+}
+
+// isMonthDay 日期是否省略了年份（如 10-20）
+func isMonthDay(s string) bool {
+	_, err := time.Parse("01-02", strings.TrimSpace(s))
+	return err == nil
+}
+
+// isRangeSeparator 日期范围的分隔词
+func isRangeSeparator(s string) bool {
+	switch s {
+	case "到", "至", "~", "～", "-":
+		return true
+	}
+	return false
+}
+
+// refreshGroupCards 请假变化后刷新本群各任务当前这一期的提醒卡片进度
+func (h *MessageHandler) refreshGroupCards(groupChatID string) {
+	tasks, err := h.taskService.GetActiveTasksByGroup(groupChatID)
+	if err != nil {
+		log.Printf("查询群任务失败: %v", err)
+		return
+	}
+	for _, task := range tasks {
+		h.cardService.Refresh(task, h.taskService.TaskDate(task, time.Now()))
+	}
+}
+
+// mentionedUserIDs 消息中被 @ 的用户（不含机器人自己）
+func mentionedUserIDs(msg *dingtalk.IncomingMessage) []string {
+	var users []string
+	for _, u := range msg.AtUsers {
+		if u.StaffID == "" || u.DingtalkID == msg.ChatbotUserID {
+			continue
+		}
+		users = append(users, u.StaffID)
+	}
+	return users
+}
+
+// 处理提醒计划（查看或设置）
+// 格式: 提醒计划 <名称> [偏移1, 偏移2, ...]
+// 例如: 提醒计划 写日报 -1d 18:00, -2h, deadline, +30m
+func (h *MessageHandler) handleReminderPlan(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	args := strings.TrimSpace(strings.TrimPrefix(content, "提醒计划"))
+	fields := strings.Fields(args)
+	if len(fields) == 0 {
+		return h.sendReply(msg, "❌ 格式: 提醒计划 <名称> [偏移1, 偏移2, ...]\n例: 提醒计划 写日报 -1d 18:00, -2h, deadline, +30m")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	// 只有名称时展示当前计划
+	planText := strings.TrimSpace(strings.TrimPrefix(args, fields[0]))
+	if planText == "" {
+		return h.sendReply(msg, fmt.Sprintf("⏰ 任务 **%s** 的提醒计划: %s", task.Name, strings.Join(h.effectiveReminderPlan(*task), ", ")))
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以修改提醒计划")
+	}
+
+	var plan []string
+	for _, spec := range strings.FieldsFunc(planText, func(r rune) bool { return r == ',' || r == '，' }) {
+		if spec = strings.TrimSpace(spec); spec != "" {
+			plan = append(plan, spec)
+		}
+	}
+
+	if err := h.taskService.SetReminderPlan(task.ID, plan); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 设置提醒计划失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "设置提醒计划")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 已更新任务 **%s** 的提醒计划: %s", task.Name, strings.Join(plan, ", ")))
+}
+
+// 处理修改任务
+// 格式: 修改任务 <名称> <字段> <新值>
+// 例如: 修改任务 写周报 cron 0 16 * * 5
+func (h *MessageHandler) handleUpdateTask(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 修改任务 <名称> <字段> <新值>\n字段: 名称 / 描述 / 类型 / cron / 截止 / 提前 / 单次截止\n例: 修改任务 写周报 截止 16:00"
+	fields := strings.Fields(content)
+	if len(fields) < 4 {
+		return h.sendReply(msg, "❌ 参数不足\n\n"+usage)
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以修改任务")
+	}
+
+	// 暂停中的任务也可以修改
+	task, err := h.findGroupTask(msg, fields[1])
+	if err != nil {
+		if task, err = h.findGroupTaskWithStatus(msg, fields[1], models.TaskStatusPaused); err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+		}
+	}
+
+	// 新值为字段之后的全部内容（cron 表达式和描述可能包含空格）
+	field := fields[2]
+	value := trimFields(content, 3)
+
+	var update models.TaskUpdate
+	switch strings.ToLower(field) {
+	case "名称", "name":
+		update.Name = &value
+	case "描述", "description":
+		if value == "无" {
+			value = ""
+		}
+		update.Description = &value
+	case "类型", "type":
+		update.Type = &value
+	case "cron":
+		update.CronExpr = &value
+	case "截止", "deadline":
+		if value == "无" {
+			value = ""
+		}
+		update.DeadlineTime = &value
+	case "提前", "advance":
+		minutes, err := strconv.Atoi(strings.TrimSuffix(value, "分钟"))
+		if err != nil {
+			return h.sendReply(msg, "❌ 提前提醒应为分钟数，如 30\n\n"+usage)
+		}
+		update.AdvanceMinutes = &minutes
+	case "单次截止", "due":
+		update.DueAt = &value
+	default:
+		return h.sendReply(msg, fmt.Sprintf("❌ 未知字段: %s\n\n%s", field, usage))
+	}
+
+	updated, err := h.taskService.UpdateTask(task.ID, update)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 修改任务失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "修改任务")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 任务已更新！\n\n📋 名称: %s\n⏰ 时间: %s\n📊 类型: %s", updated.Name, h.taskService.DescribeSchedule(*updated), updated.Type))
+}
+
+// 处理暂停任务
+// 格式: 暂停任务 <名称>
+func (h *MessageHandler) handlePauseTask(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	name := strings.TrimSpace(strings.TrimPrefix(content, "暂停任务"))
+	if name == "" {
+		return h.sendReply(msg, "❌ 格式: 暂停任务 <名称>")
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以暂停任务")
+	}
+
+	task, err := h.findGroupTask(msg, name)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	if err := h.taskService.PauseTask(task.ID); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 暂停任务失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "暂停任务")
+
+	return h.sendReply(msg, fmt.Sprintf("⏸️ 任务 **%s** 已暂停，恢复前不会再提醒\n\n恢复请发送: 恢复任务 %s", task.Name, task.Name))
+}
+
+// 处理恢复任务
+// 格式: 恢复任务 <名称>
+func (h *MessageHandler) handleResumeTask(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	name := strings.TrimSpace(strings.TrimPrefix(content, "恢复任务"))
+	if name == "" {
+		return h.sendReply(msg, "❌ 格式: 恢复任务 <名称>")
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以恢复任务")
+	}
+
+	task, err := h.findGroupTaskWithStatus(msg, name, models.TaskStatusPaused)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 未找到已暂停的任务: %s", name))
+	}
+
+	if err := h.taskService.ResumeTask(task.ID); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 恢复任务失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "恢复任务")
+
+	return h.sendReply(msg, fmt.Sprintf("▶️ 任务 **%s** 已恢复\n⏰ 时间: %s", task.Name, h.taskService.DescribeSchedule(*task)))
+}
+
+// findGroupTask 按名称查找当前群的活跃任务
+func (h *MessageHandler) findGroupTask(msg *dingtalk.IncomingMessage, name string) (*models.Task, error) {
+	return h.findGroupTaskWithStatus(msg, name, models.TaskStatusActive)
+}
+
+// findGroupTaskWithStatus 按名称查找当前群中指定状态的任务
+func (h *MessageHandler) findGroupTaskWithStatus(msg *dingtalk.IncomingMessage, name string, status models.TaskStatus) (*models.Task, error) {
+	tasks, err := h.taskService.GetTasksByGroup(msg.ConversationID, status)
+	if err != nil {
+		return nil, fmt.Errorf("查询任务失败: %v", err)
+	}
+
+	for i := range tasks {
+		if tasks[i].Name == name {
+			return &tasks[i], nil
+		}
+	}
+	return nil, fmt.Errorf("未找到任务: %s", name)
+}
+
+// 查看/设置任务的节假日策略
+func (h *MessageHandler) handleCalendarPolicy(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	fields := strings.Fields(strings.TrimPrefix(content, "节假日策略"))
+	if len(fields) == 0 {
+		return h.sendReply(msg, "❌ 格式: 节假日策略 <名称> [NONE|SKIP_HOLIDAYS|WORKDAYS_ONLY|INCLUDE_MAKEUP]\n例: 节假日策略 写日报 WORKDAYS_ONLY")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	// 只有名称时展示当前策略
+	if len(fields) == 1 {
+		return h.sendReply(msg, fmt.Sprintf("📅 任务 **%s** 的节假日策略: %s (%s)", task.Name, task.CalendarPolicy, task.CalendarPolicy.DisplayName()))
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以修改节假日策略")
+	}
+
+	policy, err := models.ParseCalendarPolicy(fields[1])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	if err := h.taskService.SetCalendarPolicy(task.ID, policy); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 设置节假日策略失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "设置节假日策略")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 已更新任务 **%s** 的节假日策略: %s (%s)", task.Name, policy, policy.DisplayName()))
+}
+
+// 处理任务时区（查看或设置）
+// 格式: 任务时区 <名称> [时区|默认]
+// 例如: 任务时区 写周报 Europe/Berlin
+func (h *MessageHandler) handleTaskTimezone(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	fields := strings.Fields(strings.TrimPrefix(content, "任务时区"))
+	if len(fields) == 0 {
+		return h.sendReply(msg, "❌ 格式: 任务时区 <名称> [时区|默认]\n例: 任务时区 写周报 Europe/Berlin")
+	}
+
+	task, err := h.findGroupTask(msg, fields[0])
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	// 只有名称时展示当前时区
+	if len(fields) == 1 {
+		source := "任务设置"
+		if task.Timezone == "" {
+			source = "群默认"
+		}
+		return h.sendReply(msg, fmt.Sprintf("🌏 任务 **%s** 的时区: %s（%s）", task.Name, h.taskService.Location(*task), source))
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以修改任务时区")
+	}
+
+	timezone := parseTimezoneArg(fields[1])
+	if err := h.taskService.SetTaskTimezone(task.ID, timezone); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 设置任务时区失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "设置任务时区")
+
+	task.Timezone = timezone
+	return h.sendReply(msg, fmt.Sprintf("✅ 已更新任务 **%s** 的时区: %s", task.Name, h.taskService.Location(*task)))
+}
+
+// 处理群默认时区（查看或设置）
+// 格式: 群时区 [时区|默认]
+// 例如: 群时区 Asia/Singapore
+func (h *MessageHandler) handleGroupTimezone(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	fields := strings.Fields(strings.TrimPrefix(content, "群时区"))
+	groupTask := models.Task{GroupChatID: msg.ConversationID}
+
+	if len(fields) == 0 {
+		return h.sendReply(msg, fmt.Sprintf("🌏 本群默认时区: %s", h.taskService.Location(groupTask)))
+	}
+
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以修改群时区")
+	}
+
+	if err := h.taskService.SetGroupTimezone(msg.ConversationID, parseTimezoneArg(fields[0])); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 设置群时区失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "设置群时区")
+
+	return h.sendReply(msg, fmt.Sprintf("✅ 本群默认时区已设置为: %s（未单独设置时区的任务将按该时区提醒）", h.taskService.Location(groupTask)))
+}
+
+// 处理立即同步群成员（成员变动后无需等待定期同步）
+func (h *MessageHandler) handleSyncGroupMembers(ctx context.Context, msg *dingtalk.IncomingMessage) error {
+	allowed, _, reason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, reason)
+		return h.sendReply(msg, "❌ 只有管理员可以同步群成员")
+	}
+
+	count, err := h.taskService.SyncGroupMembers(msg.ConversationID)
 	if err != nil {
-		log.Printf("序列化 Dify 请求失败: %v", err)
-		return h.sendReply(msg, "❌ 消息处理失败")
+		return h.sendReply(msg, fmt.Sprintf("❌ 同步群成员失败: %v", err))
 	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "同步群成员")
 
-	// 发送请求到 Dify 工作流
-	req, err := http.NewRequestWithContext(ctx, "POST", h.cfg.Dify.WebhookURL, bytes.NewBuffer(data))
+	members, err := h.taskService.GetGroupMembers(msg.ConversationID)
 	if err != nil {
-		log.Printf("创建 Dify 请求失败: %v", err)
-		return h.sendReply(msg, "❌ 消息处理失败")
+		return h.sendReply(msg, fmt.Sprintf("✅ 已同步群成员: 共 %d 人", count))
 	}
+	return h.sendReply(msg, fmt.Sprintf("✅ 已同步群成员: 共 %d 人，其中 %d 人需要打卡", count, len(members)))
+}
 
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+h.cfg.Dify.APIKey)
+// 处理免提醒设置：被 @ 的成员在本群（带「全局」时为所有群）免提醒或恢复提醒
+// 格式: 免提醒 @用户... [全局] [原因] / 恢复提醒 @用户... [全局]
+func (h *MessageHandler) handleSetReminderExclusion(ctx context.Context, msg *dingtalk.IncomingMessage, content, command string, excluded bool) error {
+	users := mentionedUserIDs(msg)
+	if len(users) == 0 {
+		return h.sendReply(msg, fmt.Sprintf("❌ 格式: %s @用户... [全局]", command))
+	}
 
-	client := &http.Client{Timeout: 30 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		log.Printf("调用 Dify API 失败: %v", err)
-		return h.sendReply(msg, "❌ 消息处理失败")
+	fields := strings.Fields(strings.TrimPrefix(content, command))
+	groupChatID := msg.ConversationID
+	scope := "本群"
+	if len(fields) > 0 && (fields[0] == "全局" || fields[0] == "所有群") {
+		groupChatID = ""
+		scope = "所有群"
+		fields = fields[1:]
 	}
-	defer resp.Body.Close()
+	reason := strings.Join(fields, " ")
 
-	body, _ := io.ReadAll(resp.Body)
+	allowed, _, permReason, err := h.permService.CanExecuteCommand(ctx, msg.SenderStaffID, models.PermUpdateTask)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 权限验证失败: %v", err))
+	}
+	if !allowed {
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, false, permReason)
+		return h.sendReply(msg, "❌ 只有管理员可以修改免提醒设置")
+	}
 
-	if resp.StatusCode != http.StatusOK {
-		log.Printf("Dify API 返回错误状态码: %d, 响应: %s", resp.StatusCode, string(body))
-		return h.sendReply(msg, "❌ 消息处理失败")
+	for _, userID := range users {
+		if err := h.taskService.SetReminderExclusion(userID, groupChatID, excluded, reason, msg.SenderStaffID); err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+		}
 	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, command)
 
-	// 解析 Dify 工作流响应
-	// 工作流响应格式：{"data": {"outputs": {"reply_polisher": "..."}}, ...}
-	var difyResp struct {
-		TaskID        string `json:"task_id"`
-		WorkflowRunID string `json:"workflow_run_id"`
-		Data          struct {
-			ID      string                 `json:"id"`
-			Status  string                 `json:"status"`
-			Outputs map[string]interface{} `json:"outputs"`
-			Error   string                 `json:"error"`
-		} `json:"data"`
+	if excluded {
+		return h.sendReply(msg, fmt.Sprintf("🔕 已设置 %d 人在%s免提醒：不被 @，也不计入未完成名单和完成率", len(users), scope))
 	}
+	return h.sendReply(msg, fmt.Sprintf("🔔 已恢复提醒 %d 人（%s）", len(users), scope))
+}
 
-	if err := json.Unmarshal(body, &difyResp); err != nil {
-		log.Printf("解析 Dify 响应失败: %v, 响应: %s", err, string(body))
-		return h.sendReply(msg, "❌ 消息处理失败")
+// 处理查看本群的免提醒名单
+func (h *MessageHandler) handleListReminderExclusions(msg *dingtalk.IncomingMessage) error {
+	exclusions, err := h.taskService.GetReminderExclusions(msg.ConversationID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+	leaders, err := h.taskService.GetDeptLeaders(msg.ConversationID)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
 	}
 
-	// 检查工作流执行状态
-	if difyResp.Data.Status != "succeeded" {
-		log.Printf("Dify 工作流执行失败: status=%s, error=%s", difyResp.Data.Status, difyResp.Data.Error)
-		return h.sendReply(msg, "❌ 消息处理失败")
+	userIDs := make([]string, 0, len(exclusions))
+	for _, e := range exclusions {
+		userIDs = append(userIDs, e.UserID)
+	}
+	names, err := h.taskService.GetUserNames(userIDs)
+	if err != nil {
+		names = map[string]string{}
 	}
 
-	// 提取工作流输出中的回复内容
-	// 尝试从常见的输出字段中提取回复
-	var reply string
-	for key, value := range difyResp.Data.Outputs {
-		// 常见的回复字段名：reply, reply_polisher, text, answer 等
-		if str, ok := value.(string); ok && str != "" {
-			reply = str
-			log.Printf("从工作流输出字段 '%s' 中提取到回复", key)
-			break
+	// 群内设置覆盖全局设置，只展示对本群生效的一条
+	overridden := make(map[string]bool)
+	for _, e := range exclusions {
+		if e.GroupChatID != "" {
+			overridden[e.UserID] = true
 		}
 	}
 
-	// 如果 Dify 返回了回复，则发送给用户
-	if reply != "" {
-		return h.sendReply(msg, reply)
+	var reply strings.Builder
+	reply.WriteString("🔕 **本群免提醒名单**\n\n")
+	for _, e := range exclusions {
+		if e.GroupChatID == "" && overridden[e.UserID] {
+			continue
+		}
+		name := e.UserID
+		if n, ok := names[e.UserID]; ok {
+			name = n
+		}
+		scope := "本群"
+		if e.GroupChatID == "" {
+			scope = "全局"
+		}
+		state := "免提醒"
+		if !e.Excluded {
+			state = "强制提醒"
+		}
+		line := fmt.Sprintf("- %s: %s（%s）", name, state, scope)
+		if e.Reason.Valid {
+			line += " " + e.Reason.String
+		}
+		reply.WriteString(line + "\n")
+		overridden[e.UserID] = true
+	}
+	for _, leader := range leaders {
+		if !overridden[leader.UserID] {
+			reply.WriteString(fmt.Sprintf("- %s: 免提醒（钉钉部门主管）\n", leader.DisplayName()))
+		}
+	}
+	if len(exclusions) == 0 && len(leaders) == 0 {
+		reply.WriteString("暂无，所有成员都会被提醒\n")
 	}
 
-	// 如果没有回复，表示 Dify 可能已经通过工具调用处理了请求
-	log.Printf("Dify 工作流处理完成，无直接回复")
-	return nil
+	return h.sendReply(msg, reply.String())
 }
 
-// handleLegacyCommand 处理传统命令（兜底方案）
-func (h *MessageHandler) handleLegacyCommand(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
-	// 匹配不同的命令
-	switch {
-	case strings.Contains(content, "已完成") || strings.Contains(content, "我已提交"):
-		return h.handleCompletion(msg)
-	case strings.Contains(content, "统计") || strings.Contains(content, "报告"):
-		return h.handleStats(msg, content)
-	case strings.HasPrefix(content, "创建任务") || strings.HasPrefix(content, "新建任务"):
-		return h.handleCreateTask(msg, content)
-	case strings.Contains(content, "任务列表") || strings.Contains(content, "查看任务"):
-		return h.handleListTasks(msg)
-	case strings.HasPrefix(content, "添加管理员") || strings.HasPrefix(content, "提升管理员"):
-		return h.handlePromoteAdmin(ctx, msg, content)
-	case strings.HasPrefix(content, "移除管理员") || strings.HasPrefix(content, "降级管理员"):
-		return h.handleDemoteAdmin(ctx, msg, content)
-	case strings.Contains(content, "管理员列表"):
-		return h.handleListAdmins(ctx, msg)
-	case strings.Contains(content, "我的权限"):
-		return h.handleMyPermissions(ctx, msg)
-	case strings.Contains(content, "帮助") || content == "?":
-		return h.handleHelp(msg)
-	default:
-		return h.sendReply(msg, "❓ 未识别的命令，发送「帮助」查看可用指令")
+// trimFields 去掉 content 开头的 n 个以空白分隔的字段，返回剩余内容
+func trimFields(content string, n int) string {
+	rest := strings.TrimSpace(content)
+	for i := 0; i < n; i++ {
+		idx := strings.IndexFunc(rest, unicode.IsSpace)
+		if idx < 0 {
+			return ""
+		}
+		rest = strings.TrimSpace(rest[idx:])
 	}
+	return rest
 }
 
-// 处理打卡
-func (h *MessageHandler) handleCompletion(msg *dingtalk.IncomingMessage) error {
-	// 获取该群的活跃任务
-	tasks, err := h.taskService.GetActiveTasksByGroup(msg.ConversationID)
-	if err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 查询任务失败: %v", err))
+// parseTimezoneArg 解析时区参数，"默认" 表示清除设置
+func parseTimezoneArg(arg string) string {
+	switch strings.ToLower(arg) {
+	case "默认", "default", "none":
+		return ""
 	}
+	return arg
+}
 
-	if len(tasks) == 0 {
-		return h.sendReply(msg, "❌ 当前群没有活跃的任务")
+// effectiveReminderPlan 任务生效的提醒计划（未配置时为默认计划）
+func (h *MessageHandler) effectiveReminderPlan(task models.Task) []string {
+	if len(task.ReminderPlan) > 0 {
+		return task.ReminderPlan
 	}
+	return models.DefaultReminderPlan(task)
+}
 
-	// 默认打卡第一个任务（实际应该让用户选择）
-	task := tasks[0]
+// 处理新增定时汇总：日报汇总群内每个任务本期的完成情况，周报汇总本周每个任务和每个成员
+// 格式: 定时汇总 <日报|周报> [每天|工作日|周一..周日] <HH:MM> [私聊] [排行榜] [@用户...]
+// 例如: 定时汇总 日报 工作日 19:00 / 定时汇总 周报 周五 18:00 私聊 / 定时汇总 周报 周五 18:00 排行榜
+func (h *MessageHandler) handleCreateDigest(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	usage := "格式: 定时汇总 <日报|周报> [每天|工作日|周一..周日] <HH:MM> [私聊] [排行榜] [@用户...]\n例: 定时汇总 日报 工作日 19:00 / 定时汇总 周报 周五 18:00 私聊 / 定时汇总 周报 周五 18:00 排行榜"
+	if allowed, err := h.checkUpdatePermission(ctx, msg, "只有管理员可以设置定时汇总"); !allowed {
+		return err
+	}
 
-	// 检查是否已打卡
-	completed, err := h.taskService.HasCompletedToday(task.ID, msg.SenderStaffID)
-	if err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 检查打卡状态失败: %v", err))
+	fields := strings.Fields(strings.TrimPrefix(content, "定时汇总"))
+	if len(fields) < 2 {
+		return h.sendReply(msg, "❌ "+usage)
 	}
 
-	if completed {
-		return h.sendReply(msg, "✅ 您今天已经打过卡了！")
+	digest := models.Digest{
+		GroupChatID: msg.ConversationID,
+		Target:      models.DigestToGroup,
+		CreatedBy:   msg.SenderStaffID,
+	}
+	days := "工作日"
+	switch fields[0] {
+	case "日报":
+		digest.Kind = models.DigestDaily
+	case "周报":
+		digest.Kind = models.DigestWeekly
+		days = "周五"
+	default:
+		return h.sendReply(msg, "❌ "+usage)
+	}
+
+	var clock time.Time
+	for _, field := range fields[1:] {
+		if t, err := services.ParseDeadlineTime(field); err == nil {
+			clock = t
+			continue
+		}
+		switch {
+		case field == "私聊":
+			digest.Target = models.DigestToPrivate
+		case field == "排行榜":
+			digest.IncludeLeaderboard = true
+		case field == "每天" || field == "工作日" || digestWeekdays[field] != "":
+			days = field
+		}
+	}
+	if clock.IsZero() {
+		return h.sendReply(msg, "❌ 缺少发送时间\n\n"+usage)
 	}
 
-	// 判断是否按时完成
-	isOnTime := true
-	if task.Type == models.TaskTypeTask && task.DeadlineTime.Valid {
-		now := time.Now()
-		deadline := task.DeadlineTime.Time
-		if now.After(deadline) {
-			isOnTime = false
+	// 私聊发给被 @ 的人，没有 @ 时发给自己
+	if digest.Target == models.DigestToPrivate {
+		digest.Recipients = mentionedUserIDs(msg)
+		if len(digest.Recipients) == 0 {
+			digest.Recipients = []string{msg.SenderStaffID}
 		}
 	}
 
-	// 记录完成
-	record := &models.CompletionRecord{
-		TaskID:      task.ID,
-		UserID:      msg.SenderStaffID,
-		UserName:    sql.NullString{String: msg.SenderNick, Valid: true},
-		GroupChatID: msg.ConversationID,
-		TaskDate:    time.Now(),
-		IsOnTime:    isOnTime,
+	dow := "*"
+	switch days {
+	case "每天":
+	case "工作日":
+		digest.CalendarPolicy = models.CalendarPolicyWorkdaysOnly
+	default:
+		dow = digestWeekdays[days]
 	}
+	digest.CronExpr = fmt.Sprintf("%d %d * * %s", clock.Minute(), clock.Hour(), dow)
 
-	if err := h.taskService.RecordCompletion(record); err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 打卡失败: %v", err))
+	created, err := h.digestService.CreateDigest(digest)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
 	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "设置定时汇总")
 
-	status := "✅"
-	if !isOnTime {
-		status = "⏰"
+	extra := ""
+	if created.IncludeLeaderboard {
+		extra = "，附带本月排行榜"
 	}
+	return h.sendReply(msg, fmt.Sprintf("✅ 已设置定时汇总 #%d：%s %s %s，%s%s",
+		created.ID, days, clock.Format("15:04"), created.Kind.DisplayName(), h.describeDigestTarget(*created), extra))
+}
 
-	return h.sendReply(msg, fmt.Sprintf("%s 打卡成功！任务: %s", status, task.Name))
+// 定时汇总支持的星期写法 → cron 的星期字段
+var digestWeekdays = map[string]string{
+	"周一": "1", "周二": "2", "周三": "3", "周四": "4", "周五": "5", "周六": "6", "周日": "0",
 }
 
-// 处理统计查询
-func (h *MessageHandler) handleStats(msg *dingtalk.IncomingMessage, content string) error {
-	// 获取该群的活跃任务
-	tasks, err := h.taskService.GetActiveTasksByGroup(msg.ConversationID)
+// 处理查看本群的定时汇总
+func (h *MessageHandler) handleListDigests(msg *dingtalk.IncomingMessage) error {
+	digests, err := h.digestService.ListDigests(msg.ConversationID)
 	if err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 查询任务失败: %v", err))
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
 	}
-
-	if len(tasks) == 0 {
-		return h.sendReply(msg, "❌ 当前群没有活跃的任务")
+	if len(digests) == 0 {
+		return h.sendReply(msg, "📭 本群暂无定时汇总\n\n设置: 定时汇总 日报 工作日 19:00")
 	}
 
-	// 默认统计第一个任务
-	task := tasks[0]
+	var reply strings.Builder
+	reply.WriteString("📬 **本群定时汇总**\n\n")
+	for _, d := range digests {
+		line := fmt.Sprintf("- #%d %s: %s", d.ID, d.Kind.DisplayName(), d.CronExpr)
+		if d.CalendarPolicy != "" && d.CalendarPolicy != models.CalendarPolicyNone {
+			line += "（" + d.CalendarPolicy.DisplayName() + "）"
+		}
+		line += "，" + h.describeDigestTarget(d)
+		if d.IncludeLeaderboard {
+			line += "，附带排行榜"
+		}
+		reply.WriteString(line + "\n")
+	}
+	reply.WriteString("\n删除: 删除汇总 <ID>，立即发送: 发送汇总 <ID>")
+	return h.sendReply(msg, reply.String())
+}
 
-	stats, err := h.statsService.GetTodayStats(task.ID)
+// 处理删除或立即发送本群的定时汇总
+// 格式: 删除汇总 <ID> / 发送汇总 <ID>
+func (h *MessageHandler) handleDigestByID(ctx context.Context, msg *dingtalk.IncomingMessage, content, command string) error {
+	digestID, err := strconv.Atoi(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(content, command)), "#"))
 	if err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 获取统计失败: %v", err))
+		return h.sendReply(msg, fmt.Sprintf("❌ 格式: %s <ID>（ID 见「汇总列表」）", command))
+	}
+	if allowed, err := h.checkUpdatePermission(ctx, msg, "只有管理员可以管理定时汇总"); !allowed {
+		return err
 	}
 
-	report := h.statsService.FormatStatsReport(stats)
-	return h.sendReply(msg, report)
+	digest, err := h.digestService.GetDigest(digestID)
+	if err != nil || digest.GroupChatID != msg.ConversationID {
+		return h.sendReply(msg, "❌ 本群没有这个定时汇总")
+	}
+
+	if command == "删除汇总" {
+		if err := h.digestService.DeleteDigest(digestID); err != nil {
+			return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+		}
+		h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "删除定时汇总")
+		return h.sendReply(msg, fmt.Sprintf("✅ 已删除定时汇总 #%d", digestID))
+	}
+
+	if err := h.digestService.Send(*digest, time.Now()); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 发送失败: %v", err))
+	}
+	if digest.Target == models.DigestToPrivate {
+		return h.sendReply(msg, "✅ 已私聊发送")
+	}
+	return nil
 }
 
-// 处理创建任务
-func (h *MessageHandler) handleCreateTask(msg *dingtalk.IncomingMessage, content string) error {
-	// 检查权限
-	if !h.cfg.IsAdmin(msg.SenderStaffID) {
-		return h.sendReply(msg, "❌ 只有管理员可以创建任务")
+// describeDigestTarget 描述汇总的发送对象
+func (h *MessageHandler) describeDigestTarget(d models.Digest) string {
+	if d.Target != models.DigestToPrivate {
+		return "发到本群"
+	}
+	recipients := d.Recipients
+	if len(recipients) == 0 {
+		recipients = []string{d.CreatedBy}
 	}
+	names := make([]string, len(recipients))
+	for i, userID := range recipients {
+		names[i] = h.displayNameOr(userID)
+	}
+	return "私聊 " + strings.Join(names, "、")
+}
 
-	// 解析命令
-	// 格式: 创建任务 <名称> <cron表达式> [截止时间] [类型]
-	// 例如: 创建任务 写周报 0 17 * * 5 15:00 TASK
-	task, err := h.parseCreateTaskCommand(content, msg)
-	if err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 解析命令失败: %v\n\n格式: 创建任务 <名称> <cron表达式> [截止时间] [类型]", err))
+// 处理排行榜：本群（或指定任务）某月的按时完成次数、连续按时期数和首位提交徽章
+// 格式: 排行榜 [本月|上月|2026-10|9月] [任务名称|#ID]
+func (h *MessageHandler) handleLeaderboard(msg *dingtalk.IncomingMessage, content string) error {
+	loc := h.taskService.Location(models.Task{GroupChatID: msg.ConversationID})
+	query := models.LeaderboardQuery{GroupChatID: msg.ConversationID, Month: services.StartOfToday(loc)}
+
+	var selector []string
+	for _, field := range strings.Fields(strings.TrimPrefix(content, "排行榜")) {
+		if month, err := services.LeaderboardMonth(field, loc); err == nil {
+			query.Month = month
+			continue
+		}
+		selector = append(selector, field)
 	}
 
-	if err := h.taskService.CreateTask(task); err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 创建任务失败: %v", err))
+	if len(selector) > 0 {
+		tasks, err := h.groupTasks(msg)
+		if tasks == nil {
+			return err
+		}
+		candidates := services.MatchTasks(tasks, strings.Join(selector, " "))
+		switch len(candidates) {
+		case 0:
+			return h.sendReply(msg, fmt.Sprintf("❌ 未找到任务: %s", strings.Join(selector, " ")))
+		case 1:
+			query.TaskID = candidates[0].ID
+		default:
+			return h.sendReply(msg, taskChoiceText("匹配到多个任务，请指定：", h.taskChoiceLines(candidates), "排行榜 [月份] #任务ID"))
+		}
 	}
 
-	return h.sendReply(msg, fmt.Sprintf("✅ 任务创建成功！\n\n📋 名称: %s\n⏰ Cron: %s\n📊 类型: %s", task.Name, task.CronExpr, task.Type))
+	board, err := h.statsService.GetLeaderboard(query)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 获取排行榜失败: %v", err))
+	}
+	return h.sendReply(msg, h.statsService.FormatLeaderboard(board, 10))
 }
 
-// 处理任务列表
-func (h *MessageHandler) handleListTasks(msg *dingtalk.IncomingMessage) error {
-	tasks, err := h.taskService.GetActiveTasksByGroup(msg.ConversationID)
+// 处理导出：生成打卡记录（或提醒记录）文件并发到本群，默认导出本月、本群所有任务的 Excel
+// 格式: 导出 [本月|上月|本周|上周|今天|昨天|开始日期~结束日期] [提醒记录] [csv] [任务名称|#ID]
+// 例如: 导出 本月 周报 / 导出 上月 / 导出 10-01~10-15 提醒记录 #3
+func (h *MessageHandler) handleExport(ctx context.Context, msg *dingtalk.IncomingMessage, content string) error {
+	if allowed, err := h.checkUpdatePermission(ctx, msg, "只有管理员可以导出记录"); !allowed {
+		return err
+	}
+
+	loc := h.taskService.Location(models.Task{GroupChatID: msg.ConversationID})
+	kind, format := models.ExportCompletions, models.ExportXLSX
+	query := models.ExportQuery{GroupChatID: msg.ConversationID}
+	query.To = services.StartOfToday(loc)
+	query.From = query.To.AddDate(0, 0, 1-query.To.Day())
+
+	var selector []string
+	for _, field := range strings.Fields(strings.TrimPrefix(content, "导出")) {
+		switch strings.ToLower(field) {
+		case "打卡记录":
+			kind = models.ExportCompletions
+		case "提醒记录":
+			kind = models.ExportReminderLogs
+		case "csv":
+			format = models.ExportCSV
+		case "xlsx", "excel":
+			format = models.ExportXLSX
+		default:
+			if from, to, ok := parseExportPeriod(field, loc); ok {
+				query.From, query.To = from, to
+				continue
+			}
+			selector = append(selector, field)
+		}
+	}
+	if query.To.Before(query.From) {
+		return h.sendReply(msg, "❌ 结束日期不能早于开始日期")
+	}
+
+	scope := "本群所有任务"
+	if len(selector) > 0 {
+		tasks, err := h.groupTasks(msg)
+		if tasks == nil {
+			return err
+		}
+		candidates := services.MatchTasks(tasks, strings.Join(selector, " "))
+		switch len(candidates) {
+		case 0:
+			return h.sendReply(msg, fmt.Sprintf("❌ 未找到任务: %s", strings.Join(selector, " ")))
+		case 1:
+			query.TaskID = candidates[0].ID
+			scope = candidates[0].Name
+		default:
+			return h.sendReply(msg, taskChoiceText("匹配到多个任务，请指定：", h.taskChoiceLines(candidates), "导出 <时间范围> #任务ID"))
+		}
+	}
+
+	// 先写入临时文件，再边读边上传，避免整个文件留在内存中
+	file, err := os.CreateTemp("", "dingteam-export-*."+string(format))
 	if err != nil {
-		return h.sendReply(msg, fmt.Sprintf("❌ 查询失败: %v", err))
+		return h.sendReply(msg, fmt.Sprintf("❌ 导出失败: %v", err))
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	if len(tasks) == 0 {
-		return h.sendReply(msg, "当前群没有活跃的任务")
+	count, err := h.exportService.Export(file, kind, format, query)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 导出失败: %v", err))
+	}
+	period := fmt.Sprintf("%s ~ %s", query.From.Format("2006-01-02"), query.To.Format("2006-01-02"))
+	if count == 0 {
+		return h.sendReply(msg, fmt.Sprintf("📭 %s（%s）没有%s", period, scope, kind.DisplayName()))
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 导出失败: %v", err))
 	}
 
-	var list strings.Builder
-	list.WriteString("📋 **当前任务列表**\n\n")
-	for i, task := range tasks {
-		list.WriteString(fmt.Sprintf("%d. %s\n", i+1, task.Name))
-		list.WriteString(fmt.Sprintf("   - 类型: %s\n", task.Type))
-		list.WriteString(fmt.Sprintf("   - Cron: %s\n", task.CronExpr))
-		if task.DeadlineTime.Valid {
-			list.WriteString(fmt.Sprintf("   - 截止: %s\n", task.DeadlineTime.Time.Format("15:04")))
+	fileName := services.ExportFileName(kind, format, query)
+	if err := h.dtClient.SendGroupFile(msg.ConversationID, fileName, file); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 发送文件失败: %v", err))
+	}
+	h.permService.LogPermissionCheck(ctx, msg.SenderStaffID, models.PermUpdateTask, true, "导出"+kind.DisplayName())
+
+	return h.sendReply(msg, fmt.Sprintf("📤 已导出 %s（%s）的%s，共 %d 条", period, scope, kind.DisplayName(), count))
+}
+
+// parseExportPeriod 解析导出的时间范围：本月、上月、本周、上周、今天、昨天、单个日期或 "10-01~10-15"
+func parseExportPeriod(field string, loc *time.Location) (from, to time.Time, ok bool) {
+	today := services.StartOfToday(loc)
+	monday := today.AddDate(0, 0, -((int(today.Weekday()) + 6) % 7))
+	switch field {
+	case "本月":
+		return today.AddDate(0, 0, 1-today.Day()), today, true
+	case "上月":
+		end := today.AddDate(0, 0, -today.Day())
+		return end.AddDate(0, 0, 1-end.Day()), end, true
+	case "本周":
+		return monday, today, true
+	case "上周":
+		return monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1), true
+	case "今天":
+		return today, today, true
+	case "昨天":
+		return today.AddDate(0, 0, -1), today.AddDate(0, 0, -1), true
+	}
+
+	for _, sep := range []string{"~", "～", "至"} {
+		start, end, found := strings.Cut(field, sep)
+		if !found {
+			continue
 		}
-		list.WriteString("\n")
+		from, err := services.ParseTaskDate(start, loc)
+		if err != nil {
+			return from, to, false
+		}
+		if to, err = services.ParseTaskDate(end, loc); err != nil {
+			return from, to, false
+		}
+		return from, to, true
 	}
 
-	return h.sendReply(msg, list.String())
+	date, err := services.ParseTaskDate(field, loc)
+	if err != nil {
+		return from, to, false
+	}
+	return date, date, true
 }
 
 // 处理帮助
@@ -332,14 +2228,48 @@ func (h *MessageHandler) handleHelp(msg *dingtalk.IncomingMessage) error {
 	help := `📖 **DingTeam Bot 使用指南**
 
 **基本命令：**
-• @我 已完成 - 打卡完成任务
-• @我 统计 - 查看今日完成统计
+• @我 已完成 [任务名称|#ID] [说明或链接] - 打卡完成任务（多个任务时可点选，可附带图片或文件）
+• @我 撤销打卡 [任务名称|#ID] - 撤销刚才的打卡（仅限打卡后几分钟内，之后请联系管理员更正）
+• @我 请假 [日期] [到 结束日期] [#ID] [原因] - 期间不提醒、不计入完成率（默认今天）
+  例: 请假 明天 / 请假 10-20 到 10-25 年假 / 取消请假 [#ID] / 请假名单
+• @我 统计 [任务名称|#ID] - 查看本期完成统计
+• @我 排行榜 [本月|上月|2026-10] [任务名称|#ID] - 查看月度排行榜（按时次数、连续按时期数、首位提交徽章）
 • @我 任务列表 - 查看所有任务
 • @我 我的权限 - 查看我的权限
 
 **子管理员命令：**
 • @我 创建任务 <名称> <cron> [截止时间] [类型]
   例: 创建任务 写周报 0 17 * * 5 15:00 TASK
+• @我 创建单次任务 <名称> <日期> <时间> [类型] - 只截止一次的任务
+  例: 创建单次任务 交报销单 2026-10-23 18:00
+• @我 修改任务 <名称> <字段> <新值> - 修改名称/描述/类型/cron/截止/提前/单次截止
+  例: 修改任务 写周报 cron 0 16 * * 5
+• @我 暂停任务 <名称> / 恢复任务 <名称> - 暂停或恢复提醒
+• @我 跳过今天 <名称> [原因] - 跳过本期（不提醒、不计入完成率）
+  例: 跳过 写日报 2026-10-20 团建 / 取消跳过 写日报 2026-10-20
+• @我 延后提醒 <名称> <分钟> - 将下一次提醒延后
+• @我 指派任务 <名称> @用户... [部门 <部门ID>] - 指定负责人（全员 = 恢复为群内所有人）
+• @我 任务成员 <名称> - 查看负责人、本期需执行的成员和豁免
+• @我 豁免 <名称> @用户 <开始日期> [结束日期] [原因] - 请假等期间不提醒、不计入完成率
+  例: 豁免 写日报 @张三 2026-10-20 2026-10-24 年假 / 取消豁免 写日报 @张三
+• @我 请假 @用户... <日期> [到 结束日期] [原因] - 为成员登记请假 / 取消请假 @用户...
+• @我 补卡 <名称> @用户... [日期] [迟交] [原因] - 将成员某一期更正为已完成（变更留痕）
+  例: 补卡 写日报 @张三 2026-10-15 线下已提交 / 删除打卡 写日报 @张三 2026-10-15 误打卡
+• @我 提醒计划 <名称> [偏移1, 偏移2, ...] - 查看/设置提醒计划
+  例: 提醒计划 写周报 -1d 18:00, -2h, deadline, +30m
+• @我 节假日策略 <名称> [策略] - 查看/设置节假日策略
+  策略: NONE / SKIP_HOLIDAYS / WORKDAYS_ONLY / INCLUDE_MAKEUP
+• @我 任务时区 <名称> [时区|默认] - 查看/设置任务时区
+  例: 任务时区 写周报 Europe/Berlin
+• @我 群时区 [时区|默认] - 查看/设置本群任务的默认时区
+• @我 同步群成员 - 立即从钉钉同步本群成员（默认每小时自动同步）
+• @我 免提醒 @用户... [全局] [原因] - 不 @ 该成员，也不计入完成率（如领导）
+• @我 恢复提醒 @用户... [全局] / 免提醒名单 - 恢复提醒 / 查看本群免提醒名单
+• @我 定时汇总 <日报|周报> [每天|工作日|周五] <HH:MM> [私聊] [排行榜] [@用户...] - 定时发送本群所有任务的完成情况
+  例: 定时汇总 日报 工作日 19:00 / 定时汇总 周报 周五 18:00 私聊 / 加上「排行榜」附带本月前三名
+• @我 汇总列表 / 删除汇总 <ID> / 发送汇总 <ID> - 查看、删除或立即发送定时汇总
+• @我 导出 [本月|上月|本周|上周|日期范围] [提醒记录] [csv] [任务名称|#ID] - 导出本群的打卡记录（Excel）并发到群里
+  例: 导出 本月 周报 / 导出 10-01~10-15 提醒记录
 
 **主管理员命令：**
 • @我 添加管理员 @用户 - 将用户提升为子管理员
@@ -351,6 +2281,12 @@ func (h *MessageHandler) handleHelp(msg *dingtalk.IncomingMessage) error {
 • 0 17 * * 5 (每周五下午5点)
 • 0 0 * * * (每天0点)
 
+**提醒偏移：**
+• deadline (截止/触发时间) • -2h (提前2小时) • +30m (超时30分钟)
+• 09:00 (当天9点) • -1d 18:00 (前一天18点)
+• +30m every 15m x4 (超时后每15分钟催一次，共4次)
+• +1h dm (私聊未完成的人) • +2h summary (向创建人发送未完成汇总)
+
 **任务类型：**
 • TASK - 任务型（过期通报）
 • NOTIFICATION - 通知型（提前提醒）`
@@ -408,11 +2344,139 @@ func (h *MessageHandler) sendReply(msg *dingtalk.IncomingMessage, content string
 	return h.dtClient.SendGroupMessage(msg.ConversationID, content)
 }
 
-// 处理卡片回调
+// 处理卡片回调：按钮 ID 为 "<操作>:<任务ID>"，以点击者的身份执行
+// 提醒卡片上的操作记到卡片对应的那一期，任务选择卡片记到当前这一期
 func (h *MessageHandler) HandleCardCallback(ctx context.Context, callback *dingtalk.CardCallback) error {
-	// TODO: 实现卡片按钮回调处理
-	log.Printf("收到卡片回调: %+v", callback)
-	return nil
+	action, value, ok := strings.Cut(callback.Value, ":")
+	if !ok {
+		log.Printf("未知的卡片操作: %s", callback.Value)
+		return nil
+	}
+	taskID, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("无效的任务ID: %s", value)
+	}
+
+	task, err := h.taskService.GetTaskByID(taskID)
+	if err != nil {
+		return err
+	}
+
+	// 以点击者的身份在任务所在的群内回复
+	msg := &dingtalk.IncomingMessage{
+		ConversationID: task.GroupChatID,
+		SenderStaffID:  callback.UserID,
+		SenderNick:     h.userDisplayName(callback.UserID),
+	}
+	if task.Status != models.TaskStatusActive {
+		return h.sendReply(msg, fmt.Sprintf("❌ 任务 %s 已不在进行中", task.Name))
+	}
+
+	taskDate := h.taskService.TaskDate(*task, time.Now())
+	card, err := h.cardService.Get(callback.OutTrackID)
+	if err != nil {
+		return err
+	}
+	if card != nil && card.TaskID == task.ID {
+		taskDate = card.TaskDate
+	}
+
+	switch action {
+	case services.CardActionCheckIn:
+		return h.completeTask(msg, *task, taskDate, nil)
+	case services.CardActionSnooze:
+		return h.snoozeFromCard(msg, *task, taskDate)
+	case services.CardActionLeave:
+		return h.leaveFromCard(msg, *task, taskDate)
+	case cardActionStats:
+		return h.sendTaskStats(msg, *task)
+	default:
+		log.Printf("未知的卡片操作: %s", callback.Value)
+		return nil
+	}
+}
+
+// snoozeFromCard 提醒卡片上的"稍后提醒"：一段时间后私聊提醒点击者（届时已完成则不提醒）
+func (h *MessageHandler) snoozeFromCard(msg *dingtalk.IncomingMessage, task models.Task, taskDate time.Time) error {
+	if ok, err := h.requireAssigned(msg, task); !ok {
+		return err
+	}
+
+	completed, err := h.taskService.HasCompleted(task.ID, msg.SenderStaffID, taskDate)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 检查打卡状态失败: %v", err))
+	}
+	if completed {
+		return h.sendReply(msg, fmt.Sprintf("✅ 您本期已经完成过 %s 了！", task.Name))
+	}
+
+	remindAt, err := h.cardService.SnoozeMember(task.ID, msg.SenderStaffID, taskDate)
+	if err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ %v", err))
+	}
+
+	loc := h.taskService.Location(task)
+	return h.sendReply(msg, fmt.Sprintf("⏰ 好的，将在 %s 私聊提醒 %s 完成 %s",
+		remindAt.In(loc).Format("15:04"), h.senderName(msg), task.Name))
+}
+
+// leaveFromCard 提醒卡片上的"请假"：点击者对该任务请这一期的假，不再被提醒也不计入完成率
+func (h *MessageHandler) leaveFromCard(msg *dingtalk.IncomingMessage, task models.Task, taskDate time.Time) error {
+	if ok, err := h.requireAssigned(msg, task); !ok {
+		return err
+	}
+
+	leave := models.Leave{
+		UserID:    msg.SenderStaffID,
+		TaskID:    sql.NullInt64{Int64: int64(task.ID), Valid: true},
+		StartDate: taskDate,
+		EndDate:   taskDate,
+		CreatedBy: msg.SenderStaffID,
+	}
+	if _, err := h.taskService.AddLeave(leave); err != nil {
+		return h.sendReply(msg, fmt.Sprintf("❌ 请假失败: %v", err))
+	}
+	h.cardService.Refresh(task, taskDate)
+
+	return h.sendReply(msg, fmt.Sprintf("🙅 已登记 %s 请假，%s 本期（%s）不再提醒",
+		h.senderName(msg), task.Name, taskDate.Format("2006-01-02")))
+}
+
+// requireAssigned 检查发送者（或卡片点击者）是否需要执行任务，不是时回复提示并返回 false
+func (h *MessageHandler) requireAssigned(msg *dingtalk.IncomingMessage, task models.Task) (bool, error) {
+	assigned, err := h.taskService.IsAssigned(task, msg.SenderStaffID)
+	if err != nil {
+		return false, h.sendReply(msg, fmt.Sprintf("❌ 获取任务负责人失败: %v", err))
+	}
+	if !assigned {
+		return false, h.sendReply(msg, fmt.Sprintf("❌ %s 不是任务 %s 的负责人", h.senderName(msg), task.Name))
+	}
+	return true, nil
+}
+
+// senderName 消息发送者的显示名称
+func (h *MessageHandler) senderName(msg *dingtalk.IncomingMessage) string {
+	if msg.SenderNick != "" {
+		return msg.SenderNick
+	}
+	return msg.SenderStaffID
+}
+
+// displayNameOr 用户的显示名称，查不到时为用户ID
+func (h *MessageHandler) displayNameOr(userID string) string {
+	if name := h.userDisplayName(userID); name != "" {
+		return name
+	}
+	return userID
+}
+
+// userDisplayName 用户的显示名称（卡片回调中没有昵称，从用户表查询）
+func (h *MessageHandler) userDisplayName(userID string) string {
+	names, err := h.taskService.GetUserNames([]string{userID})
+	if err == nil && names[userID] != "" {
+		return names[userID]
+	}
+	return ""
 }
 
 // ========================================
@@ -568,6 +2632,8 @@ func (h *MessageHandler) getPermissionDisplayName(perm string) string {
 		"list_tasks":    "查看任务列表",
 		"complete_task": "打卡完成任务",
 		"view_stats":    "查看统计",
+
+		"manage_calendar": "管理节假日日历",
 	}
 
 	if display, ok := permMap[perm]; ok {
@@ -0,0 +1,214 @@
+package handlers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"dingteam-bot/internal/models"
+	"dingteam-bot/internal/services"
+)
+
+func TestParseLeaveRange(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("加载时区失败: %v", err)
+	}
+	// 省略年份的日期按今年解析
+	today := services.StartOfToday(loc)
+	year := today.Year()
+	date := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name      string
+		args      string
+		wantStart time.Time
+		wantEnd   time.Time
+		wantRest  []string
+	}{
+		{
+			name:      "省略日期时为今天",
+			args:      "",
+			wantStart: today,
+			wantEnd:   today,
+		},
+		{
+			name:      "第一个参数不是日期时视为原因",
+			args:      "家里有事",
+			wantStart: today,
+			wantEnd:   today,
+			wantRest:  []string{"家里有事"},
+		},
+		{
+			name:      "明天",
+			args:      "明天 看病",
+			wantStart: today.AddDate(0, 0, 1),
+			wantEnd:   today.AddDate(0, 0, 1),
+			wantRest:  []string{"看病"},
+		},
+		{
+			name:      "今天到后天",
+			args:      "今天 到 后天",
+			wantStart: today,
+			wantEnd:   today.AddDate(0, 0, 2),
+		},
+		{
+			name:      "用「到」分隔的范围",
+			args:      "10-20 到 10-25 #3 出差",
+			wantStart: date(year, 10, 20),
+			wantEnd:   date(year, 10, 25),
+			wantRest:  []string{"#3", "出差"},
+		},
+		{
+			name:      "写在一起的范围",
+			args:      "10-20~10-25",
+			wantStart: date(year, 10, 20),
+			wantEnd:   date(year, 10, 25),
+		},
+		{
+			name:      "带年份的范围",
+			args:      "2026-12-30 至 2027-01-02",
+			wantStart: date(2026, 12, 30),
+			wantEnd:   date(2027, 1, 2),
+		},
+		{
+			name:      "省略年份的跨年范围",
+			args:      "12-30 到 01-02",
+			wantStart: date(year, 12, 30),
+			wantEnd:   date(year+1, 1, 2),
+		},
+		{
+			name:      "写在一起的跨年范围",
+			args:      "12-28到01-03 年假",
+			wantStart: date(year, 12, 28),
+			wantEnd:   date(year+1, 1, 3),
+			wantRest:  []string{"年假"},
+		},
+		{
+			name:      "单独的日期",
+			args:      "2026-10-20 #3",
+			wantStart: date(2026, 10, 20),
+			wantEnd:   date(2026, 10, 20),
+			wantRest:  []string{"#3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, rest, err := parseLeaveRange(strings.Fields(tt.args), loc)
+			if err != nil {
+				t.Fatalf("parseLeaveRange(%q) 返回错误: %v", tt.args, err)
+			}
+			if !start.Equal(tt.wantStart) || !end.Equal(tt.wantEnd) {
+				t.Errorf("parseLeaveRange(%q) = %s ~ %s，期望 %s ~ %s", tt.args,
+					start.Format("2006-01-02"), end.Format("2006-01-02"),
+					tt.wantStart.Format("2006-01-02"), tt.wantEnd.Format("2006-01-02"))
+			}
+			if strings.Join(rest, " ") != strings.Join(tt.wantRest, " ") {
+				t.Errorf("parseLeaveRange(%q) 剩余参数 = %q，期望 %q", tt.args, rest, tt.wantRest)
+			}
+		})
+	}
+}
+
+func TestParseLeaveRangeInvalid(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("加载时区失败: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		args string
+	}{
+		{name: "结束日期早于开始日期", args: "10-25 到 10-20"},
+		{name: "带年份时不视为跨年", args: "2026-12-30 到 2026-01-02"},
+		{name: "顺延后超过半年", args: "10-20 到 05-01"},
+		{name: "结束日期无效", args: "10-20 到 下周"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start, end, _, err := parseLeaveRange(strings.Fields(tt.args), loc)
+			if err == nil {
+				t.Errorf("parseLeaveRange(%q) = %s ~ %s，期望返回错误", tt.args,
+					start.Format("2006-01-02"), end.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestSplitCheckIn(t *testing.T) {
+	single := []models.Task{{ID: 3, Name: "写周报"}}
+	several := []models.Task{{ID: 3, Name: "写周报"}, {ID: 12, Name: "Daily Standup"}}
+
+	tests := []struct {
+		name           string
+		tasks          []models.Task
+		args           string
+		hasAttachments bool
+		wantSelector   string
+		wantNote       string
+	}{
+		{name: "为空", tasks: several, args: "", wantSelector: "", wantNote: ""},
+		{name: "第一个词确定任务", tasks: several, args: "写周报 已发邮件", wantSelector: "写周报", wantNote: "已发邮件"},
+		{name: "只有任务名称", tasks: several, args: "#12", wantSelector: "#12", wantNote: ""},
+		{name: "多个任务时一个词按任务匹配", tasks: several, args: "周总结", wantSelector: "周总结", wantNote: ""},
+		{name: "只有一个任务时不匹配的词作为说明", tasks: single, args: "done", wantSelector: "", wantNote: "done"},
+		{name: "只有一个任务时匹配的词仍作为任务选择", tasks: single, args: "周报", wantSelector: "周报", wantNote: ""},
+		{name: "多个词时全部作为说明", tasks: several, args: "今天 搞定了", wantSelector: "", wantNote: "今天 搞定了"},
+		{name: "链接作为说明", tasks: several, args: "https://example.com/doc", wantSelector: "", wantNote: "https://example.com/doc"},
+		{name: "附带附件时作为说明", tasks: several, args: "初稿", hasAttachments: true, wantSelector: "", wantNote: "初稿"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selector, note := splitCheckIn(tt.tasks, tt.args, tt.hasAttachments)
+			if selector != tt.wantSelector || note != tt.wantNote {
+				t.Errorf("splitCheckIn(%q) = (%q, %q)，期望 (%q, %q)", tt.args, selector, note, tt.wantSelector, tt.wantNote)
+			}
+		})
+	}
+}
+
+func TestLegacyCommand(t *testing.T) {
+	tests := []struct {
+		content string
+		want    string
+	}{
+		{content: "暂停任务 月度报告", want: "暂停任务"},
+		{content: "恢复任务 月度报告", want: "恢复任务"},
+		{content: "修改任务 写日报 描述 写完回复已完成", want: "修改任务"},
+		{content: "编辑任务 周报告 截止 17:00", want: "修改任务"},
+		{content: "创建任务 周报告 0 16 * * 5", want: "创建任务"},
+		{content: "创建单次任务 季度报告 2026-10-30 18:00", want: "创建单次任务"},
+		{content: "跳过 周报告 2026-10-20", want: "跳过"},
+		{content: "取消跳过 周报告 2026-10-20", want: "取消跳过"},
+		{content: "推迟提醒 周报告 30", want: "延后提醒"},
+		{content: "豁免 @张三 周报告 到 10-30", want: "豁免"},
+		{content: "取消豁免 周报告 3", want: "取消豁免"},
+		{content: "指派任务 周报告 @张三", want: "指派任务"},
+		{content: "任务成员 周报告", want: "任务成员"},
+		{content: "提醒计划 周报告 -1h deadline", want: "提醒计划"},
+		{content: "节假日策略 日报告 skip_holidays", want: "节假日策略"},
+		{content: "任务时区 日报告 Europe/Berlin", want: "任务时区"},
+		{content: "免提醒名单", want: "免提醒名单"},
+		{content: "免提醒 @张三 统计不需要", want: "免提醒"},
+		{content: "定时汇总 日报告 daily 18:00", want: "定时汇总"},
+		{content: "发送汇总 3 统计", want: "发送汇总"},
+		{content: "补卡 写日报 @张三 线下已完成", want: "补卡"},
+		{content: "已完成 写日报", want: "已完成"},
+		{content: "周报告 统计", want: "统计"},
+		{content: "任务列表", want: "任务列表"},
+		{content: "帮助", want: "帮助"},
+		{content: "你好", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := legacyCommand(tt.content); got != tt.want {
+			t.Errorf("legacyCommand(%q) = %q，期望 %q", tt.content, got, tt.want)
+		}
+	}
+}
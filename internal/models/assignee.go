@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// AssigneeType 任务负责人的类型
+type AssigneeType string
+
+const (
+	AssigneeUser       AssigneeType = "USER"       // 指定用户
+	AssigneeDepartment AssigneeType = "DEPARTMENT" // 钉钉部门（部门内所有成员）
+)
+
+// TaskAssignee 任务的负责人（未指定负责人时为群内所有成员）
+type TaskAssignee struct {
+	TaskID     int          `json:"task_id"`
+	Type       AssigneeType `json:"type"`
+	AssigneeID string       `json:"assignee_id"` // 用户ID或部门ID
+	CreatedBy  string       `json:"created_by"`
+	CreatedAt  time.Time    `json:"created_at"`
+}
+
+// TaskExemption 成员在一段日期内免于执行任务（如请假），不提醒也不计入完成率
+type TaskExemption struct {
+	ID        int            `json:"id"`
+	TaskID    int            `json:"task_id"`
+	UserID    string         `json:"user_id"`
+	StartDate time.Time      `json:"start_date"`
+	EndDate   time.Time      `json:"end_date"` // 含当天
+	Reason    sql.NullString `json:"reason"`
+	CreatedBy string         `json:"created_by"`
+	CreatedAt time.Time      `json:"created_at"`
+}
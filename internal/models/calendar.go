@@ -0,0 +1,63 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// CalendarPolicy 任务遵循节假日日历的方式
+type CalendarPolicy string
+
+const (
+	CalendarPolicyNone          CalendarPolicy = "NONE"           // 不考虑节假日，按 cron 触发
+	CalendarPolicySkipHolidays  CalendarPolicy = "SKIP_HOLIDAYS"  // 跳过法定节假日
+	CalendarPolicyWorkdaysOnly  CalendarPolicy = "WORKDAYS_ONLY"  // 只在工作日触发（周末和节假日跳过，调休上班日照常）
+	CalendarPolicyIncludeMakeup CalendarPolicy = "INCLUDE_MAKEUP" // 跳过节假日，调休上班日即使 cron 不触发也照常执行
+)
+
+// ParseCalendarPolicy 解析日历策略，支持英文常量和中文别名，空串视为 NONE
+func ParseCalendarPolicy(s string) (CalendarPolicy, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "", "NONE", "无", "不限":
+		return CalendarPolicyNone, nil
+	case "SKIP_HOLIDAYS", "跳过节假日":
+		return CalendarPolicySkipHolidays, nil
+	case "WORKDAYS_ONLY", "仅工作日", "工作日":
+		return CalendarPolicyWorkdaysOnly, nil
+	case "INCLUDE_MAKEUP", "含调休", "调休上班":
+		return CalendarPolicyIncludeMakeup, nil
+	}
+	return "", fmt.Errorf("未知的日历策略: %s（可选: NONE, SKIP_HOLIDAYS, WORKDAYS_ONLY, INCLUDE_MAKEUP）", s)
+}
+
+// DisplayName 日历策略的中文名称
+func (p CalendarPolicy) DisplayName() string {
+	switch p {
+	case CalendarPolicySkipHolidays:
+		return "跳过节假日"
+	case CalendarPolicyWorkdaysOnly:
+		return "仅工作日"
+	case CalendarPolicyIncludeMakeup:
+		return "跳过节假日，调休上班日照常"
+	default:
+		return "不考虑节假日"
+	}
+}
+
+// HolidayKind 日历日期类型
+type HolidayKind string
+
+const (
+	HolidayKindHoliday HolidayKind = "HOLIDAY" // 放假
+	HolidayKindWorkday HolidayKind = "WORKDAY" // 调休上班
+)
+
+// Holiday 节假日日历中的一天
+type Holiday struct {
+	ID        int         `json:"id"`
+	Date      time.Time   `json:"date"`
+	Kind      HolidayKind `json:"kind"`
+	Name      string      `json:"name"`
+	CreatedAt time.Time   `json:"created_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// CompletionAuditAction 打卡记录的变更类型
+type CompletionAuditAction string
+
+const (
+	CompletionAuditUndo       CompletionAuditAction = "UNDO"        // 成员撤销自己的打卡
+	CompletionAuditMarkDone   CompletionAuditAction = "MARK_DONE"   // 管理员补记为已完成
+	CompletionAuditMarkUndone CompletionAuditAction = "MARK_UNDONE" // 管理员改为未完成（删除打卡）
+)
+
+// CompletionAudit 打卡记录的变更审计（撤销、补卡、删除打卡）
+type CompletionAudit struct {
+	ID                  int                   `json:"id"`
+	TaskID              int                   `json:"task_id"`
+	UserID              string                `json:"user_id"`
+	TaskDate            time.Time             `json:"task_date"`
+	Action              CompletionAuditAction `json:"action"`
+	OperatorID          string                `json:"operator_id"`
+	Reason              sql.NullString        `json:"reason"`
+	PreviousCompletedAt sql.NullTime          `json:"previous_completed_at"` // 变更前的打卡时间，之前未打卡时为空
+	PreviousOnTime      sql.NullBool          `json:"previous_on_time"`
+	IsOnTime            sql.NullBool          `json:"is_on_time"` // 补卡后的按时状态，撤销/删除时为空
+	CreatedAt           time.Time             `json:"created_at"`
+}
@@ -0,0 +1,42 @@
+package models
+
+import "time"
+
+// DigestKind 定时汇总的内容
+type DigestKind string
+
+const (
+	DigestDaily  DigestKind = "DAILY"  // 群内每个任务本期的完成情况和未完成名单
+	DigestWeekly DigestKind = "WEEKLY" // 本周每个任务和每个成员的完成情况
+)
+
+// DigestTarget 定时汇总的发送方式
+type DigestTarget string
+
+const (
+	DigestToGroup   DigestTarget = "GROUP"   // 发到任务所在的群
+	DigestToPrivate DigestTarget = "PRIVATE" // 私聊接收人（未指定时为创建人）
+)
+
+// Digest 定时发送的完成情况汇总（日报、周报）
+type Digest struct {
+	ID                 int            `json:"id"`
+	GroupChatID        string         `json:"group_chat_id"` // 汇总该群的所有任务
+	Kind               DigestKind     `json:"kind"`
+	CronExpr           string         `json:"cron_expr"`       // 发送时间，按群时区计算
+	CalendarPolicy     CalendarPolicy `json:"calendar_policy"` // 节假日策略（如仅工作日发送）
+	Target             DigestTarget   `json:"target"`
+	Recipients         []string       `json:"recipients"`          // 私聊接收人
+	IncludeLeaderboard bool           `json:"include_leaderboard"` // 末尾附带本月排行榜前几名
+	CreatedBy          string         `json:"created_by"`
+	CreatedAt          time.Time      `json:"created_at"`
+	LastSentAt         *time.Time     `json:"last_sent_at,omitempty"`
+}
+
+// DisplayName 汇总类型的中文名称
+func (k DigestKind) DisplayName() string {
+	if k == DigestWeekly {
+		return "周报"
+	}
+	return "日报"
+}
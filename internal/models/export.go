@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// ExportFormat 导出文件格式
+type ExportFormat string
+
+const (
+	ExportCSV  ExportFormat = "csv"
+	ExportXLSX ExportFormat = "xlsx"
+)
+
+// IsValid 是否为支持的导出格式
+func (f ExportFormat) IsValid() bool {
+	return f == ExportCSV || f == ExportXLSX
+}
+
+// ContentType 导出文件的 MIME 类型
+func (f ExportFormat) ContentType() string {
+	if f == ExportXLSX {
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	}
+	return "text/csv; charset=utf-8"
+}
+
+// ExportKind 导出的数据
+type ExportKind string
+
+const (
+	ExportCompletions     ExportKind = "completions"      // 打卡记录
+	ExportReminderLogs    ExportKind = "reminder-logs"    // 提醒发送记录
+	ExportPermissionAudit ExportKind = "permission-audit" // 权限审计日志
+)
+
+// DisplayName 导出数据的中文名称（用于文件名和工作表名）
+func (k ExportKind) DisplayName() string {
+	switch k {
+	case ExportCompletions:
+		return "打卡记录"
+	case ExportReminderLogs:
+		return "提醒记录"
+	case ExportPermissionAudit:
+		return "权限审计"
+	}
+	return string(k)
+}
+
+// ExportQuery 导出的筛选条件（TaskID、GroupChatID、UserID 为空时不限）
+// 打卡记录按任务日期筛选，提醒记录和审计日志按发生时间所在的日期筛选；权限审计不按任务和群筛选
+type ExportQuery struct {
+	TaskID      int
+	GroupChatID string
+	UserID      string
+	From        time.Time // 日期范围（含两端）
+	To          time.Time
+}
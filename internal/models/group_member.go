@@ -0,0 +1,30 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// GroupMember 从钉钉同步的群成员
+type GroupMember struct {
+	GroupChatID string         `json:"group_chat_id"`
+	UserID      string         `json:"user_id"`
+	UserName    sql.NullString `json:"user_name"`
+	DeptLeader  bool           `json:"dept_leader"` // 钉钉部门主管标记（开启 DINGTALK_EXCLUDE_DEPT_LEADERS 时定期刷新）
+	SyncedAt    time.Time      `json:"synced_at"`   // 最近一次同步时仍在群内的时间
+}
+
+// GroupMemberPeriod 成员一次在群内的期间（同步时记录）
+type GroupMemberPeriod struct {
+	GroupMember
+	JoinedAt sql.NullTime `json:"joined_at"` // 为空表示开始记录前已在群内
+	LeftAt   sql.NullTime `json:"left_at"`   // 为空表示仍在群内
+}
+
+// DisplayName 成员的显示名称（没有名称时为 userid）
+func (m GroupMember) DisplayName() string {
+	if m.UserName.Valid && m.UserName.String != "" {
+		return m.UserName.String
+	}
+	return m.UserID
+}
@@ -0,0 +1,10 @@
+package models
+
+import "time"
+
+// GroupSettings 群级别的设置
+type GroupSettings struct {
+	GroupChatID string    `json:"group_chat_id"`
+	Timezone    string    `json:"timezone"` // 群内任务的默认时区（IANA 名称，如 Asia/Singapore），为空时使用全局时区
+	UpdatedAt   time.Time `json:"updated_at"`
+}
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+// LeaderboardQuery 排行榜的范围：指定任务，或群内所有任务型任务，在 Month 所在的月份
+type LeaderboardQuery struct {
+	TaskID      int
+	GroupChatID string
+	Month       time.Time // 月份内任意一天
+}
+
+// LeaderboardEntry 成员在排行榜上的成绩（只计算已截止的各期）
+type LeaderboardEntry struct {
+	Rank           int     `json:"rank"` // 成绩相同的成员名次相同
+	UserID         string  `json:"user_id"`
+	UserName       string  `json:"user_name"`
+	Expected       int     `json:"expected"`
+	Completed      int     `json:"completed"`
+	OnTime         int     `json:"on_time"`
+	CompletionRate float64 `json:"completion_rate"`
+	FirstSubmits   int     `json:"first_submits"`  // 首位提交徽章：本月各期第一个打卡的次数
+	CurrentStreak  int     `json:"current_streak"` // 截至月末（或今天）连续按时完成的期数，可跨月
+	LongestStreak  int     `json:"longest_streak"` // 最近一年内最长的连续按时完成期数
+}
+
+// Leaderboard 月度排行榜：按时完成次数多的在前，其次为完成率和首位提交次数
+type Leaderboard struct {
+	TaskID      int                `json:"task_id,omitempty"`
+	TaskName    string             `json:"task_name,omitempty"`
+	GroupChatID string             `json:"group_chat_id,omitempty"`
+	Month       string             `json:"month"` // 如 2026-10
+	From        time.Time          `json:"from"`
+	To          time.Time          `json:"to"`
+	Entries     []LeaderboardEntry `json:"entries"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// Leave 成员请假：期间不被提醒，也不计入完成率（未指定任务时对所有任务生效）
+type Leave struct {
+	ID        int            `json:"id"`
+	UserID    string         `json:"user_id"`
+	TaskID    sql.NullInt64  `json:"task_id"` // 只对某个任务请假，为空时对所有任务生效
+	StartDate time.Time      `json:"start_date"`
+	EndDate   time.Time      `json:"end_date"` // 含当天
+	Reason    sql.NullString `json:"reason"`
+	CreatedBy string         `json:"created_by"` // 本人请假或管理员代为登记
+	CreatedAt time.Time      `json:"created_at"`
+}
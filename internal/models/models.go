@@ -15,9 +15,18 @@ const (
 type TaskStatus string
 
 const (
-	TaskStatusActive  TaskStatus = "ACTIVE"
-	TaskStatusPaused  TaskStatus = "PAUSED"
-	TaskStatusDeleted TaskStatus = "DELETED"
+	TaskStatusActive   TaskStatus = "ACTIVE"
+	TaskStatusPaused   TaskStatus = "PAUSED"
+	TaskStatusDeleted  TaskStatus = "DELETED"
+	TaskStatusFinished TaskStatus = "FINISHED" // 已结束：单次任务已截止，或周期任务超出结束日期/执行次数
+)
+
+// ScheduleKind 任务的调度方式
+type ScheduleKind string
+
+const (
+	ScheduleKindCron ScheduleKind = "CRON" // 按 cron 周期执行（可限定开始日期、结束日期和执行次数）
+	ScheduleKindOnce ScheduleKind = "ONCE" // 单次任务，截止时间为 due_at
 )
 
 type Task struct {
@@ -37,6 +46,41 @@ type Task struct {
 	UpdatedAt      time.Time      `json:"updated_at"`
 	LastRunAt      sql.NullTime   `json:"last_run_at"`
 	NextRunAt      sql.NullTime   `json:"next_run_at"`
+	CalendarPolicy CalendarPolicy `json:"calendar_policy"`         // 节假日策略
+	ScheduleKind   ScheduleKind   `json:"schedule_kind"`           // 调度方式
+	DueAt          sql.NullTime   `json:"due_at"`                  // 单次任务的截止时间
+	StartDate      sql.NullTime   `json:"start_date"`              // 周期任务的开始日期
+	EndDate        sql.NullTime   `json:"end_date"`                // 周期任务的结束日期（含当天）
+	MaxOccurrences int            `json:"max_occurrences"`         // 周期任务最多执行次数（0 为不限）
+	Timezone       string         `json:"timezone"`                // 任务时区（为空时使用群默认时区）
+	ReminderPlan   []string       `json:"reminder_plan,omitempty"` // 提醒计划（为空时使用默认计划）
+}
+
+// IsOnce 是否为单次任务
+func (t Task) IsOnce() bool {
+	return t.ScheduleKind == ScheduleKindOnce
+}
+
+// IsBounded 任务是否会结束（单次任务，或设置了结束日期/执行次数的周期任务）
+func (t Task) IsBounded() bool {
+	return t.IsOnce() || t.EndDate.Valid || t.MaxOccurrences > 0
+}
+
+// TaskUpdate 修改任务的字段（nil 表示不修改）
+type TaskUpdate struct {
+	Name           *string `json:"name"`
+	Description    *string `json:"description"`
+	Type           *string `json:"type"`
+	CronExpr       *string `json:"cron_expr"`
+	DeadlineTime   *string `json:"deadline_time"` // HH:MM，空字符串表示清除
+	AdvanceMinutes *int    `json:"advance_minutes"`
+	DueAt          *string `json:"due_at"` // 单次任务的截止时间，如 2026-10-23 18:00
+}
+
+// IsEmpty 是否没有要修改的字段
+func (u TaskUpdate) IsEmpty() bool {
+	return u.Name == nil && u.Description == nil && u.Type == nil && u.CronExpr == nil &&
+		u.DeadlineTime == nil && u.AdvanceMinutes == nil && u.DueAt == nil
 }
 
 type CompletionRecord struct {
@@ -52,15 +96,27 @@ type CompletionRecord struct {
 
 type ReminderType string
 
+// 提醒类型由任务提醒计划中的偏移决定（见 ReminderOffset.ReminderType）
+const (
+	ReminderTypeScheduled ReminderType = "SCHEDULED"    // 固定时刻提醒（如 "-1d 18:00"）
+	ReminderTypeAdvance   ReminderType = "ADVANCE"      // 截止前提醒（如 "-2h"）
+	ReminderTypeDeadline  ReminderType = "DEADLINE"     // 截止时间（任务型）
+	ReminderTypeTrigger   ReminderType = "TRIGGER_TIME" // 触发时间（通知型）
+	ReminderTypeOverdue   ReminderType = "OVERDUE"      // 截止后提醒（如 "+30m"）
+
+	// 以下为旧版固定提醒类型，仅用于识别历史日志
+	ReminderTypeMorning10AM  ReminderType = "MORNING_10AM"  // 10点提醒
+	ReminderTypeAdvance1Hour ReminderType = "ADVANCE_1HOUR" // 提前1小时（任务型）
+	ReminderTypeAdvance30Min ReminderType = "ADVANCE_30MIN" // 提前30分钟（通知型）
+	ReminderTypeNormal       ReminderType = "NORMAL"        // 普通提醒（兼容旧代码）
+)
+
+// 提醒日志状态
 const (
-	ReminderTypeMorning10AM  ReminderType = "MORNING_10AM"   // 10点提醒
-	ReminderTypeAdvance1Hour ReminderType = "ADVANCE_1HOUR"  // 提前1小时（任务型）
-	ReminderTypeAdvance30Min ReminderType = "ADVANCE_30MIN"  // 提前30分钟（通知型）
-	ReminderTypeDeadline     ReminderType = "DEADLINE"       // 截止时间（任务型）
-	ReminderTypeTrigger      ReminderType = "TRIGGER_TIME"   // 触发时间（通知型）
-	ReminderTypeOverdue      ReminderType = "OVERDUE"        // 已超时（兼容旧代码）
-	ReminderTypeNormal       ReminderType = "NORMAL"         // 普通提醒（兼容旧代码）
-	ReminderTypeAdvance      ReminderType = "ADVANCE"        // 提前提醒（兼容旧代码）
+	ReminderStatusSending = "SENDING" // 已登记，正在发送
+	ReminderStatusSent    = "SENT"    // 已发送
+	ReminderStatusFailed  = "FAILED"  // 发送失败
+	ReminderStatusSkipped = "SKIPPED" // 未发送（停机错过超过补发窗口，或全部已完成停止升级）
 )
 
 type ReminderLog struct {
@@ -68,6 +124,9 @@ type ReminderLog struct {
 	TaskID         int            `json:"task_id"`
 	GroupChatID    string         `json:"group_chat_id"`
 	ReminderType   string         `json:"reminder_type"`
+	ReminderOffset string         `json:"reminder_offset"` // 提醒计划中的偏移，如 "-2h"
+	OccurrenceAt   sql.NullTime   `json:"occurrence_at"`   // 本次提醒对应的截止/触发时间
+	Status         string         `json:"status"`
 	MessageText    sql.NullString `json:"message_text"`
 	SentAt         time.Time      `json:"sent_at"`
 	MemberCount    int            `json:"member_count"`
@@ -76,13 +135,15 @@ type ReminderLog struct {
 
 // 任务统计
 type TaskStats struct {
-	TaskID         int       `json:"task_id"`
-	TaskName       string    `json:"task_name"`
-	TaskType       TaskType  `json:"task_type"`
-	TaskDate       time.Time `json:"task_date"`
-	TotalMembers   int       `json:"total_members"`
-	CompletedCount int       `json:"completed_count"`
-	CompletionRate float64   `json:"completion_rate"`
-	CompletedUsers []string  `json:"completed_users"`
-	PendingUsers   []string  `json:"pending_users"`
+	TaskID         int          `json:"task_id"`
+	TaskName       string       `json:"task_name"`
+	TaskType       TaskType     `json:"task_type"`
+	TaskDate       time.Time    `json:"task_date"`
+	TotalMembers   int          `json:"total_members"`
+	CompletedCount int          `json:"completed_count"`
+	CompletionRate float64      `json:"completion_rate"`
+	CompletedUsers []string     `json:"completed_users"`
+	PendingUsers   []string     `json:"pending_users"`
+	Skipped        bool         `json:"skipped"`               // 本期已被管理员跳过（不计入完成率）
+	Submissions    []Submission `json:"submissions,omitempty"` // 本期打卡附带的提交内容
 }
@@ -0,0 +1,35 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// OverrideKind 单次执行的调整方式
+type OverrideKind string
+
+const (
+	OverrideSkip   OverrideKind = "SKIP"   // 跳过某个任务日期：不提醒、不计入完成率
+	OverrideSnooze OverrideKind = "SNOOZE" // 将下一次提醒延后若干分钟
+)
+
+// OccurrenceOverride 对任务某一次执行的调整（跳过或延后提醒）
+type OccurrenceOverride struct {
+	ID            int            `json:"id"`
+	TaskID        int            `json:"task_id"`
+	Kind          OverrideKind   `json:"kind"`
+	TaskDate      sql.NullTime   `json:"task_date"`      // 跳过的任务日期
+	SnoozeMinutes int            `json:"snooze_minutes"` // 延后的分钟数
+	ReminderAt    sql.NullTime   `json:"reminder_at"`    // 被延后的提醒的原定时间（提醒触发时才确定）
+	Reason        sql.NullString `json:"reason"`
+	CreatedBy     string         `json:"created_by"`
+	CreatedAt     time.Time      `json:"created_at"`
+}
+
+// SnoozeUntil 延后后的提醒时间（尚未应用到某次提醒时返回零值）
+func (o OccurrenceOverride) SnoozeUntil() time.Time {
+	if !o.ReminderAt.Valid {
+		return time.Time{}
+	}
+	return o.ReminderAt.Time.Add(time.Duration(o.SnoozeMinutes) * time.Minute)
+}
@@ -23,6 +23,8 @@ const (
 	PermListTasks    PermissionName = "list_tasks"    // 查看任务列表
 	PermCompleteTask PermissionName = "complete_task" // 打卡完成任务
 	PermViewStats    PermissionName = "view_stats"    // 查看统计
+
+	PermManageCalendar PermissionName = "manage_calendar" // 管理节假日日历
 )
 
 // User 用户模型
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// ReminderCard 以互动卡片发送的任务提醒，按钮回调和刷新卡片时按 OutTrackID 查找
+type ReminderCard struct {
+	OutTrackID  string    `json:"out_track_id"`
+	TaskID      int       `json:"task_id"`
+	GroupChatID string    `json:"group_chat_id"`
+	TaskDate    time.Time `json:"task_date"` // 提醒对应的任务日期，卡片上的打卡记到这一期
+	Title       string    `json:"title"`
+	Body        string    `json:"body"` // 卡片正文（不含实时的完成进度）
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MemberSnooze 成员点击"稍后提醒"后，到时间私聊提醒该成员（已完成则不再提醒）
+type MemberSnooze struct {
+	ID       int       `json:"id"`
+	TaskID   int       `json:"task_id"`
+	UserID   string    `json:"user_id"`
+	TaskDate time.Time `json:"task_date"`
+	RemindAt time.Time `json:"remind_at"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// ReminderExclusion 成员的免提醒设置：免提醒的成员不被 @、不计入未完成名单和完成率
+// GroupChatID 为空表示对所有群生效；群内设置优先于全局设置，全局设置优先于钉钉部门主管标记
+type ReminderExclusion struct {
+	UserID      string         `json:"user_id"`
+	GroupChatID string         `json:"group_chat_id"`
+	Excluded    bool           `json:"excluded"` // false 表示强制提醒（覆盖全局设置或部门主管标记）
+	Reason      sql.NullString `json:"reason"`
+	CreatedBy   string         `json:"created_by"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
@@ -0,0 +1,484 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReminderOffsetDeadline 截止时间（通知型为触发时间）本身
+const ReminderOffsetDeadline = "deadline"
+
+// 提醒偏移写法的最大长度（与 task_reminder_plans.offset_spec 一致）
+const maxOffsetSpecLen = 200
+
+// 升级步骤重复催办的默认次数、最大次数和最小间隔
+const (
+	defaultEscalationTimes = 3
+	maxEscalationTimes     = 20
+	minEscalationEvery     = 5 * time.Minute
+)
+
+// EscalationAction 超时升级步骤的动作
+type EscalationAction string
+
+const (
+	EscalationGroup   EscalationAction = "group"   // 群内 @ 未完成的人
+	EscalationDM      EscalationAction = "dm"      // 私聊每个未完成的人
+	EscalationSummary EscalationAction = "summary" // 向创建人（或指定群）发送未完成汇总
+)
+
+// ReminderPlanItem 任务提醒计划中的一项（按 position 顺序执行）
+type ReminderPlanItem struct {
+	ID         int       `json:"id"`
+	TaskID     int       `json:"task_id"`
+	Position   int       `json:"position"`
+	OffsetSpec string    `json:"offset_spec"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// ReminderOffset 解析后的提醒偏移
+//
+// 支持的写法（均相对于截止时间，通知型为触发时间）：
+//   - "deadline"          截止时间本身
+//   - "-2h" / "-30m"      截止前 N 小时 / 分钟
+//   - "+30m" / "+1h"      截止后 N 分钟 / 小时（超时通报）
+//   - "09:00"             截止当天 09:00
+//   - "-1d 18:00"         截止前一天 18:00
+//
+// 截止时间或截止后的提醒可以追加升级步骤（仅任务型生效，全部完成后自动停止）：
+//   - "+30m every 15m x4" 截止后 30 分钟起每 15 分钟 @ 一次未完成的人，共 4 次（省略 xN 时为 3 次）
+//   - "+1h dm"            截止后 1 小时私聊每个未完成的人
+//   - "+2h summary"       截止后 2 小时向任务创建人私聊发送未完成汇总
+//   - "+2h summary to=cidXXX" 汇总发送到指定群（如主管群）
+type ReminderOffset struct {
+	Spec     string        `json:"spec"`
+	Days     int           `json:"days"`      // 相对截止日期的天数（仅固定时刻）
+	HasClock bool          `json:"has_clock"` // 是否为固定时刻
+	Hour     int           `json:"hour"`
+	Minute   int           `json:"minute"`
+	Duration time.Duration `json:"duration"` // 相对截止时间的偏移（负数为提前）
+
+	Action       EscalationAction `json:"action,omitempty"`         // 升级动作，为空表示普通提醒
+	Every        time.Duration    `json:"every,omitempty"`          // 重复催办的间隔
+	Times        int              `json:"times,omitempty"`          // 重复催办的总次数
+	Step         int              `json:"step,omitempty"`           // 展开后的第几次（从 1 开始）
+	TargetChatID string           `json:"target_chat_id,omitempty"` // 汇总发送的群，为空时私聊任务创建人
+}
+
+// ParseReminderOffset 解析提醒偏移写法
+func ParseReminderOffset(spec string) (ReminderOffset, error) {
+	spec = strings.Join(strings.Fields(spec), " ")
+	if len(spec) > maxOffsetSpecLen {
+		return ReminderOffset{Spec: spec}, fmt.Errorf("提醒偏移 %q 过长", spec)
+	}
+
+	// 偏移之后的部分为升级步骤
+	fields := strings.Fields(spec)
+	base := len(fields)
+	for i, f := range fields {
+		if isEscalationToken(f) {
+			base = i
+			break
+		}
+	}
+
+	offset, err := parseBaseOffset(strings.Join(fields[:base], " "))
+	offset.Spec = spec
+	if err != nil || base == len(fields) {
+		return offset, err
+	}
+
+	if offset.HasClock || offset.Duration < 0 {
+		return offset, fmt.Errorf("无效的提醒偏移 %q: 升级步骤只能用于截止时间或截止后的提醒", spec)
+	}
+	if err := offset.parseEscalation(fields[base:]); err != nil {
+		return offset, fmt.Errorf("无效的提醒偏移 %q: %w", spec, err)
+	}
+	return offset, nil
+}
+
+// parseBaseOffset 解析不含升级步骤的偏移
+func parseBaseOffset(spec string) (ReminderOffset, error) {
+	offset := ReminderOffset{Spec: spec}
+
+	switch strings.ToLower(spec) {
+	case ReminderOffsetDeadline, "trigger", "截止":
+		return offset, nil
+	case "":
+		return offset, fmt.Errorf("提醒偏移不能为空")
+	}
+
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 1:
+		if hour, minute, ok := parseClock(fields[0]); ok {
+			offset.HasClock, offset.Hour, offset.Minute = true, hour, minute
+			return offset, nil
+		}
+		d, err := parseSignedDuration(fields[0])
+		if err != nil {
+			return offset, fmt.Errorf("无效的提醒偏移 %q: %w", spec, err)
+		}
+		offset.Duration = d
+		return offset, nil
+
+	case 2:
+		days, err := parseDays(fields[0])
+		if err != nil {
+			return offset, fmt.Errorf("无效的提醒偏移 %q: %w", spec, err)
+		}
+		hour, minute, ok := parseClock(fields[1])
+		if !ok {
+			return offset, fmt.Errorf("无效的提醒偏移 %q: 时间格式应为 HH:MM", spec)
+		}
+		offset.Days, offset.HasClock, offset.Hour, offset.Minute = days, true, hour, minute
+		return offset, nil
+	}
+
+	return offset, fmt.Errorf("无效的提醒偏移 %q", spec)
+}
+
+// isEscalationToken 判断是否为升级步骤的写法
+func isEscalationToken(f string) bool {
+	lower := strings.ToLower(f)
+	switch lower {
+	case "every", "dm", "私聊", "summary", "汇总":
+		return true
+	}
+	if strings.HasPrefix(lower, "to=") || strings.HasPrefix(f, "每") {
+		return true
+	}
+	_, ok := parseTimes(lower)
+	return ok
+}
+
+// parseEscalation 解析升级步骤：every 15m / 每15m、x4、dm / 私聊、summary / 汇总、to=群ID
+func (o *ReminderOffset) parseEscalation(fields []string) error {
+	o.Action = EscalationGroup
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		lower := strings.ToLower(f)
+
+		switch {
+		case lower == "every" || strings.HasPrefix(f, "每"):
+			value := strings.TrimPrefix(f, "每")
+			if lower == "every" {
+				if i+1 >= len(fields) {
+					return fmt.Errorf("every 后需指定间隔，如 every 15m")
+				}
+				i++
+				value = fields[i]
+			}
+			every, err := time.ParseDuration(value)
+			if err != nil || every%time.Minute != 0 {
+				return fmt.Errorf("无效的催办间隔 %q", value)
+			}
+			if every < minEscalationEvery {
+				return fmt.Errorf("催办间隔不能小于 %s", FormatOffsetDuration(minEscalationEvery))
+			}
+			o.Every = every
+
+		case lower == "dm" || f == "私聊":
+			o.Action = EscalationDM
+
+		case lower == "summary" || f == "汇总":
+			o.Action = EscalationSummary
+
+		case strings.HasPrefix(lower, "to="):
+			o.TargetChatID = f[len("to="):]
+			if o.TargetChatID == "" {
+				return fmt.Errorf("to= 后需指定群ID")
+			}
+
+		default:
+			times, ok := parseTimes(lower)
+			if !ok {
+				return fmt.Errorf("无法识别 %q", f)
+			}
+			if times < 1 || times > maxEscalationTimes {
+				return fmt.Errorf("催办次数应在 1-%d 之间", maxEscalationTimes)
+			}
+			o.Times = times
+		}
+	}
+
+	if o.TargetChatID != "" && o.Action != EscalationSummary {
+		return fmt.Errorf("to= 只能用于 summary")
+	}
+	if o.Times > 1 && o.Every == 0 {
+		return fmt.Errorf("重复催办需指定间隔，如 every 15m")
+	}
+	if o.Every > 0 && o.Times == 0 {
+		o.Times = defaultEscalationTimes
+	}
+	if o.Times == 0 {
+		o.Times = 1
+	}
+	return nil
+}
+
+// parseTimes 解析催办次数 "x4" / "×4"
+func parseTimes(s string) (int, bool) {
+	rest, ok := strings.CutPrefix(s, "x")
+	if !ok {
+		rest, ok = strings.CutPrefix(s, "×")
+	}
+	if !ok || rest == "" {
+		return 0, false
+	}
+	n, err := strconv.Atoi(rest)
+	return n, err == nil
+}
+
+// IsEscalation 是否为升级步骤
+func (o ReminderOffset) IsEscalation() bool {
+	return o.Action != ""
+}
+
+// Key 提醒项的幂等键（写入 reminder_logs.reminder_offset），按规范写法计算，重复催办的每一次各不相同
+func (o ReminderOffset) Key() string {
+	key := o.Canonical()
+	if o.Times > 1 {
+		return fmt.Sprintf("%s#%d", key, o.Step)
+	}
+	return key
+}
+
+// Canonical 提醒偏移的规范写法：等价的写法结果相同（如 deadline、截止、-0m 均为 deadline，-60m 为 -1h）
+// 展开后的重复催办按第一次的偏移计算
+func (o ReminderOffset) Canonical() string {
+	duration := o.Duration
+	if o.Step > 1 {
+		duration -= time.Duration(o.Step-1) * o.Every
+	}
+
+	var b strings.Builder
+	switch {
+	case o.HasClock && o.Days != 0:
+		fmt.Fprintf(&b, "%+dd %02d:%02d", o.Days, o.Hour, o.Minute)
+	case o.HasClock:
+		fmt.Fprintf(&b, "%02d:%02d", o.Hour, o.Minute)
+	case duration == 0:
+		b.WriteString(ReminderOffsetDeadline)
+	case duration < 0:
+		b.WriteString("-" + formatSpecDuration(-duration))
+	default:
+		b.WriteString("+" + formatSpecDuration(duration))
+	}
+	if !o.IsEscalation() {
+		return b.String()
+	}
+
+	switch o.Action {
+	case EscalationDM:
+		b.WriteString(" dm")
+	case EscalationSummary:
+		b.WriteString(" summary")
+	}
+	if o.Every > 0 {
+		b.WriteString(" every " + formatSpecDuration(o.Every))
+	}
+	// 群内催办至少有一项升级写法，用次数与普通提醒区分
+	if o.Every > 0 || o.Action == EscalationGroup {
+		fmt.Fprintf(&b, " x%d", o.Times)
+	}
+	if o.TargetChatID != "" {
+		b.WriteString(" to=" + o.TargetChatID)
+	}
+	return b.String()
+}
+
+// Steps 将重复催办展开为独立的提醒项，第 i 次的偏移为 Duration + (i-1)·Every
+func (o ReminderOffset) Steps() []ReminderOffset {
+	if !o.IsEscalation() {
+		return []ReminderOffset{o}
+	}
+	steps := make([]ReminderOffset, o.Times)
+	for i := range steps {
+		step := o
+		step.Step = i + 1
+		step.Duration = o.Duration + time.Duration(i)*o.Every
+		steps[i] = step
+	}
+	return steps
+}
+
+// IsDeadline 是否为截止时间本身
+func (o ReminderOffset) IsDeadline() bool {
+	return !o.HasClock && o.Duration == 0
+}
+
+// At 根据截止时间计算提醒时间
+func (o ReminderOffset) At(deadline time.Time) time.Time {
+	if o.HasClock {
+		y, m, d := deadline.Date()
+		return time.Date(y, m, d+o.Days, o.Hour, o.Minute, 0, 0, deadline.Location())
+	}
+	return deadline.Add(o.Duration)
+}
+
+// ReminderType 提醒项对应的提醒类型
+func (o ReminderOffset) ReminderType(taskType TaskType) ReminderType {
+	switch {
+	case o.HasClock:
+		return ReminderTypeScheduled
+	case o.Duration < 0:
+		return ReminderTypeAdvance
+	case o.Duration > 0:
+		return ReminderTypeOverdue
+	case taskType == TaskTypeNotification:
+		return ReminderTypeTrigger
+	default:
+		return ReminderTypeDeadline
+	}
+}
+
+// DefaultReminderPlan 未配置提醒计划时的默认计划：当天 10:00 + 提前 AdvanceMinutes 分钟 + 截止时间
+func DefaultReminderPlan(task Task) []string {
+	plan := []string{"10:00"}
+	if task.AdvanceMinutes > 0 {
+		plan = append(plan, fmt.Sprintf("-%dm", task.AdvanceMinutes))
+	}
+	return append(plan, ReminderOffsetDeadline)
+}
+
+// ReminderOffsets 返回任务生效的提醒项（未配置时使用默认计划），重复催办已展开为多项
+func (t Task) ReminderOffsets() ([]ReminderOffset, error) {
+	plan := t.ReminderPlan
+	if len(plan) == 0 {
+		plan = DefaultReminderPlan(t)
+	}
+	offsets, err := ParseReminderPlan(plan)
+	if err != nil {
+		return nil, err
+	}
+
+	var steps []ReminderOffset
+	for _, offset := range offsets {
+		steps = append(steps, offset.Steps()...)
+	}
+	return steps, nil
+}
+
+// ParseReminderPlan 解析并校验整个提醒计划
+func ParseReminderPlan(plan []string) ([]ReminderOffset, error) {
+	offsets := make([]ReminderOffset, 0, len(plan))
+	// 按规范写法判断重复（deadline 与 截止、-1h 与 -60m 会在同一时刻重复提醒）
+	seen := make(map[string]string, len(plan))
+	for _, spec := range plan {
+		offset, err := ParseReminderOffset(spec)
+		if err != nil {
+			return nil, err
+		}
+		key := offset.Canonical()
+		if previous, ok := seen[key]; ok {
+			if previous == offset.Spec {
+				return nil, fmt.Errorf("提醒偏移 %q 重复", offset.Spec)
+			}
+			return nil, fmt.Errorf("提醒偏移 %q 与 %q 重复", offset.Spec, previous)
+		}
+		seen[key] = offset.Spec
+		offsets = append(offsets, offset)
+	}
+	return offsets, nil
+}
+
+// FormatOffsetDuration 将偏移时长格式化为中文描述（如 "1小时30分钟"）
+func FormatOffsetDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	var b strings.Builder
+	if days > 0 {
+		fmt.Fprintf(&b, "%d天", days)
+	}
+	if hours > 0 {
+		fmt.Fprintf(&b, "%d小时", hours)
+	}
+	if minutes > 0 || b.Len() == 0 {
+		fmt.Fprintf(&b, "%d分钟", minutes)
+	}
+	return b.String()
+}
+
+// formatSpecDuration 将非负时长格式化为提醒偏移的写法（如 "1d12h"、"1h30m"、"30m"）
+func formatSpecDuration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+
+	var b strings.Builder
+	if days > 0 {
+		fmt.Fprintf(&b, "%dd", days)
+	}
+	if hours > 0 {
+		fmt.Fprintf(&b, "%dh", hours)
+	}
+	if minutes > 0 || b.Len() == 0 {
+		fmt.Fprintf(&b, "%dm", minutes)
+	}
+	return b.String()
+}
+
+// parseClock 解析 HH:MM
+func parseClock(s string) (int, int, bool) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, false
+	}
+	return t.Hour(), t.Minute(), true
+}
+
+// parseDays 解析 "-1d" / "0d" / "+1d"
+func parseDays(s string) (int, error) {
+	if !strings.HasSuffix(s, "d") {
+		return 0, fmt.Errorf("天数格式应为 -1d")
+	}
+	days, err := strconv.Atoi(strings.TrimSuffix(s, "d"))
+	if err != nil {
+		return 0, fmt.Errorf("天数格式应为 -1d")
+	}
+	if days < -31 || days > 31 {
+		return 0, fmt.Errorf("天数偏移超出范围")
+	}
+	return days, nil
+}
+
+// parseSignedDuration 解析带符号的时长，额外支持 "d"（天）单位，如 "-1d12h"
+func parseSignedDuration(s string) (time.Duration, error) {
+	if s == "" || (s[0] != '-' && s[0] != '+') {
+		return 0, fmt.Errorf("时长需以 + 或 - 开头")
+	}
+	sign := time.Duration(1)
+	if s[0] == '-' {
+		sign = -1
+	}
+	rest := s[1:]
+
+	var total time.Duration
+	if i := strings.Index(rest, "d"); i >= 0 {
+		days, err := strconv.Atoi(rest[:i])
+		if err != nil {
+			return 0, fmt.Errorf("无效的天数")
+		}
+		total = time.Duration(days) * 24 * time.Hour
+		rest = rest[i+1:]
+	}
+	if rest != "" {
+		d, err := time.ParseDuration(rest)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("无效的时长")
+		}
+		total += d
+	}
+	if total%time.Minute != 0 {
+		return 0, fmt.Errorf("时长精度为分钟")
+	}
+	return sign * total, nil
+}
@@ -0,0 +1,383 @@
+package models
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseReminderOffset(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want ReminderOffset
+	}{
+		{
+			name: "截止时间本身",
+			spec: "deadline",
+			want: ReminderOffset{Spec: "deadline"},
+		},
+		{
+			name: "中文写法的截止时间",
+			spec: "截止",
+			want: ReminderOffset{Spec: "截止"},
+		},
+		{
+			name: "截止前两小时",
+			spec: "-2h",
+			want: ReminderOffset{Spec: "-2h", Duration: -2 * time.Hour},
+		},
+		{
+			name: "截止后 30 分钟",
+			spec: "+30m",
+			want: ReminderOffset{Spec: "+30m", Duration: 30 * time.Minute},
+		},
+		{
+			name: "带天数单位的时长",
+			spec: "-1d12h",
+			want: ReminderOffset{Spec: "-1d12h", Duration: -36 * time.Hour},
+		},
+		{
+			name: "截止当天固定时刻",
+			spec: "09:00",
+			want: ReminderOffset{Spec: "09:00", HasClock: true, Hour: 9},
+		},
+		{
+			name: "截止前一天固定时刻",
+			spec: "-1d 18:00",
+			want: ReminderOffset{Spec: "-1d 18:00", Days: -1, HasClock: true, Hour: 18},
+		},
+		{
+			name: "多余空白被规整",
+			spec: "  -1d   18:30 ",
+			want: ReminderOffset{Spec: "-1d 18:30", Days: -1, HasClock: true, Hour: 18, Minute: 30},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReminderOffset(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseReminderOffset(%q) 返回错误: %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReminderOffset(%q) = %+v，期望 %+v", tt.spec, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReminderOffsetInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "空", spec: "  "},
+		{name: "缺少符号", spec: "2h"},
+		{name: "无效单位", spec: "-2w"},
+		{name: "秒级精度", spec: "-90s"},
+		{name: "无效时刻", spec: "25:00"},
+		{name: "天数缺少单位", spec: "-1 18:00"},
+		{name: "天数超出范围", spec: "-32d 18:00"},
+		{name: "天数后不是时刻", spec: "-1d 6pm"},
+		{name: "多余的字段", spec: "-1d 18:00 19:00"},
+		{name: "过长", spec: "-" + strings.Repeat("1", maxOffsetSpecLen) + "m"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseReminderOffset(tt.spec); err == nil {
+				t.Errorf("ParseReminderOffset(%q) = %+v，期望返回错误", tt.spec, got)
+			}
+		})
+	}
+}
+
+func TestReminderOffsetAt(t *testing.T) {
+	loc := time.FixedZone("CST", 8*3600)
+	deadline := time.Date(2026, 10, 16, 18, 0, 0, 0, loc)
+
+	tests := []struct {
+		spec string
+		want time.Time
+	}{
+		{spec: "deadline", want: deadline},
+		{spec: "-2h", want: time.Date(2026, 10, 16, 16, 0, 0, 0, loc)},
+		{spec: "+30m", want: time.Date(2026, 10, 16, 18, 30, 0, 0, loc)},
+		{spec: "09:00", want: time.Date(2026, 10, 16, 9, 0, 0, 0, loc)},
+		{spec: "-1d 18:00", want: time.Date(2026, 10, 15, 18, 0, 0, 0, loc)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			offset, err := ParseReminderOffset(tt.spec)
+			if err != nil {
+				t.Fatalf("解析提醒偏移失败: %v", err)
+			}
+			if got := offset.At(deadline); !got.Equal(tt.want) {
+				t.Errorf("At = %s，期望 %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReminderPlan(t *testing.T) {
+	tests := []struct {
+		name    string
+		plan    []string
+		want    []string
+		wantErr bool
+	}{
+		{
+			name: "按顺序解析",
+			plan: []string{"-1d 18:00", "09:00", "-2h", "deadline", "+30m"},
+			want: []string{"-1d 18:00", "09:00", "-2h", "deadline", "+30m"},
+		},
+		{
+			name: "空计划",
+			plan: []string{},
+			want: []string{},
+		},
+		{
+			name:    "重复的偏移",
+			plan:    []string{"-2h", " -2h"},
+			wantErr: true,
+		},
+		{
+			name:    "截止时间的不同写法重复",
+			plan:    []string{"deadline", "截止"},
+			wantErr: true,
+		},
+		{
+			name:    "零偏移与截止时间重复",
+			plan:    []string{"trigger", "-0m"},
+			wantErr: true,
+		},
+		{
+			name:    "等价的时长重复",
+			plan:    []string{"-1h", "-60m"},
+			wantErr: true,
+		},
+		{
+			name:    "等价的固定时刻重复",
+			plan:    []string{"0d 09:00", "09:00"},
+			wantErr: true,
+		},
+		{
+			name: "普通提醒与同一时刻的催办不重复",
+			plan: []string{"+30m", "+30m x1"},
+			want: []string{"+30m", "+30m x1"},
+		},
+		{
+			name:    "任一项无效",
+			plan:    []string{"-2h", "later"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			offsets, err := ParseReminderPlan(tt.plan)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseReminderPlan(%q) 期望返回错误", tt.plan)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseReminderPlan(%q) 返回错误: %v", tt.plan, err)
+			}
+			if len(offsets) != len(tt.want) {
+				t.Fatalf("解析出 %d 项，期望 %d 项", len(offsets), len(tt.want))
+			}
+			for i, offset := range offsets {
+				if offset.Spec != tt.want[i] {
+					t.Errorf("第 %d 项 = %q，期望 %q", i+1, offset.Spec, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestDefaultReminderPlan(t *testing.T) {
+	tests := []struct {
+		name string
+		task Task
+		want []string
+	}{
+		{name: "提前分钟数", task: Task{AdvanceMinutes: 30}, want: []string{"10:00", "-30m", "deadline"}},
+		{name: "不提前", task: Task{}, want: []string{"10:00", "deadline"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := DefaultReminderPlan(tt.task)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Errorf("DefaultReminderPlan = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseReminderOffsetEscalation(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+		want ReminderOffset
+	}{
+		{
+			name: "重复催办",
+			spec: "+30m every 15m x4",
+			want: ReminderOffset{Spec: "+30m every 15m x4", Duration: 30 * time.Minute, Action: EscalationGroup, Every: 15 * time.Minute, Times: 4},
+		},
+		{
+			name: "省略次数时默认 3 次",
+			spec: "deadline every 10m",
+			want: ReminderOffset{Spec: "deadline every 10m", Action: EscalationGroup, Every: 10 * time.Minute, Times: defaultEscalationTimes},
+		},
+		{
+			name: "中文写法",
+			spec: "+1h 每30m ×2",
+			want: ReminderOffset{Spec: "+1h 每30m ×2", Duration: time.Hour, Action: EscalationGroup, Every: 30 * time.Minute, Times: 2},
+		},
+		{
+			name: "私聊",
+			spec: "+1h dm",
+			want: ReminderOffset{Spec: "+1h dm", Duration: time.Hour, Action: EscalationDM, Times: 1},
+		},
+		{
+			name: "私聊重复催办",
+			spec: "+1h 私聊 every 1h x2",
+			want: ReminderOffset{Spec: "+1h 私聊 every 1h x2", Duration: time.Hour, Action: EscalationDM, Every: time.Hour, Times: 2},
+		},
+		{
+			name: "汇总给创建人",
+			spec: "+2h summary",
+			want: ReminderOffset{Spec: "+2h summary", Duration: 2 * time.Hour, Action: EscalationSummary, Times: 1},
+		},
+		{
+			name: "汇总到指定群",
+			spec: "+2h summary to=cidABC",
+			want: ReminderOffset{Spec: "+2h summary to=cidABC", Duration: 2 * time.Hour, Action: EscalationSummary, Times: 1, TargetChatID: "cidABC"},
+		},
+		{
+			name: "最小间隔和最多次数",
+			spec: "+5m every 5m x20",
+			want: ReminderOffset{Spec: "+5m every 5m x20", Duration: 5 * time.Minute, Action: EscalationGroup, Every: 5 * time.Minute, Times: maxEscalationTimes},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseReminderOffset(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseReminderOffset(%q) 返回错误: %v", tt.spec, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseReminderOffset(%q) = %+v，期望 %+v", tt.spec, got, tt.want)
+			}
+			if !got.IsEscalation() {
+				t.Errorf("ParseReminderOffset(%q) 应为升级步骤", tt.spec)
+			}
+		})
+	}
+}
+
+func TestParseReminderOffsetEscalationInvalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{name: "截止前不能升级", spec: "-30m every 15m"},
+		{name: "固定时刻不能升级", spec: "18:00 dm"},
+		{name: "every 缺少间隔", spec: "+30m every"},
+		{name: "间隔无效", spec: "+30m every soon"},
+		{name: "间隔小于最小值", spec: "+30m every 4m"},
+		{name: "间隔不是整分钟", spec: "+30m every 90s"},
+		{name: "次数为 0", spec: "+30m every 15m x0"},
+		{name: "次数超过上限", spec: "+30m every 15m x21"},
+		{name: "重复催办缺少间隔", spec: "+30m x4"},
+		{name: "to= 只能用于汇总", spec: "+30m dm to=cidABC"},
+		{name: "to= 缺少群ID", spec: "+2h summary to="},
+		{name: "无法识别的步骤", spec: "+30m dm loudly"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got, err := ParseReminderOffset(tt.spec); err == nil {
+				t.Errorf("ParseReminderOffset(%q) = %+v，期望返回错误", tt.spec, got)
+			}
+		})
+	}
+}
+
+func TestReminderOffsetSteps(t *testing.T) {
+	offset, err := ParseReminderOffset("+30m every 15m x3")
+	if err != nil {
+		t.Fatalf("解析提醒偏移失败: %v", err)
+	}
+
+	steps := offset.Steps()
+	want := []struct {
+		duration time.Duration
+		key      string
+	}{
+		{30 * time.Minute, "+30m every 15m x3#1"},
+		{45 * time.Minute, "+30m every 15m x3#2"},
+		{60 * time.Minute, "+30m every 15m x3#3"},
+	}
+	if len(steps) != len(want) {
+		t.Fatalf("展开为 %d 项，期望 %d 项", len(steps), len(want))
+	}
+	for i, step := range steps {
+		if step.Duration != want[i].duration || step.Key() != want[i].key {
+			t.Errorf("第 %d 次 = (%s, %q)，期望 (%s, %q)", i+1, step.Duration, step.Key(), want[i].duration, want[i].key)
+		}
+	}
+
+	// 单次升级不带序号
+	single, err := ParseReminderOffset("+1h dm")
+	if err != nil {
+		t.Fatalf("解析提醒偏移失败: %v", err)
+	}
+	if steps := single.Steps(); len(steps) != 1 || steps[0].Key() != "+1h dm" {
+		t.Errorf("单次升级展开为 %+v", steps)
+	}
+}
+
+func TestReminderOffsetKey(t *testing.T) {
+	tests := []struct {
+		spec string
+		want []string
+	}{
+		{spec: "deadline", want: []string{"deadline"}},
+		{spec: "截止", want: []string{"deadline"}},
+		{spec: "-0m", want: []string{"deadline"}},
+		{spec: "-60m", want: []string{"-1h"}},
+		{spec: "-90m", want: []string{"-1h30m"}},
+		{spec: "-36h", want: []string{"-1d12h"}},
+		{spec: "0d 9:00", want: []string{"09:00"}},
+		{spec: "-1d 18:00", want: []string{"-1d 18:00"}},
+		{spec: "+60m 私聊", want: []string{"+1h dm"}},
+		{spec: "+2h 汇总 to=cid1", want: []string{"+2h summary to=cid1"}},
+		{spec: "截止 每15m x2", want: []string{"deadline every 15m x2#1", "deadline every 15m x2#2"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			offset, err := ParseReminderOffset(tt.spec)
+			if err != nil {
+				t.Fatalf("ParseReminderOffset(%q) 返回错误: %v", tt.spec, err)
+			}
+			steps := offset.Steps()
+			if len(steps) != len(tt.want) {
+				t.Fatalf("展开为 %d 项，期望 %d 项", len(steps), len(tt.want))
+			}
+			for i, step := range steps {
+				if got := step.Key(); got != tt.want[i] {
+					t.Errorf("第 %d 项 Key() = %q，期望 %q", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+import "time"
+
+// StatsGranularity 历史统计按天、周（周一开始）或月汇总
+type StatsGranularity string
+
+const (
+	GranularityDay   StatsGranularity = "day"
+	GranularityWeek  StatsGranularity = "week"
+	GranularityMonth StatsGranularity = "month"
+)
+
+// IsValid 是否为支持的汇总粒度
+func (g StatsGranularity) IsValid() bool {
+	switch g {
+	case GranularityDay, GranularityWeek, GranularityMonth:
+		return true
+	}
+	return false
+}
+
+// StatsQuery 历史统计的筛选条件（TaskID、GroupChatID、UserID 为空时不限）
+type StatsQuery struct {
+	TaskID      int
+	GroupChatID string
+	UserID      string
+	From        time.Time // 任务日期范围（含两端）
+	To          time.Time
+	Granularity StatsGranularity
+}
+
+// PeriodStats 一个汇总周期内的完成情况（按人次计算）
+type PeriodStats struct {
+	PeriodStart    time.Time `json:"period_start"`
+	Expected       int       `json:"expected"` // 应打卡人次
+	Completed      int       `json:"completed"`
+	OnTime         int       `json:"on_time"`
+	CompletionRate float64   `json:"completion_rate"`
+	OnTimeRate     float64   `json:"on_time_rate"` // 按时打卡占已打卡的比例
+}
+
+// MissedPeriod 成员没有打卡的一期
+type MissedPeriod struct {
+	TaskID   int       `json:"task_id"`
+	TaskName string    `json:"task_name"`
+	TaskDate time.Time `json:"task_date"`
+}
+
+// UserStats 成员在统计范围内的完成情况
+type UserStats struct {
+	UserID         string         `json:"user_id"`
+	UserName       string         `json:"user_name"`
+	Expected       int            `json:"expected"`
+	Completed      int            `json:"completed"`
+	OnTime         int            `json:"on_time"`
+	CompletionRate float64        `json:"completion_rate"`
+	OnTimeRate     float64        `json:"on_time_rate"`
+	CurrentStreak  int            `json:"current_streak"` // 截至最近一期连续完成的期数
+	LongestStreak  int            `json:"longest_streak"`
+	Missed         []MissedPeriod `json:"missed"`
+}
+
+// HistoryStats 历史统计结果
+type HistoryStats struct {
+	From        time.Time        `json:"from"`
+	To          time.Time        `json:"to"`
+	Granularity StatsGranularity `json:"granularity"`
+	Periods     []PeriodStats    `json:"periods"`
+	Users       []UserStats      `json:"users"`
+}
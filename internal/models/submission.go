@@ -0,0 +1,39 @@
+package models
+
+import (
+	"database/sql"
+	"time"
+)
+
+// SubmissionKind 打卡时提交内容的类型
+type SubmissionKind string
+
+const (
+	SubmissionText    SubmissionKind = "TEXT"    // 文字说明
+	SubmissionLink    SubmissionKind = "LINK"    // 链接（如在线文档）
+	SubmissionPicture SubmissionKind = "PICTURE" // 钉钉图片消息
+	SubmissionFile    SubmissionKind = "FILE"    // 钉钉文件消息
+	SubmissionVideo   SubmissionKind = "VIDEO"   // 钉钉视频消息
+	SubmissionAudio   SubmissionKind = "AUDIO"   // 钉钉语音消息
+)
+
+// Submission 打卡时附带的完成凭证（文字、链接或钉钉文件/图片），一次打卡可以有多条
+type Submission struct {
+	ID           int            `json:"id"`
+	CompletionID int            `json:"completion_id"`
+	TaskID       int            `json:"task_id"`
+	UserID       string         `json:"user_id"`
+	UserName     sql.NullString `json:"user_name"`
+	TaskDate     time.Time      `json:"task_date"`
+	Kind         SubmissionKind `json:"kind"`
+	Content      string         `json:"content"`                // 文字或链接，文件/图片为空
+	FileName     sql.NullString `json:"file_name"`              // 文件名（文件消息）
+	DownloadCode sql.NullString `json:"download_code"`          // 钉钉机器人消息文件的下载码
+	DownloadURL  string         `json:"download_url,omitempty"` // 查询时用下载码换取的临时下载链接（不落库）
+	CreatedAt    time.Time      `json:"created_at"`
+}
+
+// IsAttachment 是否为钉钉文件/图片等需要下载的内容
+func (s Submission) IsAttachment() bool {
+	return s.DownloadCode.Valid && s.DownloadCode.String != ""
+}
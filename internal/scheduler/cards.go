@@ -0,0 +1,97 @@
+package scheduler
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// 检查成员"稍后提醒"是否到时间的间隔
+const memberSnoozeCheckInterval = time.Minute
+
+// sendTaskReminder 在群内发送任务型提醒并 @ 未完成的成员，返回发送的内容
+// 优先发送带"已完成/稍后提醒/请假"按钮的互动卡片，卡片发送失败时改为 Markdown 消息
+func (s *Scheduler) sendTaskReminder(task models.Task, offset models.ReminderOffset, deadline, taskDate time.Time, pending []string) (string, error) {
+	if s.cardService != nil {
+		title, status := s.taskReminderHeading(task, offset, deadline)
+		body := fmt.Sprintf("📋 任务: **%s**\n\n⏰ %s", task.Name, status)
+		if task.Description.Valid && task.Description.String != "" {
+			body += "\n\n" + task.Description.String
+		}
+
+		message, err := s.cardService.Send(task, taskDate, title, body, pending)
+		if err == nil {
+			return message, nil
+		}
+		log.Printf("发送提醒卡片失败，改为 Markdown 消息: %v", err)
+	}
+
+	message := s.buildTaskReminderMessage(task, offset, deadline, len(pending))
+	return message, s.dtClient.SendMarkdownWithMentions(task.GroupChatID, task.Name, message, pending)
+}
+
+// runMemberSnoozes 定期私聊提醒点击了"稍后提醒"且到时间的成员
+func (s *Scheduler) runMemberSnoozes(ctx context.Context) {
+	ticker := time.NewTicker(memberSnoozeCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.sendMemberSnoozes()
+		}
+	}
+}
+
+// sendMemberSnoozes 发送已到时间的稍后提醒，本期已完成或任务已不在进行中的跳过
+func (s *Scheduler) sendMemberSnoozes() {
+	if s.cardService == nil {
+		return
+	}
+
+	snoozes, err := s.cardService.ClaimDueSnoozes(time.Now())
+	if err != nil {
+		log.Printf("获取稍后提醒失败: %v", err)
+		return
+	}
+
+	for _, snooze := range snoozes {
+		task, err := s.taskService.GetTaskByID(snooze.TaskID)
+		if err != nil || task.Status != models.TaskStatusActive {
+			continue
+		}
+		completed, err := s.taskService.HasCompleted(task.ID, snooze.UserID, snooze.TaskDate)
+		if err != nil {
+			log.Printf("检查打卡状态失败: %v", err)
+			continue
+		}
+		if completed {
+			continue
+		}
+
+		message := s.buildMemberSnoozeMessage(*task, snooze.TaskDate)
+		if err := s.dtClient.SendPrivateMarkdown([]string{snooze.UserID}, task.Name, message); err != nil {
+			log.Printf("发送稍后提醒失败 (%s): %v", snooze.UserID, err)
+			continue
+		}
+		log.Printf("✓ 稍后提醒已发送: [%s] %s", task.Name, snooze.UserID)
+	}
+}
+
+// buildMemberSnoozeMessage 稍后提醒的私聊消息
+func (s *Scheduler) buildMemberSnoozeMessage(task models.Task, taskDate time.Time) string {
+	var b strings.Builder
+	b.WriteString("### ⏰ 稍后提醒\n\n")
+	b.WriteString(fmt.Sprintf("📋 任务: **%s**\n", task.Name))
+	if deadline := s.taskService.DeadlineOn(task, taskDate); !deadline.IsZero() {
+		b.WriteString(fmt.Sprintf("⏰ 截止时间: %s\n", s.formatDeadline(task, deadline)))
+	}
+	b.WriteString("\n完成后请在群里回复: @我 已完成，或点击提醒卡片上的 ✅ 已完成")
+	return b.String()
+}
@@ -0,0 +1,178 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// 补发时最多回溯的时长，停机更久时更早的提醒不再检查
+const maxCatchUpLookback = 7 * 24 * time.Hour
+
+// 每个任务最多检查的错过提醒数（只保留最近的）
+const maxMissedPerTask = 200
+
+// missedReminder 停机期间错过的一次提醒
+type missedReminder struct {
+	offset   models.ReminderOffset
+	at       time.Time // 原定提醒时间
+	deadline time.Time // 对应的截止/触发时间
+}
+
+// catchUp 补发停机期间错过的提醒
+//
+// 从任务的 last_run_at 开始计算应发送的提醒：同一次截止只补发最晚的一条，且原定时间距今不超过补发窗口；
+// 其余记为 SKIPPED。已发送过的提醒在登记时会因幂等键冲突而跳过。
+func (s *Scheduler) catchUp(ctx context.Context, tasks []models.Task) {
+	now := time.Now().In(s.location)
+	var sent, skipped int
+
+	for _, task := range tasks {
+		if ctx.Err() != nil {
+			return
+		}
+
+		// 从未运行过的任务没有基准时间，只记录本次运行
+		if task.LastRunAt.Valid {
+			missed, err := s.missedReminders(task, task.LastRunAt.Time, now)
+			if err != nil {
+				log.Printf("计算错过的提醒 [%s] 失败: %v", task.Name, err)
+				continue
+			}
+
+			// 每次截止只补发最晚的一条
+			latest := make(map[int64]int)
+			for i, m := range missed {
+				key := m.deadline.Unix()
+				if j, ok := latest[key]; !ok || m.at.After(missed[j].at) {
+					latest[key] = i
+				}
+			}
+
+			for i, m := range missed {
+				if ctx.Err() != nil {
+					return
+				}
+
+				if latest[m.deadline.Unix()] == i && now.Sub(m.at) <= s.catchUpGrace {
+					log.Printf("补发错过的提醒: [%s - %s] 原定 %s", task.Name, m.offset.Key(), m.at.Format("2006-01-02 15:04"))
+					if err := s.executeReminder(task, m.offset, m.deadline); err != nil {
+						log.Printf("补发提醒 [%s - %s] 失败: %v", task.Name, m.offset.Key(), err)
+						continue
+					}
+					sent++
+					continue
+				}
+
+				ok, err := s.skipReminder(task, m, now)
+				if err != nil {
+					log.Printf("记录跳过的提醒 [%s - %s] 失败: %v", task.Name, m.offset.Key(), err)
+					continue
+				}
+				if ok {
+					skipped++
+				}
+			}
+		}
+
+		// 重新安排停机前已延后、尚未发送的提醒
+		s.resumeSnoozed(task, now)
+
+		s.updateRunTime(task, now)
+		if s.taskExpired(task, now) {
+			s.finishTask(task)
+			s.UnregisterTask(task.ID)
+		}
+	}
+
+	if sent+skipped > 0 {
+		log.Printf("✓ 错过的提醒已处理: 补发 %d, 跳过 %d", sent, skipped)
+	}
+}
+
+// missedReminders 返回 (since, now] 之间原定发送的提醒，按原定时间升序
+func (s *Scheduler) missedReminders(task models.Task, since, now time.Time) ([]missedReminder, error) {
+	if earliest := now.Add(-maxCatchUpLookback); since.Before(earliest) {
+		since = earliest
+	}
+
+	offsets, err := task.ReminderOffsets()
+	if err != nil {
+		return nil, fmt.Errorf("解析提醒计划失败: %w", err)
+	}
+
+	var missed []missedReminder
+	for _, offset := range offsets {
+		schedule, err := s.reminderSchedule(task, offset)
+		if err != nil {
+			return nil, err
+		}
+
+		at, deadline := schedule.find(since)
+		for !at.IsZero() && !at.After(now) {
+			missed = append(missed, missedReminder{offset: offset, at: at, deadline: deadline})
+			at, deadline = schedule.find(at)
+		}
+	}
+
+	sort.Slice(missed, func(i, j int) bool { return missed[i].at.Before(missed[j].at) })
+	if len(missed) > maxMissedPerTask {
+		missed = missed[len(missed)-maxMissedPerTask:]
+	}
+	return missed, nil
+}
+
+// skipReminder 将超出补发窗口的提醒记为 SKIPPED，返回 false 表示该提醒已有记录
+func (s *Scheduler) skipReminder(task models.Task, m missedReminder, now time.Time) (bool, error) {
+	reminderLog := &models.ReminderLog{
+		TaskID:         task.ID,
+		GroupChatID:    task.GroupChatID,
+		ReminderType:   string(m.offset.ReminderType(task.Type)),
+		ReminderOffset: m.offset.Key(),
+		OccurrenceAt:   sql.NullTime{Time: m.deadline, Valid: true},
+	}
+
+	claimed, err := s.taskService.ClaimReminder(reminderLog)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	reminderLog.Status = models.ReminderStatusSkipped
+	reminderLog.MessageText = sql.NullString{
+		String: fmt.Sprintf("停机期间错过（原定 %s，超过补发窗口 %s）", m.at.Format("2006-01-02 15:04"), s.catchUpGrace),
+		Valid:  true,
+	}
+	return true, s.taskService.FinishReminder(reminderLog)
+}
+
+// updateRunTime 记录任务最近一次运行时间和下一次提醒时间
+func (s *Scheduler) updateRunTime(task models.Task, lastRun time.Time) {
+	if err := s.taskService.UpdateTaskRunTime(task.ID, lastRun, s.nextReminderAt(task, lastRun)); err != nil {
+		log.Printf("更新任务运行时间 [%s] 失败: %v", task.Name, err)
+	}
+}
+
+// nextReminderAt 返回 after 之后最近的一次提醒时间，没有时返回零值
+func (s *Scheduler) nextReminderAt(task models.Task, after time.Time) time.Time {
+	offsets, err := task.ReminderOffsets()
+	if err != nil {
+		return time.Time{}
+	}
+
+	var next time.Time
+	for _, offset := range offsets {
+		schedule, err := s.reminderSchedule(task, offset)
+		if err != nil {
+			continue
+		}
+		if at := schedule.Next(after); !at.IsZero() && (next.IsZero() || at.Before(next)) {
+			next = at
+		}
+	}
+	return next
+}
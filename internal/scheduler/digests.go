@@ -0,0 +1,129 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"dingteam-bot/internal/models"
+	"dingteam-bot/internal/services"
+
+	"github.com/robfig/cron/v3"
+)
+
+// digestEntry 记录定时汇总注册时的快照及其 cron 条目
+type digestEntry struct {
+	digest   models.Digest
+	location string // 注册时使用的群时区
+	entryID  cron.EntryID
+}
+
+// ReloadDigests 定时汇总被新增或删除后重新加载（调度器未运行时忽略）
+func (s *Scheduler) ReloadDigests() {
+	if !s.IsRunning() {
+		return
+	}
+	if err := s.reloadDigests(); err != nil {
+		log.Printf("重新加载定时汇总失败: %v", err)
+	}
+}
+
+// reloadDigests 对比数据库中的定时汇总与已注册条目，增量地新增、移除或重新注册
+func (s *Scheduler) reloadDigests() error {
+	if s.digestService == nil {
+		return nil
+	}
+	digests, err := s.digestService.ListDigests("")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+
+	current := make(map[int]bool, len(digests))
+	for _, d := range digests {
+		current[d.ID] = true
+	}
+	for id := range s.digests {
+		if !current[id] {
+			s.removeDigestLocked(id)
+		}
+	}
+
+	for _, d := range digests {
+		registered, ok := s.digests[d.ID]
+		if ok && !digestChanged(registered.digest, d) && registered.location == s.digestService.Location(d).String() {
+			continue
+		}
+		s.removeDigestLocked(d.ID)
+		if err := s.addDigestLocked(d); err != nil {
+			log.Printf("注册定时汇总 #%d 失败: %v", d.ID, err)
+		}
+	}
+	return nil
+}
+
+// addDigestLocked 注册定时汇总（调用方需持有 s.mu）
+func (s *Scheduler) addDigestLocked(d models.Digest) error {
+	schedule, err := s.digestService.Schedule(d)
+	if err != nil {
+		return err
+	}
+	entryID := s.cron.Schedule(schedule, cron.FuncJob(func() {
+		if err := s.executeDigest(d); err != nil {
+			log.Printf("发送定时汇总 #%d 失败: %v", d.ID, err)
+		}
+	}))
+	s.digests[d.ID] = &digestEntry{digest: d, location: s.digestService.Location(d).String(), entryID: entryID}
+	log.Printf("✓ 注册定时汇总: #%d %s (%s)", d.ID, d.Kind.DisplayName(), d.CronExpr)
+	return nil
+}
+
+// removeDigestLocked 移除定时汇总的 cron 条目（调用方需持有 s.mu）
+func (s *Scheduler) removeDigestLocked(digestID int) {
+	if registered, ok := s.digests[digestID]; ok {
+		s.cron.Remove(registered.entryID)
+		delete(s.digests, digestID)
+	}
+}
+
+// executeDigest 先登记本次计划发送再生成并发送汇总，避免切换领导者时重复发送
+func (s *Scheduler) executeDigest(d models.Digest) error {
+	now := time.Now()
+	claimed, err := s.digestService.Claim(d.ID, now.Truncate(time.Minute))
+	if err != nil {
+		return err
+	}
+	if !claimed {
+		log.Printf("定时汇总已发送过，跳过: #%d", d.ID)
+		return nil
+	}
+
+	if err := s.digestService.Send(d, now); err != nil {
+		if errors.Is(err, services.ErrNoDigestTasks) {
+			log.Printf("群内没有进行中的任务，跳过定时汇总: #%d", d.ID)
+			return nil
+		}
+		return fmt.Errorf("发送失败: %w", err)
+	}
+	log.Printf("✓ 定时汇总已发送: #%d %s", d.ID, d.Kind.DisplayName())
+	return nil
+}
+
+// digestChanged 判断定时汇总中影响发送的字段是否发生变化
+func digestChanged(old, cur models.Digest) bool {
+	return old.GroupChatID != cur.GroupChatID ||
+		old.Kind != cur.Kind ||
+		old.CronExpr != cur.CronExpr ||
+		old.CalendarPolicy != cur.CalendarPolicy ||
+		old.Target != cur.Target ||
+		old.CreatedBy != cur.CreatedBy ||
+		!slices.Equal(old.Recipients, cur.Recipients)
+}
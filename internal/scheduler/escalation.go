@@ -0,0 +1,152 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// isEscalation 任务型截止后的提醒和升级步骤：只催未完成的人，全部完成后停止
+func isEscalation(task models.Task, offset models.ReminderOffset) bool {
+	if task.Type != models.TaskTypeTask {
+		return false
+	}
+	return offset.IsEscalation() || offset.ReminderType(task.Type) == models.ReminderTypeOverdue
+}
+
+// executeEscalation 执行已登记的升级步骤，按动作在群内 @、私聊或发送汇总
+func (s *Scheduler) executeEscalation(task models.Task, offset models.ReminderOffset, deadline time.Time, reminderLog *models.ReminderLog) error {
+	taskDate := s.taskService.TaskDate(task, deadline)
+	pending, err := s.taskService.GetIncompleteUsers(task, taskDate)
+	if err != nil {
+		s.finishReminder(reminderLog, models.ReminderStatusFailed)
+		return fmt.Errorf("获取未完成用户失败: %w", err)
+	}
+
+	// 全部完成后不再升级
+	if len(pending) == 0 {
+		reminderLog.MessageText = sql.NullString{String: "全部已完成，停止升级", Valid: true}
+		s.finishReminder(reminderLog, models.ReminderStatusSkipped)
+		log.Printf("全部已完成，停止升级: [%s - %s]", task.Name, offset.Key())
+		return nil
+	}
+
+	var message string
+	switch offset.Action {
+	case models.EscalationDM:
+		message = s.buildEscalationDMMessage(task, offset, deadline)
+		err = s.dtClient.SendPrivateMarkdown(pending, task.Name, message)
+
+	case models.EscalationSummary:
+		message = s.buildEscalationSummary(task, offset, deadline, pending)
+		err = s.sendEscalationSummary(task, offset, message)
+
+	default:
+		message, err = s.sendTaskReminder(task, offset, deadline, taskDate, pending)
+	}
+
+	reminderLog.MessageText = sql.NullString{String: message, Valid: true}
+	reminderLog.MemberCount = len(pending)
+
+	if err != nil {
+		s.finishReminder(reminderLog, models.ReminderStatusFailed)
+		return fmt.Errorf("发送升级提醒失败: %w", err)
+	}
+
+	reminderLog.Status = models.ReminderStatusSent
+	if err := s.taskService.FinishReminder(reminderLog); err != nil {
+		return fmt.Errorf("记录日志失败: %w", err)
+	}
+
+	log.Printf("✓ 升级提醒已发送: [%s - %s] %s %d人", task.Name, offset.Key(), escalationLabel(offset.Action), len(pending))
+	return nil
+}
+
+// sendEscalationSummary 汇总发送到指定群；未指定时私聊任务创建人，创建人未知时发到任务所在群
+func (s *Scheduler) sendEscalationSummary(task models.Task, offset models.ReminderOffset, message string) error {
+	title := fmt.Sprintf("%s 未完成汇总", task.Name)
+	switch {
+	case offset.TargetChatID != "":
+		return s.dtClient.SendMarkdown(offset.TargetChatID, title, message)
+	case task.CreatorUserID != "":
+		return s.dtClient.SendPrivateMarkdown([]string{task.CreatorUserID}, title, message)
+	default:
+		return s.dtClient.SendMarkdown(task.GroupChatID, title, message)
+	}
+}
+
+// finishReminder 记录提醒结果，记录失败只打印日志
+func (s *Scheduler) finishReminder(reminderLog *models.ReminderLog, status string) {
+	reminderLog.Status = status
+	if err := s.taskService.FinishReminder(reminderLog); err != nil {
+		log.Printf("记录日志失败: %v", err)
+	}
+}
+
+// 构建私聊催办消息
+func (s *Scheduler) buildEscalationDMMessage(task models.Task, offset models.ReminderOffset, deadline time.Time) string {
+	return fmt.Sprintf(
+		"### 🔴 任务超时提醒\n\n"+
+			"📋 任务: **%s**\n"+
+			"⏰ 截止时间: %s，%s\n\n"+
+			"你还没有完成该任务，请尽快完成并在群里回复: @我 已完成",
+		task.Name,
+		s.formatDeadline(task, deadline),
+		overdueText(offset),
+	)
+}
+
+// 构建未完成汇总消息（优先显示用户名）
+func (s *Scheduler) buildEscalationSummary(task models.Task, offset models.ReminderOffset, deadline time.Time, pending []string) string {
+	names, err := s.taskService.GetUserNames(pending)
+	if err != nil {
+		log.Printf("获取用户名称失败: %v", err)
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "### 📊 超时未完成汇总\n\n")
+	fmt.Fprintf(&b, "📋 任务: **%s**\n", task.Name)
+	if task.GroupChatName.Valid && task.GroupChatName.String != "" {
+		fmt.Fprintf(&b, "💬 群: %s\n", task.GroupChatName.String)
+	}
+	fmt.Fprintf(&b, "⏰ 截止时间: %s，%s\n", s.formatDeadline(task, deadline), overdueText(offset))
+	fmt.Fprintf(&b, "👥 未完成 **%d 人**:\n\n", len(pending))
+	for _, userID := range pending {
+		if name, ok := names[userID]; ok {
+			fmt.Fprintf(&b, "- %s\n", name)
+		} else {
+			fmt.Fprintf(&b, "- %s\n", userID)
+		}
+	}
+	return b.String()
+}
+
+// formatDeadline 按任务时区格式化截止时间
+func (s *Scheduler) formatDeadline(task models.Task, deadline time.Time) string {
+	loc := s.taskService.Location(task)
+	return deadline.In(loc).Format("01-02 15:04") + s.zoneSuffix(loc)
+}
+
+// overdueText 描述升级步骤相对截止时间的超时时长
+func overdueText(offset models.ReminderOffset) string {
+	if offset.Duration <= 0 {
+		return "已到截止时间"
+	}
+	return fmt.Sprintf("已超时%s", models.FormatOffsetDuration(offset.Duration))
+}
+
+// escalationLabel 升级动作的中文名称
+func escalationLabel(action models.EscalationAction) string {
+	switch action {
+	case models.EscalationDM:
+		return "私聊"
+	case models.EscalationSummary:
+		return "汇总"
+	default:
+		return "群内@"
+	}
+}
@@ -0,0 +1,108 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+)
+
+// LeaderElector 基于 Postgres advisory lock 的选主
+//
+// 锁持有在一个专用的数据库连接（会话）上：进程退出或连接断开时数据库自动释放锁，
+// 其他副本在下一次竞选时即可接管。领导者定期检查该连接，连接失效即视为失去领导权。
+type LeaderElector struct {
+	db       *sql.DB
+	lockID   int64
+	interval time.Duration
+}
+
+func NewLeaderElector(db *sql.DB, lockID int64, interval time.Duration) *LeaderElector {
+	return &LeaderElector{db: db, lockID: lockID, interval: interval}
+}
+
+// Run 持续竞选直到 ctx 取消
+// 成为领导者时调用 onElected（返回错误时放弃领导权稍后重试），失去领导权（或 ctx 取消）时调用 onRevoked
+func (e *LeaderElector) Run(ctx context.Context, onElected func(ctx context.Context) error, onRevoked func()) {
+	for {
+		conn, ok := e.tryAcquire(ctx)
+		if ok {
+			log.Printf("✓ 当选调度领导者 (lock %d)", e.lockID)
+			e.lead(ctx, conn, onElected, onRevoked)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(e.interval):
+		}
+	}
+}
+
+// tryAcquire 在新连接上尝试获取锁，成功时返回持有锁的连接
+func (e *LeaderElector) tryAcquire(ctx context.Context) (*sql.Conn, bool) {
+	conn, err := e.db.Conn(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			log.Printf("选主获取数据库连接失败: %v", err)
+		}
+		return nil, false
+	}
+
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, `SELECT pg_try_advisory_lock($1)`, e.lockID).Scan(&acquired); err != nil || !acquired {
+		if err != nil && ctx.Err() == nil {
+			log.Printf("竞选调度领导者失败: %v", err)
+		}
+		conn.Close()
+		return nil, false
+	}
+
+	return conn, true
+}
+
+// lead 以领导者身份运行，直到连接失效或 ctx 取消
+func (e *LeaderElector) lead(ctx context.Context, conn *sql.Conn, onElected func(ctx context.Context) error, onRevoked func()) {
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	defer conn.Close()
+
+	if err := onElected(leaderCtx); err != nil {
+		log.Printf("⚠️  领导者初始化失败，放弃领导权: %v", err)
+		e.release(conn)
+		return
+	}
+
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			onRevoked()
+			e.release(conn)
+			log.Println("✓ 已释放调度领导权")
+			return
+
+		case <-ticker.C:
+			checkCtx, checkCancel := context.WithTimeout(ctx, e.interval)
+			err := conn.PingContext(checkCtx)
+			checkCancel()
+			if err != nil && ctx.Err() == nil {
+				// 会话断开后数据库会释放锁，此时必须立即停止调度，避免与新领导者重复发送
+				log.Printf("⚠️  选主连接失效，失去调度领导权: %v", err)
+				onRevoked()
+				return
+			}
+		}
+	}
+}
+
+// release 主动释放锁，让其他副本尽快接管
+func (e *LeaderElector) release(conn *sql.Conn) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.interval)
+	defer cancel()
+	if _, err := conn.ExecContext(ctx, `SELECT pg_advisory_unlock($1)`, e.lockID); err != nil {
+		log.Printf("释放调度领导权失败: %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package scheduler
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"testing"
+	"time"
+
+	"dingteam-bot/internal/database"
+	"dingteam-bot/internal/models"
+	"dingteam-bot/internal/services"
+)
+
+// openTestDB 连接 DATABASE_URL 指向的测试库并执行迁移，未设置时跳过测试
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+	dsn := os.Getenv("DATABASE_URL")
+	if dsn == "" {
+		t.Skip("未设置 DATABASE_URL，跳过依赖数据库的测试")
+	}
+	db, err := database.NewDB(dsn)
+	if err != nil {
+		t.Fatalf("连接数据库失败: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+	if err := db.RunMigrations(); err != nil {
+		t.Fatalf("执行迁移失败: %v", err)
+	}
+	return db.DB
+}
+
+func TestLeaderElectorSingleLeader(t *testing.T) {
+	db := openTestDB(t)
+	ctx := context.Background()
+	lockID := time.Now().UnixNano()
+
+	first := NewLeaderElector(db, lockID, time.Second)
+	second := NewLeaderElector(db, lockID, time.Second)
+
+	conn, ok := first.tryAcquire(ctx)
+	if !ok {
+		t.Fatal("第一个副本未能当选")
+	}
+	if other, ok := second.tryAcquire(ctx); ok {
+		other.Close()
+		t.Fatal("两个副本同时当选领导者")
+	}
+
+	// 领导者释放后另一个副本可以接管
+	first.release(conn)
+	conn.Close()
+	other, ok := second.tryAcquire(ctx)
+	if !ok {
+		t.Fatal("领导者释放后其他副本未能接管")
+	}
+	second.release(other)
+	other.Close()
+}
+
+func TestClaimReminder(t *testing.T) {
+	db := openTestDB(t)
+
+	var taskID int
+	err := db.QueryRow(`
+		INSERT INTO tasks (name, cron_expr, group_chat_id, creator_user_id)
+		VALUES ('claim-test', '0 18 * * *', 'cid-claim-test', 'tester')
+		RETURNING id
+	`).Scan(&taskID)
+	if err != nil {
+		t.Fatalf("创建任务失败: %v", err)
+	}
+	t.Cleanup(func() { db.Exec(`DELETE FROM tasks WHERE id = $1`, taskID) })
+
+	taskService := services.NewTaskService(db, mustLocation(t), nil, nil, nil, nil)
+	newLog := func() *models.ReminderLog {
+		return &models.ReminderLog{
+			TaskID:         taskID,
+			GroupChatID:    "cid-claim-test",
+			ReminderType:   string(models.ReminderTypeDeadline),
+			ReminderOffset: "-1h",
+			OccurrenceAt:   sql.NullTime{Time: time.Date(2026, 10, 16, 18, 0, 0, 0, time.UTC), Valid: true},
+		}
+	}
+
+	claim := func(name string, want bool) *models.ReminderLog {
+		t.Helper()
+		reminderLog := newLog()
+		claimed, err := taskService.ClaimReminder(reminderLog)
+		if err != nil {
+			t.Fatalf("%s: 登记提醒失败: %v", name, err)
+		}
+		if claimed != want {
+			t.Fatalf("%s: claimed = %v, want %v", name, claimed, want)
+		}
+		return reminderLog
+	}
+
+	first := claim("首次登记", true)
+	claim("发送中重复登记", false)
+
+	// 发送失败的记录可以重新登记
+	first.Status = models.ReminderStatusFailed
+	if err := taskService.FinishReminder(first); err != nil {
+		t.Fatalf("记录发送结果失败: %v", err)
+	}
+	retry := claim("失败后重新登记", true)
+	if retry.ID != first.ID {
+		t.Errorf("重新登记应复用原记录: id = %d, want %d", retry.ID, first.ID)
+	}
+
+	// 发送中超过租约的记录视为发送方已崩溃
+	if _, err := db.Exec(`UPDATE reminder_logs SET sent_at = sent_at - make_interval(secs => $2) WHERE id = $1`,
+		first.ID, (services.ReminderSendingLease + time.Minute).Seconds()); err != nil {
+		t.Fatalf("修改登记时间失败: %v", err)
+	}
+	claim("发送中超时后重新登记", true)
+
+	// 已发送的记录不再登记
+	retry.Status = models.ReminderStatusSent
+	if err := taskService.FinishReminder(retry); err != nil {
+		t.Fatalf("记录发送结果失败: %v", err)
+	}
+	claim("已发送后重复登记", false)
+}
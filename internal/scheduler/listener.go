@@ -0,0 +1,63 @@
+package scheduler
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// TaskChangeListener 监听任务变更通知（Postgres LISTEN/NOTIFY）
+//
+// 任务可能在任意副本上被修改，而只有领导者运行调度器：每个副本都监听同一频道，
+// 由当前的领导者立即重新注册变更的任务。连接断开期间错过的通知在重连后整体重新加载补齐。
+type TaskChangeListener struct {
+	dsn     string
+	channel string
+}
+
+func NewTaskChangeListener(dsn, channel string) *TaskChangeListener {
+	return &TaskChangeListener{dsn: dsn, channel: channel}
+}
+
+// Run 持续监听直到 ctx 取消：收到通知时调用 onChange，重连后调用 onReconnect
+func (l *TaskChangeListener) Run(ctx context.Context, onChange func(taskID int), onReconnect func()) {
+	listener := pq.NewListener(l.dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Printf("任务变更监听连接异常: %v", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(l.channel); err != nil {
+		log.Printf("⚠️  监听任务变更失败，任务修改将在定期重新加载时生效: %v", err)
+		return
+	}
+	log.Printf("✓ 已监听任务变更通知 (%s)", l.channel)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case n := <-listener.Notify:
+			// 重连后会收到 nil，期间的通知可能已丢失
+			if n == nil {
+				onReconnect()
+				continue
+			}
+			taskID, err := strconv.Atoi(n.Extra)
+			if err != nil {
+				log.Printf("无效的任务变更通知: %q", n.Extra)
+				continue
+			}
+			onChange(taskID)
+
+		case <-time.After(90 * time.Second):
+			// 定期检查连接，及时发现断线
+			go listener.Ping()
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package scheduler
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// snoozedUntil 提醒被延后时返回延后后的发送时间（已到时间的提醒照常发送）
+func (s *Scheduler) snoozedUntil(task models.Task, offset models.ReminderOffset, deadline time.Time) (time.Time, bool) {
+	until, ok, err := s.taskService.ApplySnooze(task.ID, offset.At(deadline))
+	if err != nil {
+		log.Printf("查询延后设置 [%s] 失败: %v", task.Name, err)
+		return time.Time{}, false
+	}
+	if !ok || !time.Now().Before(until) {
+		return time.Time{}, false
+	}
+	return until, true
+}
+
+// deferReminder 在 until 时重新执行被延后的提醒（届时按任务的最新状态发送）
+func (s *Scheduler) deferReminder(task models.Task, offset models.ReminderOffset, deadline, until time.Time) {
+	key := fmt.Sprintf("%d/%s/%d", task.ID, offset.Key(), deadline.Unix())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return
+	}
+	if _, ok := s.deferred[key]; ok {
+		return
+	}
+
+	s.deferred[key] = time.AfterFunc(time.Until(until), func() {
+		s.mu.Lock()
+		_, pending := s.deferred[key]
+		delete(s.deferred, key)
+		s.mu.Unlock()
+		if !pending {
+			return
+		}
+
+		// 延后期间任务可能已被修改、暂停或删除（最后一次提醒被延后的有期限任务此时已结束，仍需发送）
+		current, err := s.taskService.GetTaskByID(task.ID)
+		if err != nil || (current.Status != models.TaskStatusActive && current.Status != models.TaskStatusFinished) {
+			return
+		}
+		if err := s.executeReminder(*current, offset, deadline); err != nil {
+			log.Printf("执行延后的提醒 [%s - %s] 失败: %v", current.Name, offset.Key(), err)
+		}
+	})
+	log.Printf("提醒已延后: [%s - %s] 至 %s", task.Name, offset.Key(), until.In(s.location).Format("15:04"))
+}
+
+// stopDeferredLocked 取消所有尚未执行的延后提醒（调用方需持有 s.mu）
+// 新的领导者启动补发时会按延后设置重新安排
+func (s *Scheduler) stopDeferredLocked() {
+	for key, timer := range s.deferred {
+		timer.Stop()
+		delete(s.deferred, key)
+	}
+}
+
+// resumeSnoozed 重新安排已延后但可能尚未发送的提醒（重启或切换领导者后，原来的定时器已丢失）
+// 已发送的提醒在登记时会因幂等键冲突而跳过，延后时间已过超过补发窗口的不再发送
+func (s *Scheduler) resumeSnoozed(task models.Task, now time.Time) {
+	snoozed, err := s.taskService.SnoozedReminders(task.ID, now.Add(-s.catchUpGrace))
+	if err != nil {
+		log.Printf("查询延后的提醒 [%s] 失败: %v", task.Name, err)
+		return
+	}
+	if len(snoozed) == 0 {
+		return
+	}
+
+	offsets, err := task.ReminderOffsets()
+	if err != nil {
+		return
+	}
+	for _, at := range snoozed {
+		for _, offset := range offsets {
+			schedule, err := s.reminderSchedule(task, offset)
+			if err != nil {
+				continue
+			}
+			if found, deadline := schedule.find(at.Add(-time.Second)); found.Equal(at) {
+				if err := s.executeReminder(task, offset, deadline); err != nil {
+					log.Printf("补发延后的提醒 [%s - %s] 失败: %v", task.Name, offset.Key(), err)
+				}
+			}
+		}
+	}
+}
+
+// skipIfWaived 本期已被跳过时将已登记的提醒记为 SKIPPED，返回是否已跳过
+func (s *Scheduler) skipIfWaived(task models.Task, offset models.ReminderOffset, deadline time.Time, reminderLog *models.ReminderLog) bool {
+	taskDate := s.taskService.TaskDate(task, deadline)
+	skipped, err := s.taskService.IsOccurrenceSkipped(task.ID, taskDate)
+	if err != nil {
+		log.Printf("查询跳过设置 [%s] 失败: %v", task.Name, err)
+		return false
+	}
+	if !skipped {
+		return false
+	}
+
+	reminderLog.MessageText = sql.NullString{String: fmt.Sprintf("%s 已跳过", taskDate.Format("2006-01-02")), Valid: true}
+	s.finishReminder(reminderLog, models.ReminderStatusSkipped)
+	log.Printf("本期已跳过: [%s - %s] %s", task.Name, offset.Key(), taskDate.Format("2006-01-02"))
+	return true
+}
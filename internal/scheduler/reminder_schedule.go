@@ -0,0 +1,68 @@
+package scheduler
+
+import (
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 查找提醒时间时最多检查的截止时间个数
+const maxReminderScan = 64
+
+// reminderSchedule 将任务的截止时间序列按提醒偏移平移后得到的提醒时间序列（实现 cron.Schedule）
+type reminderSchedule struct {
+	deadlines cron.Schedule
+	offset    models.ReminderOffset
+}
+
+// Next 返回 t 之后的下一个提醒时间，没有时返回零值
+func (r *reminderSchedule) Next(t time.Time) time.Time {
+	at, _ := r.find(t)
+	return at
+}
+
+// deadlineFor 返回在 firedAt 触发的提醒所对应的截止时间
+// 取 firedAt 之前一分钟内最晚的一次提醒，容忍任务执行的少量延迟
+func (r *reminderSchedule) deadlineFor(firedAt time.Time) time.Time {
+	at, deadline := r.find(firedAt.Add(-time.Minute))
+	for !at.IsZero() {
+		nextAt, nextDeadline := r.find(at)
+		if nextAt.IsZero() || nextAt.After(firedAt) {
+			break
+		}
+		at, deadline = nextAt, nextDeadline
+	}
+	return deadline
+}
+
+// find 返回 t 之后的第一个提醒时间及其对应的截止时间
+// 提醒时间随截止时间单调不减，因此从可能的最早截止时间开始顺序查找即可
+func (r *reminderSchedule) find(t time.Time) (time.Time, time.Time) {
+	deadline := r.deadlines.Next(r.searchFrom(t))
+	for i := 0; i < maxReminderScan && !deadline.IsZero(); i++ {
+		if at := r.offset.At(deadline); at.After(t) {
+			return at, deadline
+		}
+
+		if r.offset.HasClock {
+			// 固定时刻只与截止日期有关，直接跳到下一天的截止时间
+			y, m, d := deadline.Date()
+			deadline = r.deadlines.Next(time.Date(y, m, d+1, 0, 0, 0, 0, deadline.Location()).Add(-time.Second))
+		} else {
+			deadline = r.deadlines.Next(deadline)
+		}
+	}
+
+	return time.Time{}, time.Time{}
+}
+
+// searchFrom 返回查找截止时间的起点：提醒时间晚于 t 的截止时间一定晚于该起点
+func (r *reminderSchedule) searchFrom(t time.Time) time.Time {
+	if r.offset.HasClock {
+		// 提醒时间落在截止日期 + Days 天当天，多留一小时应对夏令时
+		return t.Add(-time.Duration(r.offset.Days+1)*24*time.Hour - time.Hour)
+	}
+	return t.Add(-r.offset.Duration - time.Second)
+}
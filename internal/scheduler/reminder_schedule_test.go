@@ -0,0 +1,234 @@
+package scheduler
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"dingteam-bot/internal/models"
+	"dingteam-bot/internal/services"
+)
+
+func mustLocation(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("加载时区失败: %v", err)
+	}
+	return loc
+}
+
+func deadlineClock(hour, minute int) sql.NullTime {
+	return sql.NullTime{Time: time.Date(0, 1, 1, hour, minute, 0, 0, time.UTC), Valid: true}
+}
+
+func dateValue(month time.Month, day int) sql.NullTime {
+	return sql.NullTime{Time: time.Date(2026, month, day, 0, 0, 0, 0, time.UTC), Valid: true}
+}
+
+func newTestReminderSchedule(t *testing.T, task models.Task, spec string, loc *time.Location) *reminderSchedule {
+	t.Helper()
+	deadlines, err := services.NewTaskSchedule(task, loc)
+	if err != nil {
+		t.Fatalf("创建截止时间序列失败: %v", err)
+	}
+	offset, err := models.ParseReminderOffset(spec)
+	if err != nil {
+		t.Fatalf("解析提醒偏移失败: %v", err)
+	}
+	return &reminderSchedule{deadlines: deadlines, offset: offset}
+}
+
+func TestReminderScheduleNext(t *testing.T) {
+	loc := mustLocation(t)
+	// 2026-10-16 是周五
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, loc)
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name   string
+		task   models.Task
+		offset string
+		want   []time.Time
+	}{
+		{
+			name:   "每周一零点的通知提前 30 分钟跨过午夜",
+			task:   models.Task{Type: models.TaskTypeNotification, CronExpr: "0 0 * * 1"},
+			offset: "-30m",
+			want:   []time.Time{at(10, 18, 23, 30), at(10, 25, 23, 30)},
+		},
+		{
+			name:   "一天多个时刻的通知每个时刻都提前",
+			task:   models.Task{Type: models.TaskTypeNotification, CronExpr: "0 9,14 * * *"},
+			offset: "-1h",
+			want:   []time.Time{at(10, 16, 13, 0), at(10, 17, 8, 0), at(10, 17, 13, 0)},
+		},
+		{
+			name:   "间隔触发的通知",
+			task:   models.Task{Type: models.TaskTypeNotification, CronExpr: "*/30 13-14 * * *"},
+			offset: "-10m",
+			want:   []time.Time{at(10, 16, 12, 50), at(10, 16, 13, 20), at(10, 16, 13, 50), at(10, 16, 14, 20), at(10, 17, 12, 50)},
+		},
+		{
+			name:   "带秒的 6 段表达式",
+			task:   models.Task{Type: models.TaskTypeNotification, CronExpr: "0 30 8 * * *"},
+			offset: "deadline",
+			want:   []time.Time{at(10, 17, 8, 30), at(10, 18, 8, 30)},
+		},
+		{
+			name:   "通知触发后的提醒",
+			task:   models.Task{Type: models.TaskTypeNotification, CronExpr: "0 23 * * *"},
+			offset: "+90m",
+			want:   []time.Time{at(10, 17, 0, 30), at(10, 18, 0, 30)},
+		},
+		{
+			name:   "周任务按截止时间提前",
+			task:   models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 5", DeadlineTime: deadlineClock(18, 0)},
+			offset: "-2h",
+			want:   []time.Time{at(10, 16, 16, 0), at(10, 23, 16, 0)},
+		},
+		{
+			name:   "周任务前一天的固定时刻",
+			task:   models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 5", DeadlineTime: deadlineClock(18, 0)},
+			offset: "-1d 18:00",
+			want:   []time.Time{at(10, 22, 18, 0), at(10, 29, 18, 0)},
+		},
+		{
+			name:   "周任务固定时刻只在触发日提醒",
+			task:   models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 1,5", DeadlineTime: deadlineClock(18, 0)},
+			offset: "10:00",
+			want:   []time.Time{at(10, 19, 10, 0), at(10, 23, 10, 0), at(10, 26, 10, 0)},
+		},
+		{
+			name:   "凌晨截止的任务提前提醒落在前一天",
+			task:   models.Task{Type: models.TaskTypeTask, CronExpr: "0 0 * * 6", DeadlineTime: deadlineClock(0, 30)},
+			offset: "-1h",
+			want:   []time.Time{at(10, 16, 23, 30), at(10, 23, 23, 30)},
+		},
+		{
+			name:   "一天多次触发的任务每天只截止一次",
+			task:   models.Task{Type: models.TaskTypeTask, CronExpr: "0 9,14 * * *"},
+			offset: "-30m",
+			want:   []time.Time{at(10, 17, 8, 30), at(10, 18, 8, 30)},
+		},
+		{
+			name: "单次任务只提醒一次",
+			task: models.Task{
+				Type:         models.TaskTypeTask,
+				ScheduleKind: models.ScheduleKindOnce,
+				DueAt:        sql.NullTime{Time: at(10, 23, 18, 0), Valid: true},
+			},
+			offset: "-1h",
+			want:   []time.Time{at(10, 23, 17, 0), {}},
+		},
+		{
+			name: "开始日期之前不提醒",
+			task: models.Task{
+				Type:      models.TaskTypeTask,
+				CronExpr:  "0 9 * * *",
+				StartDate: dateValue(10, 20),
+			},
+			offset: "deadline",
+			want:   []time.Time{at(10, 20, 9, 0), at(10, 21, 9, 0)},
+		},
+		{
+			name: "结束日期当天仍提醒",
+			task: models.Task{
+				Type:     models.TaskTypeNotification,
+				CronExpr: "0 9 * * *",
+				EndDate:  dateValue(10, 18),
+			},
+			offset: "-10m",
+			want:   []time.Time{at(10, 17, 8, 50), at(10, 18, 8, 50), {}},
+		},
+		{
+			name: "执行次数从开始日期起计算",
+			task: models.Task{
+				Type:           models.TaskTypeTask,
+				CronExpr:       "0 9 * * 5",
+				DeadlineTime:   deadlineClock(18, 0),
+				StartDate:      dateValue(10, 9),
+				MaxOccurrences: 3,
+			},
+			offset: "+30m",
+			want:   []time.Time{at(10, 16, 18, 30), at(10, 23, 18, 30), {}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := newTestReminderSchedule(t, tt.task, tt.offset, loc)
+
+			cur := now
+			for i, want := range tt.want {
+				got := schedule.Next(cur)
+				if !got.Equal(want) {
+					t.Fatalf("第 %d 次提醒 = %s，期望 %s", i+1, got, want)
+				}
+				cur = got
+			}
+		})
+	}
+}
+
+func TestReminderScheduleDeadlineFor(t *testing.T) {
+	loc := mustLocation(t)
+	at := func(day, hour, minute, second int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, second, 0, loc)
+	}
+
+	tests := []struct {
+		name    string
+		task    models.Task
+		offset  string
+		firedAt time.Time
+		want    time.Time
+	}{
+		{
+			name:    "跨午夜的提前提醒对应次日截止",
+			task:    models.Task{Type: models.TaskTypeNotification, CronExpr: "0 0 * * 1"},
+			offset:  "-30m",
+			firedAt: at(18, 23, 30, 0),
+			want:    at(19, 0, 0, 0),
+		},
+		{
+			name:    "执行稍有延迟仍对应本次截止",
+			task:    models.Task{Type: models.TaskTypeNotification, CronExpr: "0 9,14 * * *"},
+			offset:  "-1h",
+			firedAt: at(16, 13, 0, 2),
+			want:    at(16, 14, 0, 0),
+		},
+		{
+			name:    "周任务前一天提醒对应本周截止",
+			task:    models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 5", DeadlineTime: deadlineClock(18, 0)},
+			offset:  "-1d 18:00",
+			firedAt: at(22, 18, 0, 0),
+			want:    at(23, 18, 0, 0),
+		},
+		{
+			name:    "超时提醒对应已过去的截止",
+			task:    models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 5", DeadlineTime: deadlineClock(18, 0)},
+			offset:  "+30m",
+			firedAt: at(16, 18, 30, 0),
+			want:    at(16, 18, 0, 0),
+		},
+		{
+			name:    "秒级间隔取最近一次",
+			task:    models.Task{Type: models.TaskTypeNotification, CronExpr: "*/20 * * * * *"},
+			offset:  "deadline",
+			firedAt: at(16, 12, 0, 40),
+			want:    at(16, 12, 0, 40),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := newTestReminderSchedule(t, tt.task, tt.offset, loc)
+			if got := schedule.deadlineFor(tt.firedAt); !got.Equal(tt.want) {
+				t.Errorf("deadlineFor(%s) = %s，期望 %s", tt.firedAt, got, tt.want)
+			}
+		})
+	}
+}
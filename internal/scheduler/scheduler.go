@@ -5,6 +5,9 @@ import (
 	"database/sql"
 	"fmt"
 	"log"
+	"slices"
+	"strings"
+	"sync"
 	"time"
 
 	"dingteam-bot/internal/dingtalk"
@@ -15,13 +18,31 @@ import (
 )
 
 type Scheduler struct {
-	cron        *cron.Cron
-	taskService *services.TaskService
-	dtClient    *dingtalk.Client
-	location    *time.Location
+	cron          *cron.Cron
+	taskService   *services.TaskService
+	cardService   *services.ReminderCardService
+	digestService *services.DigestService
+	dtClient      *dingtalk.Client
+	location      *time.Location
+
+	catchUpGrace time.Duration // 启动时补发错过提醒的窗口，原定时间更早的记为跳过
+
+	mu       sync.Mutex
+	entries  map[int]*taskEntries   // 任务ID → 已注册的 cron 条目
+	digests  map[int]*digestEntry   // 定时汇总ID → 已注册的 cron 条目
+	deferred map[string]*time.Timer // 被延后的提醒（任务ID/偏移/截止时间 → 定时器）
+	running  bool                   // 是否正在调度（多副本部署时只有领导者运行）
+	cancel   context.CancelFunc     // 停止定期重新加载
 }
 
-func NewScheduler(taskService *services.TaskService, dtClient *dingtalk.Client, timezone string) (*Scheduler, error) {
+// taskEntries 记录任务注册时的快照及其对应的 cron 条目
+type taskEntries struct {
+	task     models.Task
+	location string // 注册时任务使用的时区（群默认时区变化时需要重新注册）
+	entryIDs []cron.EntryID
+}
+
+func NewScheduler(taskService *services.TaskService, cardService *services.ReminderCardService, digestService *services.DigestService, dtClient *dingtalk.Client, timezone string, catchUpGrace time.Duration) (*Scheduler, error) {
 	loc, err := time.LoadLocation(timezone)
 	if err != nil {
 		return nil, fmt.Errorf("加载时区失败: %w", err)
@@ -30,14 +51,20 @@ func NewScheduler(taskService *services.TaskService, dtClient *dingtalk.Client,
 	c := cron.New(cron.WithLocation(loc), cron.WithSeconds())
 
 	return &Scheduler{
-		cron:        c,
-		taskService: taskService,
-		dtClient:    dtClient,
-		location:    loc,
+		cron:          c,
+		taskService:   taskService,
+		cardService:   cardService,
+		digestService: digestService,
+		dtClient:      dtClient,
+		location:      loc,
+		catchUpGrace:  catchUpGrace,
+		entries:       make(map[int]*taskEntries),
+		digests:       make(map[int]*digestEntry),
+		deferred:      make(map[string]*time.Timer),
 	}, nil
 }
 
-// 启动调度器
+// 启动调度器（可在 Stop 之后再次启动）
 func (s *Scheduler) Start(ctx context.Context) error {
 	// 加载所有活跃任务
 	tasks, err := s.taskService.GetPendingTasks()
@@ -45,9 +72,20 @@ func (s *Scheduler) Start(ctx context.Context) error {
 		return fmt.Errorf("加载任务失败: %w", err)
 	}
 
-	// 注册每个任务
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.running {
+		return fmt.Errorf("调度器已在运行")
+	}
+
+	// 注册每个任务（已结束的任务在补发错过的提醒后标记为结束）
+	now := time.Now().In(s.location)
 	for _, task := range tasks {
-		if err := s.registerTask(task); err != nil {
+		if s.taskExpired(task, now) {
+			continue
+		}
+		if err := s.addTaskLocked(task); err != nil {
 			log.Printf("注册任务 [%s] 失败: %v", task.Name, err)
 			continue
 		}
@@ -55,218 +93,209 @@ func (s *Scheduler) Start(ctx context.Context) error {
 
 	// 启动 cron
 	s.cron.Start()
+	s.running = true
 	log.Printf("✓ 调度器已启动，共加载 %d 个任务", len(tasks))
 
 	// 定期重新加载任务（每 5 分钟）
-	go s.periodicReload(ctx)
+	reloadCtx, cancel := context.WithCancel(ctx)
+	s.cancel = cancel
+	go s.periodicReload(reloadCtx)
 
-	return nil
-}
+	// 补发停机期间错过的提醒
+	go s.catchUp(reloadCtx, tasks)
 
-// 注册任务到 cron（为每个任务注册多个提醒时间点）
-func (s *Scheduler) registerTask(task models.Task) error {
-	switch task.Type {
-	case models.TaskTypeTask:
-		// 任务型：注册 3 个提醒
-		// 1. 每天10点提醒
-		if err := s.registerReminder(task, models.ReminderTypeMorning10AM, "0 0 10 * * *"); err != nil {
-			log.Printf("注册10点提醒失败: %v", err)
-		}
+	// 私聊提醒点击了"稍后提醒"的成员
+	go s.runMemberSnoozes(reloadCtx)
 
-		// 2. 提前1小时提醒
-		if task.DeadlineTime.Valid {
-			cronExpr := s.calculateAdvanceReminderCron(task.DeadlineTime.Time, 60)
-			if err := s.registerReminder(task, models.ReminderTypeAdvance1Hour, cronExpr); err != nil {
-				log.Printf("注册提前1小时提醒失败: %v", err)
-			}
-		}
+	// 注册定时汇总（日报、周报）
+	go s.ReloadDigests()
 
-		// 3. 截止时间提醒
-		if task.DeadlineTime.Valid {
-			cronExpr := s.calculateDeadlineCron(task.DeadlineTime.Time)
-			if err := s.registerReminder(task, models.ReminderTypeDeadline, cronExpr); err != nil {
-				log.Printf("注册截止时间提醒失败: %v", err)
-			}
-		}
+	return nil
+}
+
+// addTaskLocked 注册任务并记录其 cron 条目（调用方需持有 s.mu）
+func (s *Scheduler) addTaskLocked(task models.Task) error {
+	entryIDs, err := s.registerTask(task)
+	if err != nil {
+		return err
+	}
+	s.entries[task.ID] = &taskEntries{task: task, location: s.taskService.Location(task).String(), entryIDs: entryIDs}
+	return nil
+}
 
-		log.Printf("✓ 注册任务型提醒: [%s] (10点 + 提前1小时 + 截止时间)", task.Name)
+// removeTaskLocked 移除任务的所有 cron 条目（调用方需持有 s.mu）
+func (s *Scheduler) removeTaskLocked(taskID int) {
+	registered, ok := s.entries[taskID]
+	if !ok {
+		return
+	}
+	for _, id := range registered.entryIDs {
+		s.cron.Remove(id)
+	}
+	delete(s.entries, taskID)
+}
 
-	case models.TaskTypeNotification:
-		// 通知型：注册 3 个提醒
-		// 1. 每天10点提醒
-		if err := s.registerReminder(task, models.ReminderTypeMorning10AM, "0 0 10 * * *"); err != nil {
-			log.Printf("注册10点提醒失败: %v", err)
-		}
+// 注册任务到 cron（按任务的提醒计划为每个提醒项注册一个条目），返回注册成功的条目
+func (s *Scheduler) registerTask(task models.Task) ([]cron.EntryID, error) {
+	if task.Type != models.TaskTypeTask && task.Type != models.TaskTypeNotification {
+		return nil, fmt.Errorf("未知任务类型: %s", task.Type)
+	}
 
-		// 2. 提前30分钟提醒（基于 cron 表达式计算）
-		cronExpr30Min := s.calculateAdvanceReminderFromCron(task.CronExpr, 30)
-		if cronExpr30Min != "" {
-			if err := s.registerReminder(task, models.ReminderTypeAdvance30Min, cronExpr30Min); err != nil {
-				log.Printf("注册提前30分钟提醒失败: %v", err)
-			}
-		}
+	offsets, err := task.ReminderOffsets()
+	if err != nil {
+		return nil, fmt.Errorf("解析提醒计划失败: %w", err)
+	}
 
-		// 3. 触发时间提醒（使用原 cron 表达式）
-		if err := s.registerReminder(task, models.ReminderTypeTrigger, task.CronExpr); err != nil {
-			log.Printf("注册触发时间提醒失败: %v", err)
+	var entryIDs []cron.EntryID
+	var specs []string
+	for _, offset := range offsets {
+		schedule, err := s.reminderSchedule(task, offset)
+		if err != nil {
+			log.Printf("注册提醒 [%s - %s] 失败: %v", task.Name, offset.Spec, err)
+			continue
 		}
 
-		log.Printf("✓ 注册通知型提醒: [%s] (10点 + 提前30分钟 + 触发时间)", task.Name)
+		entryIDs = append(entryIDs, s.registerReminder(task, offset, schedule))
+		specs = append(specs, offset.Key())
+	}
 
-	default:
-		return fmt.Errorf("未知任务类型: %s", task.Type)
+	label := "任务型"
+	if task.Type == models.TaskTypeNotification {
+		label = "通知型"
 	}
+	log.Printf("✓ 注册%s提醒: [%s] (%s)", label, task.Name, strings.Join(specs, " + "))
 
-	return nil
+	return entryIDs, nil
 }
 
 // 注册单个提醒
-func (s *Scheduler) registerReminder(task models.Task, reminderType models.ReminderType, cronExpr string) error {
-	if cronExpr == "" {
-		return fmt.Errorf("空的 cron 表达式")
-	}
+func (s *Scheduler) registerReminder(task models.Task, offset models.ReminderOffset, schedule *reminderSchedule) cron.EntryID {
+	return s.cron.Schedule(schedule, cron.FuncJob(func() {
+		deadline := schedule.deadlineFor(time.Now().In(s.location))
 
-	_, err := s.cron.AddFunc(cronExpr, func() {
-		if err := s.executeReminder(task, reminderType); err != nil {
-			log.Printf("执行提醒 [%s - %s] 失败: %v", task.Name, reminderType, err)
+		if err := s.executeReminder(task, offset, deadline); err != nil {
+			log.Printf("执行提醒 [%s - %s] 失败: %v", task.Name, offset.Key(), err)
 		}
-	})
 
+		now := time.Now().In(s.location)
+		s.updateRunTime(task, now)
+		if s.taskExpired(task, now) {
+			s.finishTask(task)
+			s.UnregisterTask(task.ID)
+		}
+	}))
+}
+
+// 计算提醒项的触发时间序列：由任务的截止时间序列按偏移平移得到
+// 任务型只在 cron 有触发的日期提醒；通知型的每次触发都是一次截止
+func (s *Scheduler) reminderSchedule(task models.Task, offset models.ReminderOffset) (*reminderSchedule, error) {
+	deadlines, err := s.taskService.Schedule(task)
 	if err != nil {
-		return fmt.Errorf("添加 cron 任务失败: %w", err)
+		return nil, err
 	}
-
-	return nil
+	return &reminderSchedule{deadlines: deadlines, offset: offset}, nil
 }
 
-// 计算截止时间的 cron 表达式
-func (s *Scheduler) calculateDeadlineCron(deadline time.Time) string {
-	// 将 deadline 转换为当前时区
-	deadline = deadline.In(s.location)
-	hour := deadline.Hour()
-	minute := deadline.Minute()
-
-	// 秒 分 时 日 月 周
-	// 每天的指定时间执行
-	return fmt.Sprintf("0 %d %d * * *", minute, hour)
+// 计算下一次截止时间：任务型为下一个触发日的截止时间，通知型为下一次触发时间
+func (s *Scheduler) nextDeadline(task models.Task) (time.Time, bool) {
+	deadlines, err := s.taskService.Schedule(task)
+	if err != nil {
+		return time.Time{}, false
+	}
+	next := deadlines.Next(time.Now().In(s.location))
+	return next, !next.IsZero()
 }
 
-// 计算提前提醒的 cron 表达式（基于截止时间）
-func (s *Scheduler) calculateAdvanceReminderCron(deadline time.Time, advanceMinutes int) string {
-	// 将 deadline 转换为当前时区
-	deadline = deadline.In(s.location)
-
-	// 计算提前时间
-	reminderTime := deadline.Add(-time.Duration(advanceMinutes) * time.Minute)
-	hour := reminderTime.Hour()
-	minute := reminderTime.Minute()
-
-	// 秒 分 时 日 月 周
-	return fmt.Sprintf("0 %d %d * * *", minute, hour)
-}
+// 执行提醒（deadline 为本次提醒对应的截止时间）
+func (s *Scheduler) executeReminder(task models.Task, offset models.ReminderOffset, deadline time.Time) error {
+	// 被延后的提醒到时间再发送（在登记之前判断，延后期间不占用幂等键）
+	if until, ok := s.snoozedUntil(task, offset, deadline); ok {
+		s.deferReminder(task, offset, deadline, until)
+		return nil
+	}
 
-// 计算提前提醒的 cron 表达式（基于原 cron 表达式）
-func (s *Scheduler) calculateAdvanceReminderFromCron(originalCron string, advanceMinutes int) string {
-	// 解析原 cron 表达式提取时间
-	// 假设格式为 "秒 分 时 日 月 周"
-	// 简单实现：如果是固定时间的 cron，提前 N 分钟
+	now := time.Now()
+	reminderType := offset.ReminderType(task.Type)
+	log.Printf("执行提醒: [%s - %s] %s", task.Name, offset.Key(), now.Format("2006-01-02 15:04:05"))
 
-	// 使用 cron 库解析
-	schedule, err := cron.ParseStandard(originalCron)
+	// 先登记再发送：同一次提醒只会被一个副本登记成功，避免切换领导者时重复发送
+	reminderLog := &models.ReminderLog{
+		TaskID:         task.ID,
+		GroupChatID:    task.GroupChatID,
+		ReminderType:   string(reminderType),
+		ReminderOffset: offset.Key(),
+		OccurrenceAt:   sql.NullTime{Time: deadline, Valid: true},
+	}
+	claimed, err := s.taskService.ClaimReminder(reminderLog)
 	if err != nil {
-		log.Printf("解析 cron 表达式失败: %v", err)
-		return ""
+		return fmt.Errorf("登记提醒失败: %w", err)
+	}
+	if !claimed {
+		log.Printf("提醒已发送过，跳过: [%s - %s] %s", task.Name, offset.Key(), deadline.Format("2006-01-02 15:04"))
+		return nil
 	}
 
-	// 获取下一次执行时间
-	now := time.Now().In(s.location)
-	nextTime := schedule.Next(now)
-
-	// 提前 N 分钟
-	reminderTime := nextTime.Add(-time.Duration(advanceMinutes) * time.Minute)
-	hour := reminderTime.Hour()
-	minute := reminderTime.Minute()
-
-	// 生成新的 cron 表达式（每天同一时间）
-	return fmt.Sprintf("0 %d %d * * *", minute, hour)
-}
+	// 本期已被管理员跳过
+	if s.skipIfWaived(task, offset, deadline, reminderLog) {
+		return nil
+	}
 
-// 执行提醒
-func (s *Scheduler) executeReminder(task models.Task, reminderType models.ReminderType) error {
-	now := time.Now()
-	log.Printf("执行提醒: [%s - %s] %s", task.Name, reminderType, now.Format("2006-01-02 15:04:05"))
+	// 任务型截止后的提醒按升级步骤执行
+	if isEscalation(task, offset) {
+		return s.executeEscalation(task, offset, deadline, reminderLog)
+	}
 
 	var message string
 	var atUserIDs []string
-	var err error
+	var sendErr error
 
-	// 根据任务类型和提醒类型构建消息和@用户列表
+	// 根据任务类型和提醒类型构建消息和@用户列表，发送群消息（带@）
 	switch task.Type {
 	case models.TaskTypeTask:
-		// 任务型：@未完成的人（排除领导）
-		atUserIDs, err = s.taskService.GetIncompleteUsersToday(task.ID, task.GroupChatID)
+		// 任务型：@本期未完成的负责人（排除免提醒和被豁免的成员）
+		taskDate := s.taskService.TaskDate(task, deadline)
+		atUserIDs, err = s.taskService.GetIncompleteUsers(task, taskDate)
 		if err != nil {
 			log.Printf("获取未完成用户失败: %v", err)
 			atUserIDs = []string{}
 		}
-		message = s.buildTaskReminderMessage(task, reminderType, len(atUserIDs))
+		message, sendErr = s.sendTaskReminder(task, offset, deadline, taskDate, atUserIDs)
 
 	case models.TaskTypeNotification:
-		// 通知型：@所有人（排除领导）
-		atUserIDs, err = s.taskService.GetAllNonLeaderUsers()
+		// 通知型：@所有负责人（排除免提醒和被豁免的成员）
+		atUserIDs, err = s.taskService.GetResponsibleUserIDs(task, s.taskService.TaskDate(task, deadline))
 		if err != nil {
 			log.Printf("获取用户列表失败: %v", err)
 			atUserIDs = []string{}
 		}
-		message = s.buildNotificationReminderMessage(task, reminderType)
+		message = s.buildNotificationReminderMessage(task, offset)
+		sendErr = s.dtClient.SendMarkdownWithMentions(task.GroupChatID, task.Name, message, atUserIDs)
 	}
 
-	// 发送群消息（带@）
-	if err := s.dtClient.SendMarkdownWithMentions(task.GroupChatID, task.Name, message, atUserIDs); err != nil {
-		return fmt.Errorf("发送消息失败: %w", err)
+	reminderLog.MessageText = sql.NullString{String: message, Valid: true}
+	reminderLog.MemberCount = len(atUserIDs)
+
+	if sendErr != nil {
+		reminderLog.Status = models.ReminderStatusFailed
+		if logErr := s.taskService.FinishReminder(reminderLog); logErr != nil {
+			log.Printf("记录日志失败: %v", logErr)
+		}
+		return fmt.Errorf("发送消息失败: %w", sendErr)
 	}
 
 	// 记录提醒日志
-	reminderLog := &models.ReminderLog{
-		TaskID:       task.ID,
-		GroupChatID:  task.GroupChatID,
-		ReminderType: string(reminderType),
-		MessageText:  sql.NullString{String: message, Valid: true},
-		MemberCount:  len(atUserIDs),
-	}
-	if err := s.taskService.LogReminder(reminderLog); err != nil {
+	reminderLog.Status = models.ReminderStatusSent
+	if err := s.taskService.FinishReminder(reminderLog); err != nil {
 		return fmt.Errorf("记录日志失败: %w", err)
 	}
 
-	log.Printf("✓ 提醒已发送: [%s - %s] @%d人", task.Name, reminderType, len(atUserIDs))
+	log.Printf("✓ 提醒已发送: [%s - %s] @%d人", task.Name, offset.Key(), len(atUserIDs))
 	return nil
 }
 
 // 构建任务型提醒消息
-func (s *Scheduler) buildTaskReminderMessage(task models.Task, reminderType models.ReminderType, incompleteCount int) string {
-	now := time.Now()
-	deadline := task.DeadlineTime.Time.In(s.location)
-
-	var title, status string
-
-	switch reminderType {
-	case models.ReminderTypeMorning10AM:
-		title = "🌅 早安提醒"
-		status = fmt.Sprintf("今日需完成，截止时间: %s", deadline.Format("15:04"))
-
-	case models.ReminderTypeAdvance1Hour:
-		title = "⏰ 提前1小时提醒"
-		status = fmt.Sprintf("距离截止时间还有1小时，截止时间: %s", deadline.Format("15:04"))
-
-	case models.ReminderTypeDeadline:
-		if now.After(deadline) {
-			title = "🔴 超时通报"
-			status = "**任务已超时，请尽快完成！**"
-		} else {
-			title = "⏰ 截止时间提醒"
-			status = fmt.Sprintf("现在是截止时间: %s", deadline.Format("15:04"))
-		}
-	}
+func (s *Scheduler) buildTaskReminderMessage(task models.Task, offset models.ReminderOffset, deadline time.Time, incompleteCount int) string {
+	title, status := s.taskReminderHeading(task, offset, deadline)
 
 	message := fmt.Sprintf(
 		"### %s\n\n"+
@@ -285,22 +314,80 @@ func (s *Scheduler) buildTaskReminderMessage(task models.Task, reminderType mode
 	return message
 }
 
+// taskReminderHeading 任务型提醒的标题和截止状态
+func (s *Scheduler) taskReminderHeading(task models.Task, offset models.ReminderOffset, deadline time.Time) (title, status string) {
+	// 日期和时刻按任务的时区显示
+	loc := s.taskService.Location(task)
+	now := time.Now().In(loc)
+	deadline = deadline.In(loc)
+
+	// 截止时间不在今天时带上日期（如周任务的前一天提醒）
+	deadlineText := deadline.Format("15:04")
+	dueText := "今日需完成"
+	if deadline.YearDay() != now.YearDay() || deadline.Year() != now.Year() {
+		deadlineText = deadline.Format("01-02 15:04")
+		dueText = fmt.Sprintf("需在 %s 前完成", deadlineText)
+	}
+	deadlineText += s.zoneSuffix(loc)
+
+	switch offset.ReminderType(task.Type) {
+	case models.ReminderTypeScheduled:
+		title = "📌 任务提醒"
+		status = fmt.Sprintf("%s，截止时间: %s", dueText, deadlineText)
+
+	case models.ReminderTypeAdvance:
+		title = fmt.Sprintf("⏰ 提前%s提醒", models.FormatOffsetDuration(offset.Duration))
+		status = fmt.Sprintf("距离截止时间还有%s，截止时间: %s", models.FormatOffsetDuration(offset.Duration), deadlineText)
+
+	case models.ReminderTypeDeadline:
+		if now.After(deadline) {
+			title = "🔴 超时通报"
+			status = "**任务已超时，请尽快完成！**"
+		} else {
+			title = "⏰ 截止时间提醒"
+			status = fmt.Sprintf("现在是截止时间: %s", deadlineText)
+		}
+
+	case models.ReminderTypeOverdue:
+		title = "🔴 超时通报"
+		status = fmt.Sprintf("**任务已超时%s，请尽快完成！**", models.FormatOffsetDuration(offset.Duration))
+	}
+
+	// 重复催办标明第几次
+	if offset.Times > 1 {
+		title = fmt.Sprintf("%s（第 %d/%d 次催办）", title, offset.Step, offset.Times)
+	}
+	return title, status
+}
+
+// zoneSuffix 任务时区与调度器默认时区不同时，在时间后注明时区
+func (s *Scheduler) zoneSuffix(loc *time.Location) string {
+	if loc.String() == s.location.String() {
+		return ""
+	}
+	return fmt.Sprintf(" (%s)", loc)
+}
+
 // 构建通知型提醒消息
-func (s *Scheduler) buildNotificationReminderMessage(task models.Task, reminderType models.ReminderType) string {
+func (s *Scheduler) buildNotificationReminderMessage(task models.Task, offset models.ReminderOffset) string {
 	var title, timeInfo string
 
-	switch reminderType {
-	case models.ReminderTypeMorning10AM:
-		title = "🌅 早安通知"
+	switch offset.ReminderType(task.Type) {
+	case models.ReminderTypeScheduled:
+		title = "📌 事项提醒"
 		timeInfo = "今日待办事项提醒"
 
-	case models.ReminderTypeAdvance30Min:
-		title = "⏰ 提前30分钟提醒"
+	case models.ReminderTypeAdvance:
+		title = fmt.Sprintf("⏰ 提前%s提醒", models.FormatOffsetDuration(offset.Duration))
 		timeInfo = "即将开始，请做好准备"
 
 	case models.ReminderTypeTrigger:
 		title = "🔔 事件提醒"
 		timeInfo = "现在是触发时间"
+
+	case models.ReminderTypeOverdue:
+		title = "🔔 事件跟进"
+		timeInfo = fmt.Sprintf("事件已开始%s", models.FormatOffsetDuration(offset.Duration))
 	}
 
 	message := fmt.Sprintf(
@@ -317,21 +404,112 @@ func (s *Scheduler) buildNotificationReminderMessage(task models.Task, reminderT
 	return message
 }
 
-
-// RegisterNewTask 注册新创建的任务到调度器
+// RegisterNewTask 注册新创建的任务到调度器（已注册的任务会先移除旧条目）
+// 调度器未运行（非领导者）时忽略，由领导者定期重新加载时接管
 func (s *Scheduler) RegisterNewTask(task models.Task) error {
-	return s.registerTask(task)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.running {
+		return nil
+	}
+	s.removeTaskLocked(task.ID)
+	return s.addTaskLocked(task)
+}
+
+// ReloadTask 按数据库中的最新状态重新注册任务（任务被修改、暂停、恢复或删除后调用）
+// 调度器未运行（非领导者）时忽略
+func (s *Scheduler) ReloadTask(taskID int) {
+	if !s.IsRunning() {
+		return
+	}
+
+	task, err := s.taskService.GetTaskByID(taskID)
+	if err != nil {
+		log.Printf("重新加载任务 %d 失败: %v", taskID, err)
+		return
+	}
+
+	if task.Status != models.TaskStatusActive {
+		s.UnregisterTask(task.ID)
+		log.Printf("移除任务提醒: [%s] (%s)", task.Name, task.Status)
+		return
+	}
+
+	// 修改后已没有后续提醒的有期限任务直接结束
+	if s.taskExpired(*task, time.Now().In(s.location)) {
+		s.finishTask(*task)
+		s.UnregisterTask(task.ID)
+		return
+	}
+
+	if err := s.RegisterNewTask(*task); err != nil {
+		log.Printf("重新注册任务 [%s] 失败: %v", task.Name, err)
+	}
+}
+
+// ReloadAll 整体重新加载所有任务（调度器未运行时忽略）
+func (s *Scheduler) ReloadAll() {
+	if !s.IsRunning() {
+		return
+	}
+	if err := s.reload(); err != nil {
+		log.Printf("重新加载任务失败: %v", err)
+	}
+}
+
+// IsRunning 调度器是否正在运行（多副本部署时即是否为领导者）
+func (s *Scheduler) IsRunning() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.running
+}
+
+// UnregisterTask 从调度器移除任务的所有提醒
+func (s *Scheduler) UnregisterTask(taskID int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeTaskLocked(taskID)
 }
 
-// SendImmediateReminderIfNeeded 如果当前时间超过10点，立即发送10点提醒
+// SendImmediateReminderIfNeeded 如果下一次截止前的某个提醒时间已经错过，立即补发最近的一次
 func (s *Scheduler) SendImmediateReminderIfNeeded(task models.Task) {
+	if !s.IsRunning() {
+		return
+	}
+
+	offsets, err := task.ReminderOffsets()
+	if err != nil {
+		log.Printf("解析提醒计划失败: %v", err)
+		return
+	}
+
 	now := time.Now().In(s.location)
-	hour := now.Hour()
+	deadline, ok := s.nextDeadline(task)
+	if !ok {
+		return
+	}
 
-	// 如果当前时间超过10点，立即发送10点提醒
-	if hour >= 10 {
-		log.Printf("当前时间已超过10点，立即发送提醒: [%s]", task.Name)
-		if err := s.executeReminder(task, models.ReminderTypeMorning10AM); err != nil {
+	var missed *models.ReminderOffset
+	var missedAt time.Time
+	for i, offset := range offsets {
+		// 只补发截止前的提醒
+		if !offset.HasClock && offset.Duration >= 0 {
+			continue
+		}
+		at := offset.At(deadline)
+		if !at.Before(deadline) {
+			continue
+		}
+		if !now.Before(at) && (missed == nil || at.After(missedAt)) {
+			missed, missedAt = &offsets[i], at
+		}
+	}
+
+	if missed != nil {
+		log.Printf("已错过 %s 提醒，立即发送: [%s]", missed.Spec, task.Name)
+		if err := s.executeReminder(task, *missed, deadline); err != nil {
 			log.Printf("立即发送提醒失败: %v", err)
 		}
 	}
@@ -347,17 +525,144 @@ func (s *Scheduler) periodicReload(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			log.Println("重新加载任务...")
-			// TODO: 实现增量更新逻辑
+			if err := s.reload(); err != nil {
+				log.Printf("重新加载任务失败: %v", err)
+			}
+			if err := s.reloadDigests(); err != nil {
+				log.Printf("重新加载定时汇总失败: %v", err)
+			}
 		}
 	}
 }
 
-// 停止调度器
+// reload 对比数据库中的活跃任务与已注册条目，增量地新增、移除或重新注册
+func (s *Scheduler) reload() error {
+	tasks, err := s.taskService.GetPendingTasks()
+	if err != nil {
+		return fmt.Errorf("加载任务失败: %w", err)
+	}
+
+	// 已没有后续提醒的有期限任务标记为结束，不再注册
+	now := time.Now().In(s.location)
+	active := make(map[int]models.Task, len(tasks))
+	running := tasks[:0]
+	for _, task := range tasks {
+		if s.taskExpired(task, now) {
+			s.finishTask(task)
+			continue
+		}
+		active[task.ID] = task
+		running = append(running, task)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// 加载期间调度器已停止（失去领导权）
+	if !s.running {
+		return nil
+	}
+
+	var added, removed, updated int
+
+	// 1. 移除已删除或暂停的任务
+	for taskID, registered := range s.entries {
+		if _, ok := active[taskID]; !ok {
+			s.removeTaskLocked(taskID)
+			removed++
+			log.Printf("移除任务提醒: [%s]", registered.task.Name)
+		}
+	}
+
+	// 2. 新增任务 / 重新注册已修改的任务
+	for _, task := range running {
+		registered, ok := s.entries[task.ID]
+		switch {
+		case !ok:
+			if err := s.addTaskLocked(task); err != nil {
+				log.Printf("注册任务 [%s] 失败: %v", task.Name, err)
+				continue
+			}
+			added++
+		case taskScheduleChanged(registered.task, task) || registered.location != s.taskService.Location(task).String():
+			s.removeTaskLocked(task.ID)
+			if err := s.addTaskLocked(task); err != nil {
+				log.Printf("重新注册任务 [%s] 失败: %v", task.Name, err)
+				continue
+			}
+			updated++
+		}
+	}
+
+	if added+removed+updated > 0 {
+		log.Printf("✓ 任务已重新加载: 新增 %d, 移除 %d, 更新 %d", added, removed, updated)
+	}
+	return nil
+}
+
+// taskScheduleChanged 判断任务中影响提醒的字段是否发生变化
+func taskScheduleChanged(old, cur models.Task) bool {
+	return old.Name != cur.Name ||
+		old.Description != cur.Description ||
+		old.Type != cur.Type ||
+		old.CronExpr != cur.CronExpr ||
+		!nullTimeEqual(old.DeadlineTime, cur.DeadlineTime) ||
+		old.AdvanceMinutes != cur.AdvanceMinutes ||
+		old.GroupChatID != cur.GroupChatID ||
+		old.CalendarPolicy != cur.CalendarPolicy ||
+		old.ScheduleKind != cur.ScheduleKind ||
+		!nullTimeEqual(old.DueAt, cur.DueAt) ||
+		!nullTimeEqual(old.StartDate, cur.StartDate) ||
+		!nullTimeEqual(old.EndDate, cur.EndDate) ||
+		old.MaxOccurrences != cur.MaxOccurrences ||
+		old.Timezone != cur.Timezone ||
+		!slices.Equal(old.ReminderPlan, cur.ReminderPlan)
+}
+
+// nullTimeEqual 比较两个可空时间
+func nullTimeEqual(a, b sql.NullTime) bool {
+	return a.Valid == b.Valid && a.Time.Equal(b.Time)
+}
+
+// taskExpired 有期限的任务（单次任务、设置了结束日期或执行次数的周期任务）在 now 之后已没有提醒
+func (s *Scheduler) taskExpired(task models.Task, now time.Time) bool {
+	if !task.IsBounded() {
+		return false
+	}
+	if _, err := task.ReminderOffsets(); err != nil {
+		return false
+	}
+	return s.nextReminderAt(task, now).IsZero()
+}
+
+// finishTask 将任务标记为已结束
+func (s *Scheduler) finishTask(task models.Task) {
+	if err := s.taskService.UpdateTaskStatus(task.ID, models.TaskStatusFinished); err != nil {
+		log.Printf("标记任务 [%s] 结束失败: %v", task.Name, err)
+		return
+	}
+	log.Printf("✓ 任务已结束: [%s]", task.Name)
+}
+
+// 停止调度器：等待正在执行的提醒结束并清空所有条目，之后可以再次 Start
 func (s *Scheduler) Stop() {
-	if s.cron != nil {
-		ctx := s.cron.Stop()
-		<-ctx.Done()
-		log.Println("✓ 调度器已停止")
+	s.mu.Lock()
+	if !s.running {
+		s.mu.Unlock()
+		return
+	}
+	s.running = false
+	s.cancel()
+	for taskID := range s.entries {
+		s.removeTaskLocked(taskID)
 	}
+	for digestID := range s.digests {
+		s.removeDigestLocked(digestID)
+	}
+	s.stopDeferredLocked()
+	s.mu.Unlock()
+
+	ctx := s.cron.Stop()
+	<-ctx.Done()
+	log.Println("✓ 调度器已停止")
 }
@@ -0,0 +1,483 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"sort"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// GetTaskAssignees 获取任务的负责人（用户和部门）
+func (s *TaskService) GetTaskAssignees(taskID int) ([]models.TaskAssignee, error) {
+	query := `
+		SELECT task_id, assignee_type, assignee_id, created_by, created_at
+		FROM task_assignees
+		WHERE task_id = $1
+		ORDER BY assignee_type DESC, created_at, assignee_id
+	`
+	rows, err := s.db.Query(query, taskID)
+	if err != nil {
+		return nil, fmt.Errorf("获取任务负责人失败: %w", err)
+	}
+	defer rows.Close()
+
+	var assignees []models.TaskAssignee
+	for rows.Next() {
+		var a models.TaskAssignee
+		if err := rows.Scan(&a.TaskID, &a.Type, &a.AssigneeID, &a.CreatedBy, &a.CreatedAt); err != nil {
+			return nil, err
+		}
+		assignees = append(assignees, a)
+	}
+	return assignees, rows.Err()
+}
+
+// SetTaskAssignees 替换任务的负责人，用户和部门都为空时恢复为群内所有成员
+// 保留的负责人不改动原有记录：历史统计从 created_at 开始计算负责人的各期
+func (s *TaskService) SetTaskAssignees(taskID int, userIDs, departmentIDs []string, createdBy string) error {
+	userIDs, departmentIDs = nonEmpty(userIDs), nonEmpty(departmentIDs)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteQuery := `
+		DELETE FROM task_assignees
+		WHERE task_id = $1
+		  AND NOT (assignee_type = 'USER' AND assignee_id = ANY($2))
+		  AND NOT (assignee_type = 'DEPARTMENT' AND assignee_id = ANY($3))
+	`
+	if _, err := tx.Exec(deleteQuery, taskID, pq.Array(userIDs), pq.Array(departmentIDs)); err != nil {
+		return fmt.Errorf("清除任务负责人失败: %w", err)
+	}
+
+	insertQuery := `
+		INSERT INTO task_assignees (task_id, assignee_type, assignee_id, created_by)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING
+	`
+	add := func(kind models.AssigneeType, ids []string) error {
+		for _, id := range ids {
+			if _, err := tx.Exec(insertQuery, taskID, kind, id, createdBy); err != nil {
+				return fmt.Errorf("保存任务负责人失败: %w", err)
+			}
+		}
+		return nil
+	}
+	if err := add(models.AssigneeUser, userIDs); err != nil {
+		return err
+	}
+	if err := add(models.AssigneeDepartment, departmentIDs); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// nonEmpty 去掉空字符串，结果不为 nil（作为 SQL 数组参数时为空数组而不是 NULL）
+func nonEmpty(ids []string) []string {
+	result := []string{}
+	for _, id := range ids {
+		if id != "" {
+			result = append(result, id)
+		}
+	}
+	return result
+}
+
+// AddTaskExemption 登记成员在一段日期内免于执行任务
+func (s *TaskService) AddTaskExemption(exemption models.TaskExemption) (*models.TaskExemption, error) {
+	if exemption.UserID == "" {
+		return nil, fmt.Errorf("缺少豁免的成员")
+	}
+	if exemption.EndDate.IsZero() {
+		exemption.EndDate = exemption.StartDate
+	}
+	if exemption.EndDate.Before(exemption.StartDate) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+
+	query := `
+		INSERT INTO task_exemptions (task_id, user_id, start_date, end_date, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRow(query,
+		exemption.TaskID,
+		exemption.UserID,
+		exemption.StartDate.Format("2006-01-02"),
+		exemption.EndDate.Format("2006-01-02"),
+		exemption.Reason,
+		exemption.CreatedBy,
+	).Scan(&exemption.ID, &exemption.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("登记豁免失败: %w", err)
+	}
+	return &exemption, nil
+}
+
+// DeleteTaskExemption 删除一条豁免
+func (s *TaskService) DeleteTaskExemption(taskID, exemptionID int) error {
+	result, err := s.db.Exec(`DELETE FROM task_exemptions WHERE id = $1 AND task_id = $2`, exemptionID, taskID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("豁免不存在")
+	}
+	return nil
+}
+
+// CancelUserExemptions 取消成员在 from 当天及之后的豁免，返回涉及的条数：
+// 从 from 及之后开始的豁免直接删除，已经开始的豁免截止到 from 前一天（已豁免的日期仍不计为缺卡）
+func (s *TaskService) CancelUserExemptions(taskID int, userID string, from time.Time) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	args := []interface{}{taskID, userID, from.Format("2006-01-02")}
+	deleted, err := tx.Exec(
+		`DELETE FROM task_exemptions WHERE task_id = $1 AND user_id = $2 AND start_date >= $3`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	truncated, err := tx.Exec(
+		`UPDATE task_exemptions SET end_date = $3::date - 1
+		 WHERE task_id = $1 AND user_id = $2 AND start_date < $3 AND end_date >= $3`,
+		args...,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	n1, _ := deleted.RowsAffected()
+	n2, _ := truncated.RowsAffected()
+	return int(n1 + n2), nil
+}
+
+// GetTaskExemptions 获取任务在 since 当天及之后仍生效的豁免
+func (s *TaskService) GetTaskExemptions(taskID int, since time.Time) ([]models.TaskExemption, error) {
+	query := `
+		SELECT id, task_id, user_id, start_date, end_date, reason, created_by, created_at
+		FROM task_exemptions
+		WHERE task_id = $1 AND end_date >= $2
+		ORDER BY start_date, user_id
+	`
+	rows, err := s.db.Query(query, taskID, since.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("获取豁免失败: %w", err)
+	}
+	defer rows.Close()
+
+	var exemptions []models.TaskExemption
+	for rows.Next() {
+		var e models.TaskExemption
+		if err := rows.Scan(&e.ID, &e.TaskID, &e.UserID, &e.StartDate, &e.EndDate, &e.Reason, &e.CreatedBy, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		exemptions = append(exemptions, e)
+	}
+	return exemptions, rows.Err()
+}
+
+// exemptUserIDs 在任务日期被豁免或请假的成员
+func (s *TaskService) exemptUserIDs(taskID int, taskDate time.Time) (map[string]bool, error) {
+	query := `
+		SELECT user_id FROM task_exemptions
+		WHERE task_id = $1 AND start_date <= $2 AND end_date >= $2
+		UNION
+		SELECT user_id FROM member_leaves
+		WHERE (task_id IS NULL OR task_id = $1) AND start_date <= $2 AND end_date >= $2
+	`
+	rows, err := s.db.Query(query, taskID, taskDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("获取豁免失败: %w", err)
+	}
+	defer rows.Close()
+
+	exempt := make(map[string]bool)
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		exempt[userID] = true
+	}
+	return exempt, rows.Err()
+}
+
+// GetResponsibleMembers 任务在某个任务日期需要执行的成员：负责人（未指定时为群内成员，均不含免提醒的成员）减去被豁免或请假的成员
+func (s *TaskService) GetResponsibleMembers(task models.Task, taskDate time.Time) ([]models.GroupMember, error) {
+	members, err := s.assignedMembers(task)
+	if err != nil {
+		return nil, err
+	}
+
+	exempt, err := s.exemptUserIDs(task.ID, taskDate)
+	if err != nil {
+		return nil, err
+	}
+
+	result := members[:0]
+	for _, member := range members {
+		if !exempt[member.UserID] {
+			result = append(result, member)
+		}
+	}
+	return result, nil
+}
+
+// GetResponsibleUserIDs 任务在某个任务日期需要执行的成员ID（用于提醒 @）
+func (s *TaskService) GetResponsibleUserIDs(task models.Task, taskDate time.Time) ([]string, error) {
+	members, err := s.GetResponsibleMembers(task, taskDate)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make([]string, len(members))
+	for i, member := range members {
+		users[i] = member.UserID
+	}
+	return users, nil
+}
+
+// assignedMembers 任务的负责人展开为成员：指定的用户和部门成员，未指定负责人时为群内成员
+func (s *TaskService) assignedMembers(task models.Task) ([]models.GroupMember, error) {
+	assignees, err := s.GetTaskAssignees(task.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(assignees) == 0 {
+		return s.GetGroupMembers(task.GroupChatID)
+	}
+
+	seen := make(map[string]bool)
+	var userIDs, deptUserIDs []string
+	for _, assignee := range assignees {
+		switch assignee.Type {
+		case models.AssigneeUser:
+			// 直接指定的用户即使设置了免提醒也需要执行
+			if !seen[assignee.AssigneeID] {
+				seen[assignee.AssigneeID] = true
+				userIDs = append(userIDs, assignee.AssigneeID)
+			}
+		case models.AssigneeDepartment:
+			members, err := s.members.DepartmentMembers(assignee.AssigneeID)
+			if err != nil {
+				return nil, err
+			}
+			deptUserIDs = append(deptUserIDs, members...)
+		}
+	}
+
+	excluded, err := s.exclusions.ExcludedUserIDs(task.GroupChatID, deptUserIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, userID := range deptUserIDs {
+		if !seen[userID] && !excluded[userID] {
+			seen[userID] = true
+			userIDs = append(userIDs, userID)
+		}
+	}
+
+	return s.membersWithNames(task.GroupChatID, userIDs)
+}
+
+// IsAssigned 成员是否可以为任务打卡、请假或稍后提醒：指定了负责人时为负责人（含部门成员），
+// 未指定时为群成员。免提醒只影响提醒，不影响打卡；群成员未知（从未同步成功）时不做限制
+func (s *TaskService) IsAssigned(task models.Task, userID string) (bool, error) {
+	assignees, err := s.GetTaskAssignees(task.ID)
+	if err != nil {
+		return false, err
+	}
+
+	if len(assignees) == 0 {
+		members, err := s.members.Members(task.GroupChatID)
+		if err != nil {
+			return false, err
+		}
+		if len(members) == 0 {
+			return true, nil
+		}
+		for _, member := range members {
+			if member.UserID == userID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	// 先比对直接指定的用户，避免不必要的部门成员查询
+	var deptIDs []string
+	for _, assignee := range assignees {
+		switch assignee.Type {
+		case models.AssigneeUser:
+			if assignee.AssigneeID == userID {
+				return true, nil
+			}
+		case models.AssigneeDepartment:
+			deptIDs = append(deptIDs, assignee.AssigneeID)
+		}
+	}
+	for _, deptID := range deptIDs {
+		members, err := s.members.DepartmentMembers(deptID)
+		if err != nil {
+			return false, err
+		}
+		for _, member := range members {
+			if member == userID {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// memberPeriod 成员需要执行任务的期间，since、until 无效时表示不限
+type memberPeriod struct {
+	member models.GroupMember
+	since  sql.NullTime
+	until  sql.NullTime
+}
+
+// assignedMemberPeriods 任务的负责人及其需要执行任务的期间（用于历史统计）：
+// 未指定负责人时为群成员在 since 之后在群内的各个期间，指定的用户和部门成员从被指定时开始。
+// 部门成员和免提醒设置只能按当前状态计算，已移除的负责人不再计入以前的各期
+func (s *TaskService) assignedMemberPeriods(task models.Task, since time.Time) ([]memberPeriod, error) {
+	assignees, err := s.GetTaskAssignees(task.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(assignees) == 0 {
+		periods, err := s.members.MemberPeriods(task.GroupChatID, since)
+		if err != nil {
+			return nil, err
+		}
+		userIDs := make([]string, len(periods))
+		for i, p := range periods {
+			userIDs[i] = p.UserID
+		}
+		excluded, err := s.exclusions.ExcludedUserIDs(task.GroupChatID, userIDs)
+		if err != nil {
+			return nil, err
+		}
+
+		var result []memberPeriod
+		for _, p := range periods {
+			if !excluded[p.UserID] {
+				result = append(result, memberPeriod{member: p.GroupMember, since: p.JoinedAt, until: p.LeftAt})
+			}
+		}
+		return result, nil
+	}
+
+	// 同一成员被直接指定又属于指定的部门时，取最早被指定的时间
+	assignedAt := make(map[string]time.Time)
+	var userIDs []string
+	assign := func(userID string, at time.Time) {
+		prev, ok := assignedAt[userID]
+		if !ok {
+			userIDs = append(userIDs, userID)
+		}
+		if !ok || at.Before(prev) {
+			assignedAt[userID] = at
+		}
+	}
+
+	type deptAssignee struct {
+		at      time.Time
+		userIDs []string
+	}
+	var depts []deptAssignee
+	var deptUserIDs []string
+	for _, assignee := range assignees {
+		switch assignee.Type {
+		case models.AssigneeUser:
+			assign(assignee.AssigneeID, assignee.CreatedAt)
+		case models.AssigneeDepartment:
+			members, err := s.members.DepartmentMembers(assignee.AssigneeID)
+			if err != nil {
+				return nil, err
+			}
+			depts = append(depts, deptAssignee{at: assignee.CreatedAt, userIDs: members})
+			deptUserIDs = append(deptUserIDs, members...)
+		}
+	}
+
+	excluded, err := s.exclusions.ExcludedUserIDs(task.GroupChatID, deptUserIDs)
+	if err != nil {
+		return nil, err
+	}
+	for _, dept := range depts {
+		for _, userID := range dept.userIDs {
+			if !excluded[userID] {
+				assign(userID, dept.at)
+			}
+		}
+	}
+
+	members, err := s.membersWithNames(task.GroupChatID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]memberPeriod, len(members))
+	for i, member := range members {
+		result[i] = memberPeriod{member: member, since: sql.NullTime{Time: assignedAt[member.UserID], Valid: true}}
+	}
+	return result, nil
+}
+
+// membersWithNames 为用户补齐显示名称：优先使用群成员表，其次 users 表，按名称排序
+func (s *TaskService) membersWithNames(groupChatID string, userIDs []string) ([]models.GroupMember, error) {
+	groupMembers, err := s.members.Members(groupChatID)
+	if err != nil {
+		return nil, err
+	}
+	known := make(map[string]models.GroupMember, len(groupMembers))
+	for _, member := range groupMembers {
+		known[member.UserID] = member
+	}
+
+	var missing []string
+	for _, userID := range userIDs {
+		if member, ok := known[userID]; !ok || !member.UserName.Valid {
+			missing = append(missing, userID)
+		}
+	}
+	names, err := s.GetUserNames(missing)
+	if err != nil {
+		return nil, err
+	}
+
+	members := make([]models.GroupMember, 0, len(userIDs))
+	for _, userID := range userIDs {
+		member, ok := known[userID]
+		if !ok {
+			member = models.GroupMember{GroupChatID: groupChatID, UserID: userID}
+		}
+		if name, ok := names[userID]; ok && !member.UserName.Valid {
+			member.UserName = sql.NullString{String: name, Valid: true}
+		}
+		members = append(members, member)
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return members[i].DisplayName() < members[j].DisplayName()
+	})
+	return members, nil
+}
@@ -0,0 +1,450 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 日历缓存的有效期，过期后在下次查询时从数据库重新加载（多实例部署时也能看到其他实例的导入）
+const calendarCacheTTL = 10 * time.Minute
+
+// 连续跳过的触发次数上限（春节等长假加上周末也远小于该值）
+const maxCalendarSkip = 400
+
+// CalendarService 节假日与调休日历（带内存缓存）
+type CalendarService struct {
+	db *sql.DB
+
+	mu       sync.RWMutex
+	days     map[string]models.HolidayKind // 日期(2006-01-02) -> 类型
+	makeup   []string                      // 调休上班日，升序
+	loadedAt time.Time
+}
+
+func NewCalendarService(db *sql.DB) *CalendarService {
+	return &CalendarService{db: db, days: map[string]models.HolidayKind{}}
+}
+
+// Reload 从数据库重新加载日历缓存
+func (s *CalendarService) Reload() error {
+	rows, err := s.db.Query(`SELECT date, kind FROM holidays ORDER BY date`)
+	if err != nil {
+		return fmt.Errorf("加载节假日日历失败: %w", err)
+	}
+	defer rows.Close()
+
+	days := make(map[string]models.HolidayKind)
+	var makeup []string
+	for rows.Next() {
+		var date time.Time
+		var kind models.HolidayKind
+		if err := rows.Scan(&date, &kind); err != nil {
+			return err
+		}
+		key := date.Format("2006-01-02")
+		days[key] = kind
+		if kind == models.HolidayKindWorkday {
+			makeup = append(makeup, key)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.days = days
+	s.makeup = makeup
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// 缓存过期时重新加载；加载失败时继续使用旧缓存，避免每次查询都访问数据库
+func (s *CalendarService) ensureFresh() {
+	s.mu.RLock()
+	fresh := time.Since(s.loadedAt) < calendarCacheTTL
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	if err := s.Reload(); err != nil {
+		log.Printf("⚠️  %v", err)
+		s.mu.Lock()
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// 查询某天在日历中的类型（只看日期部分，按 day 自身的时区）
+func (s *CalendarService) kindOf(day time.Time) (models.HolidayKind, bool) {
+	s.ensureFresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	kind, ok := s.days[day.Format("2006-01-02")]
+	return kind, ok
+}
+
+// IsHoliday 是否为法定放假日
+func (s *CalendarService) IsHoliday(day time.Time) bool {
+	kind, ok := s.kindOf(day)
+	return ok && kind == models.HolidayKindHoliday
+}
+
+// IsWorkday 是否为工作日：调休上班日为工作日，放假日不是，其余按周一至周五
+func (s *CalendarService) IsWorkday(day time.Time) bool {
+	if kind, ok := s.kindOf(day); ok {
+		return kind == models.HolidayKindWorkday
+	}
+	return day.Weekday() != time.Saturday && day.Weekday() != time.Sunday
+}
+
+// Allows 按策略判断 cron 在某天的触发是否生效
+func (s *CalendarService) Allows(policy models.CalendarPolicy, day time.Time) bool {
+	switch policy {
+	case models.CalendarPolicySkipHolidays, models.CalendarPolicyIncludeMakeup:
+		return !s.IsHoliday(day)
+	case models.CalendarPolicyWorkdaysOnly:
+		return s.IsWorkday(day)
+	default:
+		return true
+	}
+}
+
+// 返回 day 当天及之后的调休上班日
+func (s *CalendarService) makeupDaysFrom(day time.Time) []string {
+	s.ensureFresh()
+
+	key := day.Format("2006-01-02")
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	i := sort.SearchStrings(s.makeup, key)
+	return s.makeup[i:]
+}
+
+// Wrap 按日历策略过滤 cron 的触发时间，loc 为判断日期使用的时区
+func (s *CalendarService) Wrap(policy models.CalendarPolicy, spec cron.Schedule, loc *time.Location) cron.Schedule {
+	if s == nil || policy == "" || policy == models.CalendarPolicyNone {
+		return spec
+	}
+
+	schedule := &calendarSchedule{calendar: s, policy: policy, spec: spec, location: loc}
+	if policy == models.CalendarPolicyIncludeMakeup {
+		// 调休上班日按 cron 的时分执行，忽略日期和星期限制
+		if spec, ok := spec.(*cron.SpecSchedule); ok {
+			anyDay := *spec
+			anyDay.Dom = ^uint64(0)
+			anyDay.Dow = ^uint64(0)
+			schedule.makeup = &anyDay
+		}
+	}
+	return schedule
+}
+
+// calendarSchedule 按节假日日历过滤后的触发时间序列（实现 cron.Schedule）
+type calendarSchedule struct {
+	calendar *CalendarService
+	policy   models.CalendarPolicy
+	spec     cron.Schedule
+	makeup   cron.Schedule // 调休上班日的触发时间（仅 INCLUDE_MAKEUP）
+	location *time.Location
+}
+
+func (c *calendarSchedule) Next(t time.Time) time.Time {
+	next := c.spec.Next(t)
+	for i := 0; !next.IsZero(); i++ {
+		if i >= maxCalendarSkip {
+			next = time.Time{}
+			break
+		}
+		if c.calendar.Allows(c.policy, next.In(c.location)) {
+			break
+		}
+		// 跳过当天剩余的触发
+		next = c.spec.Next(startOfDay(next.In(c.location)).AddDate(0, 0, 1).Add(-time.Second))
+	}
+
+	if c.makeup != nil {
+		if m := c.nextMakeup(t); !m.IsZero() && (next.IsZero() || m.Before(next)) {
+			return m
+		}
+	}
+	return next
+}
+
+// 返回 t 之后第一个调休上班日的触发时间
+func (c *calendarSchedule) nextMakeup(t time.Time) time.Time {
+	for _, key := range c.calendar.makeupDaysFrom(t.In(c.location)) {
+		day, err := time.ParseInLocation("2006-01-02", key, c.location)
+		if err != nil {
+			continue
+		}
+		from := day.Add(-time.Second)
+		if t.After(from) {
+			from = t
+		}
+		if m := c.makeup.Next(from); !m.IsZero() && startOfDay(m.In(c.location)).Equal(day) {
+			return m
+		}
+	}
+	return time.Time{}
+}
+
+// ListHolidays 查询日期范围内的日历
+func (s *CalendarService) ListHolidays(from, to time.Time) ([]models.Holiday, error) {
+	query := `
+		SELECT id, date, kind, COALESCE(name, ''), created_at
+		FROM holidays
+		WHERE date >= $1 AND date <= $2
+		ORDER BY date
+	`
+
+	rows, err := s.db.Query(query, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holidays []models.Holiday
+	for rows.Next() {
+		var h models.Holiday
+		if err := rows.Scan(&h.ID, &h.Date, &h.Kind, &h.Name, &h.CreatedAt); err != nil {
+			return nil, err
+		}
+		holidays = append(holidays, h)
+	}
+
+	return holidays, rows.Err()
+}
+
+// SaveHolidays 批量保存日历（同一天重复导入时覆盖），返回保存的天数
+func (s *CalendarService) SaveHolidays(holidays []models.Holiday) (int, error) {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO holidays (date, kind, name) VALUES ($1, $2, $3)
+		ON CONFLICT (date) DO UPDATE SET kind = EXCLUDED.kind, name = EXCLUDED.name
+	`
+	for _, h := range holidays {
+		if _, err := tx.Exec(query, h.Date.Format("2006-01-02"), h.Kind, h.Name); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	if err := s.Reload(); err != nil {
+		log.Printf("⚠️  %v", err)
+	}
+
+	return len(holidays), nil
+}
+
+// Import 导入日历文件，format 为 json 或 ics
+func (s *CalendarService) Import(format string, data []byte) (int, error) {
+	var holidays []models.Holiday
+	var err error
+
+	switch strings.ToLower(format) {
+	case "json":
+		holidays, err = ParseHolidayJSON(data)
+	case "ics", "ical":
+		holidays, err = ParseHolidayICS(data)
+	default:
+		return 0, fmt.Errorf("不支持的日历格式: %s", format)
+	}
+	if err != nil {
+		return 0, err
+	}
+	if len(holidays) == 0 {
+		return 0, fmt.Errorf("日历文件中没有日期")
+	}
+
+	return s.SaveHolidays(holidays)
+}
+
+// ParseHolidayJSON 解析 JSON 日历，支持两种格式：
+//
+//	[{"date": "2026-10-01", "kind": "HOLIDAY", "name": "国庆节"}, ...]
+//	{"days": [{"date": "2026-10-01", "name": "国庆节", "isOffDay": true}, ...]}（holiday-cn 格式）
+func ParseHolidayJSON(data []byte) ([]models.Holiday, error) {
+	type item struct {
+		Date     string `json:"date"`
+		Kind     string `json:"kind"`
+		Type     string `json:"type"`
+		Name     string `json:"name"`
+		IsOffDay *bool  `json:"isOffDay"`
+	}
+
+	var items []item
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '{' {
+		var wrapper struct {
+			Days []item `json:"days"`
+		}
+		if err := json.Unmarshal(data, &wrapper); err != nil {
+			return nil, fmt.Errorf("解析 JSON 日历失败: %w", err)
+		}
+		items = wrapper.Days
+	} else if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("解析 JSON 日历失败: %w", err)
+	}
+
+	holidays := make([]models.Holiday, 0, len(items))
+	for _, it := range items {
+		date, err := time.Parse("2006-01-02", it.Date)
+		if err != nil {
+			return nil, fmt.Errorf("日期格式错误: %s", it.Date)
+		}
+
+		var kind models.HolidayKind
+		switch {
+		case it.IsOffDay != nil && *it.IsOffDay:
+			kind = models.HolidayKindHoliday
+		case it.IsOffDay != nil:
+			kind = models.HolidayKindWorkday
+		default:
+			kindText := it.Kind
+			if kindText == "" {
+				kindText = it.Type
+			}
+			kind, err = parseHolidayKind(kindText)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", it.Date, err)
+			}
+		}
+
+		holidays = append(holidays, models.Holiday{Date: date, Kind: kind, Name: it.Name})
+	}
+
+	return holidays, nil
+}
+
+func parseHolidayKind(s string) (models.HolidayKind, error) {
+	switch strings.ToUpper(strings.TrimSpace(s)) {
+	case "HOLIDAY", "休", "放假":
+		return models.HolidayKindHoliday, nil
+	case "WORKDAY", "班", "上班", "调休":
+		return models.HolidayKindWorkday, nil
+	}
+	return "", fmt.Errorf("未知的日期类型: %s（可选: HOLIDAY, WORKDAY）", s)
+}
+
+// ParseHolidayICS 解析 ICS 日历：每个全天 VEVENT 覆盖 DTSTART 到 DTEND（不含）之间的日期。
+// 类型优先取 CATEGORIES 中的 HOLIDAY / WORKDAY（或 休 / 班），没有时按常见的中国节假日日历约定，
+// SUMMARY 中含「班」的视为调休上班日，其余视为放假
+func ParseHolidayICS(data []byte) ([]models.Holiday, error) {
+	// 展开折行（以空格或制表符开头的行是上一行的延续）
+	var lines []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if (strings.HasPrefix(line, " ") || strings.HasPrefix(line, "\t")) && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("读取 ICS 日历失败: %w", err)
+	}
+
+	var holidays []models.Holiday
+	var inEvent bool
+	var start, end time.Time
+	var summary string
+	var kind models.HolidayKind
+
+	for _, line := range lines {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		// 去掉属性参数，如 DTSTART;VALUE=DATE
+		name, _, _ = strings.Cut(strings.ToUpper(name), ";")
+
+		switch name {
+		case "BEGIN":
+			if value == "VEVENT" {
+				inEvent = true
+				start, end, summary, kind = time.Time{}, time.Time{}, "", ""
+			}
+		case "DTSTART", "DTEND":
+			if !inEvent {
+				continue
+			}
+			date, err := parseICSDate(value)
+			if err != nil {
+				return nil, err
+			}
+			if name == "DTSTART" {
+				start = date
+			} else {
+				end = date
+			}
+		case "SUMMARY":
+			summary = strings.TrimSpace(value)
+		case "CATEGORIES":
+			for _, category := range strings.Split(value, ",") {
+				if k, err := parseHolidayKind(category); err == nil {
+					kind = k
+				}
+			}
+		case "END":
+			if value != "VEVENT" || !inEvent {
+				continue
+			}
+			inEvent = false
+			if start.IsZero() {
+				continue
+			}
+			if !end.After(start) {
+				end = start.AddDate(0, 0, 1)
+			}
+
+			if kind == "" {
+				kind = models.HolidayKindHoliday
+				if strings.Contains(summary, "班") {
+					kind = models.HolidayKindWorkday
+				}
+			}
+			for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+				holidays = append(holidays, models.Holiday{Date: day, Kind: kind, Name: summary})
+			}
+		}
+	}
+
+	return holidays, nil
+}
+
+// 解析 ICS 日期（20261001 或 20261001T000000Z），只取日期部分
+func parseICSDate(value string) (time.Time, error) {
+	if len(value) < 8 {
+		return time.Time{}, fmt.Errorf("ICS 日期格式错误: %s", value)
+	}
+	date, err := time.Parse("20060102", value[:8])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("ICS 日期格式错误: %s", value)
+	}
+	return date, nil
+}
@@ -0,0 +1,297 @@
+package services
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+func mustLocation(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("Asia/Shanghai")
+	if err != nil {
+		t.Fatalf("加载时区失败: %v", err)
+	}
+	return loc
+}
+
+// newTestCalendar 只使用内存中的日历（缓存视为刚加载，不访问数据库）
+func newTestCalendar(days map[string]models.HolidayKind) *CalendarService {
+	var makeup []string
+	for key, kind := range days {
+		if kind == models.HolidayKindWorkday {
+			makeup = append(makeup, key)
+		}
+	}
+	sort.Strings(makeup)
+	return &CalendarService{days: days, makeup: makeup, loadedAt: time.Now()}
+}
+
+type holidayDay struct {
+	date string
+	kind models.HolidayKind
+	name string
+}
+
+func checkHolidays(t *testing.T, got []models.Holiday, want []holidayDay) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("解析出 %d 天 %+v，期望 %d 天", len(got), got, len(want))
+	}
+	for i, h := range got {
+		if h.Date.Format("2006-01-02") != want[i].date || h.Kind != want[i].kind || h.Name != want[i].name {
+			t.Errorf("第 %d 天 = (%s, %s, %q)，期望 (%s, %s, %q)",
+				i+1, h.Date.Format("2006-01-02"), h.Kind, h.Name, want[i].date, want[i].kind, want[i].name)
+		}
+	}
+}
+
+func TestParseHolidayICS(t *testing.T) {
+	tests := []struct {
+		name string
+		ics  string
+		want []holidayDay
+	}{
+		{
+			name: "DTEND 不含当天的多日放假",
+			ics:  "BEGIN:VCALENDAR\r\nBEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261001\r\nDTEND;VALUE=DATE:20261004\r\nSUMMARY:国庆节\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n",
+			want: []holidayDay{
+				{"2026-10-01", models.HolidayKindHoliday, "国庆节"},
+				{"2026-10-02", models.HolidayKindHoliday, "国庆节"},
+				{"2026-10-03", models.HolidayKindHoliday, "国庆节"},
+			},
+		},
+		{
+			name: "没有 DTEND 时为一天",
+			ics:  "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261001\nSUMMARY:国庆节\nEND:VEVENT\n",
+			want: []holidayDay{{"2026-10-01", models.HolidayKindHoliday, "国庆节"}},
+		},
+		{
+			name: "带时间的日期只取日期部分",
+			ics:  "BEGIN:VEVENT\nDTSTART:20261001T000000Z\nDTEND:20261002T000000Z\nSUMMARY:国庆节\nEND:VEVENT\n",
+			want: []holidayDay{{"2026-10-01", models.HolidayKindHoliday, "国庆节"}},
+		},
+		{
+			name: "折行的 SUMMARY 拼接后判断类型",
+			ics:  "BEGIN:VEVENT\r\nDTSTART;VALUE=DATE:20261010\r\nSUMMARY:国庆节调休\r\n 上班\r\nEND:VEVENT\r\n",
+			want: []holidayDay{{"2026-10-10", models.HolidayKindWorkday, "国庆节调休上班"}},
+		},
+		{
+			name: "折行的属性参数",
+			ics:  "BEGIN:VEVENT\nDTSTART;VALUE=\n\tDATE:20261001\nSUMMARY:休\nEND:VEVENT\n",
+			want: []holidayDay{{"2026-10-01", models.HolidayKindHoliday, "休"}},
+		},
+		{
+			name: "CATEGORIES 明确指定类型",
+			ics:  "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261010\nSUMMARY:国庆节补休日\nCATEGORIES:Holidays,WORKDAY\nEND:VEVENT\n",
+			want: []holidayDay{{"2026-10-10", models.HolidayKindWorkday, "国庆节补休日"}},
+		},
+		{
+			name: "CATEGORIES 优先于 SUMMARY",
+			ics:  "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261001\nSUMMARY:值班\nCATEGORIES:HOLIDAY\nEND:VEVENT\n",
+			want: []holidayDay{{"2026-10-01", models.HolidayKindHoliday, "值班"}},
+		},
+		{
+			name: "类型不沿用到下一个事件",
+			ics: "BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261010\nSUMMARY:调休\nCATEGORIES:WORKDAY\nEND:VEVENT\n" +
+				"BEGIN:VEVENT\nDTSTART;VALUE=DATE:20261001\nSUMMARY:国庆节\nEND:VEVENT\n",
+			want: []holidayDay{
+				{"2026-10-10", models.HolidayKindWorkday, "调休"},
+				{"2026-10-01", models.HolidayKindHoliday, "国庆节"},
+			},
+		},
+		{
+			name: "事件外的日期和没有开始日期的事件被忽略",
+			ics:  "DTSTART;VALUE=DATE:20260101\nBEGIN:VEVENT\nSUMMARY:无日期\nEND:VEVENT\n",
+			want: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHolidayICS([]byte(tt.ics))
+			if err != nil {
+				t.Fatalf("ParseHolidayICS 返回错误: %v", err)
+			}
+			checkHolidays(t, got, tt.want)
+		})
+	}
+}
+
+func TestParseHolidayICSInvalidDate(t *testing.T) {
+	ics := "BEGIN:VEVENT\nDTSTART;VALUE=DATE:2026-10\nEND:VEVENT\n"
+	if _, err := ParseHolidayICS([]byte(ics)); err == nil {
+		t.Error("日期格式错误时应返回错误")
+	}
+}
+
+func TestParseHolidayJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		json    string
+		want    []holidayDay
+		wantErr bool
+	}{
+		{
+			name: "数组格式",
+			json: `[{"date": "2026-10-01", "kind": "HOLIDAY", "name": "国庆节"}, {"date": "2026-10-10", "kind": "workday", "name": "国庆节调休"}]`,
+			want: []holidayDay{
+				{"2026-10-01", models.HolidayKindHoliday, "国庆节"},
+				{"2026-10-10", models.HolidayKindWorkday, "国庆节调休"},
+			},
+		},
+		{
+			name: "type 字段和中文类型",
+			json: `[{"date": "2026-10-01", "type": "休"}, {"date": "2026-10-10", "type": "班"}]`,
+			want: []holidayDay{
+				{"2026-10-01", models.HolidayKindHoliday, ""},
+				{"2026-10-10", models.HolidayKindWorkday, ""},
+			},
+		},
+		{
+			name: "holiday-cn 格式",
+			json: ` {"days": [{"name": "国庆节", "date": "2026-10-01", "isOffDay": true}, {"name": "国庆节", "date": "2026-10-10", "isOffDay": false}]}`,
+			want: []holidayDay{
+				{"2026-10-01", models.HolidayKindHoliday, "国庆节"},
+				{"2026-10-10", models.HolidayKindWorkday, "国庆节"},
+			},
+		},
+		{name: "日期格式错误", json: `[{"date": "2026/10/01", "kind": "HOLIDAY"}]`, wantErr: true},
+		{name: "未知类型", json: `[{"date": "2026-10-01", "kind": "HALF"}]`, wantErr: true},
+		{name: "缺少类型", json: `[{"date": "2026-10-01"}]`, wantErr: true},
+		{name: "不是 JSON", json: `date,kind`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseHolidayJSON([]byte(tt.json))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseHolidayJSON 期望返回错误，得到 %+v", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseHolidayJSON 返回错误: %v", err)
+			}
+			checkHolidays(t, got, tt.want)
+		})
+	}
+}
+
+func TestCalendarScheduleNext(t *testing.T) {
+	loc := mustLocation(t)
+	// 2026-10-01（周四）至 10-07 放假，10-10（周六）调休上班
+	calendar := newTestCalendar(map[string]models.HolidayKind{
+		"2026-10-01": models.HolidayKindHoliday,
+		"2026-10-02": models.HolidayKindHoliday,
+		"2026-10-03": models.HolidayKindHoliday,
+		"2026-10-04": models.HolidayKindHoliday,
+		"2026-10-05": models.HolidayKindHoliday,
+		"2026-10-06": models.HolidayKindHoliday,
+		"2026-10-07": models.HolidayKindHoliday,
+		"2026-10-10": models.HolidayKindWorkday,
+	})
+	at := func(month time.Month, day, hour, minute int) time.Time {
+		return time.Date(2026, month, day, hour, minute, 0, 0, loc)
+	}
+	// 固定从 2026-09-30（周三）10:00 开始
+	now := at(9, 30, 10, 0)
+
+	tests := []struct {
+		name   string
+		policy models.CalendarPolicy
+		cron   string
+		want   []time.Time
+	}{
+		{
+			name:   "不考虑节假日",
+			policy: models.CalendarPolicyNone,
+			cron:   "0 9 * * 1-5",
+			want:   []time.Time{at(10, 1, 9, 0), at(10, 2, 9, 0), at(10, 5, 9, 0)},
+		},
+		{
+			name:   "跳过节假日，调休上班日不额外触发",
+			policy: models.CalendarPolicySkipHolidays,
+			cron:   "0 9 * * 1-5",
+			want:   []time.Time{at(10, 8, 9, 0), at(10, 9, 9, 0), at(10, 12, 9, 0)},
+		},
+		{
+			name:   "跳过节假日当天的所有触发",
+			policy: models.CalendarPolicySkipHolidays,
+			cron:   "0 9,14 * * *",
+			want:   []time.Time{at(9, 30, 14, 0), at(10, 8, 9, 0), at(10, 8, 14, 0)},
+		},
+		{
+			name:   "只在工作日触发",
+			policy: models.CalendarPolicyWorkdaysOnly,
+			cron:   "0 9 * * *",
+			want:   []time.Time{at(10, 8, 9, 0), at(10, 9, 9, 0), at(10, 10, 9, 0), at(10, 12, 9, 0)},
+		},
+		{
+			name:   "调休上班日按 cron 的时刻补充触发",
+			policy: models.CalendarPolicyIncludeMakeup,
+			cron:   "30 8 * * 1-5",
+			want:   []time.Time{at(10, 8, 8, 30), at(10, 9, 8, 30), at(10, 10, 8, 30), at(10, 12, 8, 30)},
+		},
+		{
+			name:   "调休上班日只触发一次",
+			policy: models.CalendarPolicyIncludeMakeup,
+			cron:   "0 9 * * 5",
+			want:   []time.Time{at(10, 9, 9, 0), at(10, 10, 9, 0), at(10, 16, 9, 0)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := cron.ParseStandard(tt.cron)
+			if err != nil {
+				t.Fatalf("解析 cron 失败: %v", err)
+			}
+			schedule := calendar.Wrap(tt.policy, spec, loc)
+
+			cur := now
+			for i, want := range tt.want {
+				got := schedule.Next(cur)
+				if !got.Equal(want) {
+					t.Fatalf("第 %d 次触发 = %s，期望 %s", i+1, got, want)
+				}
+				cur = got
+			}
+		})
+	}
+}
+
+func TestCalendarServiceIsWorkday(t *testing.T) {
+	loc := mustLocation(t)
+	calendar := newTestCalendar(map[string]models.HolidayKind{
+		"2026-10-01": models.HolidayKindHoliday,
+		"2026-10-10": models.HolidayKindWorkday,
+	})
+
+	tests := []struct {
+		date string
+		want bool
+	}{
+		{"2026-09-30", true},  // 周三
+		{"2026-10-01", false}, // 放假
+		{"2026-10-10", true},  // 周六调休上班
+		{"2026-10-11", false}, // 周日
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.date, func(t *testing.T) {
+			day, err := time.ParseInLocation("2006-01-02", tt.date, loc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := calendar.IsWorkday(day); got != tt.want {
+				t.Errorf("IsWorkday(%s) = %v，期望 %v", tt.date, got, tt.want)
+			}
+		})
+	}
+}
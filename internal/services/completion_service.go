@@ -0,0 +1,340 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// ErrAlreadyCompleted 成员在这一期已经打过卡
+var ErrAlreadyCompleted = errors.New("本期已经打过卡了")
+
+// ErrNotAssigned 成员不是任务的负责人（未指定负责人时不在群内）
+var ErrNotAssigned = errors.New("不是该任务的负责人")
+
+// ErrTaskNotActive 任务已暂停、结束或删除，不能打卡
+var ErrTaskNotActive = errors.New("任务不在进行中")
+
+// CompletionService 打卡记录的写入、撤销与更正：群聊、Dify 和 API 的打卡都经过这里，
+// 按任务时区判断所属的一期和是否按时；成员可在宽限时间内撤销自己的打卡，
+// 管理员可把过去某一期改为已完成或未完成，所有变更写入审计日志
+type CompletionService struct {
+	db    *sql.DB
+	tasks *TaskService
+	cards *ReminderCardService
+
+	// 截止后多长时间内打卡仍算按时
+	onTimeGrace time.Duration
+	// 打卡后多长时间内成员可以自己撤销
+	undoWindow time.Duration
+}
+
+func NewCompletionService(db *sql.DB, tasks *TaskService, cards *ReminderCardService, onTimeGrace, undoWindow time.Duration) *CompletionService {
+	return &CompletionService{db: db, tasks: tasks, cards: cards, onTimeGrace: onTimeGrace, undoWindow: undoWindow}
+}
+
+// Complete 记录成员在任务某一期的打卡（taskDate 为零值时为当前这一期），并刷新这一期的提醒卡片
+// 任务不在进行中时返回 ErrTaskNotActive，本期已打卡时返回 ErrAlreadyCompleted，不是任务的负责人时返回 ErrNotAssigned
+func (s *CompletionService) Complete(task models.Task, taskDate time.Time, userID, userName string) (*models.CompletionRecord, error) {
+	if task.Status != models.TaskStatusActive {
+		return nil, ErrTaskNotActive
+	}
+
+	assigned, err := s.tasks.IsAssigned(task, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取任务负责人失败: %w", err)
+	}
+	if !assigned {
+		return nil, ErrNotAssigned
+	}
+
+	now := time.Now()
+	if taskDate.IsZero() {
+		if taskDate, err = s.tasks.CheckInDate(task, userID, now); err != nil {
+			return nil, fmt.Errorf("检查打卡状态失败: %w", err)
+		}
+	}
+
+	record := &models.CompletionRecord{
+		TaskID:      task.ID,
+		UserID:      userID,
+		UserName:    sql.NullString{String: userName, Valid: userName != ""},
+		GroupChatID: task.GroupChatID,
+		TaskDate:    taskDate,
+		IsOnTime:    s.IsOnTime(task, taskDate, now),
+	}
+	if err := s.tasks.RecordCompletion(record); err != nil {
+		return nil, err
+	}
+
+	s.refresh(task, taskDate)
+	return record, nil
+}
+
+// IsOnTime 在 at 时刻打卡是否算按时：通知型任务和没有截止时间的一期总是按时，
+// 任务型按任务时区计算这一期的截止时间，截止后 onTimeGrace 内仍算按时
+func (s *CompletionService) IsOnTime(task models.Task, taskDate, at time.Time) bool {
+	if task.Type != models.TaskTypeTask {
+		return true
+	}
+	deadline := s.tasks.DeadlineOn(task, taskDate)
+	if deadline.IsZero() {
+		return true
+	}
+	return !at.After(deadline.Add(s.onTimeGrace))
+}
+
+// UndoWindow 成员可以撤销打卡的时长
+func (s *CompletionService) UndoWindow() time.Duration {
+	return s.undoWindow
+}
+
+// Undo 撤销成员在这些任务中最近的一次打卡（须在宽限时间内），返回被撤销打卡的任务和记录
+func (s *CompletionService) Undo(tasks []models.Task, userID string) (*models.Task, *models.CompletionRecord, error) {
+	taskIDs := make([]int64, len(tasks))
+	for i, task := range tasks {
+		taskIDs[i] = int64(task.ID)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	// 宽限时间在数据库中判断，与 completed_at 的默认值使用同一时钟
+	query := `
+		SELECT id, task_id, user_name, group_chat_id, completed_at, task_date, is_on_time,
+		       completed_at >= LOCALTIMESTAMP - make_interval(secs => $3)
+		FROM completion_records
+		WHERE task_id = ANY($1) AND user_id = $2
+		ORDER BY completed_at DESC
+		LIMIT 1
+		FOR UPDATE
+	`
+	record := models.CompletionRecord{UserID: userID}
+	var undoable bool
+	err = tx.QueryRow(query, pq.Array(taskIDs), userID, s.undoWindow.Seconds()).Scan(
+		&record.ID, &record.TaskID, &record.UserName, &record.GroupChatID, &record.CompletedAt, &record.TaskDate, &record.IsOnTime, &undoable,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("你还没有打卡")
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("查询打卡记录失败: %w", err)
+	}
+	if !undoable {
+		return nil, nil, fmt.Errorf("只能撤销 %s 内的打卡，请联系管理员更正", formatWindow(s.undoWindow))
+	}
+
+	var task *models.Task
+	for i := range tasks {
+		if tasks[i].ID == record.TaskID {
+			task = &tasks[i]
+		}
+	}
+
+	// 提交内容随打卡记录级联删除
+	if _, err := tx.Exec(`DELETE FROM completion_records WHERE id = $1`, record.ID); err != nil {
+		return nil, nil, fmt.Errorf("撤销打卡失败: %w", err)
+	}
+
+	audit := models.CompletionAudit{
+		TaskID:              record.TaskID,
+		UserID:              userID,
+		TaskDate:            record.TaskDate,
+		Action:              models.CompletionAuditUndo,
+		OperatorID:          userID,
+		PreviousCompletedAt: sql.NullTime{Time: record.CompletedAt, Valid: true},
+		PreviousOnTime:      sql.NullBool{Bool: record.IsOnTime, Valid: true},
+	}
+	if err := insertCompletionAudit(tx, &audit); err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	s.refresh(*task, record.TaskDate)
+	return task, &record, nil
+}
+
+// Correct 管理员更正成员在某一期的打卡：completed 为 true 时补记为已完成（已打卡的只更新是否按时），
+// 为 false 时删除打卡记录。只能更正任务已经到了的某一期，且成员必须是任务的负责人
+// （未指定负责人时为群成员，免提醒的成员也可以更正；群成员未知时不做限制）
+func (s *CompletionService) Correct(task models.Task, userID string, taskDate time.Time, completed, onTime bool, operatorID, reason string) (*models.CompletionAudit, error) {
+	current := s.tasks.PeriodDate(task, time.Now())
+	if taskDate.Format("2006-01-02") > current.Format("2006-01-02") {
+		return nil, fmt.Errorf("%s 还没到，不能更正", taskDate.Format("2006-01-02"))
+	}
+
+	date := dateIn(taskDate, s.tasks.Location(task))
+	dates, err := s.tasks.OccurrenceDates(task, date, date)
+	if err != nil {
+		return nil, err
+	}
+	if len(dates) == 0 {
+		return nil, fmt.Errorf("任务「%s」在 %s 不需要执行", task.Name, taskDate.Format("2006-01-02"))
+	}
+
+	assigned, err := s.tasks.IsAssigned(task, userID)
+	if err != nil {
+		return nil, fmt.Errorf("获取任务负责人失败: %w", err)
+	}
+	if !assigned {
+		return nil, fmt.Errorf("%s 不是任务「%s」的负责人", userID, task.Name)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	audit := models.CompletionAudit{
+		TaskID:     task.ID,
+		UserID:     userID,
+		TaskDate:   taskDate,
+		OperatorID: operatorID,
+		Reason:     sql.NullString{String: reason, Valid: reason != ""},
+	}
+
+	query := `
+		SELECT id, completed_at, is_on_time
+		FROM completion_records
+		WHERE task_id = $1 AND user_id = $2 AND task_date = $3
+		FOR UPDATE
+	`
+	var recordID int
+	err = tx.QueryRow(query, task.ID, userID, taskDate.Format("2006-01-02")).Scan(
+		&recordID, &audit.PreviousCompletedAt, &audit.PreviousOnTime,
+	)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("查询打卡记录失败: %w", err)
+	}
+	exists := err == nil
+
+	switch {
+	case completed && exists:
+		if audit.PreviousOnTime.Bool == onTime {
+			return nil, fmt.Errorf("该成员 %s 已经打卡", taskDate.Format("2006-01-02"))
+		}
+		if _, err := tx.Exec(`UPDATE completion_records SET is_on_time = $2 WHERE id = $1`, recordID, onTime); err != nil {
+			return nil, fmt.Errorf("更正打卡失败: %w", err)
+		}
+
+	case completed:
+		var userName sql.NullString
+		if names, err := s.tasks.GetUserNames([]string{userID}); err == nil && names[userID] != "" {
+			userName = sql.NullString{String: names[userID], Valid: true}
+		}
+		insert := `
+			INSERT INTO completion_records (
+				task_id, user_id, user_name, group_chat_id, task_date, is_on_time
+			) VALUES ($1, $2, $3, $4, $5, $6)
+		`
+		if _, err := tx.Exec(insert, task.ID, userID, userName, task.GroupChatID, taskDate.Format("2006-01-02"), onTime); err != nil {
+			return nil, fmt.Errorf("补卡失败: %w", err)
+		}
+
+	case exists:
+		if _, err := tx.Exec(`DELETE FROM completion_records WHERE id = $1`, recordID); err != nil {
+			return nil, fmt.Errorf("删除打卡失败: %w", err)
+		}
+
+	default:
+		return nil, fmt.Errorf("该成员 %s 没有打卡记录", taskDate.Format("2006-01-02"))
+	}
+
+	if completed {
+		audit.Action = models.CompletionAuditMarkDone
+		audit.IsOnTime = sql.NullBool{Bool: onTime, Valid: true}
+	} else {
+		audit.Action = models.CompletionAuditMarkUndone
+	}
+	if err := insertCompletionAudit(tx, &audit); err != nil {
+		return nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.refresh(task, taskDate)
+	return &audit, nil
+}
+
+// AuditLog 获取任务自 since 以来的打卡变更记录（新的在前）
+func (s *CompletionService) AuditLog(taskID int, since time.Time) ([]models.CompletionAudit, error) {
+	query := `
+		SELECT id, task_id, user_id, task_date, action, operator_id, reason,
+		       previous_completed_at, previous_on_time, is_on_time, created_at
+		FROM completion_audit_logs
+		WHERE task_id = $1 AND created_at >= $2
+		ORDER BY created_at DESC, id DESC
+	`
+	rows, err := s.db.Query(query, taskID, since)
+	if err != nil {
+		return nil, fmt.Errorf("获取打卡变更记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	var audits []models.CompletionAudit
+	for rows.Next() {
+		var a models.CompletionAudit
+		if err := rows.Scan(
+			&a.ID, &a.TaskID, &a.UserID, &a.TaskDate, &a.Action, &a.OperatorID, &a.Reason,
+			&a.PreviousCompletedAt, &a.PreviousOnTime, &a.IsOnTime, &a.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		audits = append(audits, a)
+	}
+	return audits, rows.Err()
+}
+
+// insertCompletionAudit 在打卡变更的同一事务中写入审计记录
+func insertCompletionAudit(tx *sql.Tx, audit *models.CompletionAudit) error {
+	query := `
+		INSERT INTO completion_audit_logs (
+			task_id, user_id, task_date, action, operator_id, reason,
+			previous_completed_at, previous_on_time, is_on_time
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		RETURNING id, created_at
+	`
+	err := tx.QueryRow(query,
+		audit.TaskID,
+		audit.UserID,
+		audit.TaskDate.Format("2006-01-02"),
+		audit.Action,
+		audit.OperatorID,
+		audit.Reason,
+		audit.PreviousCompletedAt,
+		audit.PreviousOnTime,
+		audit.IsOnTime,
+	).Scan(&audit.ID, &audit.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("记录打卡变更失败: %w", err)
+	}
+	return nil
+}
+
+// refresh 打卡变更后刷新该期提醒卡片上的完成进度（统计报告按打卡记录实时计算）
+func (s *CompletionService) refresh(task models.Task, taskDate time.Time) {
+	if s.cards == nil {
+		return
+	}
+	s.cards.Refresh(task, taskDate)
+}
+
+// formatWindow 将时长描述为 "10 分钟"、"2 小时"
+func formatWindow(d time.Duration) string {
+	if d >= time.Hour && d%time.Hour == 0 {
+		return fmt.Sprintf("%d 小时", int(d/time.Hour))
+	}
+	return fmt.Sprintf("%d 分钟", int(d/time.Minute))
+}
@@ -0,0 +1,387 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/dingtalk"
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+	"github.com/robfig/cron/v3"
+)
+
+// DigestChangedChannel 定时汇总被新增或删除时发送通知的 Postgres 频道（payload 为汇总ID）
+const DigestChangedChannel = "dingteam_digest_changed"
+
+// 汇总中附带的排行榜名次数
+const digestLeaderboardSize = 3
+
+// ErrNoDigestTasks 群内没有进行中的任务型任务，不发送汇总
+var ErrNoDigestTasks = errors.New("群内没有进行中的任务")
+
+// DigestService 定时汇总：按 cron 把群内所有任务的完成情况发到群里或私聊管理者
+// 日报列出每个任务本期的完成率和未完成名单，周报列出本周每个任务和每个成员的完成情况，都带与上周的对比
+type DigestService struct {
+	db       *sql.DB
+	tasks    *TaskService
+	stats    *StatsService
+	dtClient *dingtalk.Client
+}
+
+func NewDigestService(db *sql.DB, tasks *TaskService, stats *StatsService, dtClient *dingtalk.Client) *DigestService {
+	return &DigestService{db: db, tasks: tasks, stats: stats, dtClient: dtClient}
+}
+
+const digestColumns = `id, group_chat_id, kind, cron_expr, calendar_policy, target, recipients, include_leaderboard, created_by, created_at, last_sent_at`
+
+func scanDigest(row rowScanner) (models.Digest, error) {
+	var d models.Digest
+	var lastSentAt sql.NullTime
+	err := row.Scan(
+		&d.ID, &d.GroupChatID, &d.Kind, &d.CronExpr, &d.CalendarPolicy, &d.Target,
+		pq.Array(&d.Recipients), &d.IncludeLeaderboard, &d.CreatedBy, &d.CreatedAt, &lastSentAt,
+	)
+	if lastSentAt.Valid {
+		d.LastSentAt = &lastSentAt.Time
+	}
+	return d, err
+}
+
+// CreateDigest 新增定时汇总
+func (s *DigestService) CreateDigest(d models.Digest) (*models.Digest, error) {
+	if d.GroupChatID == "" {
+		return nil, fmt.Errorf("缺少汇总的群")
+	}
+	if d.Kind != models.DigestDaily && d.Kind != models.DigestWeekly {
+		return nil, fmt.Errorf("未知的汇总类型: %s（可选 DAILY、WEEKLY）", d.Kind)
+	}
+	if d.Target == "" {
+		d.Target = models.DigestToGroup
+	}
+	if d.Target != models.DigestToGroup && d.Target != models.DigestToPrivate {
+		return nil, fmt.Errorf("未知的发送方式: %s（可选 GROUP、PRIVATE）", d.Target)
+	}
+	if d.CalendarPolicy == "" {
+		d.CalendarPolicy = models.CalendarPolicyNone
+	}
+	if _, err := ParseCronExpr(d.CronExpr); err != nil {
+		return nil, fmt.Errorf("cron 表达式无效: %w", err)
+	}
+	if d.Recipients == nil {
+		d.Recipients = []string{}
+	}
+
+	query := `
+		INSERT INTO digests (group_chat_id, kind, cron_expr, calendar_policy, target, recipients, include_leaderboard, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRow(query,
+		d.GroupChatID, d.Kind, d.CronExpr, d.CalendarPolicy, d.Target, pq.Array(d.Recipients), d.IncludeLeaderboard, d.CreatedBy,
+	).Scan(&d.ID, &d.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("保存定时汇总失败: %w", err)
+	}
+
+	s.notifyChanged(d.ID)
+	return &d, nil
+}
+
+// GetDigest 获取定时汇总
+func (s *DigestService) GetDigest(digestID int) (*models.Digest, error) {
+	d, err := scanDigest(s.db.QueryRow(`SELECT `+digestColumns+` FROM digests WHERE id = $1`, digestID))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("定时汇总不存在")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// ListDigests 获取群的定时汇总，groupChatID 为空时返回所有群的
+func (s *DigestService) ListDigests(groupChatID string) ([]models.Digest, error) {
+	rows, err := s.db.Query(`
+		SELECT `+digestColumns+`
+		FROM digests
+		WHERE $1::text = '' OR group_chat_id = $1
+		ORDER BY id
+	`, groupChatID)
+	if err != nil {
+		return nil, fmt.Errorf("获取定时汇总失败: %w", err)
+	}
+	defer rows.Close()
+
+	digests := []models.Digest{}
+	for rows.Next() {
+		d, err := scanDigest(rows)
+		if err != nil {
+			return nil, err
+		}
+		digests = append(digests, d)
+	}
+	return digests, rows.Err()
+}
+
+// DeleteDigest 删除定时汇总
+func (s *DigestService) DeleteDigest(digestID int) error {
+	result, err := s.db.Exec(`DELETE FROM digests WHERE id = $1`, digestID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("定时汇总不存在")
+	}
+	s.notifyChanged(digestID)
+	return nil
+}
+
+// notifyChanged 通知领导者重新加载定时汇总（通知失败时等待定期重新加载）
+func (s *DigestService) notifyChanged(digestID int) {
+	if _, err := s.db.Exec(`SELECT pg_notify($1, $2)`, DigestChangedChannel, strconv.Itoa(digestID)); err != nil {
+		log.Printf("发送定时汇总变更通知失败: %v", err)
+	}
+}
+
+// Location 汇总使用群的时区
+func (s *DigestService) Location(d models.Digest) *time.Location {
+	return s.tasks.Location(models.Task{GroupChatID: d.GroupChatID})
+}
+
+// Schedule 汇总的发送时间序列（按群时区和节假日策略）
+func (s *DigestService) Schedule(d models.Digest) (cron.Schedule, error) {
+	spec, err := ParseCronExpr(d.CronExpr)
+	if err != nil {
+		return nil, fmt.Errorf("解析 cron 表达式失败: %w", err)
+	}
+	loc := s.Location(d)
+	if schedule, ok := spec.(*cron.SpecSchedule); ok && schedule.Location == time.Local {
+		schedule.Location = loc
+	}
+	return s.tasks.calendar.Wrap(d.CalendarPolicy, spec, loc), nil
+}
+
+// Claim 登记某次计划发送，同一时刻只有一个副本登记成功
+func (s *DigestService) Claim(digestID int, at time.Time) (bool, error) {
+	result, err := s.db.Exec(
+		`UPDATE digests SET last_sent_at = $2 WHERE id = $1 AND (last_sent_at IS NULL OR last_sent_at < $2)`,
+		digestID, at,
+	)
+	if err != nil {
+		return false, fmt.Errorf("登记定时汇总失败: %w", err)
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// Send 生成汇总并发送：发到群里，或私聊接收人（未指定时为创建人）
+func (s *DigestService) Send(d models.Digest, now time.Time) error {
+	title, text, err := s.Build(d, now)
+	if err != nil {
+		return err
+	}
+	if d.Target == models.DigestToPrivate {
+		recipients := d.Recipients
+		if len(recipients) == 0 {
+			recipients = []string{d.CreatedBy}
+		}
+		return s.dtClient.SendPrivateMarkdown(recipients, title, text)
+	}
+	return s.dtClient.SendMarkdown(d.GroupChatID, title, text)
+}
+
+// Build 生成汇总的标题和 Markdown 内容，群内没有进行中的任务时返回 ErrNoDigestTasks
+func (s *DigestService) Build(d models.Digest, now time.Time) (title, text string, err error) {
+	tasks, err := s.tasks.GetActiveTasksByGroup(d.GroupChatID)
+	if err != nil {
+		return "", "", err
+	}
+	running := tasks[:0]
+	for _, task := range tasks {
+		if task.Type == models.TaskTypeTask {
+			running = append(running, task)
+		}
+	}
+	if len(running) == 0 {
+		return "", "", ErrNoDigestTasks
+	}
+
+	today := startOfDay(now.In(s.Location(d)))
+	var b strings.Builder
+	if d.Kind == models.DigestWeekly {
+		title = fmt.Sprintf("本周汇总 %s ~ %s", weekStart(today).Format("01-02"), today.Format("01-02"))
+		err = s.buildWeekly(&b, title, d.GroupChatID, running, today)
+	} else {
+		title = fmt.Sprintf("%s 任务日报", today.Format("01-02"))
+		err = s.buildDaily(&b, title, d.GroupChatID, running, today)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if d.IncludeLeaderboard {
+		board, err := s.stats.GetLeaderboard(models.LeaderboardQuery{GroupChatID: d.GroupChatID, Month: today})
+		if err != nil {
+			return "", "", err
+		}
+		b.WriteString("\n---\n\n")
+		b.WriteString(s.stats.FormatLeaderboard(board, digestLeaderboardSize))
+	}
+	return title, b.String(), nil
+}
+
+// buildDaily 日报：每个任务本期的完成情况、未完成名单和本周完成率的周环比
+func (s *DigestService) buildDaily(b *strings.Builder, title, groupChatID string, tasks []models.Task, today time.Time) error {
+	fmt.Fprintf(b, "### 📊 %s\n\n", title)
+	writeGroupName(b, tasks)
+
+	for _, task := range tasks {
+		stats, err := s.stats.GetTodayStats(task.ID)
+		if err != nil {
+			return err
+		}
+		thisWeek, lastWeek, err := s.weekComparison(models.StatsQuery{TaskID: task.ID}, today)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(b, "**%s**（本期 %s）\n\n", task.Name, stats.TaskDate.Format("01-02"))
+		if stats.Skipped {
+			b.WriteString("- ⏭️ 本期已跳过\n")
+		} else {
+			fmt.Fprintf(b, "- ✅ 已完成 %d/%d（%.1f%%）\n", stats.CompletedCount, stats.TotalMembers, stats.CompletionRate)
+			if len(stats.PendingUsers) > 0 {
+				fmt.Fprintf(b, "- ⏳ 未完成: %s\n", strings.Join(stats.PendingUsers, "、"))
+			}
+		}
+		fmt.Fprintf(b, "- 📈 %s\n\n", formatWeekTrend(periodRate(thisWeek), periodRate(lastWeek)))
+	}
+
+	thisWeek, lastWeek, err := s.weekComparison(models.StatsQuery{GroupChatID: groupChatID}, today)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "**整体**: %s\n", formatWeekTrend(periodRate(thisWeek), periodRate(lastWeek)))
+	return nil
+}
+
+// buildWeekly 周报：本周每个任务的完成率，以及每个成员的完成情况和未完成的各期，都带与上周的对比
+func (s *DigestService) buildWeekly(b *strings.Builder, title, groupChatID string, tasks []models.Task, today time.Time) error {
+	fmt.Fprintf(b, "### 📊 %s\n\n", title)
+	writeGroupName(b, tasks)
+
+	b.WriteString("**各任务完成率**\n\n")
+	for _, task := range tasks {
+		thisWeek, lastWeek, err := s.weekComparison(models.StatsQuery{TaskID: task.ID}, today)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(b, "- %s: %s\n", task.Name, formatWeekTrend(periodRate(thisWeek), periodRate(lastWeek)))
+	}
+
+	thisWeek, lastWeek, err := s.weekComparison(models.StatsQuery{GroupChatID: groupChatID}, today)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(b, "- **整体**: %s\n\n", formatWeekTrend(periodRate(thisWeek), periodRate(lastWeek)))
+
+	if len(thisWeek.Users) == 0 {
+		b.WriteString("本周还没有已截止的一期\n")
+		return nil
+	}
+
+	lastRates := make(map[string]float64, len(lastWeek.Users))
+	for _, u := range lastWeek.Users {
+		lastRates[u.UserID] = u.CompletionRate
+	}
+
+	b.WriteString("**成员**（已完成/应完成，完成率低的在前）\n\n")
+	for _, u := range thisWeek.Users {
+		last, ok := lastRates[u.UserID]
+		var lastRate *float64
+		if ok {
+			lastRate = &last
+		}
+		current := u.CompletionRate
+		fmt.Fprintf(b, "- %s: %d/%d，按时 %d，%s\n", u.UserName, u.Completed, u.Expected, u.OnTime, formatWeekTrend(&current, lastRate))
+		if len(u.Missed) > 0 {
+			missed := make([]string, len(u.Missed))
+			for i, m := range u.Missed {
+				missed[i] = m.TaskDate.Format("01-02") + " " + m.TaskName
+			}
+			fmt.Fprintf(b, "  未完成: %s\n", strings.Join(missed, "、"))
+		}
+	}
+	return nil
+}
+
+// weekComparison 本周（周一至今天）与上周的统计，base 为任务或群的筛选条件
+func (s *DigestService) weekComparison(base models.StatsQuery, today time.Time) (thisWeek, lastWeek *models.HistoryStats, err error) {
+	monday := weekStart(today)
+	base.Granularity = models.GranularityWeek
+
+	base.From, base.To = monday, today
+	if thisWeek, err = s.stats.GetHistoryStats(base); err != nil {
+		return nil, nil, err
+	}
+	base.From, base.To = monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1)
+	if lastWeek, err = s.stats.GetHistoryStats(base); err != nil {
+		return nil, nil, err
+	}
+	return thisWeek, lastWeek, nil
+}
+
+// writeGroupName 私聊发送时注明是哪个群的汇总
+func writeGroupName(b *strings.Builder, tasks []models.Task) {
+	for _, task := range tasks {
+		if task.GroupChatName.Valid && task.GroupChatName.String != "" {
+			fmt.Fprintf(b, "💬 群: %s\n\n", task.GroupChatName.String)
+			return
+		}
+	}
+}
+
+// weekStart day 所在周的周一
+func weekStart(day time.Time) time.Time {
+	weekday := int(day.Weekday())
+	if weekday == 0 {
+		weekday = 7 // 周日转为 7
+	}
+	return day.AddDate(0, 0, 1-weekday)
+}
+
+// periodRate 按周统计结果中的完成率，没有已截止的一期时返回 nil
+func periodRate(stats *models.HistoryStats) *float64 {
+	if len(stats.Periods) == 0 {
+		return nil
+	}
+	return &stats.Periods[0].CompletionRate
+}
+
+// formatWeekTrend 本周与上周完成率的对比，如 "本周 85.0%，上周 90.0%（↓5.0）"
+func formatWeekTrend(thisWeek, lastWeek *float64) string {
+	if thisWeek == nil {
+		return "本周还没有已截止的一期"
+	}
+	text := fmt.Sprintf("本周 %.1f%%", *thisWeek)
+	if lastWeek == nil {
+		return text
+	}
+
+	diff := *thisWeek - *lastWeek
+	trend := "持平"
+	switch {
+	case diff > 0.05:
+		trend = fmt.Sprintf("↑%.1f", diff)
+	case diff < -0.05:
+		trend = fmt.Sprintf("↓%.1f", math.Abs(diff))
+	}
+	return fmt.Sprintf("%s，上周 %.1f%%（%s）", text, *lastWeek, trend)
+}
@@ -0,0 +1,213 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"io"
+	"strconv"
+
+	"dingteam-bot/internal/models"
+)
+
+// 导出中时间列的格式
+const exportTimeLayout = "2006-01-02 15:04:05"
+
+// ExportService 导出打卡记录、提醒记录和权限审计日志
+// 查询结果逐行写出，不在内存中保留整张表
+type ExportService struct {
+	db *sql.DB
+}
+
+func NewExportService(db *sql.DB) *ExportService {
+	return &ExportService{db: db}
+}
+
+// exportSpec 一种导出数据的表头、查询和逐行转换
+type exportSpec struct {
+	header []string
+	query  string
+	args   []interface{}
+	scan   func(rows *sql.Rows) ([]string, error)
+}
+
+// ExportFileName 导出文件名，如 打卡记录_2026-10-01_2026-10-31.xlsx
+func ExportFileName(kind models.ExportKind, format models.ExportFormat, query models.ExportQuery) string {
+	return fmt.Sprintf("%s_%s_%s.%s", kind.DisplayName(), query.From.Format("2006-01-02"), query.To.Format("2006-01-02"), format)
+}
+
+// Export 将数据按格式写入 w，返回导出的记录条数（不含表头）
+// 查询失败时不会向 w 写入任何内容，调用方仍可返回错误信息
+func (s *ExportService) Export(w io.Writer, kind models.ExportKind, format models.ExportFormat, query models.ExportQuery) (int, error) {
+	if !format.IsValid() {
+		return 0, fmt.Errorf("不支持的导出格式: %s（可选 csv、xlsx）", format)
+	}
+	if query.To.Before(query.From) {
+		return 0, fmt.Errorf("结束日期不能早于开始日期")
+	}
+
+	var spec exportSpec
+	switch kind {
+	case models.ExportCompletions:
+		spec = completionsExport(query)
+	case models.ExportReminderLogs:
+		spec = reminderLogsExport(query)
+	case models.ExportPermissionAudit:
+		spec = permissionAuditExport(query)
+	default:
+		return 0, fmt.Errorf("不支持导出的数据: %s", kind)
+	}
+
+	rows, err := s.db.Query(spec.query, spec.args...)
+	if err != nil {
+		return 0, fmt.Errorf("导出%s失败: %w", kind.DisplayName(), err)
+	}
+	defer rows.Close()
+
+	out, err := newExportWriter(w, format, kind.DisplayName())
+	if err != nil {
+		return 0, err
+	}
+	if err := out.WriteRow(spec.header); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		values, err := spec.scan(rows)
+		if err != nil {
+			return count, err
+		}
+		if err := out.WriteRow(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		return count, fmt.Errorf("导出%s失败: %w", kind.DisplayName(), err)
+	}
+	return count, out.Close()
+}
+
+// completionsExport 打卡记录：按任务日期筛选，附带打卡时提交的内容
+func completionsExport(query models.ExportQuery) exportSpec {
+	return exportSpec{
+		header: []string{"任务日期", "任务ID", "任务名称", "群", "成员ID", "成员", "打卡时间", "是否按时", "提交内容"},
+		query: `
+			SELECT c.task_date, c.task_id, t.name, COALESCE(t.group_chat_name, c.group_chat_id),
+			       c.user_id, COALESCE(c.user_name, ''), c.completed_at, COALESCE(c.is_on_time, FALSE),
+			       COALESCE((
+			           SELECT string_agg(CASE s.kind
+			                      WHEN 'TEXT' THEN s.content
+			                      WHEN 'LINK' THEN s.content
+			                      WHEN 'FILE' THEN '[文件] ' || COALESCE(s.file_name, '')
+			                      WHEN 'VIDEO' THEN '[视频]'
+			                      WHEN 'AUDIO' THEN '[语音]'
+			                      ELSE '[图片]'
+			                  END, ' | ' ORDER BY s.id)
+			           FROM completion_submissions s WHERE s.completion_id = c.id
+			       ), '')
+			FROM completion_records c
+			JOIN tasks t ON t.id = c.task_id
+			WHERE c.task_date >= $1 AND c.task_date <= $2
+			  AND ($3 = 0 OR c.task_id = $3)
+			  AND ($4::text = '' OR c.group_chat_id = $4)
+			  AND ($5::text = '' OR c.user_id = $5)
+			ORDER BY c.task_date, c.task_id, c.completed_at
+		`,
+		args: []interface{}{
+			query.From.Format("2006-01-02"), query.To.Format("2006-01-02"),
+			query.TaskID, query.GroupChatID, query.UserID,
+		},
+		scan: func(rows *sql.Rows) ([]string, error) {
+			var taskDate, completedAt sql.NullTime
+			var taskID int
+			var taskName, group, userID, userName, submissions string
+			var onTime bool
+			if err := rows.Scan(&taskDate, &taskID, &taskName, &group, &userID, &userName, &completedAt, &onTime, &submissions); err != nil {
+				return nil, err
+			}
+			return []string{
+				formatExportTime(taskDate, "2006-01-02"), strconv.Itoa(taskID), taskName, group,
+				userID, userName, formatExportTime(completedAt, exportTimeLayout), yesNo(onTime), submissions,
+			}, nil
+		},
+	}
+}
+
+// reminderLogsExport 提醒发送记录：按发送时间所在的日期筛选
+func reminderLogsExport(query models.ExportQuery) exportSpec {
+	return exportSpec{
+		header: []string{"发送时间", "任务ID", "任务名称", "群", "提醒类型", "提醒时间点", "对应截止时间", "状态", "应打卡人数", "已打卡人数", "提醒内容"},
+		query: `
+			SELECT r.sent_at, r.task_id, t.name, COALESCE(t.group_chat_name, r.group_chat_id),
+			       r.reminder_type, COALESCE(r.reminder_offset, ''), r.occurrence_at, r.status,
+			       COALESCE(r.member_count, 0), COALESCE(r.completed_count, 0), COALESCE(r.message_text, '')
+			FROM reminder_logs r
+			JOIN tasks t ON t.id = r.task_id
+			WHERE r.sent_at >= $1 AND r.sent_at < $2
+			  AND ($3 = 0 OR r.task_id = $3)
+			  AND ($4::text = '' OR r.group_chat_id = $4)
+			ORDER BY r.sent_at, r.id
+		`,
+		args: []interface{}{
+			query.From.Format("2006-01-02"), query.To.AddDate(0, 0, 1).Format("2006-01-02"),
+			query.TaskID, query.GroupChatID,
+		},
+		scan: func(rows *sql.Rows) ([]string, error) {
+			var sentAt, occurrenceAt sql.NullTime
+			var taskID, memberCount, completedCount int
+			var taskName, group, reminderType, offset, status, message string
+			if err := rows.Scan(&sentAt, &taskID, &taskName, &group, &reminderType, &offset, &occurrenceAt, &status, &memberCount, &completedCount, &message); err != nil {
+				return nil, err
+			}
+			return []string{
+				formatExportTime(sentAt, exportTimeLayout), strconv.Itoa(taskID), taskName, group,
+				reminderType, offset, formatExportTime(occurrenceAt, exportTimeLayout), status,
+				strconv.Itoa(memberCount), strconv.Itoa(completedCount), message,
+			}, nil
+		},
+	}
+}
+
+// permissionAuditExport 权限审计日志：按记录时间所在的日期筛选，可按用户筛选
+func permissionAuditExport(query models.ExportQuery) exportSpec {
+	return exportSpec{
+		header: []string{"时间", "用户ID", "操作", "资源类型", "资源ID", "结果", "原因", "IP 地址"},
+		query: `
+			SELECT created_at, user_id, action, COALESCE(resource_type, ''), COALESCE(resource_id, ''),
+			       result, COALESCE(reason, ''), COALESCE(ip_address, '')
+			FROM permission_audit_logs
+			WHERE created_at >= $1 AND created_at < $2
+			  AND ($3::text = '' OR user_id = $3)
+			ORDER BY created_at, id
+		`,
+		args: []interface{}{
+			query.From.Format("2006-01-02"), query.To.AddDate(0, 0, 1).Format("2006-01-02"),
+			query.UserID,
+		},
+		scan: func(rows *sql.Rows) ([]string, error) {
+			var createdAt sql.NullTime
+			var userID, action, resourceType, resourceID, result, reason, ip string
+			if err := rows.Scan(&createdAt, &userID, &action, &resourceType, &resourceID, &result, &reason, &ip); err != nil {
+				return nil, err
+			}
+			return []string{
+				formatExportTime(createdAt, exportTimeLayout), userID, action, resourceType, resourceID, result, reason, ip,
+			}, nil
+		},
+	}
+}
+
+func formatExportTime(t sql.NullTime, layout string) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format(layout)
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "是"
+	}
+	return "否"
+}
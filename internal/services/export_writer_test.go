@@ -0,0 +1,209 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"dingteam-bot/internal/models"
+)
+
+// 导出测试使用的行：表头、公式开头的内容、XML 特殊字符和控制字符
+var exportTestRows = [][]string{
+	{"成员", "提交内容", "备注"},
+	{"=SUM(A1:A9)", "+86 138", "-1"},
+	{"@张三", `<b>周报</b> & "总结" 'ok'`, "第一行\n第二行"},
+	{"", "a,b", "控制\x01字符"},
+}
+
+func writeExport(t *testing.T, format models.ExportFormat, sheetName string, rows [][]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := newExportWriter(&buf, format, sheetName)
+	if err != nil {
+		t.Fatalf("创建导出写入器失败: %v", err)
+	}
+	for _, row := range rows {
+		if err := w.WriteRow(row); err != nil {
+			t.Fatalf("写入行失败: %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("关闭导出写入器失败: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func TestCSVExportWriter(t *testing.T) {
+	data := writeExport(t, models.ExportCSV, "打卡记录", exportTestRows)
+
+	body, ok := bytes.CutPrefix(data, []byte("\xEF\xBB\xBF"))
+	if !ok {
+		t.Fatalf("CSV 缺少 UTF-8 BOM: %q", data[:min(len(data), 8)])
+	}
+
+	got, err := csv.NewReader(bytes.NewReader(body)).ReadAll()
+	if err != nil {
+		t.Fatalf("读取 CSV 失败: %v", err)
+	}
+	want := [][]string{
+		{"成员", "提交内容", "备注"},
+		{"'=SUM(A1:A9)", "'+86 138", "'-1"},
+		{"'@张三", `<b>周报</b> & "总结" 'ok'`, "第一行\n第二行"},
+		{"", "a,b", "控制\x01字符"},
+	}
+	checkExportRows(t, got, want)
+}
+
+func TestXLSXExportWriter(t *testing.T) {
+	data := writeExport(t, models.ExportXLSX, `打卡<记录>&"`, exportTestRows)
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("xlsx 不是有效的 zip: %v", err)
+	}
+
+	parts := make(map[string][]byte)
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("打开 %s 失败: %v", f.Name, err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("读取 %s 失败: %v", f.Name, err)
+		}
+		parts[f.Name] = content
+	}
+
+	for _, name := range []string{"[Content_Types].xml", "_rels/.rels", "xl/workbook.xml", "xl/_rels/workbook.xml.rels", "xl/worksheets/sheet1.xml"} {
+		content, ok := parts[name]
+		if !ok {
+			t.Fatalf("xlsx 缺少 %s", name)
+		}
+		checkWellFormed(t, name, content)
+	}
+
+	var workbook struct {
+		Sheets []struct {
+			Name string `xml:"name,attr"`
+		} `xml:"sheets>sheet"`
+	}
+	if err := xml.Unmarshal(parts["xl/workbook.xml"], &workbook); err != nil {
+		t.Fatalf("解析 workbook.xml 失败: %v", err)
+	}
+	if len(workbook.Sheets) != 1 || workbook.Sheets[0].Name != `打卡<记录>&"` {
+		t.Errorf("工作表名称 = %+v", workbook.Sheets)
+	}
+
+	sheet := parts["xl/worksheets/sheet1.xml"]
+	for _, escaped := range []string{"&lt;b&gt;周报&lt;/b&gt; &amp; &#34;总结&#34; &#39;ok&#39;", "第一行&#xA;第二行"} {
+		if !bytes.Contains(sheet, []byte(escaped)) {
+			t.Errorf("工作表中没有转义后的 %q", escaped)
+		}
+	}
+
+	var worksheet struct {
+		Rows []struct {
+			R     int `xml:"r,attr"`
+			Cells []struct {
+				Type string `xml:"t,attr"`
+				Text string `xml:"is>t"`
+			} `xml:"c"`
+		} `xml:"sheetData>row"`
+	}
+	if err := xml.Unmarshal(sheet, &worksheet); err != nil {
+		t.Fatalf("解析 sheet1.xml 失败: %v", err)
+	}
+
+	var got [][]string
+	for i, row := range worksheet.Rows {
+		if row.R != i+1 {
+			t.Errorf("第 %d 行的行号为 %d", i+1, row.R)
+		}
+		var values []string
+		for _, cell := range row.Cells {
+			if cell.Type != "inlineStr" {
+				t.Errorf("第 %d 行单元格类型为 %q", i+1, cell.Type)
+			}
+			values = append(values, cell.Text)
+		}
+		got = append(got, values)
+	}
+
+	// 内联字符串不会被当作公式，原样保存；XML 不允许的控制字符被替换
+	want := [][]string{
+		{"成员", "提交内容", "备注"},
+		{"=SUM(A1:A9)", "+86 138", "-1"},
+		{"@张三", `<b>周报</b> & "总结" 'ok'`, "第一行\n第二行"},
+		{"", "a,b", "控制�字符"},
+	}
+	checkExportRows(t, got, want)
+}
+
+func TestXLSXExportWriterTruncatesLongCells(t *testing.T) {
+	long := strings.Repeat("周", xlsxMaxCellChars+10)
+	data := writeExport(t, models.ExportXLSX, "提醒记录", [][]string{{long}})
+
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("xlsx 不是有效的 zip: %v", err)
+	}
+	for _, f := range zr.File {
+		if f.Name != "xl/worksheets/sheet1.xml" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer rc.Close()
+		content, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := strings.Count(string(content), "周"); n != xlsxMaxCellChars {
+			t.Errorf("单元格保留 %d 个字符，期望 %d", n, xlsxMaxCellChars)
+		}
+		return
+	}
+	t.Fatal("xlsx 缺少工作表")
+}
+
+func TestNewExportWriterInvalidFormat(t *testing.T) {
+	if _, err := newExportWriter(io.Discard, models.ExportFormat("pdf"), "打卡记录"); err == nil {
+		t.Error("不支持的格式应返回错误")
+	}
+}
+
+// checkWellFormed 逐个读取 XML 记号直到结束，确认文档格式正确
+func checkWellFormed(t *testing.T, name string, content []byte) {
+	t.Helper()
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for {
+		_, err := decoder.Token()
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			t.Fatalf("%s 不是格式正确的 XML: %v", name, err)
+		}
+	}
+}
+
+func checkExportRows(t *testing.T, got, want [][]string) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Fatalf("读回 %d 行 %q，期望 %d 行", len(got), got, len(want))
+	}
+	for i := range want {
+		if strings.Join(got[i], "|") != strings.Join(want[i], "|") || len(got[i]) != len(want[i]) {
+			t.Errorf("第 %d 行 = %q，期望 %q", i+1, got[i], want[i])
+		}
+	}
+}
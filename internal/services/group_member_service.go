@@ -0,0 +1,358 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"dingteam-bot/internal/dingtalk"
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// 未同步过的群在查询成员时自动同步，同步失败后至少间隔该时长再重试
+const memberSyncRetryInterval = 10 * time.Minute
+
+// 每次同步最多向钉钉查询的成员资料数，其余在后续同步时补齐
+const maxProfileLookupsPerSync = 50
+
+// 按部门主管标记免提醒时，成员资料（是否为部门主管）的刷新间隔
+const profileRefreshInterval = 24 * time.Hour
+
+// 部门成员的缓存时长（指派给部门的任务按部门成员提醒）
+const departmentCacheTTL = time.Hour
+
+// MemberSource 群成员的数据来源（钉钉）
+type MemberSource interface {
+	GetGroupMemberIDs(chatID string) ([]string, error)
+	GetDepartmentUserIDs(deptID string) ([]string, error)
+	GetUserProfile(userID string) (*dingtalk.UserProfile, error)
+}
+
+type departmentMembers struct {
+	userIDs   []string
+	fetchedAt time.Time
+}
+
+// GroupMemberService 群成员（从钉钉同步到 group_members 表）
+type GroupMemberService struct {
+	db     *sql.DB
+	source MemberSource
+
+	// 是否定期刷新成员的部门主管标记（用于部门主管免提醒）
+	trackDeptLeaders bool
+
+	mu          sync.Mutex
+	attempts    map[string]time.Time         // 群ID → 最近一次按需同步的时间
+	departments map[string]departmentMembers // 部门ID → 部门成员
+}
+
+func NewGroupMemberService(db *sql.DB, source MemberSource, trackDeptLeaders bool) *GroupMemberService {
+	return &GroupMemberService{
+		db:               db,
+		source:           source,
+		trackDeptLeaders: trackDeptLeaders,
+		attempts:         make(map[string]time.Time),
+		departments:      make(map[string]departmentMembers),
+	}
+}
+
+// SyncGroup 从钉钉同步群成员：新增入群的成员，移除已退群的成员，返回当前成员数
+func (s *GroupMemberService) SyncGroup(groupChatID string) (int, error) {
+	userIDs, err := s.source.GetGroupMemberIDs(groupChatID)
+	if err != nil {
+		return 0, err
+	}
+	// 机器人所在的群不可能没有成员，返回空列表时保留原有成员，避免误删
+	if len(userIDs) == 0 {
+		return 0, fmt.Errorf("钉钉返回的群成员为空: %s", groupChatID)
+	}
+
+	profiles, err := s.lookupProfiles(groupChatID, userIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deleteQuery := `DELETE FROM group_members WHERE group_chat_id = $1 AND NOT (user_id = ANY($2))`
+	if _, err := tx.Exec(deleteQuery, groupChatID, pq.Array(userIDs)); err != nil {
+		return 0, err
+	}
+
+	// 本次没有查询到的资料保留原值
+	upsertQuery := `
+		INSERT INTO group_members (group_chat_id, user_id, user_name, dept_leader, profile_synced_at, synced_at)
+		VALUES ($1, $2, $3, COALESCE($4, FALSE), $5, CURRENT_TIMESTAMP)
+		ON CONFLICT (group_chat_id, user_id) DO UPDATE
+		SET user_name = COALESCE(EXCLUDED.user_name, group_members.user_name),
+		    dept_leader = COALESCE($4, group_members.dept_leader),
+		    profile_synced_at = COALESCE(EXCLUDED.profile_synced_at, group_members.profile_synced_at),
+		    synced_at = CURRENT_TIMESTAMP
+	`
+	for _, userID := range userIDs {
+		p := profiles[userID]
+		if _, err := tx.Exec(upsertQuery, groupChatID, userID, p.name, p.deptLeader, p.syncedAt); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := syncMemberPeriods(tx, groupChatID, userIDs); err != nil {
+		return 0, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+	return len(userIDs), nil
+}
+
+// syncMemberPeriods 记录成员入群和退群：结束已退群成员的期间，为新入群的成员开始新的期间
+// 群第一次同步时还没有任何记录，此时的成员视为一直在群内
+func syncMemberPeriods(tx *sql.Tx, groupChatID string, userIDs []string) error {
+	closeQuery := `
+		UPDATE group_member_periods SET left_at = CURRENT_TIMESTAMP
+		WHERE group_chat_id = $1 AND left_at IS NULL AND NOT (user_id = ANY($2))
+	`
+	if _, err := tx.Exec(closeQuery, groupChatID, pq.Array(userIDs)); err != nil {
+		return err
+	}
+
+	openQuery := `
+		INSERT INTO group_member_periods (group_chat_id, user_id, user_name, joined_at)
+		SELECT m.group_chat_id, m.user_id, m.user_name,
+		       CASE WHEN EXISTS (SELECT 1 FROM group_member_periods p WHERE p.group_chat_id = $1) THEN CURRENT_TIMESTAMP END
+		FROM group_members m
+		WHERE m.group_chat_id = $1
+		ON CONFLICT (group_chat_id, user_id) WHERE left_at IS NULL DO UPDATE
+		SET user_name = COALESCE(EXCLUDED.user_name, group_member_periods.user_name)
+	`
+	_, err := tx.Exec(openQuery, groupChatID)
+	return err
+}
+
+// memberProfile 同步时写入的成员资料，无效字段表示保留原值
+type memberProfile struct {
+	name       sql.NullString
+	deptLeader sql.NullBool
+	syncedAt   sql.NullTime
+}
+
+// lookupProfiles 查询成员资料：姓名先查已有记录和 users 表，仍没有姓名或主管标记过期的成员向钉钉查询
+func (s *GroupMemberService) lookupProfiles(groupChatID string, userIDs []string) (map[string]memberProfile, error) {
+	query := `
+		SELECT ids.user_id,
+		       COALESCE(NULLIF(m.user_name, ''), NULLIF(u.username, '')),
+		       m.profile_synced_at
+		FROM unnest($2::varchar[]) AS ids(user_id)
+		LEFT JOIN group_members m ON m.group_chat_id = $1 AND m.user_id = ids.user_id
+		LEFT JOIN users u ON u.dingtalk_user_id = ids.user_id
+	`
+	rows, err := s.db.Query(query, groupChatID, pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("查询成员资料失败: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := make(map[string]memberProfile, len(userIDs))
+	var stale []string
+	for rows.Next() {
+		var userID string
+		var p memberProfile
+		var syncedAt sql.NullTime
+		if err := rows.Scan(&userID, &p.name, &syncedAt); err != nil {
+			return nil, err
+		}
+		profiles[userID] = p
+
+		expired := !syncedAt.Valid || time.Since(syncedAt.Time) > profileRefreshInterval
+		if !p.name.Valid || (s.trackDeptLeaders && expired) {
+			stale = append(stale, userID)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for i, userID := range stale {
+		if i >= maxProfileLookupsPerSync {
+			break
+		}
+		profile, err := s.source.GetUserProfile(userID)
+		if err != nil {
+			log.Printf("获取成员资料失败: %v", err)
+			continue
+		}
+		p := profiles[userID]
+		if profile.Name != "" {
+			p.name = sql.NullString{String: profile.Name, Valid: true}
+		}
+		p.deptLeader = sql.NullBool{Bool: profile.DeptLeader, Valid: true}
+		p.syncedAt = sql.NullTime{Time: time.Now(), Valid: true}
+		profiles[userID] = p
+	}
+
+	return profiles, nil
+}
+
+// SyncActiveGroups 同步所有有活跃任务的群
+func (s *GroupMemberService) SyncActiveGroups() {
+	rows, err := s.db.Query(`SELECT DISTINCT group_chat_id FROM tasks WHERE status = 'ACTIVE'`)
+	if err != nil {
+		log.Printf("查询需要同步成员的群失败: %v", err)
+		return
+	}
+
+	var groups []string
+	for rows.Next() {
+		var groupChatID string
+		if err := rows.Scan(&groupChatID); err == nil {
+			groups = append(groups, groupChatID)
+		}
+	}
+	rows.Close()
+
+	var synced int
+	for _, groupChatID := range groups {
+		if _, err := s.SyncGroup(groupChatID); err != nil {
+			log.Printf("同步群成员失败 (%s): %v", groupChatID, err)
+			continue
+		}
+		synced++
+	}
+	if synced > 0 {
+		log.Printf("✓ 群成员已同步: %d/%d 个群", synced, len(groups))
+	}
+}
+
+// Run 定期同步群成员，直到 ctx 取消
+func (s *GroupMemberService) Run(ctx context.Context, interval time.Duration) {
+	s.SyncActiveGroups()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.SyncActiveGroups()
+		}
+	}
+}
+
+// Members 获取群成员（按姓名排序），从未同步过的群先从钉钉同步
+func (s *GroupMemberService) Members(groupChatID string) ([]models.GroupMember, error) {
+	members, err := s.queryMembers(groupChatID)
+	if err != nil || len(members) > 0 {
+		return members, err
+	}
+
+	if !s.shouldSyncOnDemand(groupChatID) {
+		return nil, nil
+	}
+	if _, err := s.SyncGroup(groupChatID); err != nil {
+		log.Printf("同步群成员失败 (%s): %v", groupChatID, err)
+		return nil, nil
+	}
+	return s.queryMembers(groupChatID)
+}
+
+// DepartmentMembers 获取钉钉部门成员的用户ID（缓存一小时，钉钉接口失败时沿用上次的结果）
+func (s *GroupMemberService) DepartmentMembers(deptID string) ([]string, error) {
+	s.mu.Lock()
+	cached, ok := s.departments[deptID]
+	s.mu.Unlock()
+	if ok && time.Since(cached.fetchedAt) < departmentCacheTTL {
+		return cached.userIDs, nil
+	}
+
+	userIDs, err := s.source.GetDepartmentUserIDs(deptID)
+	if err != nil {
+		if ok {
+			log.Printf("获取部门成员失败，沿用缓存 (%s): %v", deptID, err)
+			return cached.userIDs, nil
+		}
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.departments[deptID] = departmentMembers{userIDs: userIDs, fetchedAt: time.Now()}
+	s.mu.Unlock()
+	return userIDs, nil
+}
+
+// shouldSyncOnDemand 限制按需同步的频率，避免钉钉接口异常时每次查询都重试
+func (s *GroupMemberService) shouldSyncOnDemand(groupChatID string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.attempts[groupChatID]; ok && time.Since(last) < memberSyncRetryInterval {
+		return false
+	}
+	s.attempts[groupChatID] = time.Now()
+	return true
+}
+
+// MemberPeriods 成员在群内的各个期间中，仍在群内或在 since 之后才退群的部分（用于历史统计）
+func (s *GroupMemberService) MemberPeriods(groupChatID string, since time.Time) ([]models.GroupMemberPeriod, error) {
+	// 从未同步过的群先从钉钉同步
+	if _, err := s.Members(groupChatID); err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT group_chat_id, user_id, user_name, joined_at, left_at
+		FROM group_member_periods
+		WHERE group_chat_id = $1 AND (left_at IS NULL OR left_at >= $2)
+		ORDER BY user_name, user_id, joined_at NULLS FIRST
+	`
+	rows, err := s.db.Query(query, groupChatID, since)
+	if err != nil {
+		return nil, fmt.Errorf("获取群成员期间失败: %w", err)
+	}
+	defer rows.Close()
+
+	var periods []models.GroupMemberPeriod
+	for rows.Next() {
+		var p models.GroupMemberPeriod
+		if err := rows.Scan(&p.GroupChatID, &p.UserID, &p.UserName, &p.JoinedAt, &p.LeftAt); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+func (s *GroupMemberService) queryMembers(groupChatID string) ([]models.GroupMember, error) {
+	query := `
+		SELECT group_chat_id, user_id, user_name, dept_leader, synced_at
+		FROM group_members
+		WHERE group_chat_id = $1
+		ORDER BY user_name, user_id
+	`
+	rows, err := s.db.Query(query, groupChatID)
+	if err != nil {
+		return nil, fmt.Errorf("获取群成员失败: %w", err)
+	}
+	defer rows.Close()
+
+	var members []models.GroupMember
+	for rows.Next() {
+		var m models.GroupMember
+		if err := rows.Scan(&m.GroupChatID, &m.UserID, &m.UserName, &m.DeptLeader, &m.SyncedAt); err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+	return members, rows.Err()
+}
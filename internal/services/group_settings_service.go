@@ -0,0 +1,122 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// 群设置缓存的有效期，过期后在下次查询时从数据库重新加载（多实例部署时也能看到其他实例的修改）
+const groupSettingsCacheTTL = 5 * time.Minute
+
+// GroupSettingsService 群级别的设置（带内存缓存）
+type GroupSettingsService struct {
+	db *sql.DB
+
+	mu        sync.RWMutex
+	timezones map[string]string // 群ID -> 默认时区
+	loadedAt  time.Time
+}
+
+func NewGroupSettingsService(db *sql.DB) *GroupSettingsService {
+	return &GroupSettingsService{db: db, timezones: map[string]string{}}
+}
+
+// Reload 从数据库重新加载群设置缓存
+func (s *GroupSettingsService) Reload() error {
+	rows, err := s.db.Query(`SELECT group_chat_id, timezone FROM group_settings WHERE timezone != ''`)
+	if err != nil {
+		return fmt.Errorf("加载群设置失败: %w", err)
+	}
+	defer rows.Close()
+
+	timezones := make(map[string]string)
+	for rows.Next() {
+		var groupChatID, timezone string
+		if err := rows.Scan(&groupChatID, &timezone); err != nil {
+			return err
+		}
+		timezones[groupChatID] = timezone
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.timezones = timezones
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return nil
+}
+
+// 缓存过期时重新加载；加载失败时继续使用旧缓存，避免每次查询都访问数据库
+func (s *GroupSettingsService) ensureFresh() {
+	s.mu.RLock()
+	fresh := time.Since(s.loadedAt) < groupSettingsCacheTTL
+	s.mu.RUnlock()
+	if fresh {
+		return
+	}
+
+	if err := s.Reload(); err != nil {
+		log.Printf("⚠️  %v", err)
+		s.mu.Lock()
+		s.loadedAt = time.Now()
+		s.mu.Unlock()
+	}
+}
+
+// Timezone 群的默认时区，未设置时返回空字符串
+func (s *GroupSettingsService) Timezone(groupChatID string) string {
+	s.ensureFresh()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.timezones[groupChatID]
+}
+
+// Get 获取群设置，未设置过时返回默认值
+func (s *GroupSettingsService) Get(groupChatID string) (*models.GroupSettings, error) {
+	settings := &models.GroupSettings{GroupChatID: groupChatID}
+	err := s.db.QueryRow(
+		`SELECT timezone, updated_at FROM group_settings WHERE group_chat_id = $1`,
+		groupChatID,
+	).Scan(&settings.Timezone, &settings.UpdatedAt)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// SetTimezone 设置群的默认时区，传空字符串恢复为全局时区
+func (s *GroupSettingsService) SetTimezone(groupChatID, timezone string) error {
+	if timezone != "" {
+		if _, err := LoadLocation(timezone); err != nil {
+			return err
+		}
+	}
+
+	query := `
+		INSERT INTO group_settings (group_chat_id, timezone, updated_at)
+		VALUES ($1, $2, CURRENT_TIMESTAMP)
+		ON CONFLICT (group_chat_id) DO UPDATE SET timezone = EXCLUDED.timezone, updated_at = CURRENT_TIMESTAMP
+	`
+	if _, err := s.db.Exec(query, groupChatID, timezone); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	if timezone == "" {
+		delete(s.timezones, groupChatID)
+	} else {
+		s.timezones[groupChatID] = timezone
+	}
+	s.mu.Unlock()
+
+	return nil
+}
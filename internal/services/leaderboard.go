@@ -0,0 +1,234 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// 排行榜的奖牌（前三名）
+var leaderboardMedals = []string{"🥇", "🥈", "🥉"}
+
+// GetLeaderboard 任务（或群内所有任务型任务）某月的排行榜，数据来自打卡记录：
+// 按时完成次数多的在前，其次为完成率和首位提交次数；连续按时完成的期数统计到月末（或今天）为止的最近一年，
+// 指定任务时为该任务的连续期数，按群时为群内所有任务按日期排列后的连续期数
+func (s *StatsService) GetLeaderboard(query models.LeaderboardQuery) (*models.Leaderboard, error) {
+	if query.TaskID == 0 && query.GroupChatID == "" {
+		return nil, fmt.Errorf("缺少排行榜的任务或群")
+	}
+
+	tasks, err := s.statsTasks(models.StatsQuery{TaskID: query.TaskID, GroupChatID: query.GroupChatID})
+	if err != nil {
+		return nil, err
+	}
+
+	if query.Month.IsZero() {
+		query.Month = time.Now()
+	}
+
+	board := &models.Leaderboard{TaskID: query.TaskID, GroupChatID: query.GroupChatID, Entries: []models.LeaderboardEntry{}}
+	scope := models.Task{GroupChatID: query.GroupChatID}
+	if query.TaskID != 0 && len(tasks) == 1 {
+		scope = tasks[0]
+		board.TaskName = scope.Name
+	}
+	loc := s.taskService.Location(scope)
+	month := dateIn(query.Month, loc)
+	board.From = month.AddDate(0, 0, 1-month.Day())
+	board.To = board.From.AddDate(0, 1, -1)
+	board.Month = board.From.Format("2006-01")
+
+	args, _, err := s.expectedArgs(tasks, board.From, board.To, "")
+	if err != nil {
+		return nil, err
+	}
+	if board.Entries, err = s.leaderboardEntries(args); err != nil {
+		return nil, err
+	}
+
+	streakTo := board.To
+	if today := StartOfToday(loc); today.Before(streakTo) {
+		streakTo = today
+	}
+	args, _, err = s.expectedArgs(tasks, streakTo.AddDate(0, 0, 1-maxStatsDays), streakTo, "")
+	if err != nil {
+		return nil, err
+	}
+	streaks, err := s.onTimeStreaks(args)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range board.Entries {
+		entry := &board.Entries[i]
+		entry.CurrentStreak = streaks[entry.UserID][0]
+		entry.LongestStreak = streaks[entry.UserID][1]
+
+		// 成绩相同的成员并列
+		entry.Rank = i + 1
+		if i > 0 {
+			prev := board.Entries[i-1]
+			if prev.OnTime == entry.OnTime && prev.CompletionRate == entry.CompletionRate && prev.FirstSubmits == entry.FirstSubmits {
+				entry.Rank = prev.Rank
+			}
+		}
+	}
+	return board, nil
+}
+
+// leaderboardEntries 按成员汇总应打卡、已打卡、按时次数和首位提交次数（每期最早打卡的成员）
+func (s *StatsService) leaderboardEntries(args []interface{}) ([]models.LeaderboardEntry, error) {
+	query := expectedCTE + `
+		, firsts AS (
+			SELECT DISTINCT ON (c.task_id, c.task_date) c.user_id
+			FROM completion_records c
+			JOIN occurrences o ON o.task_id = c.task_id AND o.task_date = c.task_date
+			ORDER BY c.task_id, c.task_date, c.completed_at, c.id
+		), first_counts AS (
+			SELECT user_id, count(*) AS first_submits FROM firsts GROUP BY user_id
+		)
+		SELECT e.user_id, max(e.user_name),
+		       count(*),
+		       count(*) FILTER (WHERE e.completed),
+		       count(*) FILTER (WHERE e.on_time) AS on_time_count,
+		       COALESCE(round(100.0 * count(*) FILTER (WHERE e.completed) / NULLIF(count(*), 0), 1), 0)::float8 AS completion_rate,
+		       COALESCE(max(f.first_submits), 0) AS first_submits
+		FROM expected e
+		LEFT JOIN first_counts f ON f.user_id = e.user_id
+		GROUP BY e.user_id
+		ORDER BY on_time_count DESC, completion_rate DESC, first_submits DESC, max(e.user_name)
+	`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("统计排行榜失败: %w", err)
+	}
+	defer rows.Close()
+
+	entries := []models.LeaderboardEntry{}
+	for rows.Next() {
+		var e models.LeaderboardEntry
+		if err := rows.Scan(&e.UserID, &e.UserName, &e.Expected, &e.Completed, &e.OnTime, &e.CompletionRate, &e.FirstSubmits); err != nil {
+			return nil, err
+		}
+		entries = append(entries, e)
+	}
+	return entries, rows.Err()
+}
+
+// onTimeStreaks 按成员统计连续按时完成的期数，返回 成员ID → [截至最近一期的期数, 最长期数]
+func (s *StatsService) onTimeStreaks(args []interface{}) (map[string][2]int, error) {
+	query := expectedCTE + `
+		, ranked AS (
+			SELECT user_id, on_time,
+			       row_number() OVER w AS seq,
+			       row_number() OVER w - row_number() OVER (PARTITION BY user_id, on_time ORDER BY task_date, task_id) AS run
+			FROM expected
+			WINDOW w AS (PARTITION BY user_id ORDER BY task_date, task_id)
+		), runs AS (
+			SELECT user_id, count(*) AS run_length, max(seq) AS last_seq
+			FROM ranked WHERE on_time
+			GROUP BY user_id, run
+		), latest AS (
+			SELECT user_id, max(seq) AS seq FROM ranked GROUP BY user_id
+		)
+		SELECT r.user_id,
+		       COALESCE(max(r.run_length) FILTER (WHERE r.last_seq = l.seq), 0),
+		       max(r.run_length)
+		FROM runs r
+		JOIN latest l ON l.user_id = r.user_id
+		GROUP BY r.user_id
+	`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("统计连续按时完成失败: %w", err)
+	}
+	defer rows.Close()
+
+	streaks := make(map[string][2]int)
+	for rows.Next() {
+		var userID string
+		var current, longest int
+		if err := rows.Scan(&userID, &current, &longest); err != nil {
+			return nil, err
+		}
+		streaks[userID] = [2]int{current, longest}
+	}
+	return streaks, rows.Err()
+}
+
+// FormatLeaderboard 排行榜的 Markdown 文本，limit 大于 0 时只列出前 limit 名
+func (s *StatsService) FormatLeaderboard(board *models.Leaderboard, limit int) string {
+	var b strings.Builder
+	scope := board.TaskName
+	if scope == "" {
+		scope = "本群"
+	}
+	fmt.Fprintf(&b, "🏆 **%s %d 月排行榜**\n\n", scope, int(board.From.Month()))
+
+	if len(board.Entries) == 0 {
+		b.WriteString("本月还没有已截止的一期\n")
+		return b.String()
+	}
+
+	entries := board.Entries
+	if limit > 0 && len(entries) > limit {
+		entries = entries[:limit]
+	}
+	for _, e := range entries {
+		rank := fmt.Sprintf("%d.", e.Rank)
+		if e.Rank <= len(leaderboardMedals) {
+			rank = leaderboardMedals[e.Rank-1]
+		}
+		line := fmt.Sprintf("%s %s 按时 %d/%d", rank, e.UserName, e.OnTime, e.Expected)
+		if e.CurrentStreak > 1 {
+			line += fmt.Sprintf(" · 🔥连续 %d 期", e.CurrentStreak)
+		}
+		if e.FirstSubmits > 0 {
+			line += fmt.Sprintf(" · ⚡首位提交 ×%d", e.FirstSubmits)
+		}
+		b.WriteString(line + "\n")
+	}
+
+	// 连续按时最久的成员（可能不在前几名）
+	best := board.Entries[0]
+	for _, e := range board.Entries[1:] {
+		if e.CurrentStreak > best.CurrentStreak {
+			best = e
+		}
+	}
+	if best.CurrentStreak > 1 {
+		fmt.Fprintf(&b, "\n🔥 **连续按时最久**: %s，已连续 %d 期\n", best.UserName, best.CurrentStreak)
+	}
+	return b.String()
+}
+
+// LeaderboardMonth 解析排行榜的月份：本月、上月、2026-10、10月，空时为本月
+func LeaderboardMonth(value string, loc *time.Location) (time.Time, error) {
+	today := StartOfToday(loc)
+	thisMonth := today.AddDate(0, 0, 1-today.Day())
+	value = strings.TrimSpace(value)
+	switch value {
+	case "", "本月":
+		return thisMonth, nil
+	case "上月":
+		return thisMonth.AddDate(0, -1, 0), nil
+	}
+
+	if month, err := time.ParseInLocation("2006-01", value, loc); err == nil {
+		return month, nil
+	}
+	if digits, ok := strings.CutSuffix(value, "月"); ok {
+		if m, err := strconv.Atoi(digits); err == nil && m >= 1 && m <= 12 {
+			// 只写月份时取最近的这个月（不晚于本月）
+			month := time.Date(today.Year(), time.Month(m), 1, 0, 0, 0, 0, loc)
+			if month.After(thisMonth) {
+				month = month.AddDate(-1, 0, 0)
+			}
+			return month, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("月份格式错误: %s（如 本月、上月、2026-10、9月）", value)
+}
@@ -0,0 +1,134 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// StartOfToday 时区 loc 的今天零点
+func StartOfToday(loc *time.Location) time.Time {
+	return startOfDay(time.Now().In(loc))
+}
+
+// ParseLeaveDate 解析请假日期：支持 今天/明天/后天 和 ParseTaskDate 的格式（如 10-20、2026-10-20）
+func ParseLeaveDate(value string, loc *time.Location) (time.Time, error) {
+	today := StartOfToday(loc)
+	switch strings.TrimSpace(value) {
+	case "今天":
+		return today, nil
+	case "明天":
+		return today.AddDate(0, 0, 1), nil
+	case "后天":
+		return today.AddDate(0, 0, 2), nil
+	}
+	return ParseTaskDate(value, loc)
+}
+
+// AddLeave 登记成员请假，结束日期为空时只请开始日期当天
+func (s *TaskService) AddLeave(leave models.Leave) (*models.Leave, error) {
+	if leave.UserID == "" {
+		return nil, fmt.Errorf("缺少请假的成员")
+	}
+	if leave.EndDate.IsZero() {
+		leave.EndDate = leave.StartDate
+	}
+	if leave.EndDate.Before(leave.StartDate) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+
+	query := `
+		INSERT INTO member_leaves (user_id, task_id, start_date, end_date, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	`
+	err := s.db.QueryRow(query,
+		leave.UserID,
+		leave.TaskID,
+		leave.StartDate.Format("2006-01-02"),
+		leave.EndDate.Format("2006-01-02"),
+		leave.Reason,
+		leave.CreatedBy,
+	).Scan(&leave.ID, &leave.CreatedAt)
+	if err != nil {
+		return nil, fmt.Errorf("登记请假失败: %w", err)
+	}
+	return &leave, nil
+}
+
+// DeleteLeave 删除一条请假
+func (s *TaskService) DeleteLeave(leaveID int) error {
+	result, err := s.db.Exec(`DELETE FROM member_leaves WHERE id = $1`, leaveID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("请假记录不存在")
+	}
+	return nil
+}
+
+// CancelUserLeaves 取消成员在 from 当天及之后的请假，返回涉及的条数：
+// 从 from 及之后开始的请假直接删除，已经开始的请假截止到 from 前一天（已请假的日期仍不计为缺卡）。
+// taskID 不为 0 时只取消该任务的请假；否则取消所有任务通用的请假和 groupChatID 群内任务的请假（群为空时不限）
+func (s *TaskService) CancelUserLeaves(userID string, groupChatID string, taskID int, from time.Time) (int, error) {
+	scope := `
+		user_id = $1 AND end_date >= $2
+		AND CASE WHEN $3 <> 0 THEN task_id = $3
+		         ELSE task_id IS NULL OR $4::text = ''
+		              OR task_id IN (SELECT id FROM tasks WHERE group_chat_id = $4)
+		    END
+	`
+	args := []interface{}{userID, from.Format("2006-01-02"), taskID, groupChatID}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	deleted, err := tx.Exec(`DELETE FROM member_leaves WHERE start_date >= $2 AND `+scope, args...)
+	if err != nil {
+		return 0, fmt.Errorf("取消请假失败: %w", err)
+	}
+	truncated, err := tx.Exec(`UPDATE member_leaves SET end_date = $2::date - 1 WHERE start_date < $2 AND `+scope, args...)
+	if err != nil {
+		return 0, fmt.Errorf("取消请假失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, err
+	}
+
+	n1, _ := deleted.RowsAffected()
+	n2, _ := truncated.RowsAffected()
+	return int(n1 + n2), nil
+}
+
+// GetLeaves 获取 since 当天及之后仍生效的请假，userIDs 为空时返回所有成员的请假
+func (s *TaskService) GetLeaves(userIDs []string, since time.Time) ([]models.Leave, error) {
+	query := `
+		SELECT id, user_id, task_id, start_date, end_date, reason, created_by, created_at
+		FROM member_leaves
+		WHERE end_date >= $1 AND (cardinality($2::text[]) = 0 OR user_id = ANY($2))
+		ORDER BY start_date, user_id
+	`
+	rows, err := s.db.Query(query, since.Format("2006-01-02"), pq.Array(userIDs))
+	if err != nil {
+		return nil, fmt.Errorf("获取请假记录失败: %w", err)
+	}
+	defer rows.Close()
+
+	leaves := []models.Leave{}
+	for rows.Next() {
+		var l models.Leave
+		if err := rows.Scan(&l.ID, &l.UserID, &l.TaskID, &l.StartDate, &l.EndDate, &l.Reason, &l.CreatedBy, &l.CreatedAt); err != nil {
+			return nil, err
+		}
+		leaves = append(leaves, l)
+	}
+	return leaves, rows.Err()
+}
@@ -0,0 +1,206 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+// 单次延后提醒的最大分钟数
+const maxSnoozeMinutes = 24 * 60
+
+// ResolveTaskDate 将日期参数转换为任务日期（为空时为当前周期），日期按任务时区解析
+func (s *TaskService) ResolveTaskDate(task models.Task, value string) (time.Time, error) {
+	if value == "" || value == "今天" {
+		return s.TaskDate(task, time.Now()), nil
+	}
+	date, err := s.ParseTaskDate(task, value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return s.PeriodDate(task, date), nil
+}
+
+// SkipOccurrence 跳过任务在某个任务日期的执行：当期不再提醒，也不计入完成率
+func (s *TaskService) SkipOccurrence(taskID int, taskDate time.Time, createdBy, reason string) error {
+	query := `
+		INSERT INTO task_occurrence_overrides (task_id, kind, task_date, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (task_id, task_date) WHERE kind = 'SKIP' DO NOTHING
+	`
+	result, err := s.db.Exec(query, taskID, models.OverrideSkip, taskDate.Format("2006-01-02"),
+		sql.NullString{String: reason, Valid: reason != ""}, createdBy)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s 已经跳过了", taskDate.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// CancelSkip 取消跳过任务在某个任务日期的执行
+func (s *TaskService) CancelSkip(taskID int, taskDate time.Time) error {
+	query := `DELETE FROM task_occurrence_overrides WHERE task_id = $1 AND kind = $2 AND task_date = $3`
+	result, err := s.db.Exec(query, taskID, models.OverrideSkip, taskDate.Format("2006-01-02"))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s 没有被跳过", taskDate.Format("2006-01-02"))
+	}
+	return nil
+}
+
+// IsOccurrenceSkipped 任务在某个任务日期的执行是否已被跳过
+func (s *TaskService) IsOccurrenceSkipped(taskID int, taskDate time.Time) (bool, error) {
+	query := `
+		SELECT EXISTS(
+			SELECT 1 FROM task_occurrence_overrides
+			WHERE task_id = $1 AND kind = $2 AND task_date = $3
+		)
+	`
+	var skipped bool
+	err := s.db.QueryRow(query, taskID, models.OverrideSkip, taskDate.Format("2006-01-02")).Scan(&skipped)
+	return skipped, err
+}
+
+// SkippedDates 返回 [from, to] 内被跳过的任务日期（键为 2006-01-02）
+func (s *TaskService) SkippedDates(taskID int, from, to time.Time) (map[string]bool, error) {
+	query := `
+		SELECT task_date
+		FROM task_occurrence_overrides
+		WHERE task_id = $1 AND kind = $2 AND task_date >= $3 AND task_date <= $4
+	`
+	rows, err := s.db.Query(query, taskID, models.OverrideSkip, from.Format("2006-01-02"), to.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("查询跳过的日期失败: %w", err)
+	}
+	defer rows.Close()
+
+	skipped := make(map[string]bool)
+	for rows.Next() {
+		var date time.Time
+		if err := rows.Scan(&date); err != nil {
+			return nil, err
+		}
+		skipped[date.Format("2006-01-02")] = true
+	}
+	return skipped, rows.Err()
+}
+
+// SnoozeNextReminder 将任务的下一次提醒延后 minutes 分钟（替换尚未生效的延后设置）
+// 具体延后哪一次提醒在提醒触发时确定，见 ApplySnooze
+func (s *TaskService) SnoozeNextReminder(taskID, minutes int, createdBy, reason string) error {
+	if minutes <= 0 || minutes > maxSnoozeMinutes {
+		return fmt.Errorf("延后分钟数应在 1-%d 之间", maxSnoozeMinutes)
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	deleteQuery := `DELETE FROM task_occurrence_overrides WHERE task_id = $1 AND kind = $2 AND reminder_at IS NULL`
+	if _, err := tx.Exec(deleteQuery, taskID, models.OverrideSnooze); err != nil {
+		return err
+	}
+
+	insertQuery := `
+		INSERT INTO task_occurrence_overrides (task_id, kind, snooze_minutes, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+	if _, err := tx.Exec(insertQuery, taskID, models.OverrideSnooze, minutes,
+		sql.NullString{String: reason, Valid: reason != ""}, createdBy); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// ApplySnooze 查找并应用对原定在 reminderAt 发送的提醒的延后设置，返回延后后的发送时间
+// 设置之后第一次触发的提醒即为被延后的提醒；同一提醒再次触发（延后到期、重启补发）时返回同一结果
+func (s *TaskService) ApplySnooze(taskID int, reminderAt time.Time) (time.Time, bool, error) {
+	query := `
+		UPDATE task_occurrence_overrides
+		SET reminder_at = $3
+		WHERE id = (
+			SELECT id FROM task_occurrence_overrides
+			WHERE task_id = $1 AND kind = $2
+			  AND (reminder_at = $3 OR (reminder_at IS NULL AND created_at <= $3))
+			ORDER BY reminder_at NULLS LAST, created_at DESC
+			LIMIT 1
+		)
+		RETURNING id, task_id, kind, snooze_minutes, reminder_at, created_by, created_at
+	`
+
+	var override models.OccurrenceOverride
+	err := s.db.QueryRow(query, taskID, models.OverrideSnooze, reminderAt).Scan(
+		&override.ID, &override.TaskID, &override.Kind, &override.SnoozeMinutes,
+		&override.ReminderAt, &override.CreatedBy, &override.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return override.SnoozeUntil(), true, nil
+}
+
+// SnoozedReminders 返回已被延后、且延后后的发送时间不早于 since 的提醒的原定时间
+func (s *TaskService) SnoozedReminders(taskID int, since time.Time) ([]time.Time, error) {
+	query := `
+		SELECT reminder_at
+		FROM task_occurrence_overrides
+		WHERE task_id = $1 AND kind = $2 AND reminder_at IS NOT NULL
+		  AND reminder_at + snooze_minutes * INTERVAL '1 minute' >= $3
+		ORDER BY reminder_at
+	`
+	rows, err := s.db.Query(query, taskID, models.OverrideSnooze, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reminders []time.Time
+	for rows.Next() {
+		var at time.Time
+		if err := rows.Scan(&at); err != nil {
+			return nil, err
+		}
+		reminders = append(reminders, at)
+	}
+	return reminders, rows.Err()
+}
+
+// GetOccurrenceOverrides 获取任务从 since 起的跳过记录和尚未结束的延后设置
+func (s *TaskService) GetOccurrenceOverrides(taskID int, since time.Time) ([]models.OccurrenceOverride, error) {
+	query := `
+		SELECT id, task_id, kind, task_date, snooze_minutes, reminder_at, reason, created_by, created_at
+		FROM task_occurrence_overrides
+		WHERE task_id = $1
+		  AND ((kind = 'SKIP' AND task_date >= $2)
+		    OR (kind = 'SNOOZE' AND (reminder_at IS NULL OR reminder_at + snooze_minutes * INTERVAL '1 minute' >= $3)))
+		ORDER BY created_at
+	`
+
+	rows, err := s.db.Query(query, taskID, since.Format("2006-01-02"), since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var overrides []models.OccurrenceOverride
+	for rows.Next() {
+		var o models.OccurrenceOverride
+		if err := rows.Scan(&o.ID, &o.TaskID, &o.Kind, &o.TaskDate, &o.SnoozeMinutes, &o.ReminderAt, &o.Reason, &o.CreatedBy, &o.CreatedAt); err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, o)
+	}
+	return overrides, rows.Err()
+}
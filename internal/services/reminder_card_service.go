@@ -0,0 +1,225 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/dingtalk"
+	"dingteam-bot/internal/models"
+)
+
+// 提醒卡片上的按钮 ID 为 "<操作>:<任务ID>"，打卡与任务选择卡片共用 checkin
+const (
+	CardActionCheckIn = "checkin"
+	CardActionSnooze  = "snooze"
+	CardActionLeave   = "leave"
+)
+
+// 成员点击"稍后提醒"后，间隔该时长私聊提醒
+const MemberSnoozeDelay = 30 * time.Minute
+
+// 卡片上最多列出的未完成成员数
+const maxPendingNamesOnCard = 20
+
+// CardSender 互动卡片的发送与更新（钉钉）
+type CardSender interface {
+	SendInteractiveCardWithMentions(chatID, outTrackID, title, markdown string, actions []dingtalk.CardAction, atUsers map[string]string) error
+	UpdateInteractiveCard(outTrackID, title, markdown string, actions []dingtalk.CardAction) error
+}
+
+// ReminderCardService 以互动卡片发送任务提醒，成员打卡、请假后刷新卡片上的完成进度
+type ReminderCardService struct {
+	db     *sql.DB
+	tasks  *TaskService
+	sender CardSender
+}
+
+func NewReminderCardService(db *sql.DB, tasks *TaskService, sender CardSender) *ReminderCardService {
+	return &ReminderCardService{db: db, tasks: tasks, sender: sender}
+}
+
+// reminderCardActions 提醒卡片上的按钮
+func reminderCardActions(taskID int) []dingtalk.CardAction {
+	return []dingtalk.CardAction{
+		{ID: fmt.Sprintf("%s:%d", CardActionCheckIn, taskID), Text: "✅ 已完成", Primary: true},
+		{ID: fmt.Sprintf("%s:%d", CardActionSnooze, taskID), Text: "⏰ 稍后提醒"},
+		{ID: fmt.Sprintf("%s:%d", CardActionLeave, taskID), Text: "🙅 请假"},
+	}
+}
+
+// Send 发送任务某一期的提醒卡片并@未完成的成员，返回卡片的完整内容（用于提醒日志）
+func (s *ReminderCardService) Send(task models.Task, taskDate time.Time, title, body string, atUserIDs []string) (string, error) {
+	card := models.ReminderCard{
+		OutTrackID:  dingtalk.NewOutTrackID("remind"),
+		TaskID:      task.ID,
+		GroupChatID: task.GroupChatID,
+		TaskDate:    taskDate,
+		Title:       title,
+		Body:        body,
+	}
+
+	progress, err := s.progress(task, taskDate)
+	if err != nil {
+		return "", err
+	}
+	markdown := body + "\n\n" + progress
+
+	names, err := s.tasks.GetUserNames(atUserIDs)
+	if err != nil {
+		log.Printf("获取用户名失败: %v", err)
+	}
+	atUsers := make(map[string]string, len(atUserIDs))
+	for _, userID := range atUserIDs {
+		if name := names[userID]; name != "" {
+			atUsers[userID] = name
+		} else {
+			atUsers[userID] = userID
+		}
+	}
+
+	if err := s.sender.SendInteractiveCardWithMentions(task.GroupChatID, card.OutTrackID, title, markdown, reminderCardActions(task.ID), atUsers); err != nil {
+		return "", err
+	}
+
+	// 卡片已发出，登记失败时按钮仍可用（按当前这一期处理），只是无法刷新进度
+	query := `
+		INSERT INTO reminder_cards (out_track_id, task_id, group_chat_id, task_date, title, body)
+		VALUES ($1, $2, $3, $4, $5, $6)
+	`
+	if _, err := s.db.Exec(query, card.OutTrackID, card.TaskID, card.GroupChatID,
+		taskDate.Format("2006-01-02"), card.Title, card.Body); err != nil {
+		log.Printf("登记提醒卡片失败: %v", err)
+	}
+	return markdown, nil
+}
+
+// Get 按 outTrackID 查找提醒卡片，不是提醒卡片时返回 nil
+func (s *ReminderCardService) Get(outTrackID string) (*models.ReminderCard, error) {
+	query := `
+		SELECT out_track_id, task_id, group_chat_id, task_date, title, body, created_at
+		FROM reminder_cards
+		WHERE out_track_id = $1
+	`
+	var card models.ReminderCard
+	err := s.db.QueryRow(query, outTrackID).Scan(
+		&card.OutTrackID, &card.TaskID, &card.GroupChatID, &card.TaskDate, &card.Title, &card.Body, &card.CreatedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("查询提醒卡片失败: %w", err)
+	}
+	return &card, nil
+}
+
+// Refresh 更新任务某一期已发送的所有提醒卡片上的完成进度（失败只记录日志）
+func (s *ReminderCardService) Refresh(task models.Task, taskDate time.Time) {
+	query := `
+		SELECT out_track_id, title, body
+		FROM reminder_cards
+		WHERE task_id = $1 AND task_date = $2
+		ORDER BY created_at
+	`
+	rows, err := s.db.Query(query, task.ID, taskDate.Format("2006-01-02"))
+	if err != nil {
+		log.Printf("查询提醒卡片失败: %v", err)
+		return
+	}
+	var cards []models.ReminderCard
+	for rows.Next() {
+		var card models.ReminderCard
+		if err := rows.Scan(&card.OutTrackID, &card.Title, &card.Body); err == nil {
+			cards = append(cards, card)
+		}
+	}
+	rows.Close()
+	if len(cards) == 0 {
+		return
+	}
+
+	progress, err := s.progress(task, taskDate)
+	if err != nil {
+		log.Printf("统计提醒卡片进度失败: %v", err)
+		return
+	}
+
+	for _, card := range cards {
+		if err := s.sender.UpdateInteractiveCard(card.OutTrackID, card.Title, card.Body+"\n\n"+progress, reminderCardActions(task.ID)); err != nil {
+			log.Printf("更新提醒卡片失败 (%s): %v", card.OutTrackID, err)
+		}
+	}
+}
+
+// progress 卡片上的实时完成进度：已完成/未完成人数和未完成成员
+func (s *ReminderCardService) progress(task models.Task, taskDate time.Time) (string, error) {
+	members, err := s.tasks.GetResponsibleMembers(task, taskDate)
+	if err != nil {
+		return "", err
+	}
+	completed, err := s.tasks.GetCompletedUserIDs(task.ID, taskDate)
+	if err != nil {
+		return "", err
+	}
+
+	var pending []string
+	for _, member := range members {
+		if !completed[member.UserID] {
+			pending = append(pending, member.DisplayName())
+		}
+	}
+
+	text := fmt.Sprintf("👥 已完成 **%d** 人，未完成 **%d** 人", len(members)-len(pending), len(pending))
+	switch {
+	case len(members) > 0 && len(pending) == 0:
+		text += "\n\n🎉 全部完成！"
+	case len(pending) > maxPendingNamesOnCard:
+		text += fmt.Sprintf("\n\n⏳ 未完成: %s 等", strings.Join(pending[:maxPendingNamesOnCard], "、"))
+	case len(pending) > 0:
+		text += fmt.Sprintf("\n\n⏳ 未完成: %s", strings.Join(pending, "、"))
+	}
+	return text, nil
+}
+
+// SnoozeMember 成员稍后提醒：MemberSnoozeDelay 后私聊提醒（同一期重复点击以最后一次为准），返回提醒时间
+func (s *ReminderCardService) SnoozeMember(taskID int, userID string, taskDate time.Time) (time.Time, error) {
+	remindAt := time.Now().Add(MemberSnoozeDelay)
+	query := `
+		INSERT INTO member_snoozes (task_id, user_id, task_date, remind_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (task_id, user_id, task_date) DO UPDATE
+		SET remind_at = EXCLUDED.remind_at, sent_at = NULL
+	`
+	if _, err := s.db.Exec(query, taskID, userID, taskDate.Format("2006-01-02"), remindAt); err != nil {
+		return time.Time{}, fmt.Errorf("登记稍后提醒失败: %w", err)
+	}
+	return remindAt, nil
+}
+
+// ClaimDueSnoozes 取出已到时间的稍后提醒并标记为已发送（每条只会被取出一次）
+func (s *ReminderCardService) ClaimDueSnoozes(now time.Time) ([]models.MemberSnooze, error) {
+	query := `
+		UPDATE member_snoozes
+		SET sent_at = CURRENT_TIMESTAMP
+		WHERE sent_at IS NULL AND remind_at <= $1
+		RETURNING id, task_id, user_id, task_date, remind_at
+	`
+	rows, err := s.db.Query(query, now)
+	if err != nil {
+		return nil, fmt.Errorf("查询稍后提醒失败: %w", err)
+	}
+	defer rows.Close()
+
+	var snoozes []models.MemberSnooze
+	for rows.Next() {
+		var snooze models.MemberSnooze
+		if err := rows.Scan(&snooze.ID, &snooze.TaskID, &snooze.UserID, &snooze.TaskDate, &snooze.RemindAt); err != nil {
+			return nil, err
+		}
+		snoozes = append(snoozes, snooze)
+	}
+	return snoozes, rows.Err()
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// ReminderExclusionService 免提醒设置（与管理员角色无关，按人或按群配置，可选按钉钉部门主管标记）
+type ReminderExclusionService struct {
+	db *sql.DB
+
+	// 没有单独设置的钉钉部门主管是否免提醒
+	excludeDeptLeaders bool
+}
+
+func NewReminderExclusionService(db *sql.DB, excludeDeptLeaders bool) *ReminderExclusionService {
+	return &ReminderExclusionService{db: db, excludeDeptLeaders: excludeDeptLeaders}
+}
+
+// ExcludeDeptLeaders 是否按钉钉部门主管标记免提醒
+func (s *ReminderExclusionService) ExcludeDeptLeaders() bool {
+	return s.excludeDeptLeaders
+}
+
+// Set 设置成员在某个群（groupChatID 为空时为所有群）是否免提醒
+func (s *ReminderExclusionService) Set(userID, groupChatID string, excluded bool, reason, createdBy string) error {
+	if userID == "" {
+		return fmt.Errorf("缺少用户ID")
+	}
+
+	query := `
+		INSERT INTO reminder_exclusions (user_id, group_chat_id, excluded, reason, created_by)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, group_chat_id) DO UPDATE
+		SET excluded = EXCLUDED.excluded, reason = EXCLUDED.reason,
+		    created_by = EXCLUDED.created_by, updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := s.db.Exec(query, userID, groupChatID, excluded, sql.NullString{String: reason, Valid: reason != ""}, createdBy)
+	if err != nil {
+		return fmt.Errorf("保存免提醒设置失败: %w", err)
+	}
+	return nil
+}
+
+// Remove 删除成员在某个群（groupChatID 为空时为全局）的免提醒设置，恢复为默认规则
+func (s *ReminderExclusionService) Remove(userID, groupChatID string) error {
+	result, err := s.db.Exec(`DELETE FROM reminder_exclusions WHERE user_id = $1 AND group_chat_id = $2`, userID, groupChatID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("没有该成员的免提醒设置")
+	}
+	return nil
+}
+
+// List 列出对某个群生效的设置（群内设置和全局设置），groupChatID 为空时列出所有设置
+func (s *ReminderExclusionService) List(groupChatID string) ([]models.ReminderExclusion, error) {
+	query := `
+		SELECT user_id, group_chat_id, excluded, reason, created_by, created_at, updated_at
+		FROM reminder_exclusions
+		WHERE $1 = '' OR group_chat_id IN ($1, '')
+		ORDER BY group_chat_id, user_id
+	`
+	rows, err := s.db.Query(query, groupChatID)
+	if err != nil {
+		return nil, fmt.Errorf("获取免提醒设置失败: %w", err)
+	}
+	defer rows.Close()
+
+	var exclusions []models.ReminderExclusion
+	for rows.Next() {
+		var e models.ReminderExclusion
+		if err := rows.Scan(&e.UserID, &e.GroupChatID, &e.Excluded, &e.Reason, &e.CreatedBy, &e.CreatedAt, &e.UpdatedAt); err != nil {
+			return nil, err
+		}
+		exclusions = append(exclusions, e)
+	}
+	return exclusions, rows.Err()
+}
+
+// ExcludedUserIDs 返回 userIDs 中在该群免提醒的成员
+// 优先级: 群内设置 > 全局设置 > 钉钉部门主管标记（开启时） > 默认提醒
+func (s *ReminderExclusionService) ExcludedUserIDs(groupChatID string, userIDs []string) (map[string]bool, error) {
+	excluded := make(map[string]bool)
+	if len(userIDs) == 0 {
+		return excluded, nil
+	}
+
+	query := `
+		SELECT ids.user_id
+		FROM unnest($2::varchar[]) AS ids(user_id)
+		LEFT JOIN reminder_exclusions g ON g.user_id = ids.user_id AND g.group_chat_id = $1
+		LEFT JOIN reminder_exclusions a ON a.user_id = ids.user_id AND a.group_chat_id = ''
+		WHERE COALESCE(
+			g.excluded,
+			a.excluded,
+			$3 AND EXISTS (SELECT 1 FROM group_members m WHERE m.user_id = ids.user_id AND m.dept_leader),
+			FALSE
+		)
+	`
+	rows, err := s.db.Query(query, groupChatID, pq.Array(userIDs), s.excludeDeptLeaders)
+	if err != nil {
+		return nil, fmt.Errorf("获取免提醒成员失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var userID string
+		if err := rows.Scan(&userID); err != nil {
+			return nil, err
+		}
+		excluded[userID] = true
+	}
+	return excluded, rows.Err()
+}
@@ -0,0 +1,282 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
+)
+
+// 历史统计最多查询的天数
+const maxStatsDays = 366
+
+// expectedCTE 历史统计的应打卡人次：每个已截止的任务日期 × 截止时需要执行的成员，
+// 排除任务创建前的各期、被跳过的日期、豁免和请假，并关联打卡记录
+// 参数: $1 任务ID[]、$2 任务日期[]、$3 截止时间[]（一一对应），
+// $4 任务ID[]、$5 成员ID[]、$6 成员名称[]、$7 开始时间[]、$8 结束时间[]（一一对应，空字符串表示不限），$9 成员筛选
+const expectedCTE = `
+	WITH occurrences AS (
+		SELECT o.task_id, o.task_date, o.closes_at
+		FROM unnest($1::int[], $2::date[], $3::timestamptz[]) AS o(task_id, task_date, closes_at)
+		JOIN tasks t ON t.id = o.task_id
+		WHERE o.closes_at <= CURRENT_TIMESTAMP AND o.closes_at > t.created_at
+	), members AS (
+		SELECT m.task_id, m.user_id, m.user_name,
+		       NULLIF(m.since, '')::timestamp AS since, NULLIF(m.until, '')::timestamp AS until
+		FROM unnest($4::int[], $5::text[], $6::text[], $7::text[], $8::text[]) AS m(task_id, user_id, user_name, since, until)
+		WHERE $9::text = '' OR m.user_id = $9
+	), expected AS (
+		SELECT o.task_id, o.task_date, m.user_id, m.user_name,
+		       c.id IS NOT NULL AS completed,
+		       COALESCE(c.is_on_time, FALSE) AS on_time
+		FROM occurrences o
+		JOIN members m
+		  ON m.task_id = o.task_id
+		 AND (m.since IS NULL OR m.since <= o.closes_at)
+		 AND (m.until IS NULL OR m.until > o.closes_at)
+		LEFT JOIN completion_records c
+		  ON c.task_id = o.task_id AND c.user_id = m.user_id AND c.task_date = o.task_date
+		WHERE NOT EXISTS (
+			SELECT 1 FROM task_occurrence_overrides s
+			WHERE s.task_id = o.task_id AND s.kind = 'SKIP' AND s.task_date = o.task_date
+		) AND NOT EXISTS (
+			SELECT 1 FROM task_exemptions e
+			WHERE e.task_id = o.task_id AND e.user_id = m.user_id
+			  AND e.start_date <= o.task_date AND e.end_date >= o.task_date
+		) AND NOT EXISTS (
+			SELECT 1 FROM member_leaves l
+			WHERE (l.task_id IS NULL OR l.task_id = o.task_id) AND l.user_id = m.user_id
+			  AND l.start_date <= o.task_date AND l.end_date >= o.task_date
+		)
+	)
+`
+
+// GetHistoryStats 按任务、群、成员和日期范围统计完成率、按时率、连续完成期数和缺卡记录
+// 任务日期由调度规则在 Go 中展开，每期的应打卡成员和汇总全部在 SQL 中完成；
+// 只统计已过截止时间的周期，按群或全部任务统计时只包含任务型任务
+func (s *StatsService) GetHistoryStats(query models.StatsQuery) (*models.HistoryStats, error) {
+	if query.To.Before(query.From) {
+		return nil, fmt.Errorf("结束日期不能早于开始日期")
+	}
+	if query.To.Sub(query.From) > maxStatsDays*24*time.Hour {
+		return nil, fmt.Errorf("统计范围不能超过 %d 天", maxStatsDays)
+	}
+	if !query.Granularity.IsValid() {
+		return nil, fmt.Errorf("不支持的统计粒度: %s（可选 day、week、month）", query.Granularity)
+	}
+
+	tasks, err := s.statsTasks(query)
+	if err != nil {
+		return nil, err
+	}
+
+	args, taskNames, err := s.expectedArgs(tasks, query.From, query.To, query.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.HistoryStats{
+		From:        query.From,
+		To:          query.To,
+		Granularity: query.Granularity,
+	}
+	if result.Periods, err = s.periodStats(args, query.Granularity); err != nil {
+		return nil, err
+	}
+	if result.Users, err = s.userStats(args, taskNames); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// expectedArgs 将任务在 [from, to] 内的任务日期和负责人展开为 expectedCTE 的参数 $1-$9，
+// 同时返回任务名称（按任务ID）
+//
+// 任务日期由 cron 和节假日日历决定，只能在 Go 中展开；是否已截止、是否早于任务创建，
+// 以及每一期由谁执行（按入群、退群和被指定为负责人的时间）都在 SQL 中判断。
+// 部门成员和免提醒设置没有历史记录，按当前状态计算；已移除的负责人不计入以前的各期
+func (s *StatsService) expectedArgs(tasks []models.Task, from, to time.Time, userID string) ([]interface{}, map[int]string, error) {
+	// 展开为 (任务, 任务日期, 截止时间) 和 (任务, 成员, 期间) 两组数组传给 SQL
+	var occTaskIDs, memberTaskIDs []int64
+	var occDates, occCloses, memberIDs, memberNames, memberSince, memberUntil []string
+	taskNames := make(map[int]string, len(tasks))
+	for _, task := range tasks {
+		taskNames[task.ID] = task.Name
+		loc := s.taskService.Location(task)
+		dates, err := s.taskService.OccurrenceDates(task, dateIn(from, loc), dateIn(to, loc))
+		if err != nil {
+			return nil, nil, err
+		}
+		if len(dates) == 0 {
+			continue
+		}
+
+		periods, err := s.taskService.assignedMemberPeriods(task, dates[0])
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, date := range dates {
+			// 没有截止时间的任务在当天结束时截止
+			closes := s.taskService.DeadlineOn(task, date)
+			if closes.IsZero() {
+				closes = date.AddDate(0, 0, 1)
+			}
+			occTaskIDs = append(occTaskIDs, int64(task.ID))
+			occDates = append(occDates, date.Format("2006-01-02"))
+			occCloses = append(occCloses, closes.Format(time.RFC3339))
+		}
+		for _, p := range periods {
+			memberTaskIDs = append(memberTaskIDs, int64(task.ID))
+			memberIDs = append(memberIDs, p.member.UserID)
+			memberNames = append(memberNames, p.member.DisplayName())
+			memberSince = append(memberSince, formatPeriodBound(p.since))
+			memberUntil = append(memberUntil, formatPeriodBound(p.until))
+		}
+	}
+
+	args := []interface{}{
+		pq.Array(occTaskIDs), pq.Array(occDates), pq.Array(occCloses),
+		pq.Array(memberTaskIDs), pq.Array(memberIDs), pq.Array(memberNames), pq.Array(memberSince), pq.Array(memberUntil),
+		userID,
+	}
+	return args, taskNames, nil
+}
+
+// formatPeriodBound 成员期间的起止时间（与数据库中的 TIMESTAMP 列一致，不带时区），不限时为空字符串
+func formatPeriodBound(t sql.NullTime) string {
+	if !t.Valid {
+		return ""
+	}
+	return t.Time.Format("2006-01-02 15:04:05")
+}
+
+// statsTasks 参与统计的任务：指定任务时只统计该任务，否则为群内（或所有群）未删除的任务型任务
+func (s *StatsService) statsTasks(query models.StatsQuery) ([]models.Task, error) {
+	if query.TaskID != 0 {
+		task, err := s.taskService.GetTaskByID(query.TaskID)
+		if err != nil {
+			return nil, err
+		}
+		if query.GroupChatID != "" && task.GroupChatID != query.GroupChatID {
+			return nil, nil
+		}
+		return []models.Task{*task}, nil
+	}
+
+	rows, err := s.db.Query(`
+		SELECT `+taskColumns+`
+		FROM tasks
+		WHERE status <> $1 AND type = $2 AND ($3::text = '' OR group_chat_id = $3)
+		ORDER BY id
+	`, models.TaskStatusDeleted, models.TaskTypeTask, query.GroupChatID)
+	if err != nil {
+		return nil, fmt.Errorf("获取任务失败: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// periodStats 按天、周或月汇总应打卡人次、已打卡人次和按时人次
+func (s *StatsService) periodStats(args []interface{}, granularity models.StatsGranularity) ([]models.PeriodStats, error) {
+	query := expectedCTE + `
+		SELECT date_trunc($10::text, task_date)::date AS period_start,
+		       count(*),
+		       count(*) FILTER (WHERE completed),
+		       count(*) FILTER (WHERE on_time),
+		       COALESCE(round(100.0 * count(*) FILTER (WHERE completed) / NULLIF(count(*), 0), 1), 0)::float8,
+		       COALESCE(round(100.0 * count(*) FILTER (WHERE on_time) / NULLIF(count(*) FILTER (WHERE completed), 0), 1), 0)::float8
+		FROM expected
+		GROUP BY period_start
+		ORDER BY period_start
+	`
+	rows, err := s.db.Query(query, append(args, string(granularity))...)
+	if err != nil {
+		return nil, fmt.Errorf("统计完成率失败: %w", err)
+	}
+	defer rows.Close()
+
+	periods := []models.PeriodStats{}
+	for rows.Next() {
+		var p models.PeriodStats
+		if err := rows.Scan(&p.PeriodStart, &p.Expected, &p.Completed, &p.OnTime, &p.CompletionRate, &p.OnTimeRate); err != nil {
+			return nil, err
+		}
+		periods = append(periods, p)
+	}
+	return periods, rows.Err()
+}
+
+// userStats 按成员汇总完成率、连续完成期数（按任务日期排序的连续打卡）和缺卡的各期，完成率低的在前
+func (s *StatsService) userStats(args []interface{}, taskNames map[int]string) ([]models.UserStats, error) {
+	query := expectedCTE + `
+		, ranked AS (
+			SELECT *,
+			       row_number() OVER w AS seq,
+			       row_number() OVER w - row_number() OVER (PARTITION BY user_id, completed ORDER BY task_date, task_id) AS run
+			FROM expected
+			WINDOW w AS (PARTITION BY user_id ORDER BY task_date, task_id)
+		), streaks AS (
+			SELECT user_id, max(run_length) AS longest
+			FROM (SELECT user_id, count(*) AS run_length FROM ranked WHERE completed GROUP BY user_id, run) runs
+			GROUP BY user_id
+		), last_missed AS (
+			SELECT user_id, max(seq) AS seq FROM ranked WHERE NOT completed GROUP BY user_id
+		)
+		SELECT r.user_id, max(r.user_name),
+		       count(*),
+		       count(*) FILTER (WHERE r.completed),
+		       count(*) FILTER (WHERE r.on_time),
+		       COALESCE(round(100.0 * count(*) FILTER (WHERE r.completed) / NULLIF(count(*), 0), 1), 0)::float8 AS completion_rate,
+		       COALESCE(round(100.0 * count(*) FILTER (WHERE r.on_time) / NULLIF(count(*) FILTER (WHERE r.completed), 0), 1), 0)::float8,
+		       count(*) FILTER (WHERE r.completed AND r.seq > COALESCE(lm.seq, 0)),
+		       COALESCE(max(st.longest), 0),
+		       COALESCE(array_agg(r.task_id ORDER BY r.task_date, r.task_id) FILTER (WHERE NOT r.completed), '{}'),
+		       COALESCE(array_agg(to_char(r.task_date, 'YYYY-MM-DD') ORDER BY r.task_date, r.task_id) FILTER (WHERE NOT r.completed), '{}')
+		FROM ranked r
+		LEFT JOIN streaks st ON st.user_id = r.user_id
+		LEFT JOIN last_missed lm ON lm.user_id = r.user_id
+		GROUP BY r.user_id, lm.seq
+		ORDER BY completion_rate, max(r.user_name)
+	`
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("统计成员完成情况失败: %w", err)
+	}
+	defer rows.Close()
+
+	users := []models.UserStats{}
+	for rows.Next() {
+		var u models.UserStats
+		var missedTaskIDs []int64
+		var missedDates []string
+		if err := rows.Scan(
+			&u.UserID, &u.UserName, &u.Expected, &u.Completed, &u.OnTime, &u.CompletionRate, &u.OnTimeRate,
+			&u.CurrentStreak, &u.LongestStreak, pq.Array(&missedTaskIDs), pq.Array(&missedDates),
+		); err != nil {
+			return nil, err
+		}
+
+		u.Missed = make([]models.MissedPeriod, len(missedTaskIDs))
+		for i, taskID := range missedTaskIDs {
+			date, err := time.Parse("2006-01-02", missedDates[i])
+			if err != nil {
+				return nil, err
+			}
+			u.Missed[i] = models.MissedPeriod{TaskID: int(taskID), TaskName: taskNames[int(taskID)], TaskDate: date}
+		}
+		users = append(users, u)
+	}
+	return users, rows.Err()
+}
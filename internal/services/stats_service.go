@@ -3,92 +3,119 @@ package services
 import (
 	"database/sql"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"dingteam-bot/internal/models"
 )
 
 type StatsService struct {
-	db *sql.DB
+	db          *sql.DB
+	taskService *TaskService
 }
 
-func NewStatsService(db *sql.DB) *StatsService {
-	return &StatsService{db: db}
+func NewStatsService(db *sql.DB, taskService *TaskService) *StatsService {
+	return &StatsService{db: db, taskService: taskService}
 }
 
-// 获取任务今日统计
+// 获取任务当前周期的统计（每日任务即今日）
 func (s *StatsService) GetTodayStats(taskID int) (*models.TaskStats, error) {
-	today := time.Now().Format("2006-01-02")
-	
 	// 获取任务信息
-	var stats models.TaskStats
-	query := `
-		SELECT id, name, type
-		FROM tasks
-		WHERE id = $1
-	`
-	if err := s.db.QueryRow(query, taskID).Scan(&stats.TaskID, &stats.TaskName, &stats.TaskType); err != nil {
+	task, err := s.taskService.GetTaskByID(taskID)
+	if err != nil {
 		return nil, err
 	}
 
-	stats.TaskDate, _ = time.Parse("2006-01-02", today)
+	var stats models.TaskStats
+	stats.TaskID = task.ID
+	stats.TaskName = task.Name
+	stats.TaskType = task.Type
+	stats.TaskDate = s.taskService.TaskDate(*task, time.Now())
 
-	// 获取今日完成人数
-	completedQuery := `
-		SELECT COUNT(DISTINCT user_id), 
-		       COALESCE(array_agg(DISTINCT user_name), ARRAY[]::varchar[])
-		FROM completion_records
-		WHERE task_id = $1 AND task_date = $2
-	`
-	
-	var userNames []sql.NullString
-	if err := s.db.QueryRow(completedQuery, taskID, today).Scan(&stats.CompletedCount, &userNames); err != nil {
+	// 本期已跳过时不计算完成率
+	stats.Skipped, err = s.taskService.IsOccurrenceSkipped(task.ID, stats.TaskDate)
+	if err != nil {
 		return nil, err
 	}
 
-	// 转换用户名数组
-	for _, name := range userNames {
-		if name.Valid {
-			stats.CompletedUsers = append(stats.CompletedUsers, name.String)
-		}
+	// 按负责人（排除免提醒和被豁免的成员）计算完成情况
+	members, err := s.taskService.GetResponsibleMembers(*task, stats.TaskDate)
+	if err != nil {
+		return nil, err
+	}
+	completed, err := s.taskService.GetCompletedUserIDs(task.ID, stats.TaskDate)
+	if err != nil {
+		return nil, err
 	}
 
-	// 这里假设群成员数需要从其他地方获取，暂时设为固定值
-	// 实际场景需要调用钉钉 API 获取群成员列表
-	stats.TotalMembers = 10 // TODO: 从钉钉 API 获取实际人数
+	stats.TotalMembers = len(members)
+	for _, member := range members {
+		if completed[member.UserID] {
+			stats.CompletedCount++
+			stats.CompletedUsers = append(stats.CompletedUsers, member.DisplayName())
+		} else {
+			stats.PendingUsers = append(stats.PendingUsers, member.DisplayName())
+		}
+	}
 
-	if stats.TotalMembers > 0 {
+	if stats.TotalMembers > 0 && !stats.Skipped {
 		stats.CompletionRate = float64(stats.CompletedCount) / float64(stats.TotalMembers) * 100
 	}
 
+	// 打卡附带的提交内容（文字、链接、文件、图片）
+	stats.Submissions, err = s.taskService.GetSubmissions(task.ID, stats.TaskDate)
+	if err != nil {
+		return nil, err
+	}
+
 	return &stats, nil
 }
 
-// 获取本周统计
+// 获取本周统计（只统计应执行的日期，按节假日策略跳过或被管理员跳过的日期不计为未完成）
 func (s *StatsService) GetWeeklyStats(taskID int) ([]*models.TaskStats, error) {
-	// 获取本周一到今天的日期范围
-	now := time.Now()
+	task, err := s.taskService.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	// 获取本周一到今天的日期范围（按任务时区）
+	now := time.Now().In(s.taskService.Location(*task))
 	weekday := int(now.Weekday())
 	if weekday == 0 {
 		weekday = 7 // 周日转为 7
 	}
 	mondayOffset := weekday - 1
 	monday := now.AddDate(0, 0, -mondayOffset)
-	
-	startDate := monday.Format("2006-01-02")
-	endDate := now.Format("2006-01-02")
+
+	dates, err := s.taskService.OccurrenceDates(*task, monday, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// 被管理员跳过的日期不计入统计
+	if len(dates) > 0 {
+		skipped, err := s.taskService.SkippedDates(task.ID, dates[0], dates[len(dates)-1])
+		if err != nil {
+			return nil, err
+		}
+		dates = slices.DeleteFunc(dates, func(date time.Time) bool {
+			return skipped[date.Format("2006-01-02")]
+		})
+	}
+	if len(dates) == 0 {
+		return nil, nil
+	}
+
+	startDate := dates[0].Format("2006-01-02")
+	endDate := dates[len(dates)-1].Format("2006-01-02")
 
 	query := `
-		SELECT 
-			task_date,
-			COUNT(DISTINCT user_id) as completed_count,
-			COALESCE(array_agg(DISTINCT user_name), ARRAY[]::varchar[]) as user_names
+		SELECT task_date, user_id
 		FROM completion_records
-		WHERE task_id = $1 
-		  AND task_date >= $2 
+		WHERE task_id = $1
+		  AND task_date >= $2
 		  AND task_date <= $3
-		GROUP BY task_date
-		ORDER BY task_date
 	`
 
 	rows, err := s.db.Query(query, taskID, startDate, endDate)
@@ -97,21 +124,46 @@ func (s *StatsService) GetWeeklyStats(taskID int) ([]*models.TaskStats, error) {
 	}
 	defer rows.Close()
 
-	var statsList []*models.TaskStats
+	completed := make(map[string]map[string]bool)
 	for rows.Next() {
-		var stats models.TaskStats
-		var userNames []sql.NullString
-		
-		if err := rows.Scan(&stats.TaskDate, &stats.CompletedCount, &userNames); err != nil {
+		var taskDate time.Time
+		var userID string
+		if err := rows.Scan(&taskDate, &userID); err != nil {
+			return nil, err
+		}
+		day := taskDate.Format("2006-01-02")
+		if completed[day] == nil {
+			completed[day] = make(map[string]bool)
+		}
+		completed[day][userID] = true
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// 每个应执行的日期一条统计，按当天的负责人（排除免提醒和被豁免的成员）计算完成率
+	var statsList []*models.TaskStats
+	for _, date := range dates {
+		members, err := s.taskService.GetResponsibleMembers(*task, date)
+		if err != nil {
 			return nil, err
 		}
 
-		stats.TaskID = taskID
-		stats.TotalMembers = 10 // TODO: 从钉钉 API 获取
+		stats := &models.TaskStats{
+			TaskID:       taskID,
+			TaskName:     task.Name,
+			TaskType:     task.Type,
+			TaskDate:     date,
+			TotalMembers: len(members),
+		}
 
-		for _, name := range userNames {
-			if name.Valid {
-				stats.CompletedUsers = append(stats.CompletedUsers, name.String)
+		done := completed[date.Format("2006-01-02")]
+		for _, member := range members {
+			if done[member.UserID] {
+				stats.CompletedCount++
+				stats.CompletedUsers = append(stats.CompletedUsers, member.DisplayName())
+			} else {
+				stats.PendingUsers = append(stats.PendingUsers, member.DisplayName())
 			}
 		}
 
@@ -119,16 +171,16 @@ func (s *StatsService) GetWeeklyStats(taskID int) ([]*models.TaskStats, error) {
 			stats.CompletionRate = float64(stats.CompletedCount) / float64(stats.TotalMembers) * 100
 		}
 
-		statsList = append(statsList, &stats)
+		statsList = append(statsList, stats)
 	}
 
 	return statsList, nil
 }
 
-// 获取未完成名单
-func (s *StatsService) GetPendingUsers(taskID int, allUserIDs []string) ([]string, error) {
-	today := time.Now().Format("2006-01-02")
-	
+// 获取指定任务日期的未完成名单
+func (s *StatsService) GetPendingUsers(taskID int, taskDate time.Time, allUserIDs []string) ([]string, error) {
+	today := taskDate.Format("2006-01-02")
+
 	// 获取已完成的用户 ID
 	query := `
 		SELECT user_id
@@ -166,6 +218,9 @@ func (s *StatsService) GetPendingUsers(taskID int, allUserIDs []string) ([]strin
 func (s *StatsService) FormatStatsReport(stats *models.TaskStats) string {
 	report := fmt.Sprintf("📊 **%s 统计报告**\n\n", stats.TaskName)
 	report += fmt.Sprintf("📅 日期: %s\n", stats.TaskDate.Format("2006-01-02"))
+	if stats.Skipped {
+		report += "⏭️ 本期已跳过，不计入完成率\n"
+	}
 	report += fmt.Sprintf("👥 总人数: %d\n", stats.TotalMembers)
 	report += fmt.Sprintf("✅ 已完成: %d 人\n", stats.CompletedCount)
 	report += fmt.Sprintf("📈 完成率: %.1f%%\n\n", stats.CompletionRate)
@@ -183,7 +238,43 @@ func (s *StatsService) FormatStatsReport(stats *models.TaskStats) string {
 		for _, user := range stats.PendingUsers {
 			report += fmt.Sprintf("- %s\n", user)
 		}
+		report += "\n"
+	}
+
+	if len(stats.Submissions) > 0 {
+		report += "**提交内容：**\n"
+		report += formatSubmissions(stats.Submissions)
+	}
+
+	return report
+}
+
+// 提交内容的文字说明在报告中最多显示的字数
+const maxSubmissionTextRunes = 60
+
+// formatSubmissions 按成员列出提交内容（同一成员的多条合并为一行）
+func formatSubmissions(submissions []models.Submission) string {
+	var order []string
+	names := make(map[string]string)
+	items := make(map[string][]string)
+	for _, sub := range submissions {
+		if _, ok := items[sub.UserID]; !ok {
+			order = append(order, sub.UserID)
+			names[sub.UserID] = sub.UserID
+			if sub.UserName.Valid && sub.UserName.String != "" {
+				names[sub.UserID] = sub.UserName.String
+			}
+		}
+		text := DescribeSubmission(sub)
+		if runes := []rune(text); sub.Kind == models.SubmissionText && len(runes) > maxSubmissionTextRunes {
+			text = string(runes[:maxSubmissionTextRunes]) + "…"
+		}
+		items[sub.UserID] = append(items[sub.UserID], text)
 	}
 
+	var report string
+	for _, userID := range order {
+		report += fmt.Sprintf("- %s: %s\n", names[userID], strings.Join(items[userID], "、"))
+	}
 	return report
 }
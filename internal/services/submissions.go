@@ -0,0 +1,147 @@
+package services
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"dingteam-bot/internal/dingtalk"
+	"dingteam-bot/internal/models"
+)
+
+var linkPattern = regexp.MustCompile(`https?://[^\s<>"]+`)
+
+// ParseSubmissions 将打卡时附带的文字和附件转换为提交内容：文字中的链接单独记录，其余文字作为说明
+func ParseSubmissions(text string, attachments []dingtalk.Attachment) []models.Submission {
+	var submissions []models.Submission
+
+	for _, link := range linkPattern.FindAllString(text, -1) {
+		submissions = append(submissions, models.Submission{Kind: models.SubmissionLink, Content: link})
+	}
+	if note := strings.Join(strings.Fields(linkPattern.ReplaceAllString(text, " ")), " "); note != "" {
+		submissions = append(submissions, models.Submission{Kind: models.SubmissionText, Content: note})
+	}
+
+	for _, a := range attachments {
+		submission := models.Submission{
+			Kind:         attachmentKind(a.Type),
+			DownloadCode: sql.NullString{String: a.DownloadCode, Valid: true},
+			FileName:     sql.NullString{String: a.FileName, Valid: a.FileName != ""},
+		}
+		submissions = append(submissions, submission)
+	}
+	return submissions
+}
+
+// attachmentKind 钉钉消息类型对应的提交内容类型
+func attachmentKind(msgType string) models.SubmissionKind {
+	switch msgType {
+	case "file":
+		return models.SubmissionFile
+	case "video":
+		return models.SubmissionVideo
+	case "audio":
+		return models.SubmissionAudio
+	default:
+		return models.SubmissionPicture
+	}
+}
+
+// GetCompletionID 获取成员在任务日期的打卡记录ID，未打卡时返回 0
+func (s *TaskService) GetCompletionID(taskID int, userID string, taskDate time.Time) (int, error) {
+	query := `SELECT id FROM completion_records WHERE task_id = $1 AND user_id = $2 AND task_date = $3`
+
+	var id int
+	err := s.db.QueryRow(query, taskID, userID, taskDate.Format("2006-01-02")).Scan(&id)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// AddSubmissions 为一次打卡保存提交内容（可多次追加）
+func (s *TaskService) AddSubmissions(record *models.CompletionRecord, submissions []models.Submission) error {
+	if len(submissions) == 0 {
+		return nil
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO completion_submissions (
+			completion_id, task_id, user_id, task_date, kind, content, file_name, download_code
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+	for _, submission := range submissions {
+		if _, err := tx.Exec(query,
+			record.ID,
+			record.TaskID,
+			record.UserID,
+			record.TaskDate.Format("2006-01-02"),
+			submission.Kind,
+			submission.Content,
+			submission.FileName,
+			submission.DownloadCode,
+		); err != nil {
+			return fmt.Errorf("保存提交内容失败: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// GetSubmissions 获取任务在某个任务日期的所有提交内容（按成员、提交时间排序）
+func (s *TaskService) GetSubmissions(taskID int, taskDate time.Time) ([]models.Submission, error) {
+	query := `
+		SELECT s.id, s.completion_id, s.task_id, s.user_id, c.user_name, s.task_date,
+		       s.kind, s.content, s.file_name, s.download_code, s.created_at
+		FROM completion_submissions s
+		JOIN completion_records c ON c.id = s.completion_id
+		WHERE s.task_id = $1 AND s.task_date = $2
+		ORDER BY c.completed_at, s.id
+	`
+	rows, err := s.db.Query(query, taskID, taskDate.Format("2006-01-02"))
+	if err != nil {
+		return nil, fmt.Errorf("获取提交内容失败: %w", err)
+	}
+	defer rows.Close()
+
+	var submissions []models.Submission
+	for rows.Next() {
+		var sub models.Submission
+		if err := rows.Scan(
+			&sub.ID, &sub.CompletionID, &sub.TaskID, &sub.UserID, &sub.UserName, &sub.TaskDate,
+			&sub.Kind, &sub.Content, &sub.FileName, &sub.DownloadCode, &sub.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+		submissions = append(submissions, sub)
+	}
+	return submissions, rows.Err()
+}
+
+// DescribeSubmission 提交内容的简短描述（用于统计报告）
+func DescribeSubmission(sub models.Submission) string {
+	switch sub.Kind {
+	case models.SubmissionLink:
+		return fmt.Sprintf("[链接](%s)", sub.Content)
+	case models.SubmissionText:
+		return sub.Content
+	case models.SubmissionFile:
+		if sub.FileName.Valid {
+			return "📎 " + sub.FileName.String
+		}
+		return "📎 文件"
+	case models.SubmissionVideo:
+		return "🎬 视频"
+	case models.SubmissionAudio:
+		return "🎙️ 语音"
+	default:
+		return "🖼️ 图片"
+	}
+}
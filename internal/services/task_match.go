@@ -0,0 +1,91 @@
+package services
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"dingteam-bot/internal/models"
+)
+
+// MatchTasks 按用户输入从候选任务中选择：支持 "#12"/"12" 按ID、名称完全匹配和模糊匹配
+// 返回匹配的任务，selector 为空时返回全部候选，多于一个时需要用户进一步选择
+func MatchTasks(tasks []models.Task, selector string) []models.Task {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return tasks
+	}
+
+	// 按任务ID
+	if id, err := strconv.Atoi(strings.TrimPrefix(selector, "#")); err == nil {
+		for _, task := range tasks {
+			if task.ID == id {
+				return []models.Task{task}
+			}
+		}
+		if strings.HasPrefix(selector, "#") {
+			return nil
+		}
+	}
+
+	key := normalizeTaskName(selector)
+
+	// 名称完全匹配
+	for _, task := range tasks {
+		if normalizeTaskName(task.Name) == key {
+			return []models.Task{task}
+		}
+	}
+
+	// 名称包含关系（"周报" 匹配 "写周报"）
+	var matches []models.Task
+	for _, task := range tasks {
+		name := normalizeTaskName(task.Name)
+		if strings.Contains(name, key) || strings.Contains(key, name) {
+			matches = append(matches, task)
+		}
+	}
+	if len(matches) > 0 {
+		return matches
+	}
+
+	// 按共同字符数取最相近的任务（至少覆盖输入的一半字符）
+	best := 0
+	for _, task := range tasks {
+		score := commonRunes(key, normalizeTaskName(task.Name))
+		if score*2 < len([]rune(key)) || score < best {
+			continue
+		}
+		if score > best {
+			best, matches = score, nil
+		}
+		matches = append(matches, task)
+	}
+	return matches
+}
+
+// normalizeTaskName 忽略大小写和空白
+func normalizeTaskName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsSpace(r) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, name)
+}
+
+// commonRunes a 中有多少字符（按出现次数）也出现在 b 中
+func commonRunes(a, b string) int {
+	counts := make(map[rune]int)
+	for _, r := range b {
+		counts[r]++
+	}
+	common := 0
+	for _, r := range a {
+		if counts[r] > 0 {
+			counts[r]--
+			common++
+		}
+	}
+	return common
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"dingteam-bot/internal/models"
+)
+
+func TestMatchTasks(t *testing.T) {
+	tasks := []models.Task{
+		{ID: 3, Name: "写周报"},
+		{ID: 7, Name: "周报 汇总"},
+		{ID: 12, Name: "Daily Standup"},
+		{ID: 15, Name: "月度复盘"},
+		{ID: 20, Name: "2026"},
+	}
+
+	tests := []struct {
+		name     string
+		selector string
+		want     []int
+	}{
+		{name: "为空时返回全部", selector: "  ", want: []int{3, 7, 12, 15, 20}},
+		{name: "#ID", selector: "#12", want: []int{12}},
+		{name: "不带 # 的 ID", selector: "15", want: []int{15}},
+		{name: "#ID 不存在时不再按名称匹配", selector: "#99", want: nil},
+		{name: "数字不是 ID 时按名称匹配", selector: "2026", want: []int{20}},
+		{name: "完全匹配优先于包含", selector: "写周报", want: []int{3}},
+		{name: "完全匹配忽略大小写和空白", selector: "daily standup", want: []int{12}},
+		{name: "完全匹配忽略名称中的空白", selector: "周报汇总", want: []int{7}},
+		{name: "名称包含输入", selector: "standup", want: []int{12}},
+		{name: "输入包含名称", selector: "本周的月度复盘", want: []int{15}},
+		{name: "多个任务包含输入时需要选择", selector: "周报", want: []int{3, 7}},
+		{name: "按共同字符模糊匹配", selector: "月复盘", want: []int{15}},
+		{name: "共同字符不足一半时不匹配", selector: "季度总结", want: nil},
+		{name: "共同字符数相同时都返回", selector: "周报写汇", want: []int{3, 7}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := MatchTasks(tasks, tt.selector)
+			if len(got) != len(tt.want) {
+				t.Fatalf("MatchTasks(%q) 匹配 %d 个任务 %+v，期望 %v", tt.selector, len(got), got, tt.want)
+			}
+			for i, task := range got {
+				if task.ID != tt.want[i] {
+					t.Errorf("MatchTasks(%q)[%d] = #%d，期望 #%d", tt.selector, i, task.ID, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,359 @@
+package services
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"dingteam-bot/internal/models"
+
+	"github.com/robfig/cron/v3"
+)
+
+// 任务 cron 表达式解析器：支持标准 5 段（分 时 日 月 周）、带秒的 6 段以及 @daily 等描述符
+var cronParser = cron.NewParser(
+	cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
+)
+
+// ParseCronExpr 解析任务的 cron 表达式
+func ParseCronExpr(expr string) (cron.Schedule, error) {
+	return cronParser.Parse(expr)
+}
+
+// 周期任务最多执行次数的上限
+const maxTaskOccurrences = 1000
+
+// TaskSchedule 任务的截止时间序列（实现 cron.Schedule）
+//
+// 任务型：cron 每个有触发的日期产生一次截止，截止时刻为 deadline_time（未设置时为当天第一次触发时刻）；
+// 通知型：cron 的每次触发即为一次截止（触发时间）；
+// 单次任务：只有 due_at 一次截止。
+// 周期任务设置了开始日期、结束日期或执行次数时，只保留范围内的截止时间。
+type TaskSchedule struct {
+	task     models.Task
+	spec     cron.Schedule // 单次任务为 nil
+	location *time.Location
+	start    time.Time // 第一次截止不早于该日零点（零值为不限）
+	until    time.Time // 最后一次截止时间的上限（零值为不限）
+}
+
+// NewTaskSchedule 创建任务的截止时间序列
+func NewTaskSchedule(task models.Task, loc *time.Location) (*TaskSchedule, error) {
+	if task.IsOnce() {
+		if !task.DueAt.Valid {
+			return nil, fmt.Errorf("单次任务缺少截止时间")
+		}
+		return &TaskSchedule{task: task, location: loc, until: task.DueAt.Time.In(loc)}, nil
+	}
+
+	spec, err := ParseCronExpr(task.CronExpr)
+	if err != nil {
+		return nil, fmt.Errorf("解析 cron 表达式失败: %w", err)
+	}
+	// 未通过 CRON_TZ 指定时区的表达式按调度时区计算
+	if s, ok := spec.(*cron.SpecSchedule); ok && s.Location == time.Local {
+		s.Location = loc
+	}
+
+	schedule := &TaskSchedule{
+		task:     task,
+		spec:     spec,
+		location: loc,
+	}
+	if task.StartDate.Valid {
+		schedule.start = dateIn(task.StartDate.Time, loc)
+	}
+	schedule.applyLimits()
+	return schedule, nil
+}
+
+// WithCalendar 按任务的节假日策略过滤触发日期
+func (s *TaskSchedule) WithCalendar(calendar *CalendarService) *TaskSchedule {
+	if s.spec == nil {
+		return s
+	}
+	s.spec = calendar.Wrap(s.task.CalendarPolicy, s.spec, s.location)
+	// 执行次数按过滤后的日期计算
+	s.applyLimits()
+	return s
+}
+
+// applyLimits 根据结束日期和执行次数计算最后一次截止时间的上限
+func (s *TaskSchedule) applyLimits() {
+	s.until = time.Time{}
+	if s.task.EndDate.Valid {
+		s.until = dateIn(s.task.EndDate.Time, s.location).AddDate(0, 0, 1).Add(-time.Second)
+	}
+
+	// 执行次数从开始日期起计算
+	if s.task.MaxOccurrences <= 0 || s.start.IsZero() {
+		return
+	}
+	t := s.start.Add(-time.Second)
+	for i := 0; i < min(s.task.MaxOccurrences, maxTaskOccurrences); i++ {
+		if t = s.next(t); t.IsZero() {
+			return
+		}
+	}
+	if s.until.IsZero() || t.Before(s.until) {
+		s.until = t
+	}
+}
+
+// Next 返回 t 之后的下一个截止时间，没有时返回零值
+func (s *TaskSchedule) Next(t time.Time) time.Time {
+	t = t.In(s.location)
+	if s.spec == nil {
+		if s.until.After(t) {
+			return s.until
+		}
+		return time.Time{}
+	}
+
+	if !s.start.IsZero() && t.Before(s.start) {
+		t = s.start.Add(-time.Second)
+	}
+	next := s.next(t)
+	if !s.until.IsZero() && next.After(s.until) {
+		return time.Time{}
+	}
+	return next
+}
+
+// next 不考虑起止范围的下一个截止时间
+func (s *TaskSchedule) next(t time.Time) time.Time {
+	if s.task.Type != models.TaskTypeTask {
+		return s.spec.Next(t)
+	}
+
+	// 从 t 所在日期开始，逐个检查有触发的日期；最多需要看两个触发日
+	day := startOfDay(t)
+	for i := 0; i < 3; i++ {
+		fire := s.firstFireOnOrAfter(day)
+		if fire.IsZero() {
+			return time.Time{}
+		}
+		day = startOfDay(fire)
+		if deadline := s.deadlineOn(day, fire); deadline.After(t) {
+			return deadline
+		}
+		day = day.AddDate(0, 0, 1)
+	}
+
+	return time.Time{}
+}
+
+// TaskDate 返回时刻 t 所属的一期（打卡、统计和提醒卡片的"本期"）：上一期截止后仍算上一期，
+// 直到下一期的提醒开始（提醒计划中最早的一次提醒）才算下一期。
+// 每日任务在当天提醒开始后即为当天；每周五截止的周报在周六补交仍记到本周五，
+// 直到下周五提醒开始；单次任务为截止日期
+func (s *TaskSchedule) TaskDate(t time.Time) time.Time {
+	if s.spec == nil {
+		return startOfDay(s.until)
+	}
+
+	t = t.In(s.location)
+	next := s.PeriodDate(t)
+	fire := s.firstFireOnOrAfter(next)
+	if fire.IsZero() || !t.Before(s.remindFrom(next, fire)) {
+		return next
+	}
+	if prev := s.previousDate(next); !prev.IsZero() {
+		return prev
+	}
+	return next
+}
+
+// PeriodDate 返回 t 当天或之后的第一个触发日（命令中指定日期时，按该日期所在的周期换算）
+// 每周五的任务指定周三即为本周五；单次任务为截止日期
+func (s *TaskSchedule) PeriodDate(t time.Time) time.Time {
+	if s.spec == nil {
+		return startOfDay(s.until)
+	}
+
+	day := startOfDay(t.In(s.location))
+	if day.Before(s.start) {
+		day = s.start
+	}
+	fire := s.firstFireOnOrAfter(day)
+	if fire.IsZero() {
+		return day
+	}
+	return startOfDay(fire)
+}
+
+// remindFrom 返回任务日期 day 这一期开始提醒的时间（提醒计划中最早的提醒，不晚于截止时间）
+func (s *TaskSchedule) remindFrom(day, fire time.Time) time.Time {
+	deadline := s.deadlineOn(day, fire)
+	from := deadline
+	offsets, err := s.task.ReminderOffsets()
+	if err != nil {
+		return from
+	}
+	for _, offset := range offsets {
+		if at := offset.At(deadline); at.Before(from) {
+			from = at
+		}
+	}
+	return from
+}
+
+// previousDate 返回 before 之前最近的一个任务日期（最多向前查找一年），没有时返回零值
+func (s *TaskSchedule) previousDate(before time.Time) time.Time {
+	limit := before.AddDate(-1, 0, 0)
+	for day := before.AddDate(0, 0, -1); !day.Before(limit); day = day.AddDate(0, 0, -1) {
+		if !s.start.IsZero() && day.Before(s.start) {
+			break
+		}
+		// 从后往前第一个在 before 之前有触发的日期即为上一个任务日期
+		fire := s.firstFireOnOrAfter(day)
+		if fire.IsZero() {
+			break
+		}
+		if fire.Before(before) {
+			return startOfDay(fire)
+		}
+	}
+	return time.Time{}
+}
+
+// DeadlineOn 返回任务日期 date 当天的截止时间，该日期不在序列中时返回零值
+func (s *TaskSchedule) DeadlineOn(date time.Time) time.Time {
+	day := dateIn(date, s.location)
+	if s.spec == nil {
+		if startOfDay(s.until).Equal(day) {
+			return s.until
+		}
+		return time.Time{}
+	}
+
+	fire := s.firstFireOnOrAfter(day)
+	if fire.IsZero() || !startOfDay(fire).Equal(day) {
+		return time.Time{}
+	}
+	return s.deadlineOn(day, fire)
+}
+
+// Dates 返回 [from, to] 内的所有任务日期
+func (s *TaskSchedule) Dates(from, to time.Time) []time.Time {
+	end := startOfDay(to.In(s.location))
+	if !s.until.IsZero() && startOfDay(s.until).Before(end) {
+		end = startOfDay(s.until)
+	}
+
+	day := startOfDay(from.In(s.location))
+	if day.Before(s.start) {
+		day = s.start
+	}
+
+	if s.spec == nil {
+		if due := startOfDay(s.until); !due.Before(day) && !due.After(end) {
+			return []time.Time{due}
+		}
+		return nil
+	}
+
+	var dates []time.Time
+	for !day.After(end) {
+		fire := s.firstFireOnOrAfter(day)
+		if fire.IsZero() {
+			break
+		}
+		day = startOfDay(fire)
+		if day.After(end) {
+			break
+		}
+		dates = append(dates, day)
+		day = day.AddDate(0, 0, 1)
+	}
+	return dates
+}
+
+// firstFireOnOrAfter 返回 day 零点及之后的第一次 cron 触发时间
+func (s *TaskSchedule) firstFireOnOrAfter(day time.Time) time.Time {
+	return s.spec.Next(day.Add(-time.Second))
+}
+
+// deadlineOn 计算某个触发日的截止时间（deadline_time 为不带时区的 TIME，只取时分）
+func (s *TaskSchedule) deadlineOn(day, fire time.Time) time.Time {
+	if !s.task.DeadlineTime.Valid {
+		return fire
+	}
+	clock := s.task.DeadlineTime.Time
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), 0, 0, s.location)
+}
+
+// 已加载的时区（time.LoadLocation 每次都会读取时区文件）
+var locations sync.Map
+
+// LoadLocation 按 IANA 名称加载时区（如 Asia/Singapore、Europe/Berlin），结果会被缓存
+func LoadLocation(name string) (*time.Location, error) {
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+	invalid := fmt.Errorf("无效的时区 %q，应为 IANA 名称，如 Asia/Shanghai", name)
+	if name == "" || name == "Local" {
+		return nil, invalid
+	}
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, invalid
+	}
+	locations.Store(name, loc)
+	return loc, nil
+}
+
+// dateIn 将 DATE 列的日期（按 UTC 零点扫描）转换为 loc 时区的当天零点
+func dateIn(date time.Time, loc *time.Location) time.Time {
+	y, m, d := date.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// ParseDueAt 解析单次任务的截止时间，支持 RFC3339、"2006-01-02 15:04" 和 "01-02 15:04"（今年）
+func ParseDueAt(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.In(loc), nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04", "2006/01/02 15:04"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("01-02 15:04", value, loc); err == nil {
+		return t.AddDate(time.Now().In(loc).Year(), 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的截止时间 %q，格式应为 2006-01-02 15:04", value)
+}
+
+// ParseDeadlineTime 解析每日截止时刻，支持 "15:04" 和 "15:04:05"
+func ParseDeadlineTime(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"15:04", "15:04:05"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("截止时间格式应为 HH:MM")
+}
+
+// ParseTaskDate 解析开始/结束日期，支持 "2006-01-02" 和 "01-02"（今年）
+func ParseTaskDate(value string, loc *time.Location) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range []string{"2006-01-02", "2006/01/02"} {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+	if t, err := time.ParseInLocation("01-02", value, loc); err == nil {
+		return t.AddDate(time.Now().In(loc).Year(), 0, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("无效的日期 %q，格式应为 2006-01-02", value)
+}
+
+// startOfDay 返回 t 所在日期的零点
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
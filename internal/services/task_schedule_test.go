@@ -0,0 +1,78 @@
+package services
+
+import (
+	"database/sql"
+	"testing"
+	"time"
+
+	"dingteam-bot/internal/models"
+)
+
+func TestTaskScheduleTaskDate(t *testing.T) {
+	loc := mustLocation(t)
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 10, day, hour, minute, 0, 0, loc)
+	}
+	date := func(day int) time.Time {
+		return at(day, 0, 0)
+	}
+	deadline := sql.NullTime{Time: time.Date(0, 1, 1, 18, 0, 0, 0, time.UTC), Valid: true}
+
+	weekly := models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 5", DeadlineTime: deadline}
+	daily := models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * *", DeadlineTime: deadline}
+	// 从 2026-10-20（周二）开始的周报，之前没有任何一期
+	started := weekly
+	started.StartDate = sql.NullTime{Time: time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC), Valid: true}
+	// 提前一天 18:00 开始提醒的周报
+	eveBefore := weekly
+	eveBefore.ReminderPlan = []string{"-1d 18:00", "deadline"}
+
+	// 2026-10-16、10-23 为周五，默认提醒计划在截止当天 10:00 开始提醒
+	tests := []struct {
+		name string
+		task models.Task
+		at   time.Time
+		want time.Time
+	}{
+		{name: "周报截止前", task: weekly, at: at(16, 17, 0), want: date(16)},
+		{name: "周报周六补交仍记到本周五", task: weekly, at: at(17, 10, 0), want: date(16)},
+		{name: "周报下周三仍记到本周五", task: weekly, at: at(21, 15, 0), want: date(16)},
+		{name: "下周五提醒开始前", task: weekly, at: at(23, 9, 59), want: date(16)},
+		{name: "下周五提醒开始后", task: weekly, at: at(23, 10, 0), want: date(23)},
+		{name: "提前一天开始提醒", task: eveBefore, at: at(22, 18, 0), want: date(23)},
+		{name: "提前一天提醒开始前", task: eveBefore, at: at(22, 17, 0), want: date(16)},
+		{name: "日报截止后当天", task: daily, at: at(17, 20, 0), want: date(17)},
+		{name: "日报次日提醒开始前", task: daily, at: at(18, 9, 30), want: date(17)},
+		{name: "日报次日提醒开始后", task: daily, at: at(18, 10, 0), want: date(18)},
+		{name: "开始日期之前没有上一期", task: started, at: at(21, 9, 0), want: date(23)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := NewTaskSchedule(tt.task, loc)
+			if err != nil {
+				t.Fatalf("NewTaskSchedule() error = %v", err)
+			}
+			if got := schedule.TaskDate(tt.at); !got.Equal(tt.want) {
+				t.Errorf("TaskDate(%s) = %s, want %s", tt.at.Format("01-02 15:04"), got.Format("2006-01-02"), tt.want.Format("2006-01-02"))
+			}
+		})
+	}
+}
+
+func TestTaskSchedulePeriodDate(t *testing.T) {
+	loc := mustLocation(t)
+	task := models.Task{Type: models.TaskTypeTask, CronExpr: "0 9 * * 5"}
+	schedule, err := NewTaskSchedule(task, loc)
+	if err != nil {
+		t.Fatalf("NewTaskSchedule() error = %v", err)
+	}
+
+	// 指定日期按所在周期换算：周六为下周五，周五为当天
+	for _, tt := range []struct{ day, want int }{{17, 23}, {21, 23}, {23, 23}} {
+		got := schedule.PeriodDate(time.Date(2026, 10, tt.day, 0, 0, 0, 0, loc))
+		if want := time.Date(2026, 10, tt.want, 0, 0, 0, 0, loc); !got.Equal(want) {
+			t.Errorf("PeriodDate(10-%02d) = %s, want %s", tt.day, got.Format("2006-01-02"), want.Format("2006-01-02"))
+		}
+	}
+}
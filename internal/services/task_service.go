@@ -2,19 +2,58 @@ package services
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"time"
 
 	"dingteam-bot/internal/models"
+
+	"github.com/lib/pq"
 )
 
+// ErrTaskNotFound 任务不存在（或已删除）
+var ErrTaskNotFound = errors.New("任务不存在")
+
 type TaskService struct {
-	db                      *sql.DB
-	onTaskCreatedCallback   func(models.Task) // 任务创建后的回调
+	db                    *sql.DB
+	location              *time.Location            // 默认时区（任务和群都未设置时区时使用）
+	calendar              *CalendarService          // 节假日日历
+	groups                *GroupSettingsService     // 群设置（默认时区）
+	members               *GroupMemberService       // 群成员
+	exclusions            *ReminderExclusionService // 免提醒设置
+	onTaskCreatedCallback func(models.Task)         // 任务创建后的回调
+}
+
+func NewTaskService(db *sql.DB, loc *time.Location, calendar *CalendarService, groups *GroupSettingsService, members *GroupMemberService, exclusions *ReminderExclusionService) *TaskService {
+	return &TaskService{db: db, location: loc, calendar: calendar, groups: groups, members: members, exclusions: exclusions}
 }
 
-func NewTaskService(db *sql.DB) *TaskService {
-	return &TaskService{db: db}
+// 查询任务时的列，与 scanTask 的顺序一致
+const taskColumns = `
+	id, name, description, type, cron_expr, deadline_time, advance_minutes,
+	group_chat_id, group_chat_name, creator_user_id, creator_name, status,
+	created_at, updated_at, last_run_at, next_run_at, calendar_policy,
+	schedule_kind, due_at, start_date, end_date, max_occurrences, timezone
+`
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// 按 taskColumns 的顺序扫描一行任务
+func scanTask(row rowScanner) (models.Task, error) {
+	var task models.Task
+	err := row.Scan(
+		&task.ID, &task.Name, &task.Description, &task.Type, &task.CronExpr,
+		&task.DeadlineTime, &task.AdvanceMinutes, &task.GroupChatID, &task.GroupChatName,
+		&task.CreatorUserID, &task.CreatorName, &task.Status,
+		&task.CreatedAt, &task.UpdatedAt, &task.LastRunAt, &task.NextRunAt, &task.CalendarPolicy,
+		&task.ScheduleKind, &task.DueAt, &task.StartDate, &task.EndDate, &task.MaxOccurrences, &task.Timezone,
+	)
+	return task, err
 }
 
 // SetOnTaskCreatedCallback 设置任务创建后的回调函数
@@ -24,15 +63,42 @@ func (s *TaskService) SetOnTaskCreatedCallback(callback func(models.Task)) {
 
 // 创建任务
 func (s *TaskService) CreateTask(task *models.Task) error {
+	if task.Timezone != "" {
+		if _, err := LoadLocation(task.Timezone); err != nil {
+			return err
+		}
+	}
+	if err := s.validateSchedule(task); err != nil {
+		return err
+	}
+	offsets, err := models.ParseReminderPlan(task.ReminderPlan)
+	if err != nil {
+		return err
+	}
+	policy, err := models.ParseCalendarPolicy(string(task.CalendarPolicy))
+	if err != nil {
+		return err
+	}
+	task.CalendarPolicy = policy
+
 	query := `
 		INSERT INTO tasks (
 			name, description, type, cron_expr, deadline_time, advance_minutes,
-			group_chat_id, group_chat_name, creator_user_id, creator_name, status
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			group_chat_id, group_chat_name, creator_user_id, creator_name, status,
+			calendar_policy, schedule_kind, due_at, start_date, end_date, max_occurrences,
+			timezone
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18)
 		RETURNING id, created_at, updated_at
 	`
 
-	err := s.db.QueryRow(
+	// 任务和提醒计划在同一事务中写入，避免留下没有提醒计划的任务
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRow(
 		query,
 		task.Name,
 		task.Description,
@@ -45,12 +111,26 @@ func (s *TaskService) CreateTask(task *models.Task) error {
 		task.CreatorUserID,
 		task.CreatorName,
 		task.Status,
+		task.CalendarPolicy,
+		task.ScheduleKind,
+		task.DueAt,
+		nullDate(task.StartDate),
+		nullDate(task.EndDate),
+		task.MaxOccurrences,
+		task.Timezone,
 	).Scan(&task.ID, &task.CreatedAt, &task.UpdatedAt)
 
 	if err != nil {
 		return err
 	}
 
+	if err := insertReminderPlan(tx, task.ID, offsets); err != nil {
+		return fmt.Errorf("保存提醒计划失败: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
 	// 任务创建成功后，调用回调函数（如果已设置）
 	if s.onTaskCreatedCallback != nil {
 		s.onTaskCreatedCallback(*task)
@@ -59,18 +139,207 @@ func (s *TaskService) CreateTask(task *models.Task) error {
 	return nil
 }
 
+// validateSchedule 校验并规范化任务的调度方式
+// 未指定调度方式时，只设置了 due_at 的任务视为单次任务
+func (s *TaskService) validateSchedule(task *models.Task) error {
+	loc := s.Location(*task)
+	if task.ScheduleKind == "" {
+		task.ScheduleKind = models.ScheduleKindCron
+		if task.CronExpr == "" && task.DueAt.Valid {
+			task.ScheduleKind = models.ScheduleKindOnce
+		}
+	}
+
+	switch task.ScheduleKind {
+	case models.ScheduleKindOnce:
+		if !task.DueAt.Valid {
+			return fmt.Errorf("单次任务需要设置截止时间 due_at")
+		}
+		if !task.DueAt.Time.After(time.Now()) {
+			return fmt.Errorf("截止时间 %s 已过", task.DueAt.Time.In(loc).Format("2006-01-02 15:04"))
+		}
+		if task.StartDate.Valid || task.EndDate.Valid || task.MaxOccurrences != 0 {
+			return fmt.Errorf("单次任务不能设置开始日期、结束日期或执行次数")
+		}
+		task.CronExpr = ""
+		return nil
+
+	case models.ScheduleKindCron:
+		if _, err := ParseCronExpr(task.CronExpr); err != nil {
+			return fmt.Errorf("cron 表达式无效: %w", err)
+		}
+		if task.DueAt.Valid {
+			return fmt.Errorf("周期任务不能设置 due_at，请使用 end_date 或 max_occurrences")
+		}
+		if task.StartDate.Valid && task.EndDate.Valid && dateIn(task.EndDate.Time, loc).Before(dateIn(task.StartDate.Time, loc)) {
+			return fmt.Errorf("结束日期不能早于开始日期")
+		}
+		if task.MaxOccurrences < 0 || task.MaxOccurrences > maxTaskOccurrences {
+			return fmt.Errorf("执行次数应在 0-%d 之间", maxTaskOccurrences)
+		}
+		// 执行次数从开始日期起计算，未指定时从今天开始
+		if task.MaxOccurrences > 0 && !task.StartDate.Valid {
+			task.StartDate = sql.NullTime{Time: startOfDay(time.Now().In(loc)), Valid: true}
+		}
+		return nil
+	}
+
+	return fmt.Errorf("未知的调度方式: %s", task.ScheduleKind)
+}
+
+// ParseDueAt 按任务的时区解析单次任务的截止时间（任务需已设置群ID和时区）
+func (s *TaskService) ParseDueAt(task models.Task, value string) (time.Time, error) {
+	return ParseDueAt(value, s.Location(task))
+}
+
+// ParseTaskDate 按任务的时区解析开始/结束日期
+func (s *TaskService) ParseTaskDate(task models.Task, value string) (time.Time, error) {
+	return ParseTaskDate(value, s.Location(task))
+}
+
+// Today 任务时区的今天零点
+func (s *TaskService) Today(task models.Task) time.Time {
+	return startOfDay(time.Now().In(s.Location(task)))
+}
+
+// Location 任务使用的时区：任务自身的时区 > 群默认时区 > 全局默认时区
+func (s *TaskService) Location(task models.Task) *time.Location {
+	for _, name := range []string{task.Timezone, s.groups.Timezone(task.GroupChatID)} {
+		if name == "" {
+			continue
+		}
+		if loc, err := LoadLocation(name); err == nil {
+			return loc
+		}
+	}
+	return s.location
+}
+
+// DescribeSchedule 描述任务的执行时间，如 "0 17 * * 5 · 2026-10-19 起 · 共 10 次" 或 "单次 · 截止 2026-10-23 18:00"
+func (s *TaskService) DescribeSchedule(task models.Task) string {
+	if task.IsOnce() {
+		return fmt.Sprintf("单次 · 截止 %s", task.DueAt.Time.In(s.Location(task)).Format("2006-01-02 15:04"))
+	}
+
+	parts := []string{task.CronExpr}
+	if task.StartDate.Valid {
+		parts = append(parts, task.StartDate.Time.Format("2006-01-02")+" 起")
+	}
+	if task.EndDate.Valid {
+		parts = append(parts, "至 "+task.EndDate.Time.Format("2006-01-02"))
+	}
+	if task.MaxOccurrences > 0 {
+		parts = append(parts, fmt.Sprintf("共 %d 次", task.MaxOccurrences))
+	}
+	return strings.Join(parts, " · ")
+}
+
+// nullDate 将日期转换为 DATE 列的参数
+func nullDate(date sql.NullTime) interface{} {
+	if !date.Valid {
+		return nil
+	}
+	return date.Time.Format("2006-01-02")
+}
+
+// 根据 ID 获取任务
+func (s *TaskService) GetTaskByID(taskID int) (*models.Task, error) {
+	query := `SELECT ` + taskColumns + ` FROM tasks WHERE id = $1`
+
+	task, err := scanTask(s.db.QueryRow(query, taskID))
+	if err == sql.ErrNoRows {
+		return nil, ErrTaskNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tasks := []models.Task{task}
+	if err := s.loadReminderPlans(tasks); err != nil {
+		return nil, err
+	}
+
+	return &tasks[0], nil
+}
+
+// Schedule 返回任务按节假日策略过滤后的截止时间序列
+func (s *TaskService) Schedule(task models.Task) (*TaskSchedule, error) {
+	schedule, err := NewTaskSchedule(task, s.Location(task))
+	if err != nil {
+		return nil, err
+	}
+	return schedule.WithCalendar(s.calendar), nil
+}
+
+// 计算时刻 t 所属的一期（周任务、月任务按周期而不是自然日打卡，逾期的一期在下一期提醒开始前仍为本期）
+func (s *TaskService) TaskDate(task models.Task, t time.Time) time.Time {
+	schedule, err := s.Schedule(task)
+	if err != nil {
+		return startOfDay(t.In(s.Location(task)))
+	}
+	return schedule.TaskDate(t)
+}
+
+// PeriodDate 日期 date 所在周期的任务日期（当天或之后的第一个触发日）
+func (s *TaskService) PeriodDate(task models.Task, date time.Time) time.Time {
+	schedule, err := s.Schedule(task)
+	if err != nil {
+		return startOfDay(date.In(s.Location(task)))
+	}
+	return schedule.PeriodDate(date)
+}
+
+// CheckInDate 成员在时刻 t 打卡所属的一期：逾期的上一期成员已经完成时，记到下一期（提前完成）
+func (s *TaskService) CheckInDate(task models.Task, userID string, t time.Time) (time.Time, error) {
+	taskDate := s.TaskDate(task, t)
+	next := s.PeriodDate(task, t)
+	if taskDate.Equal(next) {
+		return taskDate, nil
+	}
+
+	completed, err := s.HasCompleted(task.ID, userID, taskDate)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if completed {
+		return next, nil
+	}
+	return taskDate, nil
+}
+
+// DeadlineOn 任务在某个任务日期的截止时间（按任务时区），该日期没有截止时返回零值
+func (s *TaskService) DeadlineOn(task models.Task, taskDate time.Time) time.Time {
+	schedule, err := s.Schedule(task)
+	if err != nil {
+		return time.Time{}
+	}
+	return schedule.DeadlineOn(taskDate)
+}
+
+// 返回 [from, to] 内任务应执行的日期（节假日按任务策略排除）
+func (s *TaskService) OccurrenceDates(task models.Task, from, to time.Time) ([]time.Time, error) {
+	schedule, err := s.Schedule(task)
+	if err != nil {
+		return nil, err
+	}
+	return schedule.Dates(from, to), nil
+}
+
 // 获取群组的活跃任务
 func (s *TaskService) GetActiveTasksByGroup(groupChatID string) ([]models.Task, error) {
+	return s.GetTasksByGroup(groupChatID, models.TaskStatusActive)
+}
+
+// 获取群组中指定状态的任务
+func (s *TaskService) GetTasksByGroup(groupChatID string, status models.TaskStatus) ([]models.Task, error) {
 	query := `
-		SELECT id, name, description, type, cron_expr, deadline_time, advance_minutes,
-			   group_chat_id, group_chat_name, creator_user_id, creator_name, status,
-			   created_at, updated_at, last_run_at, next_run_at
+		SELECT ` + taskColumns + `
 		FROM tasks
-		WHERE group_chat_id = $1 AND status = 'ACTIVE'
+		WHERE group_chat_id = $1 AND status = $2
 		ORDER BY created_at DESC
 	`
 
-	rows, err := s.db.Query(query, groupChatID)
+	rows, err := s.db.Query(query, groupChatID, status)
 	if err != nil {
 		return nil, err
 	}
@@ -78,27 +347,24 @@ func (s *TaskService) GetActiveTasksByGroup(groupChatID string) ([]models.Task,
 
 	var tasks []models.Task
 	for rows.Next() {
-		var task models.Task
-		if err := rows.Scan(
-			&task.ID, &task.Name, &task.Description, &task.Type, &task.CronExpr,
-			&task.DeadlineTime, &task.AdvanceMinutes, &task.GroupChatID, &task.GroupChatName,
-			&task.CreatorUserID, &task.CreatorName, &task.Status,
-			&task.CreatedAt, &task.UpdatedAt, &task.LastRunAt, &task.NextRunAt,
-		); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			return nil, err
 		}
 		tasks = append(tasks, task)
 	}
 
+	if err := s.loadReminderPlans(tasks); err != nil {
+		return nil, err
+	}
+
 	return tasks, nil
 }
 
 // 获取所有需要执行的任务
 func (s *TaskService) GetPendingTasks() ([]models.Task, error) {
 	query := `
-		SELECT id, name, description, type, cron_expr, deadline_time, advance_minutes,
-			   group_chat_id, group_chat_name, creator_user_id, creator_name, status,
-			   created_at, updated_at, last_run_at, next_run_at
+		SELECT ` + taskColumns + `
 		FROM tasks
 		WHERE status = 'ACTIVE'
 		ORDER BY created_at DESC
@@ -112,21 +378,268 @@ func (s *TaskService) GetPendingTasks() ([]models.Task, error) {
 
 	var tasks []models.Task
 	for rows.Next() {
-		var task models.Task
-		if err := rows.Scan(
-			&task.ID, &task.Name, &task.Description, &task.Type, &task.CronExpr,
-			&task.DeadlineTime, &task.AdvanceMinutes, &task.GroupChatID, &task.GroupChatName,
-			&task.CreatorUserID, &task.CreatorName, &task.Status,
-			&task.CreatedAt, &task.UpdatedAt, &task.LastRunAt, &task.NextRunAt,
-		); err != nil {
+		task, err := scanTask(rows)
+		if err != nil {
 			return nil, err
 		}
 		tasks = append(tasks, task)
 	}
 
+	if err := s.loadReminderPlans(tasks); err != nil {
+		return nil, err
+	}
+
 	return tasks, nil
 }
 
+// 批量加载任务的提醒计划
+func (s *TaskService) loadReminderPlans(tasks []models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	ids := make([]int64, len(tasks))
+	index := make(map[int]int, len(tasks))
+	for i, task := range tasks {
+		ids[i] = int64(task.ID)
+		index[task.ID] = i
+	}
+
+	query := `
+		SELECT task_id, offset_spec
+		FROM task_reminder_plans
+		WHERE task_id = ANY($1)
+		ORDER BY task_id, position
+	`
+
+	rows, err := s.db.Query(query, pq.Array(ids))
+	if err != nil {
+		return fmt.Errorf("获取提醒计划失败: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID int
+		var spec string
+		if err := rows.Scan(&taskID, &spec); err != nil {
+			return err
+		}
+		if i, ok := index[taskID]; ok {
+			tasks[i].ReminderPlan = append(tasks[i].ReminderPlan, spec)
+		}
+	}
+
+	return rows.Err()
+}
+
+// 获取任务的提醒计划（按顺序）
+func (s *TaskService) GetReminderPlan(taskID int) ([]models.ReminderPlanItem, error) {
+	query := `
+		SELECT id, task_id, position, offset_spec, created_at
+		FROM task_reminder_plans
+		WHERE task_id = $1
+		ORDER BY position
+	`
+
+	rows, err := s.db.Query(query, taskID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []models.ReminderPlanItem
+	for rows.Next() {
+		var item models.ReminderPlanItem
+		if err := rows.Scan(&item.ID, &item.TaskID, &item.Position, &item.OffsetSpec, &item.CreatedAt); err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+// 设置任务的提醒计划（整体替换，传空列表则恢复默认计划）
+func (s *TaskService) SetReminderPlan(taskID int, plan []string) error {
+	offsets, err := models.ParseReminderPlan(plan)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM task_reminder_plans WHERE task_id = $1`, taskID); err != nil {
+		return err
+	}
+
+	if err := insertReminderPlan(tx, taskID, offsets); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	s.notifyTaskChanged(taskID)
+	return nil
+}
+
+// insertReminderPlan 按顺序写入任务的提醒计划
+func insertReminderPlan(tx *sql.Tx, taskID int, offsets []models.ReminderOffset) error {
+	query := `INSERT INTO task_reminder_plans (task_id, position, offset_spec) VALUES ($1, $2, $3)`
+	for i, offset := range offsets {
+		if _, err := tx.Exec(query, taskID, i+1, offset.Spec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TaskChangedChannel 任务被修改、暂停、恢复或删除时发送通知的 Postgres 频道（payload 为任务ID）
+// 各副本监听该频道，领导者据此立即重新注册任务，不必等待定期重新加载
+const TaskChangedChannel = "dingteam_task_changed"
+
+// notifyTaskChanged 通知所有副本任务已变更（通知失败时由定期重新加载兜底）
+func (s *TaskService) notifyTaskChanged(taskID int) {
+	if _, err := s.db.Exec(`SELECT pg_notify($1, $2)`, TaskChangedChannel, strconv.Itoa(taskID)); err != nil {
+		log.Printf("发送任务变更通知失败 (task %d): %v", taskID, err)
+	}
+}
+
+// UpdateTask 修改任务的名称、描述、类型、执行时间等字段，返回修改后的任务
+// 设置 cron_expr 会将单次任务改为周期任务，设置 due_at 会将周期任务改为单次任务
+func (s *TaskService) UpdateTask(taskID int, update models.TaskUpdate) (*models.Task, error) {
+	if update.IsEmpty() {
+		return nil, fmt.Errorf("没有要修改的字段")
+	}
+
+	task, err := s.GetTaskByID(taskID)
+	if err != nil {
+		return nil, err
+	}
+	if task.Status == models.TaskStatusDeleted {
+		return nil, ErrTaskNotFound
+	}
+
+	if update.Name != nil {
+		name := strings.TrimSpace(*update.Name)
+		if name == "" {
+			return nil, fmt.Errorf("任务名称不能为空")
+		}
+		task.Name = name
+	}
+	if update.Description != nil {
+		task.Description = sql.NullString{String: *update.Description, Valid: *update.Description != ""}
+	}
+	if update.Type != nil {
+		switch taskType := models.TaskType(strings.ToUpper(*update.Type)); taskType {
+		case models.TaskTypeTask, models.TaskTypeNotification:
+			task.Type = taskType
+		default:
+			return nil, fmt.Errorf("未知任务类型: %s，应为 TASK 或 NOTIFICATION", *update.Type)
+		}
+	}
+	if update.DeadlineTime != nil {
+		task.DeadlineTime = sql.NullTime{}
+		if *update.DeadlineTime != "" {
+			deadline, err := ParseDeadlineTime(*update.DeadlineTime)
+			if err != nil {
+				return nil, err
+			}
+			task.DeadlineTime = sql.NullTime{Time: deadline, Valid: true}
+		}
+	}
+	if update.AdvanceMinutes != nil {
+		if *update.AdvanceMinutes < 0 {
+			return nil, fmt.Errorf("提前提醒分钟数不能为负数")
+		}
+		task.AdvanceMinutes = *update.AdvanceMinutes
+	}
+
+	// 修改执行时间时切换调度方式
+	if update.CronExpr != nil && update.DueAt != nil {
+		return nil, fmt.Errorf("cron_expr 和 due_at 只能设置一个")
+	}
+	if update.CronExpr != nil {
+		task.ScheduleKind = models.ScheduleKindCron
+		task.CronExpr = strings.TrimSpace(*update.CronExpr)
+		task.DueAt = sql.NullTime{}
+	}
+	if update.DueAt != nil {
+		dueAt, err := s.ParseDueAt(*task, *update.DueAt)
+		if err != nil {
+			return nil, err
+		}
+		task.ScheduleKind = models.ScheduleKindOnce
+		task.DueAt = sql.NullTime{Time: dueAt, Valid: true}
+		task.StartDate, task.EndDate, task.MaxOccurrences = sql.NullTime{}, sql.NullTime{}, 0
+	}
+	// 只在修改执行时间时校验调度（已过截止时间的单次任务仍可修改名称、说明等）
+	if update.CronExpr != nil || update.DueAt != nil {
+		if err := s.validateSchedule(task); err != nil {
+			return nil, err
+		}
+	}
+
+	query := `
+		UPDATE tasks
+		SET name = $1, description = $2, type = $3, cron_expr = $4, deadline_time = $5,
+			advance_minutes = $6, schedule_kind = $7, due_at = $8, start_date = $9,
+			end_date = $10, max_occurrences = $11, updated_at = CURRENT_TIMESTAMP
+		WHERE id = $12
+		RETURNING updated_at
+	`
+	err = s.db.QueryRow(
+		query,
+		task.Name,
+		task.Description,
+		task.Type,
+		task.CronExpr,
+		task.DeadlineTime,
+		task.AdvanceMinutes,
+		task.ScheduleKind,
+		task.DueAt,
+		nullDate(task.StartDate),
+		nullDate(task.EndDate),
+		task.MaxOccurrences,
+		task.ID,
+	).Scan(&task.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	s.notifyTaskChanged(task.ID)
+	return task, nil
+}
+
+// PauseTask 暂停任务（暂停期间不再提醒）
+func (s *TaskService) PauseTask(taskID int) error {
+	return s.changeTaskStatus(taskID, models.TaskStatusActive, models.TaskStatusPaused, "任务不存在或不在运行中")
+}
+
+// ResumeTask 恢复已暂停的任务
+func (s *TaskService) ResumeTask(taskID int) error {
+	return s.changeTaskStatus(taskID, models.TaskStatusPaused, models.TaskStatusActive, "任务不存在或未暂停")
+}
+
+// changeTaskStatus 将任务从 from 状态改为 to 状态，任务不处于 from 状态时返回 notFound 错误
+func (s *TaskService) changeTaskStatus(taskID int, from, to models.TaskStatus, notFound string) error {
+	query := `UPDATE tasks SET status = $1, updated_at = CURRENT_TIMESTAMP WHERE id = $2 AND status = $3`
+	result, err := s.db.Exec(query, to, taskID, from)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("%s", notFound)
+	}
+
+	s.notifyTaskChanged(taskID)
+	return nil
+}
+
 // 更新任务状态
 func (s *TaskService) UpdateTaskStatus(taskID int, status models.TaskStatus) error {
 	query := `UPDATE tasks SET status = $1 WHERE id = $2`
@@ -134,18 +647,66 @@ func (s *TaskService) UpdateTaskStatus(taskID int, status models.TaskStatus) err
 	return err
 }
 
+// 设置任务的时区，传空字符串使用群默认时区
+func (s *TaskService) SetTaskTimezone(taskID int, timezone string) error {
+	if timezone != "" {
+		if _, err := LoadLocation(timezone); err != nil {
+			return err
+		}
+	}
+	query := `UPDATE tasks SET timezone = $1 WHERE id = $2 AND status != 'DELETED'`
+	result, err := s.db.Exec(query, timezone, taskID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrTaskNotFound
+	}
+	s.notifyTaskChanged(taskID)
+	return nil
+}
+
+// 获取群设置（群默认时区等）
+func (s *TaskService) GetGroupSettings(groupChatID string) (*models.GroupSettings, error) {
+	return s.groups.Get(groupChatID)
+}
+
+// 设置群的默认时区，传空字符串使用全局默认时区
+func (s *TaskService) SetGroupTimezone(groupChatID, timezone string) error {
+	return s.groups.SetTimezone(groupChatID, timezone)
+}
+
+// 设置任务的节假日策略
+func (s *TaskService) SetCalendarPolicy(taskID int, policy models.CalendarPolicy) error {
+	query := `UPDATE tasks SET calendar_policy = $1 WHERE id = $2 AND status != 'DELETED'`
+	result, err := s.db.Exec(query, policy, taskID)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrTaskNotFound
+	}
+	s.notifyTaskChanged(taskID)
+	return nil
+}
+
 // 更新任务运行时间
 func (s *TaskService) UpdateTaskRunTime(taskID int, lastRun, nextRun time.Time) error {
+	// 列为不带时区的 TIMESTAMP，统一按 UTC 存储
+	next := sql.NullTime{Time: nextRun.UTC(), Valid: !nextRun.IsZero()}
 	query := `UPDATE tasks SET last_run_at = $1, next_run_at = $2 WHERE id = $3`
-	_, err := s.db.Exec(query, lastRun, nextRun, taskID)
+	_, err := s.db.Exec(query, lastRun.UTC(), next, taskID)
 	return err
 }
 
 // 删除任务
 func (s *TaskService) DeleteTask(taskID int) error {
 	query := `UPDATE tasks SET status = 'DELETED' WHERE id = $1`
-	_, err := s.db.Exec(query, taskID)
-	return err
+	if _, err := s.db.Exec(query, taskID); err != nil {
+		return err
+	}
+	s.notifyTaskChanged(taskID)
+	return nil
 }
 
 // 记录完成
@@ -164,20 +725,19 @@ func (s *TaskService) RecordCompletion(record *models.CompletionRecord) error {
 		record.UserID,
 		record.UserName,
 		record.GroupChatID,
-		record.TaskDate,
+		record.TaskDate.Format("2006-01-02"),
 		record.IsOnTime,
 	).Scan(&record.ID, &record.CompletedAt)
 
 	if err == sql.ErrNoRows {
-		return fmt.Errorf("今天已经打卡过了")
+		return ErrAlreadyCompleted
 	}
 
 	return err
 }
 
-// 检查今天是否已完成
-func (s *TaskService) HasCompletedToday(taskID int, userID string) (bool, error) {
-	today := time.Now().Format("2006-01-02")
+// 检查指定任务日期是否已完成
+func (s *TaskService) HasCompleted(taskID int, userID string, taskDate time.Time) (bool, error) {
 	query := `
 		SELECT EXISTS(
 			SELECT 1 FROM completion_records
@@ -186,112 +746,213 @@ func (s *TaskService) HasCompletedToday(taskID int, userID string) (bool, error)
 	`
 
 	var exists bool
-	err := s.db.QueryRow(query, taskID, userID, today).Scan(&exists)
+	err := s.db.QueryRow(query, taskID, userID, taskDate.Format("2006-01-02")).Scan(&exists)
 	return exists, err
 }
 
-// 记录提醒日志
-func (s *TaskService) LogReminder(log *models.ReminderLog) error {
+// ReminderSendingLease 提醒登记后处于发送中的最长时间，超过后视为发送方已崩溃，可被重新登记
+const ReminderSendingLease = 5 * time.Minute
+
+// ClaimReminder 登记即将发送的提醒（幂等键：任务、提醒类型、偏移、截止时间）
+// 发送失败或发送中超过 ReminderSendingLease 的记录可被重新登记，以便重试、切换领导者和补发
+// 返回 false 表示该提醒已被登记过（其他副本已发送或正在发送），调用方应跳过
+func (s *TaskService) ClaimReminder(log *models.ReminderLog) (bool, error) {
 	query := `
 		INSERT INTO reminder_logs (
-			task_id, group_chat_id, reminder_type, message_text, member_count, completed_count
+			task_id, group_chat_id, reminder_type, reminder_offset, occurrence_at, status
 		) VALUES ($1, $2, $3, $4, $5, $6)
+		ON CONFLICT (task_id, reminder_type, reminder_offset, occurrence_at) DO UPDATE
+		SET status = EXCLUDED.status, group_chat_id = EXCLUDED.group_chat_id,
+		    message_text = NULL, member_count = 0, completed_count = 0, sent_at = CURRENT_TIMESTAMP
+		WHERE reminder_logs.status = 'FAILED'
+		   OR (reminder_logs.status = 'SENDING' AND reminder_logs.sent_at < CURRENT_TIMESTAMP - make_interval(secs => $7))
 		RETURNING id, sent_at
 	`
 
-	return s.db.QueryRow(
+	log.Status = models.ReminderStatusSending
+	err := s.db.QueryRow(
 		query,
 		log.TaskID,
 		log.GroupChatID,
 		log.ReminderType,
+		log.ReminderOffset,
+		log.OccurrenceAt,
+		log.Status,
+		ReminderSendingLease.Seconds(),
+	).Scan(&log.ID, &log.SentAt)
+
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// FinishReminder 更新已登记提醒的发送结果
+func (s *TaskService) FinishReminder(log *models.ReminderLog) error {
+	query := `
+		UPDATE reminder_logs
+		SET status = $1, message_text = $2, member_count = $3, completed_count = $4, sent_at = CURRENT_TIMESTAMP
+		WHERE id = $5
+		RETURNING sent_at
+	`
+
+	return s.db.QueryRow(
+		query,
+		log.Status,
 		log.MessageText,
 		log.MemberCount,
 		log.CompletedCount,
-	).Scan(&log.ID, &log.SentAt)
+		log.ID,
+	).Scan(&log.SentAt)
 }
 
-// 获取今日未完成任务的用户列表
-func (s *TaskService) GetIncompleteUsersToday(taskID int, groupChatID string) ([]string, error) {
-	today := time.Now().Format("2006-01-02")
-
-	// 获取群组中所有非领导用户（排除 super_admin）
-	allUsersQuery := `
-		SELECT DISTINCT u.dingtalk_user_id
-		FROM users u
-		WHERE u.role != 'super_admin'
-	`
+// 获取指定任务日期未完成任务的成员（负责人减去豁免、请假和免提醒的成员）
+func (s *TaskService) GetIncompleteUsers(task models.Task, taskDate time.Time) ([]string, error) {
+	members, err := s.GetResponsibleUserIDs(task, taskDate)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := s.db.Query(allUsersQuery)
+	completed, err := s.GetCompletedUserIDs(task.ID, taskDate)
 	if err != nil {
-		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+		return nil, err
 	}
-	defer rows.Close()
 
-	var allUsers []string
-	for rows.Next() {
-		var userID string
-		if err := rows.Scan(&userID); err != nil {
-			continue
+	var incompleteUsers []string
+	for _, userID := range members {
+		if !completed[userID] {
+			incompleteUsers = append(incompleteUsers, userID)
 		}
-		allUsers = append(allUsers, userID)
 	}
 
-	// 获取今日已完成的用户
-	completedQuery := `
+	return incompleteUsers, nil
+}
+
+// 获取指定任务日期已完成的用户
+func (s *TaskService) GetCompletedUserIDs(taskID int, taskDate time.Time) (map[string]bool, error) {
+	query := `
 		SELECT user_id
 		FROM completion_records
 		WHERE task_id = $1 AND task_date = $2
 	`
 
-	completedRows, err := s.db.Query(completedQuery, taskID, today)
+	rows, err := s.db.Query(query, taskID, taskDate.Format("2006-01-02"))
 	if err != nil {
 		return nil, fmt.Errorf("获取完成记录失败: %w", err)
 	}
-	defer completedRows.Close()
+	defer rows.Close()
 
-	completedMap := make(map[string]bool)
-	for completedRows.Next() {
+	completed := make(map[string]bool)
+	for rows.Next() {
 		var userID string
-		if err := completedRows.Scan(&userID); err != nil {
+		if err := rows.Scan(&userID); err != nil {
 			continue
 		}
-		completedMap[userID] = true
+		completed[userID] = true
 	}
 
-	// 计算未完成的用户
-	var incompleteUsers []string
-	for _, userID := range allUsers {
-		if !completedMap[userID] {
-			incompleteUsers = append(incompleteUsers, userID)
+	return completed, rows.Err()
+}
+
+// 获取群成员（排除免提醒的成员），用于提醒、未完成名单和完成率
+func (s *TaskService) GetGroupMembers(groupChatID string) ([]models.GroupMember, error) {
+	members, err := s.members.Members(groupChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	userIDs := make([]string, len(members))
+	for i, member := range members {
+		userIDs[i] = member.UserID
+	}
+	excluded, err := s.exclusions.ExcludedUserIDs(groupChatID, userIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	result := members[:0]
+	for _, member := range members {
+		if !excluded[member.UserID] {
+			result = append(result, member)
 		}
 	}
+	return result, nil
+}
 
-	return incompleteUsers, nil
+// GetAllGroupMembers 获取群内所有成员（包括免提醒的成员），用于请假名单等与提醒无关的查询
+func (s *TaskService) GetAllGroupMembers(groupChatID string) ([]models.GroupMember, error) {
+	return s.members.Members(groupChatID)
+}
+
+// SyncGroupMembers 立即从钉钉同步群成员，返回当前成员数
+func (s *TaskService) SyncGroupMembers(groupChatID string) (int, error) {
+	return s.members.SyncGroup(groupChatID)
+}
+
+// SetReminderExclusion 设置成员在某个群（groupChatID 为空时为所有群）是否免提醒
+func (s *TaskService) SetReminderExclusion(userID, groupChatID string, excluded bool, reason, createdBy string) error {
+	return s.exclusions.Set(userID, groupChatID, excluded, reason, createdBy)
+}
+
+// RemoveReminderExclusion 删除成员的免提醒设置，恢复为默认规则
+func (s *TaskService) RemoveReminderExclusion(userID, groupChatID string) error {
+	return s.exclusions.Remove(userID, groupChatID)
+}
+
+// GetReminderExclusions 获取对某个群生效的免提醒设置（groupChatID 为空时为所有设置）
+func (s *TaskService) GetReminderExclusions(groupChatID string) ([]models.ReminderExclusion, error) {
+	return s.exclusions.List(groupChatID)
+}
+
+// GetDeptLeaders 群内按钉钉部门主管标记免提醒的成员（未开启该规则时为空，单独设置过的成员以设置为准）
+func (s *TaskService) GetDeptLeaders(groupChatID string) ([]models.GroupMember, error) {
+	if !s.exclusions.ExcludeDeptLeaders() {
+		return nil, nil
+	}
+
+	members, err := s.members.Members(groupChatID)
+	if err != nil {
+		return nil, err
+	}
+
+	var leaders []models.GroupMember
+	for _, member := range members {
+		if member.DeptLeader {
+			leaders = append(leaders, member)
+		}
+	}
+	return leaders, nil
 }
 
-// 获取群组中所有非领导用户（用于@all，但排除领导）
-func (s *TaskService) GetAllNonLeaderUsers() ([]string, error) {
+// GetUserNames 查询用户显示名称（没有名称的用户不在结果中）
+func (s *TaskService) GetUserNames(userIDs []string) (map[string]string, error) {
+	names := make(map[string]string, len(userIDs))
+	if len(userIDs) == 0 {
+		return names, nil
+	}
+
 	query := `
-		SELECT dingtalk_user_id
+		SELECT dingtalk_user_id, username
 		FROM users
-		WHERE role != 'super_admin'
-		ORDER BY created_at
+		WHERE dingtalk_user_id = ANY($1) AND username IS NOT NULL AND username != ''
 	`
 
-	rows, err := s.db.Query(query)
+	rows, err := s.db.Query(query, pq.Array(userIDs))
 	if err != nil {
-		return nil, fmt.Errorf("获取用户列表失败: %w", err)
+		return nil, fmt.Errorf("获取用户名称失败: %w", err)
 	}
 	defer rows.Close()
 
-	var users []string
 	for rows.Next() {
-		var userID string
-		if err := rows.Scan(&userID); err != nil {
+		var userID, name string
+		if err := rows.Scan(&userID, &name); err != nil {
 			continue
 		}
-		users = append(users, userID)
+		names[userID] = name
 	}
 
-	return users, nil
+	return names, rows.Err()
 }
@@ -0,0 +1,24 @@
+-- ================================================
+-- 任务提醒计划迁移脚本
+-- 版本: 002
+-- 描述: 每个任务可配置有序的提醒偏移列表
+-- ================================================
+
+-- 任务提醒计划表
+-- offset_spec 写法（相对截止时间，通知型为触发时间）：
+--   'deadline'   截止时间本身
+--   '-2h'        截止前 2 小时
+--   '+30m'       截止后 30 分钟（超时通报）
+--   '09:00'      截止当天 09:00
+--   '-1d 18:00'  截止前一天 18:00
+-- 任务没有配置提醒计划时，使用默认计划：'10:00'、'-{advance_minutes}m'、'deadline'
+CREATE TABLE IF NOT EXISTS task_reminder_plans (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    position INT NOT NULL,
+    offset_spec VARCHAR(50) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE(task_id, position)
+);
+
+COMMENT ON TABLE task_reminder_plans IS '任务提醒计划，按 position 顺序执行';
@@ -0,0 +1,35 @@
+-- ================================================
+-- 节假日日历迁移脚本
+-- 版本: 003
+-- 描述: 法定节假日与调休上班日，任务可按节假日策略跳过提醒
+-- ================================================
+
+-- 节假日日历表
+-- kind: 'HOLIDAY' 放假，'WORKDAY' 调休上班
+CREATE TABLE IF NOT EXISTS holidays (
+    id SERIAL PRIMARY KEY,
+    date DATE UNIQUE NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    name VARCHAR(100),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_holiday_kind CHECK (kind IN ('HOLIDAY', 'WORKDAY'))
+);
+
+COMMENT ON TABLE holidays IS '节假日日历，可通过 POST /api/v1/calendar/import 导入 JSON 或 ICS';
+
+-- 任务的节假日策略
+-- 'NONE'           不考虑节假日
+-- 'SKIP_HOLIDAYS'  跳过放假日
+-- 'WORKDAYS_ONLY'  只在工作日（含调休上班日）
+-- 'INCLUDE_MAKEUP' 跳过放假日，调休上班日照常执行
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS calendar_policy VARCHAR(20) NOT NULL DEFAULT 'NONE';
+
+-- 管理日历的权限
+INSERT INTO permissions (name, description, command_pattern) VALUES
+    ('manage_calendar', '管理节假日日历', '导入日历')
+ON CONFLICT (name) DO NOTHING;
+
+INSERT INTO role_permissions (role, permission_name) VALUES
+    ('super_admin', 'manage_calendar'),
+    ('admin', 'manage_calendar')
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,18 @@
+-- ================================================
+-- 提醒幂等迁移脚本
+-- 版本: 004
+-- 描述: 多副本部署时每次提醒只发送一次
+-- ================================================
+
+-- 提醒计划中的偏移（同一任务可能有多个同类型提醒，如 '-2h' 和 '-30m'）
+ALTER TABLE reminder_logs ADD COLUMN IF NOT EXISTS reminder_offset VARCHAR(50);
+
+-- 本次提醒对应的截止时间（通知型为触发时间）
+ALTER TABLE reminder_logs ADD COLUMN IF NOT EXISTS occurrence_at TIMESTAMPTZ;
+
+-- 'SENDING' 已登记正在发送，'SENT' 已发送，'FAILED' 发送失败
+ALTER TABLE reminder_logs ADD COLUMN IF NOT EXISTS status VARCHAR(20) NOT NULL DEFAULT 'SENT';
+
+-- 幂等键：发送前先插入登记行，冲突说明其他副本已发送
+CREATE UNIQUE INDEX IF NOT EXISTS idx_reminder_logs_occurrence
+    ON reminder_logs(task_id, reminder_type, reminder_offset, occurrence_at);
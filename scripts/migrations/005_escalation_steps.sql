@@ -0,0 +1,11 @@
+-- ================================================
+-- 超时升级步骤迁移脚本
+-- 版本: 005
+-- 描述: 提醒计划支持截止后的升级步骤（重复催办、私聊、汇总）
+-- ================================================
+
+-- 升级步骤写在偏移之后，如 '+30m every 15m x4'、'+1h dm'、'+2h summary to=cidXXX'
+ALTER TABLE task_reminder_plans ALTER COLUMN offset_spec TYPE VARCHAR(200);
+
+-- 重复催办的每一次单独登记，幂等键为 '<偏移>#<次数>'
+ALTER TABLE reminder_logs ALTER COLUMN reminder_offset TYPE VARCHAR(210);
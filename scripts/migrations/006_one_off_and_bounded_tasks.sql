@@ -0,0 +1,22 @@
+-- ================================================
+-- 单次任务与有期限的周期任务迁移脚本
+-- 版本: 006
+-- 描述: 支持只截止一次的任务，以及限定开始日期、结束日期或执行次数的周期任务
+-- ================================================
+
+-- 已结束的任务（单次任务已截止，或周期任务超出结束日期/执行次数），调度器自动设置
+-- 注意: PostgreSQL 12 以下版本不能在事务中执行该语句
+ALTER TYPE task_status ADD VALUE IF NOT EXISTS 'FINISHED';
+
+-- 'CRON' 按 cron 周期执行，'ONCE' 单次任务（cron_expr 为空）
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS schedule_kind VARCHAR(20) NOT NULL DEFAULT 'CRON';
+
+-- 单次任务的截止时间
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_at TIMESTAMPTZ;
+
+-- 周期任务的开始日期和结束日期（含当天）
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS start_date DATE;
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS end_date DATE;
+
+-- 周期任务最多执行次数，从开始日期起计算（0 为不限）
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS max_occurrences INT NOT NULL DEFAULT 0;
@@ -0,0 +1,18 @@
+-- ================================================
+-- 任务与群时区迁移脚本
+-- 版本: 007
+-- 描述: 每个任务可以设置时区，群可以设置默认时区
+-- ================================================
+
+-- 任务时区（IANA 名称，如 'Asia/Singapore'），为空时使用群默认时区
+-- deadline_time、start_date、end_date 均按该时区理解
+ALTER TABLE tasks ADD COLUMN IF NOT EXISTS timezone VARCHAR(64) NOT NULL DEFAULT '';
+
+-- 群设置：timezone 为群内任务的默认时区，为空时使用服务的 TIMEZONE 配置
+CREATE TABLE IF NOT EXISTS group_settings (
+    group_chat_id VARCHAR(100) PRIMARY KEY,
+    timezone VARCHAR(64) NOT NULL DEFAULT '',
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+COMMENT ON TABLE group_settings IS '群级别设置';
@@ -0,0 +1,25 @@
+-- ================================================
+-- 单次执行调整迁移脚本
+-- 版本: 008
+-- 描述: 管理员可以跳过任务的某一期，或将下一次提醒延后若干分钟
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS task_occurrence_overrides (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    kind VARCHAR(20) NOT NULL,                 -- SKIP / SNOOZE
+    task_date DATE,                            -- SKIP: 跳过的任务日期
+    snooze_minutes INT NOT NULL DEFAULT 0,     -- SNOOZE: 延后的分钟数
+    reminder_at TIMESTAMPTZ,                   -- SNOOZE: 被延后的提醒的原定时间，提醒触发时填入
+    reason TEXT,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_override_kind CHECK (kind IN ('SKIP', 'SNOOZE'))
+);
+
+-- 每个任务日期只能跳过一次
+CREATE UNIQUE INDEX IF NOT EXISTS idx_overrides_skip_date
+    ON task_occurrence_overrides(task_id, task_date) WHERE kind = 'SKIP';
+CREATE INDEX IF NOT EXISTS idx_overrides_task ON task_occurrence_overrides(task_id, kind);
+
+COMMENT ON TABLE task_occurrence_overrides IS '任务单次执行的调整（跳过 / 延后提醒）';
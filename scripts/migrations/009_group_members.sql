@@ -0,0 +1,17 @@
+-- ================================================
+-- 群成员同步迁移脚本
+-- 版本: 009
+-- 描述: 从钉钉同步各群成员，提醒、未完成名单和完成率按群成员计算
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS group_members (
+    group_chat_id VARCHAR(100) NOT NULL,
+    user_id VARCHAR(100) NOT NULL,
+    user_name VARCHAR(100),                          -- 钉钉姓名，同步时补齐
+    synced_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (group_chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_group_members_user ON group_members(user_id);
+
+COMMENT ON TABLE group_members IS '群成员（定期从钉钉同步）';
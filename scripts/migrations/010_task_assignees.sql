@@ -0,0 +1,32 @@
+-- ================================================
+-- 任务负责人与豁免迁移脚本
+-- 版本: 010
+-- 描述: 任务可以指定负责人（用户或钉钉部门），成员可以在一段日期内被豁免
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS task_assignees (
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    assignee_type VARCHAR(20) NOT NULL,              -- USER / DEPARTMENT
+    assignee_id VARCHAR(100) NOT NULL,               -- 钉钉用户ID或部门ID
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (task_id, assignee_type, assignee_id),
+    CONSTRAINT check_assignee_type CHECK (assignee_type IN ('USER', 'DEPARTMENT'))
+);
+
+CREATE TABLE IF NOT EXISTS task_exemptions (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) NOT NULL,
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,                          -- 含当天
+    reason TEXT,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_exemption_range CHECK (end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_task_exemptions_task ON task_exemptions(task_id, end_date);
+
+COMMENT ON TABLE task_assignees IS '任务负责人（未指定时为群内所有成员）';
+COMMENT ON TABLE task_exemptions IS '成员在日期范围内免于执行任务，不提醒也不计入完成率';
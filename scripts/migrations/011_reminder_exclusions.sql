@@ -0,0 +1,27 @@
+-- ================================================
+-- 免提醒设置迁移脚本
+-- 版本: 011
+-- 描述: 是否被提醒不再由超级管理员角色决定，改为按人 / 按群单独设置，可选按钉钉部门主管标记
+-- ================================================
+
+-- 钉钉部门主管标记（开启 DINGTALK_EXCLUDE_DEPT_LEADERS 时定期刷新）
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS dept_leader BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE group_members ADD COLUMN IF NOT EXISTS profile_synced_at TIMESTAMP;
+
+CREATE TABLE IF NOT EXISTS reminder_exclusions (
+    user_id VARCHAR(100) NOT NULL,
+    group_chat_id VARCHAR(100) NOT NULL DEFAULT '',  -- 空字符串表示对所有群生效
+    excluded BOOLEAN NOT NULL DEFAULT TRUE,          -- FALSE 表示强制提醒（覆盖全局设置或部门主管标记）
+    reason TEXT,
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (user_id, group_chat_id)
+);
+
+-- 保留升级前的行为：超级管理员默认免提醒（需要被提醒的超级管理员可发送「恢复提醒 @用户 全局」）
+INSERT INTO reminder_exclusions (user_id, excluded, reason, created_by)
+SELECT dingtalk_user_id, TRUE, '升级前的超级管理员', 'system' FROM users WHERE role = 'super_admin'
+ON CONFLICT DO NOTHING;
+
+COMMENT ON TABLE reminder_exclusions IS '免提醒设置（群内设置 > 全局设置 > 钉钉部门主管标记）';
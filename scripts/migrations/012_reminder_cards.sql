@@ -0,0 +1,33 @@
+-- ================================================
+-- 互动提醒卡片迁移脚本
+-- 版本: 012
+-- 描述: 任务提醒以带按钮的互动卡片发送，记录卡片以便回调和刷新进度；成员可点击"稍后提醒"
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS reminder_cards (
+    out_track_id VARCHAR(100) PRIMARY KEY,           -- 卡片唯一标识（回调和更新卡片时使用）
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    group_chat_id VARCHAR(100) NOT NULL,
+    task_date DATE NOT NULL,                         -- 卡片对应的任务日期
+    title VARCHAR(255) NOT NULL,
+    body TEXT NOT NULL,                              -- 卡片正文（不含实时的完成进度）
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reminder_cards_task ON reminder_cards(task_id, task_date);
+
+CREATE TABLE IF NOT EXISTS member_snoozes (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) NOT NULL,
+    task_date DATE NOT NULL,
+    remind_at TIMESTAMPTZ NOT NULL,                  -- 私聊提醒的时间
+    sent_at TIMESTAMPTZ,                             -- 已提醒（或已取出）的时间
+    created_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (task_id, user_id, task_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_member_snoozes_due ON member_snoozes(remind_at) WHERE sent_at IS NULL;
+
+COMMENT ON TABLE reminder_cards IS '以互动卡片发送的任务提醒';
+COMMENT ON TABLE member_snoozes IS '成员点击"稍后提醒"后待发送的私聊提醒';
@@ -0,0 +1,23 @@
+-- ================================================
+-- 打卡提交内容迁移脚本
+-- 版本: 013
+-- 描述: 打卡时可以附带文字、链接或钉钉文件/图片作为完成凭证
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS completion_submissions (
+    id SERIAL PRIMARY KEY,
+    completion_id INT NOT NULL REFERENCES completion_records(id) ON DELETE CASCADE,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) NOT NULL,
+    task_date DATE NOT NULL,
+    kind VARCHAR(20) NOT NULL,                       -- TEXT / LINK / PICTURE / FILE / VIDEO / AUDIO
+    content TEXT NOT NULL DEFAULT '',                -- 文字或链接
+    file_name VARCHAR(255),                          -- 文件名（文件消息）
+    download_code TEXT,                              -- 钉钉机器人消息文件的下载码
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_submission_kind CHECK (kind IN ('TEXT', 'LINK', 'PICTURE', 'FILE', 'VIDEO', 'AUDIO'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_submissions_task_date ON completion_submissions(task_id, task_date);
+
+COMMENT ON TABLE completion_submissions IS '打卡时附带的完成凭证（文字、链接、文件、图片）';
@@ -0,0 +1,24 @@
+-- ================================================
+-- 打卡更正审计迁移脚本
+-- 版本: 014
+-- 描述: 成员可在宽限时间内撤销打卡，管理员可为过去的日期补卡或删除打卡，所有变更留痕
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS completion_audit_logs (
+    id SERIAL PRIMARY KEY,
+    task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+    user_id VARCHAR(100) NOT NULL,                   -- 被变更打卡记录的成员
+    task_date DATE NOT NULL,
+    action VARCHAR(20) NOT NULL,                     -- UNDO / MARK_DONE / MARK_UNDONE
+    operator_id VARCHAR(100) NOT NULL,               -- 执行变更的人（撤销时为成员本人）
+    reason TEXT,
+    previous_completed_at TIMESTAMP,                 -- 变更前的打卡时间，之前未打卡时为空
+    previous_on_time BOOLEAN,
+    is_on_time BOOLEAN,                              -- 补卡后的按时状态
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_completion_audit_action CHECK (action IN ('UNDO', 'MARK_DONE', 'MARK_UNDONE'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_completion_audit_task ON completion_audit_logs(task_id, created_at);
+
+COMMENT ON TABLE completion_audit_logs IS '打卡记录变更审计（撤销、补卡、删除打卡）';
@@ -0,0 +1,22 @@
+-- ================================================
+-- 成员请假迁移脚本
+-- 版本: 015
+-- 描述: 成员按日期范围请假（可只对某个任务），请假期间不提醒、不计入完成率
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS member_leaves (
+    id SERIAL PRIMARY KEY,
+    user_id VARCHAR(100) NOT NULL,
+    task_id INT REFERENCES tasks(id) ON DELETE CASCADE, -- 为空时对所有任务生效
+    start_date DATE NOT NULL,
+    end_date DATE NOT NULL,                          -- 含当天
+    reason TEXT,
+    created_by VARCHAR(100) NOT NULL,                -- 本人或代为登记的管理员
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    CONSTRAINT check_leave_range CHECK (end_date >= start_date)
+);
+
+CREATE INDEX IF NOT EXISTS idx_member_leaves_user ON member_leaves(user_id, end_date);
+CREATE INDEX IF NOT EXISTS idx_member_leaves_dates ON member_leaves(start_date, end_date);
+
+COMMENT ON TABLE member_leaves IS '成员请假，期间不提醒也不计入完成率';
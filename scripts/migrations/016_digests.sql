@@ -0,0 +1,24 @@
+-- ================================================
+-- 定时汇总迁移脚本
+-- 版本: 016
+-- 描述: 按 cron 定时把群内所有任务的完成情况（日报、周报）发到群里或私聊管理者
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS digests (
+    id SERIAL PRIMARY KEY,
+    group_chat_id VARCHAR(100) NOT NULL,             -- 汇总该群的所有任务
+    kind VARCHAR(20) NOT NULL,                       -- DAILY / WEEKLY
+    cron_expr VARCHAR(100) NOT NULL,                 -- 发送时间，按群时区计算
+    calendar_policy VARCHAR(20) NOT NULL DEFAULT 'NONE',
+    target VARCHAR(20) NOT NULL DEFAULT 'GROUP',     -- GROUP / PRIVATE
+    recipients TEXT[] NOT NULL DEFAULT '{}',         -- 私聊接收人，为空时为创建人
+    created_by VARCHAR(100) NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_sent_at TIMESTAMPTZ,                        -- 最近一次发送对应的计划时间（多副本时防止重复发送）
+    CONSTRAINT check_digest_kind CHECK (kind IN ('DAILY', 'WEEKLY')),
+    CONSTRAINT check_digest_target CHECK (target IN ('GROUP', 'PRIVATE'))
+);
+
+CREATE INDEX IF NOT EXISTS idx_digests_group ON digests(group_chat_id);
+
+COMMENT ON TABLE digests IS '定时发送的完成情况汇总（日报、周报）';
@@ -0,0 +1,9 @@
+-- ================================================
+-- 汇总附带排行榜迁移脚本
+-- 版本: 017
+-- 描述: 定时汇总可选附带本月排行榜（按时完成次数、连续按时期数、首位提交徽章）
+-- ================================================
+
+ALTER TABLE digests ADD COLUMN IF NOT EXISTS include_leaderboard BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMENT ON COLUMN digests.include_leaderboard IS '是否在汇总末尾附带本群本月排行榜前几名';
@@ -0,0 +1,28 @@
+-- ================================================
+-- 群成员在群期间迁移脚本
+-- 版本: 018
+-- 描述: 同步群成员时记录入群和退群时间，历史统计按当时在群内的成员计算应打卡人次
+-- ================================================
+
+CREATE TABLE IF NOT EXISTS group_member_periods (
+    id SERIAL PRIMARY KEY,
+    group_chat_id VARCHAR(100) NOT NULL,
+    user_id VARCHAR(100) NOT NULL,
+    user_name VARCHAR(100),
+    joined_at TIMESTAMP,                             -- 为空表示开始记录前已在群内
+    left_at TIMESTAMP                                -- 为空表示仍在群内
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_group_member_periods_open
+    ON group_member_periods(group_chat_id, user_id) WHERE left_at IS NULL;
+
+-- 已同步的成员视为一直在群内
+INSERT INTO group_member_periods (group_chat_id, user_id, user_name)
+SELECT m.group_chat_id, m.user_id, m.user_name
+FROM group_members m
+WHERE NOT EXISTS (
+    SELECT 1 FROM group_member_periods p
+    WHERE p.group_chat_id = m.group_chat_id AND p.user_id = m.user_id
+);
+
+COMMENT ON TABLE group_member_periods IS '群成员每次在群内的期间（同步时记录入群和退群）';